@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"real-time-forum/models"
+	"real-time-forum/respond"
+	"sort"
+	"strings"
+)
+
+// apiPrefix is where the versioned API lives. The unversioned routes in
+// Configure are aliases kept while the frontend moves over.
+const apiPrefix = "/api/v1"
+
+// apiRouter registers resource routes under apiPrefix using ServeMux method
+// and wildcard patterns. A known path requested with the wrong method gets
+// a JSON 405 rather than the mux's plain text one, and unknown paths a JSON
+// 404 rather than the SPA.
+type apiRouter struct {
+	mux     *http.ServeMux
+	log     func(http.Handler) http.Handler
+	allowed map[string][]string
+}
+
+func newAPIRouter(mux *http.ServeMux, log func(http.Handler) http.Handler) *apiRouter {
+	a := &apiRouter{mux: mux, log: log, allowed: make(map[string][]string)}
+	mux.Handle(apiPrefix+"/", log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond.NotFound(w, r, "Page not found")
+	})))
+	return a
+}
+
+// handle registers a "METHOD /path" pattern, the path relative to apiPrefix
+func (a *apiRouter) handle(pattern string, handler http.Handler) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		panic("api: pattern without a method: " + pattern)
+	}
+	a.mux.Handle(method+" "+apiPrefix+path, handler)
+
+	// The first method registered for a path also claims the path for
+	// every other method
+	if _, seen := a.allowed[path]; !seen {
+		a.mux.Handle(apiPrefix+path, a.log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Allow", strings.Join(a.allowed[path], ", "))
+			respond.MethodNotAllowed(w, r)
+		})))
+	}
+	a.allowed[path] = append(a.allowed[path], method)
+}
+
+// routes lists the registered patterns as "METHOD /path", sorted
+func (a *apiRouter) routes() []string {
+	var routes []string
+	for path, methods := range a.allowed {
+		for _, method := range methods {
+			routes = append(routes, method+" "+path)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// configureAPI registers the /api/v1 routes. Every route must be documented
+// in apispec/openapi.json.
+func configureAPI(mux *http.ServeMux, h *Handlers, m *Middlewares) *apiRouter {
+	log := m.LoggingMiddleware.Log
+	public := func(handler http.HandlerFunc) http.Handler {
+		return log(handler)
+	}
+	private := func(handler http.HandlerFunc) http.Handler {
+		return log(m.AuthMiddleware.Authorize(handler))
+	}
+	withRole := func(role string) func(http.HandlerFunc) http.Handler {
+		return func(handler http.HandlerFunc) http.Handler {
+			return log(m.AuthMiddleware.Authorize(m.AuthMiddleware.RequireRole(role)(handler)))
+		}
+	}
+	moderator := withRole(models.RoleModerator)
+	admin := withRole(models.RoleAdmin)
+
+	api := newAPIRouter(mux, log)
+
+	// The contract itself
+	api.handle("GET /openapi.json", public(h.DocsHandler.OpenAPI))
+	api.handle("GET /schemas/websocket.json", public(h.DocsHandler.WebSocketSchema))
+
+	// Accounts and sessions
+	api.handle("POST /users", public(h.AuthHandler.Register))
+	api.handle("GET /users", private(h.DashboardHandler.AllUsers))
+	api.handle("GET /users/me/posts", private(h.DashboardHandler.UserPosts))
+	api.handle("POST /sessions", public(h.AuthHandler.Login))
+	api.handle("GET /session", public(h.AuthHandler.CheckSession))
+	api.handle("DELETE /session", private(h.AuthHandler.DeleteSession))
+
+	// Posts, comments and categories
+	api.handle("GET /posts", private(h.DashboardHandler.ListPosts))
+	api.handle("POST /posts", private(h.PostHandler.AddPost))
+	api.handle("GET /posts/{id}", private(h.PostHandler.GetPost))
+	api.handle("POST /posts/{id}/comments", private(h.CommentsHandler.AddComment))
+	api.handle("POST /posts/{id}/reports", private(h.ModerationHandler.ReportPost))
+	api.handle("POST /comments/{id}/reports", private(h.ModerationHandler.ReportComment))
+	api.handle("POST /messages/{id}/reports", private(h.ModerationHandler.ReportMessage))
+	api.handle("GET /categories", private(h.PostHandler.ListCategories))
+	api.handle("GET /categories/{id}/posts", private(h.DashboardHandler.CategoryPosts))
+
+	// The current user's subscriptions, blocks, notifications and chats
+	api.handle("GET /subscriptions", private(h.CategoriesHandler.Subscriptions))
+	api.handle("POST /subscriptions", private(h.CategoriesHandler.Subscriptions))
+	api.handle("DELETE /subscriptions", private(h.CategoriesHandler.Subscriptions))
+	api.handle("GET /blocks", private(h.BlocksHandler.Blocks))
+	api.handle("POST /blocks", private(h.BlocksHandler.Blocks))
+	api.handle("DELETE /blocks", private(h.BlocksHandler.Blocks))
+	api.handle("GET /search", private(h.SearchHandler.Search))
+	api.handle("GET /notifications", private(h.NotificationsHandler.Notifications))
+	api.handle("POST /notifications/read", private(h.NotificationsHandler.MarkRead))
+	api.handle("GET /push/key", private(h.PushHandler.PublicKey))
+	api.handle("POST /push/subscriptions", private(h.PushHandler.Subscriptions))
+	api.handle("DELETE /push/subscriptions", private(h.PushHandler.Subscriptions))
+	api.handle("GET /conversations/{user}/messages", private(h.WebSocketHandler.ConversationMessages))
+	api.handle("GET /ws", private(h.WebSocketHandler.WebSocket))
+
+	// Moderation
+	api.handle("GET /moderation/reports", moderator(h.ModerationHandler.Reports))
+	api.handle("GET /moderation/reports/{id}", moderator(h.ModerationHandler.ViewReport))
+	api.handle("POST /moderation/reports/{id}/claim", moderator(h.ModerationHandler.ClaimReport))
+	api.handle("POST /moderation/reports/{id}/resolve", moderator(h.ModerationHandler.ResolveReport))
+	api.handle("GET /moderation/sanctions", moderator(h.SanctionsHandler.Sanctions))
+	api.handle("POST /moderation/sanctions", moderator(h.SanctionsHandler.Sanctions))
+	api.handle("POST /moderation/sanctions/{id}/revoke", moderator(h.SanctionsHandler.RevokeSanction))
+
+	// Administration
+	api.handle("GET /admin/categories", admin(h.CategoriesHandler.Categories))
+	api.handle("POST /admin/categories", admin(h.CategoriesHandler.Categories))
+	api.handle("PATCH /admin/categories", admin(h.CategoriesHandler.ReorderCategories))
+	api.handle("PATCH /admin/categories/{id}", admin(h.CategoriesHandler.UpdateCategory))
+	api.handle("POST /admin/categories/{id}/archive", admin(h.CategoriesHandler.ArchiveCategory))
+	api.handle("POST /admin/categories/{id}/restore", admin(h.CategoriesHandler.RestoreCategory))
+	api.handle("PUT /admin/users/{user}/role", admin(h.AdminHandler.UpdateUserRole))
+	api.handle("GET /admin/audit", admin(h.AdminHandler.AuditEvents))
+	api.handle("GET /admin/db/stats", admin(h.AdminHandler.DBStats))
+	api.handle("GET /admin/config", admin(h.AdminHandler.Config))
+	return api
+}
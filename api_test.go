@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"real-time-forum/apispec"
+	"real-time-forum/middleware"
+	"real-time-forum/respond"
+	"testing"
+)
+
+func TestAPIRouter(t *testing.T) {
+	mux := http.NewServeMux()
+	api := newAPIRouter(mux, func(next http.Handler) http.Handler { return next })
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.PathValue("id")))
+	}
+	api.handle("GET /posts/{id}", http.HandlerFunc(echo))
+	api.handle("DELETE /posts/{id}", http.HandlerFunc(echo))
+	api.handle("PATCH /posts", http.HandlerFunc(echo))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/posts/p1", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "DELETE p1" {
+		t.Errorf("DELETE: %d %q", w.Code, w.Body)
+	}
+
+	cases := []struct {
+		method, target string
+		want           int
+		wantCode       string
+	}{
+		{http.MethodPut, "/api/v1/posts/p1", http.StatusMethodNotAllowed, respond.CodeMethodNotAllowed},
+		{http.MethodGet, "/api/v1/posts/p1/likes", http.StatusNotFound, respond.CodeNotFound},
+		{http.MethodGet, "/api/v1/", http.StatusNotFound, respond.CodeNotFound},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(c.method, c.target, nil))
+
+		if w.Code != c.want {
+			t.Errorf("%s %s: status = %d, want %d", c.method, c.target, w.Code, c.want)
+		}
+		var body respond.Envelope
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Code != c.wantCode {
+			t.Errorf("%s %s: body = %+v, %v", c.method, c.target, body, err)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/posts/p1", nil))
+	if allow := w.Header().Get("Allow"); allow != "GET, DELETE" {
+		t.Errorf("Allow = %q, want GET, DELETE", allow)
+	}
+}
+
+// Registering the routes also catches overlapping patterns, as the mux
+// panics on them
+func TestAPIMatchesSpec(t *testing.T) {
+	api := configureAPI(http.NewServeMux(), &Handlers{}, &Middlewares{
+		LoggingMiddleware: middleware.NewLoggingMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil)), false),
+		AuthMiddleware:    middleware.NewAuthMiddleware(nil, nil),
+	})
+	spec, err := apispec.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := make(map[string]bool)
+	for _, op := range spec.Operations() {
+		documented[op] = true
+	}
+	for _, route := range api.routes() {
+		if !documented[route] {
+			t.Errorf("%s is not in the OpenAPI document", route)
+		}
+		delete(documented, route)
+	}
+	for op := range documented {
+		t.Errorf("%s is documented but not routed", op)
+	}
+}
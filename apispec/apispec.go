@@ -0,0 +1,192 @@
+// Package apispec holds the client contract: an OpenAPI 3.1 document for
+// the /api/v1 routes and a JSON Schema for the WebSocket frames. Both are
+// embedded in the binary and served by the API. Spec validates responses and
+// frames against them, so tests catch a handler that drifts from the
+// documents.
+package apispec
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+//go:embed websocket.schema.json
+var webSocketSchema []byte
+
+// OpenAPI returns the OpenAPI document
+func OpenAPI() []byte {
+	return openAPI
+}
+
+// WebSocketSchema returns the JSON Schema of the WebSocket frames
+func WebSocketSchema() []byte {
+	return webSocketSchema
+}
+
+// Documents are keyed by the file name references use to point at them,
+// e.g. "../openapi.json#/components/schemas/Post"
+const (
+	openAPIDoc   = "openapi.json"
+	webSocketDoc = "websocket.json"
+)
+
+// Spec is the parsed pair of documents
+type Spec struct {
+	docs map[string]map[string]interface{}
+}
+
+// Load parses the embedded documents
+func Load() (*Spec, error) {
+	s := &Spec{docs: make(map[string]map[string]interface{})}
+	for name, raw := range map[string][]byte{openAPIDoc: openAPI, webSocketDoc: webSocketSchema} {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", name, err)
+		}
+		s.docs[name] = doc
+	}
+	return s, nil
+}
+
+var methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// Operations lists the documented routes as "METHOD /path" patterns relative
+// to /api/v1, sorted
+func (s *Spec) Operations() []string {
+	paths, _ := s.docs[openAPIDoc]["paths"].(map[string]interface{})
+	var ops []string
+	for p, item := range paths {
+		item, _ := item.(map[string]interface{})
+		for _, method := range methods {
+			if _, ok := item[strings.ToLower(method)]; ok {
+				ops = append(ops, method+" "+p)
+			}
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// ValidateResponse checks a response of the operation at pattern, the route
+// as documented (e.g. /posts/{id}), against the spec. The status
+// must be documented and the body must match its schema; a response
+// documented without content must have an empty body.
+func (s *Spec) ValidateResponse(method, pattern string, status int, body []byte) error {
+	paths, _ := s.docs[openAPIDoc]["paths"].(map[string]interface{})
+	item, ok := paths[pattern].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s is not documented", pattern)
+	}
+	operation, ok := item[strings.ToLower(method)].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s %s is not documented", method, pattern)
+	}
+	responses, _ := operation["responses"].(map[string]interface{})
+	response, ok := responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("%s %s: status %d is not documented", method, pattern, status)
+	}
+	doc, resolved, err := s.resolve(openAPIDoc, response)
+	if err != nil {
+		return err
+	}
+
+	resolvedResponse, _ := resolved.(map[string]interface{})
+	content, _ := resolvedResponse["content"].(map[string]interface{})
+	media, ok := content["application/json"].(map[string]interface{})
+	if !ok {
+		if len(strings.TrimSpace(string(body))) != 0 {
+			return fmt.Errorf("%s %s: status %d is documented without a body, got %s", method, pattern, status, body)
+		}
+		return nil
+	}
+
+	value, err := decode(body)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, pattern, err)
+	}
+	if err := s.validate(doc, media["schema"], value, "body"); err != nil {
+		return fmt.Errorf("%s %s %d: %w", method, pattern, status, err)
+	}
+	return nil
+}
+
+// ValidateFrame checks a frame sent by the server against the WebSocket
+// schema
+func (s *Spec) ValidateFrame(frame []byte) error {
+	value, err := decode(frame)
+	if err != nil {
+		return err
+	}
+	return s.validate(webSocketDoc, map[string]interface{}{"$ref": "#/$defs/ServerFrame"}, value, "frame")
+}
+
+// ValidateClientFrame checks a frame sent by a client against the WebSocket
+// schema
+func (s *Spec) ValidateClientFrame(frame []byte) error {
+	value, err := decode(frame)
+	if err != nil {
+		return err
+	}
+	return s.validate(webSocketDoc, map[string]interface{}{"$ref": "#/$defs/ClientFrame"}, value, "frame")
+}
+
+func decode(raw []byte) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return value, nil
+}
+
+// resolve follows a $ref, local ("#/components/...") or into the other
+// document ("../openapi.json#/..."), and returns the document the target
+// lives in along with it. Anything that is not a reference is returned as is.
+func (s *Spec) resolve(doc string, node interface{}) (string, interface{}, error) {
+	for i := 0; ; i++ {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return doc, node, nil
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return doc, node, nil
+		}
+		if i == 16 {
+			return "", nil, fmt.Errorf("reference loop at %s", ref)
+		}
+
+		file, pointer, _ := strings.Cut(ref, "#")
+		if file != "" {
+			doc = path.Base(file)
+		}
+		target, ok := s.docs[doc]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown document in %s", ref)
+		}
+		node = interface{}(target)
+		for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+			if token == "" {
+				continue
+			}
+			token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+			parent, _ := node.(map[string]interface{})
+			next, ok := parent[token]
+			if !ok {
+				return "", nil, fmt.Errorf("unresolved reference %s", ref)
+			}
+			node = next
+		}
+	}
+}
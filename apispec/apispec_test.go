@@ -0,0 +1,133 @@
+package apispec
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func loadSpec(t *testing.T) *Spec {
+	t.Helper()
+	spec, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// walk calls fn for every node of a decoded document
+func walk(node interface{}, at string, fn func(node interface{}, at string)) {
+	fn(node, at)
+	switch node := node.(type) {
+	case map[string]interface{}:
+		for key, child := range node {
+			walk(child, at+"/"+key, fn)
+		}
+	case []interface{}:
+		for i, child := range node {
+			walk(child, fmt.Sprintf("%s/%d", at, i), fn)
+		}
+	}
+}
+
+func TestReferencesResolve(t *testing.T) {
+	spec := loadSpec(t)
+	for name, doc := range spec.docs {
+		walk(doc, name+"#", func(node interface{}, at string) {
+			m, ok := node.(map[string]interface{})
+			if !ok || m["$ref"] == nil {
+				return
+			}
+			if _, _, err := spec.resolve(name, m); err != nil {
+				t.Errorf("%s: %v", at, err)
+			}
+		})
+	}
+}
+
+func TestOperationIDsAreUnique(t *testing.T) {
+	spec := loadSpec(t)
+	seen := make(map[string]bool)
+	walk(spec.docs[openAPIDoc]["paths"], "paths", func(node interface{}, at string) {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return
+		}
+		if id, ok := m["operationId"].(string); ok {
+			if seen[id] {
+				t.Errorf("%s: duplicate operationId %s", at, id)
+			}
+			seen[id] = true
+		}
+	})
+	if len(seen) != len(spec.Operations()) {
+		t.Errorf("%d operation IDs for %d operations", len(seen), len(spec.Operations()))
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	spec := loadSpec(t)
+	post := `{"ID":"p1","AuthorID":"u1","AuthorName":"alice","Title":"Hi","Content":"Hello","ContentHTML":"<p>Hello</p>",` +
+		`"CreatedAt":"2024-05-01T10:00:00.123Z","UpdatedAt":"0001-01-01T00:00:00Z","Categories":null,"Image":"","Mentions":null}`
+
+	cases := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"valid", 200, `{"user":null,"post":` + post + `,"comments":[]}`, ""},
+		{"undocumented status", 418, `{}`, "status 418 is not documented"},
+		{"missing field", 200, `{"user":null,"comments":[]}`, "missing post"},
+		{"extra field", 200, `{"user":null,"post":` + post + `,"comments":[],"likes":3}`, "body.likes: not allowed"},
+		{"wrong type", 200, `{"user":null,"post":` + post + `,"comments":{}}`, "body.comments"},
+		{"bad date", 200, `{"user":null,"post":` + strings.Replace(post, "2024-05-01T10:00:00.123Z", "yesterday", 1) + `,"comments":null}`, "not a date-time"},
+		{"error body", 404, `{"error":"Post not found","code":"not_found","request_id":"r1"}`, ""},
+		{"unknown error code", 404, `{"error":"Post not found","code":"gone"}`, "body.code"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := spec.ValidateResponse("GET", "/posts/{id}", c.status, []byte(c.body))
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+				t.Errorf("error = %v, want one containing %q", err, c.wantErr)
+			}
+		})
+	}
+
+	if err := spec.ValidateResponse("GET", "/session", 200, nil); err != nil {
+		t.Errorf("empty body: %v", err)
+	}
+	if err := spec.ValidateResponse("GET", "/session", 200, []byte(`{}`)); err == nil {
+		t.Error("a body was accepted on a response documented without one")
+	}
+}
+
+func TestValidateFrame(t *testing.T) {
+	spec := loadSpec(t)
+	cases := []struct {
+		frame string
+		valid bool
+	}{
+		{`{"id":7,"type":"chat_message","from":"alice","to":"bobby","content":"hi","timestamp":"2024-05-01T10:00:00Z"}`, true},
+		{`{"type":"user_joined","from":"system","to":"all","content":"alice","timestamp":"2024-05-01T10:00:00Z","online_users":["bobby"]}`, true},
+		{`{"type":"error","from":"system","to":"alice","code":"muted","content":"You are muted","timestamp":"2024-05-01T10:00:00Z"}`, true},
+		{`{"type":"error","from":"system","to":"alice","code":"teapot","content":"?","timestamp":"2024-05-01T10:00:00Z"}`, false},
+		{`{"type":"subscribed","from":"system","to":"alice","timestamp":"2024-05-01T10:00:00Z"}`, false},
+		{`{"type":"mystery","from":"system","timestamp":"2024-05-01T10:00:00Z"}`, false},
+	}
+	for _, c := range cases {
+		if err := spec.ValidateFrame([]byte(c.frame)); (err == nil) != c.valid {
+			t.Errorf("%s: error = %v, want valid = %v", c.frame, err, c.valid)
+		}
+	}
+
+	if err := spec.ValidateClientFrame([]byte(`{"type":"subscribe","topic":"category:3"}`)); err != nil {
+		t.Errorf("subscribe frame: %v", err)
+	}
+	if err := spec.ValidateClientFrame([]byte(`{"type":"subscribe","topic":"everything"}`)); err == nil {
+		t.Error("an invalid topic was accepted")
+	}
+}
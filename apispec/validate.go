@@ -0,0 +1,208 @@
+package apispec
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// validate checks a decoded JSON value against a schema of doc. It covers
+// the JSON Schema keywords the two documents use: $ref, type, const, enum,
+// not, oneOf, properties, required, additionalProperties, items, minItems,
+// pattern and the date-time format. Other keywords are annotations and are
+// ignored.
+func (s *Spec) validate(doc string, schema, value interface{}, at string) error {
+	doc, schema, err := s.resolve(doc, schema)
+	if err != nil {
+		return err
+	}
+	switch schema := schema.(type) {
+	case bool:
+		if !schema {
+			return fmt.Errorf("%s: not allowed", at)
+		}
+		return nil
+	case map[string]interface{}:
+		return s.validateObject(doc, schema, value, at)
+	default:
+		return fmt.Errorf("%s: invalid schema %v", at, schema)
+	}
+}
+
+func (s *Spec) validateObject(doc string, schema map[string]interface{}, value interface{}, at string) error {
+	if t, ok := schema["type"]; ok && !hasType(t, value) {
+		return fmt.Errorf("%s: %s is not of type %v", at, describe(value), t)
+	}
+	if c, ok := schema["const"]; ok && !equal(c, value) {
+		return fmt.Errorf("%s: %s is not %v", at, describe(value), c)
+	}
+	if e, ok := schema["enum"].([]interface{}); ok && !contains(e, value) {
+		return fmt.Errorf("%s: %s is not one of %v", at, describe(value), e)
+	}
+	if not, ok := schema["not"]; ok {
+		if s.validate(doc, not, value, at) == nil {
+			return fmt.Errorf("%s: %s matches a schema it must not", at, describe(value))
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		if err := s.validateOneOf(doc, oneOf, value, at); err != nil {
+			return err
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", at, value)
+			}
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern %q", at, pattern)
+			}
+			if !re.MatchString(value) {
+				return fmt.Errorf("%s: %q does not match %s", at, value, pattern)
+			}
+		}
+	case []interface{}:
+		if min, ok := schema["minItems"].(float64); ok && len(value) < int(min) {
+			return fmt.Errorf("%s: %d items, want at least %v", at, len(value), min)
+		}
+		if items, ok := schema["items"]; ok {
+			for i, item := range value {
+				if err := s.validate(doc, items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		return s.validateProperties(doc, schema, value, at)
+	}
+	return nil
+}
+
+func (s *Spec) validateOneOf(doc string, schemas []interface{}, value interface{}, at string) error {
+	var matched int
+	var errs []string
+	for _, candidate := range schemas {
+		if err := s.validate(doc, candidate, value, at); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		matched++
+	}
+	switch {
+	case matched == 0:
+		return fmt.Errorf("%s: matches none of the oneOf schemas (%s)", at, strings.Join(errs, "; "))
+	case matched > 1:
+		return fmt.Errorf("%s: matches %d of the oneOf schemas, want one", at, matched)
+	}
+	return nil
+}
+
+func (s *Spec) validateProperties(doc string, schema, value map[string]interface{}, at string) error {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, ok := value[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing %s", at, name)
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, ok := properties[name]
+		if !ok {
+			additional, ok := schema["additionalProperties"]
+			if !ok {
+				continue
+			}
+			property = additional
+		}
+		if err := s.validate(doc, property, value[name], at+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasType reports whether value is of the JSON type t, a type name or a list
+// of them
+func hasType(t, value interface{}) bool {
+	if list, ok := t.([]interface{}); ok {
+		for _, name := range list {
+			if hasType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return false
+}
+
+// equal compares a schema constant with a value decoded with UseNumber
+func equal(constant, value interface{}) bool {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		return err == nil && f == constant
+	}
+	switch constant.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return constant == value
+}
+
+func contains(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if equal(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe shortens a value for an error message
+func describe(value interface{}) string {
+	raw, _ := json.Marshal(value)
+	if len(raw) > 60 {
+		return string(raw[:57]) + "..."
+	}
+	return string(raw)
+}
@@ -9,6 +9,7 @@ class ForumApp {
         this.ui = new UIManager(this);
         this.posts = new PostsManager(this);
         this.chat = new ChatManager(this);
+        this.push = new PushNotifications(this);
         
         this.init();
     }
@@ -80,6 +81,7 @@ class ForumApp {
             this.loadAllUsers();
             // Initialize chat after dashboard is loaded - this will get online users via WebSocket
             this.chat.init();
+            this.push.init();
             // Don't load all users immediately - let WebSocket connection establish first
             // All users will be loaded when we receive the initial online users list
         }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"real-time-forum/database"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const commandUsage = `usage: forum [flags] [command]
+
+Without a command the server starts, applying pending migrations first.
+Every flag can also be set with a FORUM_* environment variable, e.g.
+FORUM_DB_PATH for -db-path, or in the JSON file named by -config.
+
+commands:
+  migrate          apply every pending migration
+  rollback [n]     roll back the latest n migrations (default 1)
+  status           list migrations and whether they are applied
+`
+
+// runCommand runs a maintenance subcommand and returns the process exit code
+func runCommand(db *sql.DB, dialect database.Dialect, args []string) int {
+	ctx := context.Background()
+
+	migrator, err := database.NewMigrator(db, dialect)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load migrations: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "migrate":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied   %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+
+	case "rollback":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintf(os.Stderr, "Invalid number of steps: %s\n", args[1])
+				return 2
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Rollback failed: %v\n", err)
+			return 1
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("Nothing to roll back")
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state = "applied"
+				appliedAt = s.AppliedAt.Local().Format(time.RFC3339)
+			}
+			if s.Missing {
+				state += " (unknown to this build)"
+			}
+			if s.Modified {
+				state += " (modified since)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		w.Flush()
+
+	case "help", "-h", "--help":
+		fmt.Print(commandUsage)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", args[0], commandUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,363 @@
+// Package database owns the forum's schema. Migrations live in
+// migrations/<dialect>/ as NNNN_name.up.sql and NNNN_name.down.sql pairs and
+// are embedded in the binary; applied versions are recorded in the
+// schema_migrations table.
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// Dialect names a supported database and the migrations directory for it
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+var migrationName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus describes one migration known to the binary, the database
+// or both. Missing means the database has it but this binary does not;
+// Modified means the embedded up script changed after it was applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Missing   bool
+	Modified  bool
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// NewMigrator returns a migrator for the dialect's migrations embedded in the
+// binary
+func NewMigrator(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	if dialect != SQLite && dialect != Postgres {
+		return nil, fmt.Errorf("unknown database dialect %q", dialect)
+	}
+	migrations, err := loadMigrations(migrationFiles, path.Join("migrations", string(dialect)))
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected file %s in migrations", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration in order and returns the ones it
+// applied. Each runs in its own transaction that also holds the database's
+// migration lock, so servers booting side by side never apply the same
+// migration twice.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	for {
+		migration, err := m.step(ctx, m.applyNext)
+		if err != nil {
+			return applied, err
+		}
+		if migration == nil {
+			return applied, nil
+		}
+		slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+		applied = append(applied, *migration)
+	}
+}
+
+// Down rolls back the latest steps applied migrations, newest first
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var rolledBack []Migration
+	for i := 0; i < steps; i++ {
+		migration, err := m.step(ctx, m.rollbackLatest)
+		if err != nil {
+			return rolledBack, err
+		}
+		if migration == nil {
+			break
+		}
+		slog.Info("Rolled back migration", "version", migration.Version, "name", migration.Name)
+		rolledBack = append(rolledBack, *migration)
+	}
+	return rolledBack, nil
+}
+
+// Status lists every migration known to the binary or recorded in the
+// database, oldest first
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := m.ensureMigrationsTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if a, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.appliedAt
+			status.Modified = a.checksum != migration.Checksum
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for version, a := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Version:   version,
+			Name:      a.name,
+			Applied:   true,
+			AppliedAt: a.appliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// migrationLockID is the PostgreSQL advisory lock migrations hold. Any
+// number works as long as nothing else sharing the database uses it.
+const migrationLockID = 7_446_110_215
+
+// step runs fn inside a locked transaction on a single connection. On SQLite
+// BEGIN IMMEDIATE takes the database write lock up front, waiting out the
+// busy timeout when another process holds it; on PostgreSQL a transaction
+// level advisory lock queues concurrent migrators instead.
+func (m *Migrator) step(ctx context.Context, fn func(context.Context, *sql.Conn) (*Migration, error)) (*Migration, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := m.lock(ctx, conn); err != nil {
+		return nil, fmt.Errorf("failed to lock the database for migrations: %w", err)
+	}
+
+	// Created under the lock: PostgreSQL can fail two concurrent
+	// CREATE TABLE IF NOT EXISTS with a unique violation
+	err = m.ensureMigrationsTable(ctx, conn)
+	var migration *Migration
+	if err == nil {
+		migration, err = fn(ctx, conn)
+	}
+	if err != nil {
+		if _, rollbackErr := conn.ExecContext(context.Background(), "ROLLBACK"); rollbackErr != nil {
+			slog.Error("Failed to roll back migration transaction", "err", rollbackErr)
+		}
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		conn.ExecContext(context.Background(), "ROLLBACK")
+		return nil, err
+	}
+	return migration, nil
+}
+
+// applyNext applies the oldest pending migration, or returns nil when the
+// database is up to date
+func (m *Migrator) applyNext(ctx context.Context, conn *sql.Conn) (*Migration, error) {
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		if a, ok := applied[migration.Version]; ok && a.checksum != migration.Checksum {
+			slog.Warn("Migration changed after it was applied", "version", migration.Version, "name", migration.Name)
+		}
+	}
+	for version, a := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("database has migration %04d_%s, which this build does not know; refusing to run an older build against it", version, a.name)
+		}
+	}
+
+	for i := range m.migrations {
+		migration := &m.migrations[i]
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, migration.Up); err != nil {
+			return nil, fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		if _, err := conn.ExecContext(ctx, `
+			INSERT INTO schema_migrations (version, name, checksum, applied_at)
+			VALUES ($1, $2, $3, $4)`,
+			migration.Version, migration.Name, migration.Checksum, time.Now().UTC()); err != nil {
+			return nil, err
+		}
+		return migration, nil
+	}
+	return nil, nil
+}
+
+// rollbackLatest undoes the newest applied migration, or returns nil when
+// none is applied
+func (m *Migrator) rollbackLatest(ctx context.Context, conn *sql.Conn) (*Migration, error) {
+	var version int
+	err := conn.QueryRowContext(ctx, `SELECT version FROM schema_migrations ORDER BY version DESC LIMIT 1`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range m.migrations {
+		migration := &m.migrations[i]
+		if migration.Version != version {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, migration.Down); err != nil {
+			return nil, fmt.Errorf("rollback of %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		if _, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, version); err != nil {
+			return nil, err
+		}
+		return migration, nil
+	}
+	return nil, fmt.Errorf("cannot roll back migration %04d: this build has no down script for it", version)
+}
+
+// lock starts the migration transaction on conn
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) error {
+	if m.dialect == Postgres {
+		if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+			conn.ExecContext(context.Background(), "ROLLBACK")
+			return err
+		}
+		return nil
+	}
+	_, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+	return err
+}
+
+func (m *Migrator) ensureMigrationsTable(ctx context.Context, conn *sql.Conn) error {
+	timestamp := "DATETIME"
+	if m.dialect == Postgres {
+		timestamp = "TIMESTAMPTZ"
+	}
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			checksum   VARCHAR(64)  NOT NULL,
+			applied_at `+timestamp+` NOT NULL
+		)`)
+	return err
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
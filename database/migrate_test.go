@@ -0,0 +1,288 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"real-time-forum/database/sqlite"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func openTestDB(t *testing.T) *sqlite.DB {
+	t.Helper()
+	db, err := sqlite.Open(context.Background(), sqlite.Options{
+		Path:        filepath.Join(t.TempDir(), "forum.db"),
+		BusyTimeout: time.Second,
+		ReadConns:   1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// schema lists the tables, indexes and triggers in the database, skipping
+// SQLite's own and the migrations table
+func schema(t *testing.T, db *sqlite.DB) []string {
+	t.Helper()
+	rows, err := db.Write.Query(`
+		SELECT type || ' ' || name FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%' AND name <> 'schema_migrations'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var objects []string
+	for rows.Next() {
+		var object string
+		if err := rows.Scan(&object); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, object)
+	}
+	sort.Strings(objects)
+	return objects
+}
+
+// columns lists every column of every table as table.name type, skipping the
+// migrations table
+func columns(t *testing.T, db *sqlite.DB) []string {
+	t.Helper()
+	rows, err := db.Write.Query(`
+		SELECT m.name || '.' || c.name || ' ' || c.type
+		FROM sqlite_master m JOIN pragma_table_info(m.name) c
+		WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%' AND m.name <> 'schema_migrations'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err != nil {
+			t.Fatal(err)
+		}
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+func TestMigrateUpDownUp(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	migrator, err := NewMigrator(db.Write, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrator.migrations) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(migrator.migrations))
+	}
+	full := schema(t, db)
+
+	if applied, err := migrator.Up(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("second Up applied %d, %v; want nothing", len(applied), err)
+	}
+
+	rolledBack, err := migrator.Down(ctx, len(migrator.migrations)+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rolledBack) != len(migrator.migrations) || rolledBack[0].Version != applied[len(applied)-1].Version {
+		t.Errorf("rolled back %v, want every migration newest first", rolledBack)
+	}
+	if left := schema(t, db); len(left) != 0 {
+		t.Errorf("schema after rolling everything back = %v", left)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if again := schema(t, db); strings.Join(again, ",") != strings.Join(full, ",") {
+		t.Errorf("schema after up, down, up differs:\n%v\nwant\n%v", again, full)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.Missing || status.Modified {
+			t.Errorf("status = %+v, want applied and unchanged", status)
+		}
+	}
+}
+
+func TestMigrateChecksumDrift(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	migrator, err := NewMigrator(db.Write, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// As if the baseline script was edited after it was applied
+	if _, err := db.Write.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1`); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Modified || statuses[1].Modified {
+		t.Errorf("statuses = %+v, want only the baseline modified", statuses)
+	}
+	// A changed script is reported, not fatal
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Errorf("Up with a modified migration = %v", err)
+	}
+}
+
+func TestMigrateRefusesUnknownVersion(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	migrator, err := NewMigrator(db.Write, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Applied by a newer build
+	if _, err := db.Write.Exec(`
+		INSERT INTO schema_migrations (version, name, checksum, applied_at)
+		VALUES (9999, 'from_the_future', 'x', CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(ctx); err == nil || !strings.Contains(err.Error(), "9999_from_the_future") {
+		t.Errorf("Up = %v, want a refusal naming the unknown migration", err)
+	}
+	if _, err := migrator.Down(ctx, 1); err == nil {
+		t.Error("Down rolled back a migration it has no script for")
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := statuses[len(statuses)-1]
+	if last.Version != 9999 || !last.Missing {
+		t.Errorf("last status = %+v, want 9999 missing from the build", last)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(content string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(content)} }
+
+	migrations, err := loadMigrations(fstest.MapFS{
+		"m/0002_b.up.sql":   file("B"),
+		"m/0002_b.down.sql": file("-B"),
+		"m/0001_a.up.sql":   file("A"),
+		"m/0001_a.down.sql": file("-A"),
+	}, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "a" || migrations[1].Down != "-B" || migrations[0].Checksum == migrations[1].Checksum {
+		t.Errorf("migrations = %+v", migrations)
+	}
+
+	cases := map[string]fstest.MapFS{
+		"no down script": {"m/0001_a.up.sql": file("A")},
+		"bad file name":  {"m/0001_a.up.sql": file("A"), "m/0001_a.down.sql": file("-A"), "m/notes.txt": file("")},
+		"two names": {
+			"m/0001_a.up.sql":   file("A"),
+			"m/0001_b.down.sql": file("-B"),
+		},
+	}
+	for name, fsys := range cases {
+		if _, err := loadMigrations(fsys, "m"); err == nil {
+			t.Errorf("%s: loadMigrations succeeded", name)
+		}
+	}
+}
+
+func TestMigrateDatabaseFromDDL(t *testing.T) {
+	ctx := context.Background()
+
+	fresh := openTestDB(t)
+	migrator, err := NewMigrator(fresh.Write, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A database created from the old ddl.sql, with the categories the
+	// checked-in forum.db had and some content. The old code did not enforce
+	// foreign keys and stored nicknames in messages.
+	ddl, err := os.ReadFile(filepath.Join("testdata", "ddl.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := openTestDB(t)
+	for _, stmt := range []string{
+		`PRAGMA foreign_keys = OFF`,
+		string(ddl),
+		`INSERT INTO categories (id, name) VALUES (1, 'General Discussion'), (2, 'Sports')`,
+		`INSERT INTO users (id, nickname, age, gender, first_name, last_name, email, password)
+		 VALUES ('u1', 'alice', 30, 'female', 'Alice', 'A', 'alice@example.com', 'x'),
+		        ('u2', 'bob', 31, 'male', 'Bob', 'B', 'bob@example.com', 'x')`,
+		`INSERT INTO posts (id, author_id, title, content) VALUES ('p1', 'u1', 'Hello', 'World')`,
+		`INSERT INTO post_categories (post_id, category_id) VALUES ('p1', 2)`,
+		`INSERT INTO messages (from_user, to_user, body) VALUES ('alice', 'bob', 'hi')`,
+		`PRAGMA foreign_keys = ON`,
+	} {
+		if _, err := db.Write.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrator, err = NewMigrator(db.Write, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := schema(t, db), schema(t, fresh); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("schema = %v\nwant %v", got, want)
+	}
+	if got, want := columns(t, db), columns(t, fresh); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("columns = %v\nwant %v", got, want)
+	}
+
+	var role, category string
+	var position, messages int
+	if err := db.Write.QueryRow(`SELECT role FROM users WHERE id = 'u1'`).Scan(&role); err != nil || role != "user" {
+		t.Errorf("role = %q, %v; want user", role, err)
+	}
+	if err := db.Write.QueryRow(`
+		SELECT c.name, c.position FROM post_categories pc JOIN categories c ON c.id = pc.category_id
+		WHERE pc.post_id = 'p1'`).Scan(&category, &position); err != nil || category != "Sports" || position != 2 {
+		t.Errorf("category = %q at %d, %v; want Sports at 2", category, position, err)
+	}
+	if err := db.Write.QueryRow(`SELECT COUNT(*) FROM messages`).Scan(&messages); err != nil || messages != 1 {
+		t.Errorf("messages = %d, %v; want the one message kept", messages, err)
+	}
+}
@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+DROP TABLE IF EXISTS vapid_keys;
+DROP TABLE IF EXISTS push_subscriptions;
+DROP TABLE IF EXISTS mentions;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS user_blocks;
+DROP TABLE IF EXISTS user_sanctions;
+DROP TABLE IF EXISTS warnings;
+DROP TABLE IF EXISTS reports;
+DROP TABLE IF EXISTS post_categories;
+DROP TABLE IF EXISTS category_subscriptions;
+DROP TABLE IF EXISTS categories;
+DROP TRIGGER IF EXISTS messages_recipient_deleted ON users;
+DROP FUNCTION IF EXISTS messages_recipient_deleted();
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,242 @@
+-- Baseline: the schema the SQLite migrations build up, in PostgreSQL terms.
+-- Later migrations use the same version numbers in both directories, so a
+-- version may be missing here when the SQLite change it makes is already part
+-- of this baseline: 0002 through 0013 bring databases created from the old
+-- ddl.sql up to this schema, and PostgreSQL never had one.
+
+CREATE TABLE users (
+    id TEXT PRIMARY KEY,
+    nickname TEXT NOT NULL UNIQUE,
+    age INTEGER NOT NULL,
+    gender TEXT NOT NULL,
+    first_name TEXT NOT NULL,
+    last_name TEXT NOT NULL,
+    email TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL,
+    role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE sessions (
+    session_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    expires_at TIMESTAMPTZ
+);
+
+CREATE TABLE posts (
+    id TEXT PRIMARY KEY,
+    author_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    image TEXT,
+    hidden_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_posts_author ON posts(author_id);
+
+CREATE TABLE comments (
+    id TEXT PRIMARY KEY,
+    post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    author_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    hidden_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_comments_post ON comments(post_id);
+CREATE INDEX idx_comments_author ON comments(author_id);
+
+-- from_user and to_user hold nicknames. The recipient can be "all" for public
+-- messages, so only the sender is a foreign key and a trigger removes a
+-- deleted user's incoming messages.
+CREATE TABLE messages (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    from_user TEXT NOT NULL REFERENCES users(nickname) ON DELETE CASCADE,
+    to_user TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    hidden_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_messages_from_to ON messages(from_user, to_user);
+CREATE INDEX idx_messages_to ON messages(to_user);
+
+CREATE FUNCTION messages_recipient_deleted() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM messages WHERE to_user = OLD.nickname;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER messages_recipient_deleted
+AFTER DELETE ON users
+FOR EACH ROW EXECUTE FUNCTION messages_recipient_deleted();
+
+CREATE TABLE categories (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0,
+    archived_at TIMESTAMPTZ
+);
+
+CREATE TABLE category_subscriptions (
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category_id INTEGER NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, category_id)
+);
+
+CREATE TABLE post_categories (
+    post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    category_id INTEGER NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, category_id)
+);
+
+CREATE INDEX idx_post_categories_category ON post_categories(category_id);
+
+CREATE TABLE reports (
+    id TEXT PRIMARY KEY,
+    content_type TEXT NOT NULL CHECK (content_type IN ('post', 'comment', 'message')),
+    content_id TEXT NOT NULL,
+    author_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reporter_id TEXT REFERENCES users(id) ON DELETE CASCADE, -- NULL for the content filter
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved')),
+    claimed_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT CHECK (action IN ('hide', 'delete', 'warn', 'dismiss')),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_reports_status ON reports(status, created_at);
+CREATE INDEX idx_reports_content ON reports(content_type, content_id);
+
+CREATE TABLE warnings (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    moderator_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    report_id TEXT REFERENCES reports(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE user_sanctions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK (type IN ('ban', 'suspension', 'mute')),
+    reason TEXT NOT NULL,
+    moderator_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_user_sanctions_user ON user_sanctions(user_id, type);
+
+CREATE TABLE user_blocks (
+    blocker_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX idx_user_blocks_blocked ON user_blocks(blocked_id);
+
+CREATE TABLE notifications (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    actor_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    post_id TEXT REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id TEXT REFERENCES comments(id) ON DELETE CASCADE,
+    preview TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    read_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_notifications_user ON notifications(user_id, read_at, created_at);
+
+CREATE TABLE mentions (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    content_type TEXT NOT NULL CHECK (content_type IN ('post', 'comment', 'message')),
+    content_id TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    author_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (content_type, content_id, user_id)
+);
+
+CREATE INDEX idx_mentions_user ON mentions(user_id, created_at);
+
+CREATE TABLE push_subscriptions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL UNIQUE,
+    p256dh TEXT NOT NULL,
+    auth TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_push_subscriptions_user ON push_subscriptions(user_id);
+
+CREATE TABLE vapid_keys (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    public_key TEXT NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Append-only: actor_id is deliberately not a foreign key so events outlive
+-- the users they mention
+CREATE TABLE audit_events (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    action TEXT NOT NULL,
+    actor_id TEXT,
+    actor_name TEXT NOT NULL DEFAULT '',
+    target_type TEXT NOT NULL DEFAULT '',
+    target_id TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    metadata TEXT NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_events_action ON audit_events(action, created_at);
+CREATE INDEX idx_audit_events_actor ON audit_events(actor_id);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update
+BEFORE UPDATE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_delete
+BEFORE DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- Full-text search runs on these expressions; the search repository must use
+-- exactly the same ones for the planner to pick the indexes
+CREATE INDEX idx_posts_search ON posts USING GIN (to_tsvector('simple', title || ' ' || content));
+CREATE INDEX idx_comments_search ON comments USING GIN (to_tsvector('simple', content));
+CREATE INDEX idx_users_search ON users USING GIN (to_tsvector('simple', nickname));
+
+-- The default categories the frontend ships with
+INSERT INTO categories (id, name, position) VALUES
+    (1, 'General Discussion', 1),
+    (2, 'Sports', 2),
+    (3, 'Music', 3),
+    (4, 'Movies & TV', 4),
+    (5, 'Books', 5),
+    (6, 'Science', 6),
+    (7, 'News', 7);
+
+-- The IDs above were given explicitly, so move the sequence past them
+SELECT setval(pg_get_serial_sequence('categories', 'id'), (SELECT MAX(id) FROM categories));
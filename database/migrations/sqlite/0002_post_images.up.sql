@@ -0,0 +1 @@
+ALTER TABLE posts ADD COLUMN image VARCHAR(255);
@@ -0,0 +1,3 @@
+ALTER TABLE categories DROP COLUMN archived_at;
+ALTER TABLE categories DROP COLUMN position;
+ALTER TABLE categories DROP COLUMN description;
@@ -0,0 +1,6 @@
+ALTER TABLE categories ADD COLUMN description VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE categories ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE categories ADD COLUMN archived_at DATETIME;
+
+-- Keep the existing categories in the order they were listed in
+UPDATE categories SET position = id;
@@ -0,0 +1,8 @@
+CREATE TABLE category_subscriptions (
+    user_id     VARCHAR(255) NOT NULL
+                REFERENCES users(id)       ON DELETE CASCADE,
+    category_id INTEGER NOT NULL
+                REFERENCES categories(id)  ON DELETE CASCADE,
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, category_id)
+);
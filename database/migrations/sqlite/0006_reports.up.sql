@@ -0,0 +1,31 @@
+ALTER TABLE posts ADD COLUMN hidden_at DATETIME;
+ALTER TABLE comments ADD COLUMN hidden_at DATETIME;
+ALTER TABLE messages ADD COLUMN hidden_at DATETIME;
+
+CREATE TABLE reports (
+    id           VARCHAR(255) PRIMARY KEY,
+    content_type VARCHAR(10)  NOT NULL CHECK (content_type IN ('post', 'comment', 'message')),
+    content_id   VARCHAR(255) NOT NULL,
+    author_id    VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reporter_id  VARCHAR(255) REFERENCES users(id) ON DELETE CASCADE, -- NULL for the content filter
+    reason       VARCHAR(500) NOT NULL,
+    status       VARCHAR(10)  NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved')),
+    claimed_by   VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+    action       VARCHAR(10)  CHECK (action IN ('hide', 'delete', 'warn', 'dismiss')),
+    note         VARCHAR(500) NOT NULL DEFAULT '',
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at  DATETIME
+);
+
+CREATE INDEX idx_reports_status ON reports(status, created_at);
+CREATE INDEX idx_reports_content ON reports(content_type, content_id);
+
+CREATE TABLE warnings (
+    id           VARCHAR(255) PRIMARY KEY,
+    user_id      VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    moderator_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    report_id    VARCHAR(255) REFERENCES reports(id) ON DELETE SET NULL,
+    reason       VARCHAR(500) NOT NULL,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
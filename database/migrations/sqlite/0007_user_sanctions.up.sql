@@ -0,0 +1,12 @@
+CREATE TABLE user_sanctions (
+    id           VARCHAR(255) PRIMARY KEY,
+    user_id      VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type         VARCHAR(10)  NOT NULL CHECK (type IN ('ban', 'suspension', 'mute')),
+    reason       VARCHAR(500) NOT NULL,
+    moderator_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at   DATETIME,
+    revoked_at   DATETIME
+);
+
+CREATE INDEX idx_user_sanctions_user ON user_sanctions(user_id, type);
@@ -0,0 +1,8 @@
+CREATE TABLE user_blocks (
+    blocker_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX idx_user_blocks_blocked ON user_blocks(blocked_id);
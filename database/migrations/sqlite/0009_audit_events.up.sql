@@ -0,0 +1,28 @@
+-- Append-only: actor_id is deliberately not a foreign key so events outlive
+-- the users they mention
+CREATE TABLE audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    action VARCHAR(50) NOT NULL,
+    actor_id VARCHAR(255),
+    actor_name VARCHAR(50) NOT NULL DEFAULT '',
+    target_type VARCHAR(20) NOT NULL DEFAULT '',
+    target_id VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    metadata TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_events_action ON audit_events(action, created_at);
+CREATE INDEX idx_audit_events_actor ON audit_events(actor_id);
+
+CREATE TRIGGER audit_events_no_update
+BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete
+BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
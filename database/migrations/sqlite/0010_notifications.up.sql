@@ -0,0 +1,13 @@
+CREATE TABLE notifications (
+    id         VARCHAR(255) PRIMARY KEY,
+    user_id    VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type       VARCHAR(20)  NOT NULL,
+    actor_id   VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+    post_id    VARCHAR(255) REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id VARCHAR(255) REFERENCES comments(id) ON DELETE CASCADE,
+    preview    VARCHAR(200) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at    DATETIME
+);
+
+CREATE INDEX idx_notifications_user ON notifications(user_id, read_at, created_at);
@@ -0,0 +1,11 @@
+CREATE TABLE mentions (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    content_type VARCHAR(10)  NOT NULL CHECK (content_type IN ('post', 'comment', 'message')),
+    content_id   VARCHAR(255) NOT NULL,
+    user_id      VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    author_id    VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (content_type, content_id, user_id)
+);
+
+CREATE INDEX idx_mentions_user ON mentions(user_id, created_at);
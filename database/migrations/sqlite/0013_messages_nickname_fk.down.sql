@@ -0,0 +1,19 @@
+-- The foreign keys to users(id) are not restored: the columns hold nicknames,
+-- so they would fail every insert now that foreign keys are enforced.
+
+DROP TRIGGER IF EXISTS messages_recipient_deleted;
+
+CREATE TABLE messages_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_user VARCHAR(255) NOT NULL,
+    to_user VARCHAR(255) NOT NULL,
+    body VARCHAR NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    hidden_at DATETIME
+);
+
+INSERT INTO messages_old (id, from_user, to_user, body, created_at, hidden_at)
+SELECT id, from_user, to_user, body, created_at, hidden_at FROM messages;
+
+DROP TABLE messages;
+ALTER TABLE messages_old RENAME TO messages;
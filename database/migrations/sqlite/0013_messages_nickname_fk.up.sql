@@ -0,0 +1,33 @@
+-- messages.from_user and messages.to_user hold nicknames, not user IDs, so
+-- their foreign keys could never hold once foreign keys are enforced. The
+-- sender now references users(nickname). The recipient can also be "all" for
+-- public messages, so a trigger removes a deleted user's incoming messages
+-- instead of a foreign key.
+
+CREATE TABLE messages_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_user VARCHAR(50) NOT NULL,
+    to_user VARCHAR(50) NOT NULL,
+    body VARCHAR NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    hidden_at DATETIME,
+    FOREIGN KEY(from_user) REFERENCES users(nickname) ON DELETE CASCADE
+);
+
+-- Messages of senders that no longer exist cannot be kept
+INSERT INTO messages_new (id, from_user, to_user, body, created_at, hidden_at)
+SELECT id, from_user, to_user, body, created_at, hidden_at
+FROM messages
+WHERE from_user IN (SELECT nickname FROM users);
+
+DROP TABLE messages;
+ALTER TABLE messages_new RENAME TO messages;
+
+CREATE INDEX IF NOT EXISTS idx_messages_from_to ON messages(from_user, to_user);
+CREATE INDEX IF NOT EXISTS idx_messages_to ON messages(to_user);
+
+CREATE TRIGGER IF NOT EXISTS messages_recipient_deleted
+AFTER DELETE ON users
+BEGIN
+    DELETE FROM messages WHERE to_user = old.nickname;
+END;
@@ -0,0 +1,39 @@
+// Package postgres opens the forum's PostgreSQL database. Unlike SQLite,
+// PostgreSQL handles concurrent writers itself, so reads and writes share
+// one pool.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+type Options struct {
+	// DSN is a lib/pq connection string, either a postgres:// URL or
+	// key=value pairs
+	DSN      string
+	MaxConns int
+}
+
+// Open opens the pool and checks that the server answers
+func Open(ctx context.Context, opts Options) (*sql.DB, error) {
+	if opts.MaxConns < 1 {
+		opts.MaxConns = 1
+	}
+
+	db, err := sql.Open("postgres", opts.DSN)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(opts.MaxConns)
+	db.SetMaxIdleConns(opts.MaxConns)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connecting to postgres: %w", err)
+	}
+	return db, nil
+}
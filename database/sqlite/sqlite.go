@@ -0,0 +1,119 @@
+// Package sqlite opens the forum's SQLite database. Every connection runs in
+// WAL mode with foreign keys enforced and a busy timeout. Writes go through a
+// single-connection pool so they queue in Go instead of failing with
+// SQLITE_BUSY, while reads use a separate query-only pool that WAL lets run
+// alongside the writer.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type Options struct {
+	Path        string
+	BusyTimeout time.Duration
+	// ReadConns caps the read pool; the write pool always has one connection
+	ReadConns int
+}
+
+// DB holds the two pools. Write is also the one to use for transactions and
+// for reads that must not run on a query-only connection.
+type DB struct {
+	Write *sql.DB
+	Read  *sql.DB
+}
+
+// Open opens both pools and checks that the pragmas took effect
+func Open(ctx context.Context, opts Options) (*DB, error) {
+	if opts.ReadConns < 1 {
+		opts.ReadConns = 1
+	}
+
+	// The writer goes first: it creates the file and switches it to WAL,
+	// which is a property of the database file rather than the connection
+	write, err := openPool(ctx, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	write.SetMaxOpenConns(1)
+	write.SetMaxIdleConns(1)
+
+	read, err := openPool(ctx, opts, true)
+	if err != nil {
+		write.Close()
+		return nil, err
+	}
+	read.SetMaxOpenConns(opts.ReadConns)
+	read.SetMaxIdleConns(opts.ReadConns)
+
+	return &DB{Write: write, Read: read}, nil
+}
+
+func openPool(ctx context.Context, opts Options, readOnly bool) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_foreign_keys", "on")
+	params.Set("_busy_timeout", strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10))
+	// WAL makes NORMAL safe: a crash may lose the last commits, never corrupt
+	params.Set("_synchronous", "NORMAL")
+	if readOnly {
+		params.Set("_query_only", "true")
+	} else {
+		// Take the write lock when a transaction starts, not at its first
+		// write, so a transaction never fails half-way on a busy database
+		params.Set("_txlock", "immediate")
+	}
+
+	db, err := sql.Open("sqlite3", opts.Path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPragmas(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening %s: %w", opts.Path, err)
+	}
+	return db, nil
+}
+
+func checkPragmas(ctx context.Context, db *sql.DB) error {
+	var journalMode string
+	if err := db.QueryRowContext(ctx, `PRAGMA journal_mode`).Scan(&journalMode); err != nil {
+		return err
+	}
+	if journalMode != "wal" {
+		return fmt.Errorf("journal mode is %s, not wal", journalMode)
+	}
+
+	var foreignKeys int
+	if err := db.QueryRowContext(ctx, `PRAGMA foreign_keys`).Scan(&foreignKeys); err != nil {
+		return err
+	}
+	if foreignKeys != 1 {
+		return fmt.Errorf("foreign keys are not enforced")
+	}
+	return nil
+}
+
+// Close closes both pools
+func (db *DB) Close() error {
+	readErr := db.Read.Close()
+	if err := db.Write.Close(); err != nil {
+		return err
+	}
+	return readErr
+}
+
+// Stats reports the connection pool statistics by pool name
+func (db *DB) Stats() map[string]sql.DBStats {
+	return map[string]sql.DBStats{
+		"write": db.Write.Stats(),
+		"read":  db.Read.Stats(),
+	}
+}
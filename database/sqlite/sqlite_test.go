@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenConfiguresPools(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, Options{Path: filepath.Join(t.TempDir(), "test.db"), BusyTimeout: 2 * time.Second, ReadConns: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var timeout int
+	if err := db.Write.QueryRowContext(ctx, `PRAGMA busy_timeout`).Scan(&timeout); err != nil {
+		t.Fatal(err)
+	}
+	if timeout != 2000 {
+		t.Errorf("busy_timeout = %d, want 2000", timeout)
+	}
+
+	if _, err := db.Write.ExecContext(ctx, `CREATE TABLE t (id INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Read.ExecContext(ctx, `INSERT INTO t (id) VALUES (1)`); err == nil {
+		t.Error("the read pool accepted a write")
+	}
+
+	stats := db.Stats()
+	if stats["write"].MaxOpenConnections != 1 || stats["read"].MaxOpenConnections != 3 {
+		t.Errorf("pool sizes = %d write, %d read; want 1 and 3", stats["write"].MaxOpenConnections, stats["read"].MaxOpenConnections)
+	}
+}
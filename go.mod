@@ -9,3 +9,17 @@ require golang.org/x/crypto v0.43.0
 require github.com/mattn/go-sqlite3 v1.14.32
 
 require github.com/gorilla/websocket v1.5.3
+
+require github.com/yuin/goldmark v1.8.6
+
+require github.com/microcosm-cc/bluemonday v1.0.27
+
+require (
+	github.com/lib/pq v1.10.9
+	golang.org/x/net v0.45.0
+)
+
+require (
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/gorilla/css v1.0.1 // indirect
+)
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"real-time-forum/models"
+	"real-time-forum/respond"
+	"real-time-forum/services"
+	"real-time-forum/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AdminHandler struct {
+	userService  services.UserService
+	auditService services.AuditService
+	config       map[string]string
+	dbStats      func() map[string]sql.DBStats
+}
+
+// NewAdminHandler returns the admin handler. config is the effective server
+// configuration with secrets already redacted; dbStats reports the database
+// connection pools by name.
+func NewAdminHandler(us services.UserService, aus services.AuditService, config map[string]string, dbStats func() map[string]sql.DBStats) *AdminHandler {
+	return &AdminHandler{
+		userService:  us,
+		auditService: aus,
+		config:       config,
+		dbStats:      dbStats,
+	}
+}
+
+// SetUserRole handles POST /admin/users/role with user=<nickname or email>
+// and role=user|moderator|admin, the legacy alias of UpdateUserRole
+func (h *AdminHandler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.Logger(r.Context()).Warn("SetUserRole: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	r.SetPathValue("user", r.FormValue("user"))
+	h.UpdateUserRole(w, r)
+}
+
+// UpdateUserRole handles PUT /api/v1/admin/users/{user}/role, where user is a
+// nickname or email, with role=user|moderator|admin
+func (h *AdminHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		utils.Logger(r.Context()).Warn("UpdateUserRole: invalid form data", "err", err)
+		respond.BadRequest(w, r, "Invalid form data")
+		return
+	}
+
+	identifier := strings.TrimSpace(r.PathValue("user"))
+	if identifier == "" {
+		respond.BadRequest(w, r, "User is required")
+		return
+	}
+
+	user, err := h.userService.SetUserRole(r.Context(), identifier, strings.TrimSpace(r.FormValue("role")))
+	if err != nil {
+		utils.Logger(r.Context()).Warn("UpdateUserRole: failed to set role", "err", err)
+		respond.Error(w, r, err, "Failed to update role")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Role updated successfully",
+		"user":    user,
+	})
+}
+
+// AuditEvents handles GET /admin/audit. Filters: action= (a trailing ".*"
+// matches a group, e.g. moderation.*), actor= (user ID or nickname),
+// target_type=, target_id=, ip=, since= and until= (RFC 3339), limit and
+// offset.
+func (h *AdminHandler) AuditEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.Logger(r.Context()).Warn("AuditEvents: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Action:     strings.TrimSpace(query.Get("action")),
+		Actor:      strings.TrimSpace(query.Get("actor")),
+		TargetType: strings.TrimSpace(query.Get("target_type")),
+		TargetID:   strings.TrimSpace(query.Get("target_id")),
+		IP:         strings.TrimSpace(query.Get("ip")),
+		Limit:      10,
+		Offset:     0,
+	}
+
+	since, sinceErr := parseAuditTime(query.Get("since"))
+	until, untilErr := parseAuditTime(query.Get("until"))
+	if sinceErr != nil || untilErr != nil {
+		utils.Logger(r.Context()).Warn("AuditEvents: invalid time range", "since", query.Get("since"), "until", query.Get("until"))
+		respond.BadRequest(w, r, "Since and until must be RFC 3339 timestamps")
+		return
+	}
+	filter.Since = since
+	filter.Until = until
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 50 {
+			filter.Limit = parsedLimit
+		}
+	}
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			filter.Offset = parsedOffset
+		}
+	}
+
+	events, err := h.auditService.ListEvents(r.Context(), filter)
+	if err != nil {
+		respond.Internal(w, r, "Failed to fetch audit events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events":  events,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+		"hasMore": len(events) == filter.Limit,
+	})
+}
+
+// parseAuditTime parses an optional RFC 3339 query parameter
+func parseAuditTime(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Config handles GET /admin/config, listing the effective settings by name
+// with secrets redacted
+func (h *AdminHandler) Config(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.Logger(r.Context()).Warn("Config: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config": h.config,
+	})
+}
+
+// DBStats handles GET /admin/db/stats, reporting the database connection
+// pools. Durations are in milliseconds.
+func (h *AdminHandler) DBStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.Logger(r.Context()).Warn("DBStats: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	pools := make(map[string]interface{})
+	for name, stats := range h.dbStats() {
+		pools[name] = map[string]interface{}{
+			"max_open":             stats.MaxOpenConnections,
+			"open":                 stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"wait_count":           stats.WaitCount,
+			"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+			"max_idle_closed":      stats.MaxIdleClosed,
+			"max_idle_time_closed": stats.MaxIdleTimeClosed,
+			"max_lifetime_closed":  stats.MaxLifetimeClosed,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pools": pools,
+	})
+}
@@ -2,24 +2,35 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"real-time-forum/models"
+	"real-time-forum/respond"
 	"real-time-forum/services"
 	"real-time-forum/utils"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type AuthHandler struct {
 	authService services.AuthService
 	sessionService services.SessionService
+	sanctionService services.SanctionService
+	auditService services.AuditService
+	secureCookies bool
 }
 
-func NewAuthHandler(as services.AuthService, ss services.SessionService) *AuthHandler {
+// NewAuthHandler returns the auth handler. secureCookies marks the session
+// cookie Secure, which browsers only send over HTTPS.
+func NewAuthHandler(as services.AuthService, ss services.SessionService, sns services.SanctionService, aus services.AuditService, secureCookies bool) *AuthHandler {
 	return &AuthHandler{
-		authService:    as,
-		sessionService: ss,
+		authService:     as,
+		sessionService:  ss,
+		sanctionService: sns,
+		auditService:    aus,
+		secureCookies:   secureCookies,
 	}
 }
 
@@ -29,8 +40,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		respond.MethodNotAllowed(w, r)
 		return
 	}
 
@@ -41,14 +51,12 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	if strings.Contains(contentType, "multipart/form-data") {
 		// Allow up to 32MB in memory before spooling to disk
 		if err := r.ParseMultipartForm(32 << 20); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid multipart form data"})
+			respond.BadRequest(w, r, "Invalid multipart form data")
 			return
 		}
 	} else {
 		if err := r.ParseForm(); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid form data"})
+			respond.BadRequest(w, r, "Invalid form data")
 			return
 		}
 	}
@@ -56,15 +64,13 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	// Convert form values to user struct
 	ageStr := strings.TrimSpace(r.FormValue("age"))
 	if ageStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Age is required"})
+		respond.Invalid(w, r, "age", "Age is required")
 		return
 	}
 
 	age, err := strconv.Atoi(ageStr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid age format"})
+		respond.Invalid(w, r, "age", "Invalid age format")
 		return
 	}
 
@@ -80,86 +86,63 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	// Validate required fields
 	if user.Nickname == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Nickname is required"})
+		respond.Invalid(w, r, "nickname", "Nickname is required")
 		return
 	}
 	if user.FirstName == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "First name is required"})
+		respond.Invalid(w, r, "firstName", "First name is required")
 		return
 	}
 	if user.LastName == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Last name is required"})
+		respond.Invalid(w, r, "lastName", "Last name is required")
 		return
 	}
 	if user.Email == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Email is required"})
+		respond.Invalid(w, r, "email", "Email is required")
 		return
 	}
 	if user.Password == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Password is required"})
+		respond.Invalid(w, r, "password", "Password is required")
 		return
 	}
 	if user.Age <= 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Age is required"})
+		respond.Invalid(w, r, "age", "Age is required")
 		return
 	}
 	if user.Gender == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Gender is required"})
+		respond.Invalid(w, r, "gender", "Gender is required")
 		return
 	}
 
 	// Validate email format
 	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
 	if !emailRegex.MatchString(user.Email) {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid email format"})
+		respond.Invalid(w, r, "email", "Invalid email format")
 		return
 	}
 
 	// Validate age range
 	if user.Age < 13 || user.Age > 120 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Age must be between 13 and 120"})
+		respond.Invalid(w, r, "age", "Age must be between 13 and 120")
 		return
 	}
 
 	// Validate password length
 	if len(user.Password) < 6 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Password must be at least 6 characters long"})
+		respond.Invalid(w, r, "password", "Password must be at least 6 characters long")
 		return
 	}
 
 	// Validate gender
 	validGenders := map[string]bool{"Male": true, "Female": true, "Other": true}
 	if !validGenders[user.Gender] {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid gender selection"})
+		respond.Invalid(w, r, "gender", "Invalid gender selection")
 		return
 	}
 
 	// Attempt to register user
 	if err := h.authService.Register(r.Context(), &user); err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			w.WriteHeader(http.StatusConflict)
-			if strings.Contains(err.Error(), "email") {
-				json.NewEncoder(w).Encode(map[string]string{"error": "Email already exists"})
-			} else if strings.Contains(err.Error(), "nickname") {
-				json.NewEncoder(w).Encode(map[string]string{"error": "Nickname already exists"})
-			} else {
-				json.NewEncoder(w).Encode(map[string]string{"error": "User already exists"})
-			}
-			return
-		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Registration failed: " + err.Error()})
+		respond.Error(w, r, err, "Registration failed")
 		return
 	}
 
@@ -175,25 +158,19 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(contentType, "application/json") {
 			// JSON body
 			if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON body"})
+				respond.BadRequest(w, r, "Invalid JSON body")
 				return
 			}
 		} else {
 			// Try form values (supports application/x-www-form-urlencoded and multipart/form-data)
 			if strings.Contains(contentType, "multipart/form-data") {
 				if err := r.ParseMultipartForm(32 << 20); err != nil {
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusBadRequest)
-					json.NewEncoder(w).Encode(map[string]string{"error": "Invalid multipart form data"})
+					respond.BadRequest(w, r, "Invalid multipart form data")
 					return
 				}
 			} else {
 				if err := r.ParseForm(); err != nil {
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusBadRequest)
-					json.NewEncoder(w).Encode(map[string]string{"error": "Invalid form data"})
+					respond.BadRequest(w, r, "Invalid form data")
 					return
 				}
 			}
@@ -208,18 +185,40 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		}
 
 		user, err := h.authService.LoginUser(r.Context(), &input)
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			respond.Unauthorized(w, r, "Invalid nickname, email or password")
+			return
+		}
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			respond.Error(w, r, err, "Login failed")
+			return
+		}
+
+		// Banned and suspended users don't get a session at all
+		ban, err := h.sanctionService.ActiveBan(r.Context(), user.ID)
+		if err != nil {
+			respond.Internal(w, r, "Internal server error")
+			return
+		}
+		if ban != nil {
+			h.auditService.Record(r.Context(), models.AuditEvent{
+				Action:     models.AuditLoginRefused,
+				ActorID:    user.ID,
+				ActorName:  user.Nickname,
+				TargetType: "user",
+				TargetID:   user.ID,
+				Metadata: map[string]interface{}{
+					"sanction_id": ban.ID,
+					"type":        ban.Type,
+				},
+			})
+			respond.Banned(w, r, ban)
 			return
 		}
 
 		session, err := h.sessionService.GenerateSession(r.Context(), user)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create session"})
+			respond.Internal(w, r, "Failed to create session")
 			return
 		}
 
@@ -229,10 +228,10 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 			Value:    session.ID,
 			Path:     "/",
 			HttpOnly: false, // Allow JavaScript access for development
-			Secure:   false, // Allow HTTP for development
+			Secure:   h.secureCookies, // Off by default to allow HTTP for development
 			SameSite: http.SameSiteLaxMode,
 			Domain:   "", // Browser will automatically set to current domain
-			MaxAge:   3600, // 1 hour
+			MaxAge:   int(time.Until(session.ExpiresAt).Round(time.Second).Seconds()), // Same lifetime as the session
 		})
 
 		w.Header().Set("Content-Type", "application/json")
@@ -244,18 +243,22 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		})
 
 	default:
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		respond.MethodNotAllowed(w, r)
 	}
 }
 
+// LogOut handles POST /logout, the legacy alias of DELETE /api/v1/session
 func (h *AuthHandler) LogOut(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		respond.MethodNotAllowed(w, r)
 		return
 	}
 
+	h.DeleteSession(w, r)
+}
+
+// DeleteSession logs the current user out
+func (h *AuthHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 	user := utils.GetUserFromContext(r.Context())
 	// Expire session cookie
 	http.SetCookie(w, &http.Cookie{
@@ -266,7 +269,7 @@ func (h *AuthHandler) LogOut(w http.ResponseWriter, r *http.Request) {
 	})
 	err := h.sessionService.ExpireSession(r.Context(), user.ID)
 	if err != nil {
-		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		respond.Internal(w, r, "Failed to log out")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -276,9 +279,7 @@ func (h *AuthHandler) LogOut(w http.ResponseWriter, r *http.Request) {
 
 func (h *AuthHandler) CheckSession(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		respond.MethodNotAllowed(w, r)
 		return
 	}
 	sessionID := r.Header.Get("X-Session-ID")
@@ -291,9 +292,7 @@ func (h *AuthHandler) CheckSession(w http.ResponseWriter, r *http.Request) {
 			Path:   "/",
 			MaxAge: -1,
 		})
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid session"})
+		respond.Unauthorized(w, r, "Invalid session")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
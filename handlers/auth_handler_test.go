@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"real-time-forum/models"
+	"real-time-forum/services"
+	"strings"
+	"testing"
+	"time"
+)
+
+type authFixture struct {
+	handler   *AuthHandler
+	auth      *fakeAuthService
+	sessions  *fakeSessionService
+	sanctions *fakeSanctionService
+	audit     *fakeAuditService
+}
+
+func newAuthFixture() *authFixture {
+	f := &authFixture{
+		auth:      &fakeAuthService{},
+		sessions:  &fakeSessionService{},
+		sanctions: &fakeSanctionService{},
+		audit:     &fakeAuditService{},
+	}
+	f.handler = NewAuthHandler(f.auth, f.sessions, f.sanctions, f.audit, true)
+	return f
+}
+
+func registrationForm() url.Values {
+	return url.Values{
+		"nickname":  {" alice "},
+		"firstName": {"Alice"},
+		"lastName":  {"Liddell"},
+		"email":     {"alice@example.com"},
+		"age":       {"30"},
+		"gender":    {"Female"},
+		"password":  {"secret1"},
+	}
+}
+
+func postForm(target string, form url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestRegister(t *testing.T) {
+	f := newAuthFixture()
+	w := httptest.NewRecorder()
+	f.handler.Register(w, postForm("/register", registrationForm()))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body)
+	}
+	if f.auth.registered == nil || f.auth.registered.Nickname != "alice" || f.auth.registered.Age != 30 {
+		t.Errorf("registered %+v", f.auth.registered)
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	cases := []struct {
+		field, value, want string
+	}{
+		{"age", "", "Age is required"},
+		{"age", "thirty", "Invalid age format"},
+		{"age", "12", "Age must be between 13 and 120"},
+		{"nickname", "  ", "Nickname is required"},
+		{"email", "alice@example", "Invalid email format"},
+		{"password", "12345", "Password must be at least 6 characters long"},
+		{"gender", "Robot", "Invalid gender selection"},
+	}
+	for _, c := range cases {
+		t.Run(c.field+"="+c.value, func(t *testing.T) {
+			f := newAuthFixture()
+			form := registrationForm()
+			form.Set(c.field, c.value)
+
+			w := httptest.NewRecorder()
+			f.handler.Register(w, postForm("/register", form))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", w.Code)
+			}
+			if got := decodeBody(t, w.Body)["error"]; got != c.want {
+				t.Errorf("error = %q, want %q", got, c.want)
+			}
+			if f.auth.registered != nil {
+				t.Error("an invalid registration reached the service")
+			}
+		})
+	}
+}
+
+func TestRegisterServiceErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		want      int
+		wantCode  string
+		wantError string
+	}{
+		{"nickname taken", services.ErrNicknameTaken, http.StatusConflict, "conflict", "Nickname already exists"},
+		{"email taken", services.ErrEmailTaken, http.StatusConflict, "conflict", "Email already exists"},
+		{"invalid", &services.ValidationError{Field: "nickname", Message: "nickname must be at least four characters long"}, http.StatusBadRequest, "validation_failed", "Nickname must be at least four characters long"},
+		{"database", errors.New("disk I/O error"), http.StatusInternalServerError, "internal", "Registration failed"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := newAuthFixture()
+			f.auth.registerErr = c.err
+
+			w := httptest.NewRecorder()
+			f.handler.Register(w, postForm("/register", registrationForm()))
+
+			if w.Code != c.want {
+				t.Fatalf("status = %d, want %d", w.Code, c.want)
+			}
+			body := decodeBody(t, w.Body)
+			if body["code"] != c.wantCode || body["error"] != c.wantError {
+				t.Errorf("body = %v", body)
+			}
+		})
+	}
+}
+
+func TestRegisterMethodNotAllowed(t *testing.T) {
+	f := newAuthFixture()
+	w := httptest.NewRecorder()
+	f.handler.Register(w, httptest.NewRequest(http.MethodGet, "/register", nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", w.Code)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	f := newAuthFixture()
+	f.auth.user = &models.User{ID: "u1", Nickname: "alice"}
+
+	w := httptest.NewRecorder()
+	f.handler.Login(w, postForm("/login", url.Values{"nickname": {"alice@example.com"}, "password": {"secret1"}}))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if f.auth.loginInput.Email != "alice@example.com" || f.auth.loginInput.Nickname != "" {
+		t.Errorf("an email in the nickname field was passed as %+v", f.auth.loginInput)
+	}
+	if got := decodeBody(t, w.Body)["session_id"]; got != "session-u1" {
+		t.Errorf("session_id = %v", got)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "session_id" || cookies[0].Value != "session-u1" {
+		t.Fatalf("cookies = %v", cookies)
+	}
+	if !cookies[0].Secure || cookies[0].MaxAge != int(time.Hour.Seconds()) {
+		t.Errorf("cookie Secure = %v, MaxAge = %d", cookies[0].Secure, cookies[0].MaxAge)
+	}
+}
+
+func TestLoginJSON(t *testing.T) {
+	f := newAuthFixture()
+	f.auth.user = &models.User{ID: "u1", Nickname: "alice"}
+
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"Nickname":"alice","Password":"secret1"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	f.handler.Login(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if f.auth.loginInput.Nickname != "alice" || f.auth.loginInput.Password != "secret1" {
+		t.Errorf("login input %+v", f.auth.loginInput)
+	}
+}
+
+func TestLoginInvalidCredentials(t *testing.T) {
+	f := newAuthFixture()
+	f.auth.loginErr = services.ErrInvalidCredentials
+
+	w := httptest.NewRecorder()
+	f.handler.Login(w, postForm("/login", url.Values{"nickname": {"alice"}, "password": {"wrong"}}))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", w.Code)
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("a failed login set a cookie")
+	}
+}
+
+func TestLoginBanned(t *testing.T) {
+	f := newAuthFixture()
+	f.auth.user = &models.User{ID: "u1", Nickname: "alice"}
+	f.sanctions.ban = &models.Sanction{ID: "s1", Type: models.SanctionBan}
+
+	w := httptest.NewRecorder()
+	f.handler.Login(w, postForm("/login", url.Values{"nickname": {"alice"}, "password": {"secret1"}}))
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("a banned user got a session cookie")
+	}
+	if len(f.audit.events) != 1 || f.audit.events[0].Action != models.AuditLoginRefused {
+		t.Errorf("audit events = %+v", f.audit.events)
+	}
+}
+
+func TestLogOut(t *testing.T) {
+	f := newAuthFixture()
+	r := asUser(httptest.NewRequest(http.MethodPost, "/logout", nil), &models.User{ID: "u1", Nickname: "alice"})
+	w := httptest.NewRecorder()
+	f.handler.LogOut(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if len(f.sessions.expired) != 1 || f.sessions.expired[0] != "u1" {
+		t.Errorf("expired sessions of %v", f.sessions.expired)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("cookies = %v, want the session cookie cleared", cookies)
+	}
+}
+
+func TestCheckSession(t *testing.T) {
+	f := newAuthFixture()
+	w := httptest.NewRecorder()
+	f.handler.CheckSession(w, httptest.NewRequest(http.MethodGet, "/validate-session", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("valid session: status = %d, want 200", w.Code)
+	}
+
+	f.sessions.validateErr = errors.New("session expired")
+	w = httptest.NewRecorder()
+	f.handler.CheckSession(w, httptest.NewRequest(http.MethodGet, "/validate-session", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expired session: status = %d, want 401", w.Code)
+	}
+}
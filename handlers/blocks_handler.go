@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"real-time-forum/respond"
+	"real-time-forum/services"
+	"real-time-forum/utils"
+	"strings"
+)
+
+type BlocksHandler struct {
+	chatService services.ChatService
+}
+
+func NewBlocksHandler(chatService services.ChatService) *BlocksHandler {
+	return &BlocksHandler{chatService: chatService}
+}
+
+// Blocks handles /blocks for the current user: GET lists blocked users, POST
+// blocks user=<nickname> and DELETE /blocks?user=<nickname> unblocks them.
+func (h *BlocksHandler) Blocks(w http.ResponseWriter, r *http.Request) {
+	user := utils.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.Logger(r.Context()).Warn("Blocks: no user found in context")
+		respond.Unauthorized(w, r, "Not logged in")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		blocked, err := h.chatService.GetBlockedUsers(r.Context(), user.ID)
+		if err != nil {
+			respond.Internal(w, r, "Failed to fetch blocked users")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"blocked": blocked,
+		})
+
+	case http.MethodPost, http.MethodDelete:
+		if err := r.ParseForm(); err != nil {
+			utils.Logger(r.Context()).Warn("Blocks: invalid form data", "err", err)
+			respond.BadRequest(w, r, "Invalid form data")
+			return
+		}
+
+		nickname := strings.TrimSpace(r.FormValue("user"))
+		if nickname == "" {
+			respond.BadRequest(w, r, "User is required")
+			return
+		}
+
+		var err error
+		message := "User blocked successfully"
+		if r.Method == http.MethodPost {
+			err = h.chatService.BlockUser(r.Context(), user, nickname)
+		} else {
+			message = "User unblocked successfully"
+			err = h.chatService.UnblockUser(r.Context(), user, nickname)
+		}
+		if err != nil {
+			utils.Logger(r.Context()).Warn("Blocks: failed to update block list", "err", err)
+			respond.Error(w, r, err, "Failed to update block list")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": message,
+		})
+
+	default:
+		utils.Logger(r.Context()).Warn("Blocks: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"real-time-forum/models"
+	"real-time-forum/respond"
+	"real-time-forum/services"
+	"real-time-forum/utils"
+	"strings"
+)
+
+type CategoriesHandler struct {
+	categoriesService services.CategoriesService
+}
+
+func NewCategoriesHandler(cs services.CategoriesService) *CategoriesHandler {
+	return &CategoriesHandler{
+		categoriesService: cs,
+	}
+}
+
+// Categories handles /admin/categories: GET lists every category including
+// archived ones, POST creates a new one.
+func (h *CategoriesHandler) Categories(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		categories, err := h.categoriesService.GetAllCategoriesWithArchived(r.Context())
+		if err != nil {
+			utils.Logger(r.Context()).Error("Categories GET: failed to fetch categories", "err", err)
+			respond.Internal(w, r, "Failed to fetch categories")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"categories": categories,
+		})
+
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			utils.Logger(r.Context()).Warn("Categories POST: invalid form data", "err", err)
+			respond.BadRequest(w, r, "Invalid form data")
+			return
+		}
+
+		category := models.Category{
+			Name:        r.FormValue("name"),
+			Description: r.FormValue("description"),
+		}
+
+		if err := h.categoriesService.CreateCategory(r.Context(), &category); err != nil {
+			writeCategoryError(w, r, "Categories POST", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"message":  "Category created successfully",
+			"category": category,
+		})
+
+	default:
+		utils.Logger(r.Context()).Warn("Categories: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+	}
+}
+
+// Category handles the legacy /admin/categories/ subtree:
+//
+//	POST  /admin/categories/reorder       ids=3&ids=1&ids=2
+//	PATCH /admin/categories/{id}          name=...&description=...
+//	POST  /admin/categories/{id}/archive
+//	POST  /admin/categories/{id}/restore
+//
+// Under /api/v1 reordering is PATCH /api/v1/admin/categories, the rest keeps
+// its path.
+func (h *CategoriesHandler) Category(w http.ResponseWriter, r *http.Request) {
+	const prefix = "/admin/categories/"
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+	r.SetPathValue("id", parts[0])
+
+	switch {
+	case len(parts) == 1 && parts[0] == "reorder":
+		h.ReorderCategories(w, r)
+	case len(parts) == 1 && parts[0] != "":
+		h.UpdateCategory(w, r)
+	case len(parts) == 2 && parts[1] == "archive":
+		h.ArchiveCategory(w, r)
+	case len(parts) == 2 && parts[1] == "restore":
+		h.RestoreCategory(w, r)
+	default:
+		utils.Logger(r.Context()).Warn("Category: unknown path", "path", r.URL.Path)
+		respond.NotFound(w, r, "Page not found")
+	}
+}
+
+// UpdateCategory renames a category or changes its description
+func (h *CategoriesHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch && r.Method != http.MethodPut {
+		utils.Logger(r.Context()).Warn("UpdateCategory: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		utils.Logger(r.Context()).Warn("UpdateCategory: invalid form data", "err", err)
+		respond.BadRequest(w, r, "Invalid form data")
+		return
+	}
+
+	// An explicitly empty description clears it, a missing one keeps it
+	_, setDescription := r.PostForm["description"]
+
+	category, err := h.categoriesService.UpdateCategory(r.Context(), r.PathValue("id"), r.PostFormValue("name"), r.PostFormValue("description"), setDescription)
+	if err != nil {
+		writeCategoryError(w, r, "UpdateCategory", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Category updated successfully",
+		"category": category,
+	})
+}
+
+// ArchiveCategory hides a category from the post form and the sidebar
+func (h *CategoriesHandler) ArchiveCategory(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+// RestoreCategory brings an archived category back
+func (h *CategoriesHandler) RestoreCategory(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
+
+func (h *CategoriesHandler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	if r.Method != http.MethodPost {
+		utils.Logger(r.Context()).Warn("ArchiveCategory: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	category, err := h.categoriesService.SetCategoryArchived(r.Context(), r.PathValue("id"), archived)
+	if err != nil {
+		writeCategoryError(w, r, "ArchiveCategory", err)
+		return
+	}
+
+	message := "Category restored successfully"
+	if archived {
+		message = "Category archived successfully"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  message,
+		"category": category,
+	})
+}
+
+// ReorderCategories sets the display order from ids=3&ids=1&ids=2
+func (h *CategoriesHandler) ReorderCategories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPatch {
+		utils.Logger(r.Context()).Warn("ReorderCategories: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		utils.Logger(r.Context()).Warn("ReorderCategories: invalid form data", "err", err)
+		respond.BadRequest(w, r, "Invalid form data")
+		return
+	}
+
+	if err := h.categoriesService.ReorderCategories(r.Context(), r.PostForm["ids"]); err != nil {
+		writeCategoryError(w, r, "ReorderCategories", err)
+		return
+	}
+
+	categories, err := h.categoriesService.GetAllCategoriesWithArchived(r.Context())
+	if err != nil {
+		utils.Logger(r.Context()).Error("ReorderCategories: failed to fetch categories", "err", err)
+		respond.Internal(w, r, "Failed to fetch categories")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"message":    "Categories reordered successfully",
+		"categories": categories,
+	})
+}
+
+// Subscriptions handles /subscriptions for the current user: GET lists the
+// subscribed categories, POST subscribes to category_id and DELETE
+// unsubscribes from it.
+func (h *CategoriesHandler) Subscriptions(w http.ResponseWriter, r *http.Request) {
+	user := utils.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.Logger(r.Context()).Warn("Subscriptions: no user found in context")
+		respond.Unauthorized(w, r, "Not logged in")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		categories, err := h.categoriesService.GetSubscribedCategories(r.Context(), user.ID)
+		if err != nil {
+			utils.Logger(r.Context()).Error("Subscriptions GET: failed to fetch subscriptions", "err", err)
+			respond.Internal(w, r, "Failed to fetch subscriptions")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"categories": categories,
+		})
+
+	case http.MethodPost, http.MethodDelete:
+		if err := r.ParseForm(); err != nil {
+			utils.Logger(r.Context()).Warn("Subscriptions: invalid form data", "err", err)
+			respond.BadRequest(w, r, "Invalid form data")
+			return
+		}
+
+		categoryID := strings.TrimSpace(r.FormValue("category_id"))
+		if categoryID == "" {
+			respond.BadRequest(w, r, "Category ID is required")
+			return
+		}
+
+		var err error
+		message := "Subscribed successfully"
+		if r.Method == http.MethodPost {
+			err = h.categoriesService.Subscribe(r.Context(), user.ID, categoryID)
+		} else {
+			message = "Unsubscribed successfully"
+			err = h.categoriesService.Unsubscribe(r.Context(), user.ID, categoryID)
+		}
+		if err != nil {
+			writeCategoryError(w, r, "Subscriptions", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": message,
+		})
+
+	default:
+		utils.Logger(r.Context()).Warn("Subscriptions: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+	}
+}
+
+// writeCategoryError maps category service errors to HTTP responses
+func writeCategoryError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	utils.Logger(r.Context()).Warn(op, "err", err)
+	respond.Error(w, r, err, "Failed to update categories")
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"real-time-forum/models"
+	"real-time-forum/services"
+	"strings"
+	"testing"
+)
+
+func newCategoriesFixture() (*CategoriesHandler, *fakeCategoriesService) {
+	categories := &fakeCategoriesService{categories: []models.Category{{ID: "1", Name: "General Discussion"}}}
+	return NewCategoriesHandler(categories), categories
+}
+
+func formRequest(method, target string, form url.Values) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestAdminCategories(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		form   url.Values
+		err    error
+		want   int
+		call   string
+	}{
+		{"list", http.MethodGet, "/admin/categories", nil, nil, http.StatusOK, ""},
+		{"create", http.MethodPost, "/admin/categories", url.Values{"name": {"Games"}, "description": {"Board"}}, nil, http.StatusCreated, "create:Games:Board"},
+		{"create invalid", http.MethodPost, "/admin/categories", url.Values{"name": {""}}, services.ErrInvalidCategory, http.StatusBadRequest, "create::"},
+		{"create taken", http.MethodPost, "/admin/categories", url.Values{"name": {"Sports"}}, services.ErrCategoryExists, http.StatusConflict, "create:Sports:"},
+		{"wrong method", http.MethodDelete, "/admin/categories", nil, nil, http.StatusMethodNotAllowed, ""},
+		{"rename keeps description", http.MethodPatch, "/admin/categories/3", url.Values{"name": {"Tunes"}}, nil, http.StatusOK, "update:3:Tunes::false"},
+		{"clear description", http.MethodPatch, "/admin/categories/3", url.Values{"description": {""}}, nil, http.StatusOK, "update:3:::true"},
+		{"update missing", http.MethodPatch, "/admin/categories/99", url.Values{"name": {"x"}}, services.ErrCategoryNotFound, http.StatusNotFound, "update:99:x::false"},
+		{"archive", http.MethodPost, "/admin/categories/3/archive", nil, nil, http.StatusOK, "archive:3"},
+		{"restore", http.MethodPost, "/admin/categories/3/restore", nil, nil, http.StatusOK, "restore:3"},
+		{"archive by GET", http.MethodGet, "/admin/categories/3/archive", nil, nil, http.StatusMethodNotAllowed, ""},
+		{"reorder", http.MethodPost, "/admin/categories/reorder", url.Values{"ids": {"3", "1"}}, nil, http.StatusOK, "reorder:3,1"},
+		{"unknown path", http.MethodPost, "/admin/categories/3/delete", nil, nil, http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h, categories := newCategoriesFixture()
+			categories.err = c.err
+			r := asUser(formRequest(c.method, c.path, c.form), &models.User{ID: "admin", Role: models.RoleAdmin})
+
+			w := httptest.NewRecorder()
+			if c.path == "/admin/categories" {
+				h.Categories(w, r)
+			} else {
+				h.Category(w, r)
+			}
+
+			if w.Code != c.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, c.want, w.Body)
+			}
+			if got := strings.Join(categories.calls, " "); got != c.call {
+				t.Errorf("calls = %q, want %q", got, c.call)
+			}
+		})
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		target string
+		form   url.Values
+		err    error
+		want   int
+		call   string
+	}{
+		{"list", http.MethodGet, "/subscriptions", nil, nil, http.StatusOK, ""},
+		{"subscribe", http.MethodPost, "/subscriptions", url.Values{"category_id": {"2"}}, nil, http.StatusOK, "subscribe:u1:2"},
+		{"subscribe archived", http.MethodPost, "/subscriptions", url.Values{"category_id": {"7"}}, services.ErrCategoryNotFound, http.StatusNotFound, "subscribe:u1:7"},
+		{"missing category", http.MethodPost, "/subscriptions", nil, nil, http.StatusBadRequest, ""},
+		{"unsubscribe", http.MethodDelete, "/subscriptions?category_id=2", nil, nil, http.StatusOK, "unsubscribe:u1:2"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h, categories := newCategoriesFixture()
+			categories.err = c.err
+			r := asUser(formRequest(c.method, c.target, c.form), &models.User{ID: "u1"})
+
+			w := httptest.NewRecorder()
+			h.Subscriptions(w, r)
+
+			if w.Code != c.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, c.want, w.Body)
+			}
+			if got := strings.Join(categories.calls, " "); got != c.call {
+				t.Errorf("calls = %q, want %q", got, c.call)
+			}
+		})
+	}
+}
@@ -2,9 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"real-time-forum/models"
+	"real-time-forum/respond"
 	"real-time-forum/services"
 	"real-time-forum/utils"
 	"strings"
@@ -26,66 +26,58 @@ func NewCommentsHandler(ps services.PostService, coms services.CommentsService,
 	}
 }
 
+// CreateComment handles /post/createcomment, the legacy alias of
+// POST /api/v1/posts/{id}/comments that takes the post ID as a form value
 func (h *CommentsHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		log.Printf("CreateComment: invalid method %s", r.Method)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		utils.Logger(r.Context()).Warn("CreateComment: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
 		return
 	}
 
+	r.SetPathValue("id", r.FormValue("post_id"))
+	h.AddComment(w, r)
+}
+
+// AddComment comments on the post in the path
+func (h *CommentsHandler) AddComment(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
 	user := utils.GetUserFromContext(r.Context())
 	if user == nil {
-		log.Printf("CreateComment: no user found in context")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Not logged in"})
+		utils.Logger(r.Context()).Warn("AddComment: no user found in context")
+		respond.Unauthorized(w, r, "Not logged in")
 		return
 	}
 
-
-	log.Printf("CreateComment: authenticated user ID='%s', Nickname='%s'", user.ID, user.Nickname)
-
 	// Parse multipart form data
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
 		// If multipart parsing fails, try regular form parsing
 		if err := r.ParseForm(); err != nil {
-			log.Printf("CreateComment: invalid form data: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid form data"})
+			utils.Logger(r.Context()).Warn("AddComment: invalid form data", "err", err)
+			respond.BadRequest(w, r, "Invalid form data")
 			return
 		}
 	}
 
 	comment_input := r.FormValue("comment")
-	postIDStr := r.FormValue("post_id")
-
-	log.Printf("CreateComment: received comment='%s', post_id='%s'", comment_input, postIDStr)
+	postIDStr := r.PathValue("id")
 
 	if strings.TrimSpace(comment_input) == "" {
-		log.Printf("CreateComment: comment cannot be empty")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Comment cannot be empty"})
+		utils.Logger(r.Context()).Warn("AddComment: comment cannot be empty")
+		respond.BadRequest(w, r, "Comment cannot be empty")
 		return
 	}
 
 	comment := models.Comment{
-		PostID:   postIDStr,
-		AuthorID: user.ID,
-		Content:  comment_input,
+		PostID:     postIDStr,
+		AuthorID:   user.ID,
+		AuthorName: user.Nickname,
+		Content:    comment_input,
 	}
 
-	log.Printf("CreateComment: creating comment with AuthorID='%s', PostID='%s', Content='%s'", user.ID, postIDStr, comment_input)
-
 	if err := h.commentService.CreateComment(r.Context(), &comment); err != nil {
-		log.Printf("CreateComment: failed to create comment: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create comment"})
+		utils.Logger(r.Context()).Warn("AddComment: failed to create comment", "err", err)
+		respond.Error(w, r, err, "Failed to create comment")
 		return
 	}
 
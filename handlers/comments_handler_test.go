@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"real-time-forum/models"
+	"real-time-forum/services"
+	"testing"
+)
+
+func newCommentsFixture() (*CommentsHandler, *fakeCommentsService) {
+	comments := &fakeCommentsService{}
+	return NewCommentsHandler(&fakePostService{}, comments, &fakeCategoriesService{}, &fakeUserService{}), comments
+}
+
+func TestCreateComment(t *testing.T) {
+	handler, comments := newCommentsFixture()
+	r := asUser(postForm("/post/createcomment", url.Values{"post_id": {"p1"}, "comment": {"Nice post"}}), &models.User{ID: "u1", Nickname: "alice"})
+
+	w := httptest.NewRecorder()
+	handler.CreateComment(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body)
+	}
+	created := comments.created
+	if created.PostID != "p1" || created.AuthorID != "u1" || created.AuthorName != "alice" || created.Content != "Nice post" {
+		t.Errorf("created %+v", created)
+	}
+	comment, _ := decodeBody(t, w.Body)["comment"].(map[string]interface{})
+	if comment["ID"] != "c-new" {
+		t.Errorf("response comment = %v", comment)
+	}
+}
+
+func TestAddComment(t *testing.T) {
+	handler, comments := newCommentsFixture()
+	r := asUser(postForm("/api/v1/posts/p1/comments", url.Values{"comment": {"Nice post"}}), &models.User{ID: "u1", Nickname: "alice"})
+	r.SetPathValue("id", "p1")
+
+	w := httptest.NewRecorder()
+	handler.AddComment(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body)
+	}
+	if comments.created.PostID != "p1" {
+		t.Errorf("created %+v", comments.created)
+	}
+}
+
+func TestCreateCommentErrors(t *testing.T) {
+	user := &models.User{ID: "u1", Nickname: "alice"}
+	valid := url.Values{"post_id": {"p1"}, "comment": {"Nice post"}}
+
+	cases := []struct {
+		name      string
+		request   *http.Request
+		createErr error
+		want      int
+	}{
+		{"wrong method", asUser(httptest.NewRequest(http.MethodGet, "/post/createcomment", nil), user), nil, http.StatusMethodNotAllowed},
+		{"not logged in", postForm("/post/createcomment", valid), nil, http.StatusUnauthorized},
+		{"empty comment", asUser(postForm("/post/createcomment", url.Values{"post_id": {"p1"}, "comment": {"  "}}), user), nil, http.StatusBadRequest},
+		{"content filter", asUser(postForm("/post/createcomment", valid), user), &services.ContentRejectedError{Filter: "links", Reason: "too many links"}, http.StatusUnprocessableEntity},
+		{"service failure", asUser(postForm("/post/createcomment", valid), user), errors.New("database is locked"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			handler, comments := newCommentsFixture()
+			comments.createErr = c.createErr
+
+			w := httptest.NewRecorder()
+			handler.CreateComment(w, c.request)
+
+			if w.Code != c.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, c.want, w.Body)
+			}
+		})
+	}
+}
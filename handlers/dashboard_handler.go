@@ -2,8 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
+	"real-time-forum/models"
+	"real-time-forum/respond"
 	"real-time-forum/services"
 	"real-time-forum/utils"
 	"strings"
@@ -23,96 +24,129 @@ func NewDashboardHandler(ps services.PostService, cs services.CategoriesService,
 	}
 }
 
+// Home handles / and /dashboard, the legacy aliases of GET /api/v1/posts
 func (h *DashboardHandler) Home(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		log.Printf("Home: invalid method %s", r.Method)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		utils.Logger(r.Context()).Warn("Home: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
 		return
 	}
 
 	// Accept both "/" and "/dashboard" paths
 	if r.URL.Path != "/" && r.URL.Path != "/dashboard" {
-		log.Printf("Home: invalid path %s", r.URL.Path)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Page not found"})
+		utils.Logger(r.Context()).Warn("Home: invalid path", "path", r.URL.Path)
+		respond.NotFound(w, r, "Page not found")
 		return
 	}
 
-	posts, err := h.postService.GetAllPosts(r.Context())
-	if err != nil {
-		log.Printf("Home: failed to fetch posts: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch posts"})
-		return
-	}
+	h.ListPosts(w, r)
+}
 
+// ListPosts returns the feed picked by ?feed=, all posts by default
+func (h *DashboardHandler) ListPosts(w http.ResponseWriter, r *http.Request) {
 	// Try to get user from session, but don't fail if not found
 	user := utils.GetUserFromContext(r.Context())
 
+	var posts []models.Post
+	var err error
+
+	feed := r.URL.Query().Get("feed")
+	switch feed {
+	case "", "all":
+		feed = "all"
+		posts, err = h.postService.GetAllPosts(r.Context())
+	case "subscribed":
+		if user == nil {
+			utils.Logger(r.Context()).Warn("ListPosts: subscribed feed requested without a user")
+			respond.Unauthorized(w, r, "Unauthorized")
+			return
+		}
+		posts, err = h.postService.GetSubscribedPosts(r.Context(), user.ID)
+	default:
+		utils.Logger(r.Context()).Warn("ListPosts: invalid feed", "feed", feed)
+		respond.BadRequest(w, r, "Invalid feed")
+		return
+	}
+	if err != nil {
+		utils.Logger(r.Context()).Error("ListPosts: failed to fetch posts", "err", err)
+		respond.Internal(w, r, "Failed to fetch posts")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"user":  user,
+		"feed":  feed,
 		"posts": posts,
 	})
 }
 
+// PostsByCategory handles /category/{id}, the legacy alias of
+// GET /api/v1/categories/{id}/posts
 func (h *DashboardHandler) PostsByCategory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		log.Printf("PostsByCategory: invalid method %s", r.Method)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
-		return
-	}
-
-	categories, err := h.categoriesService.GetAllCategories(r.Context())
-	if err != nil {
-		log.Printf("PostsByCategory: failed to fetch categories: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch categories"})
+		utils.Logger(r.Context()).Warn("PostsByCategory: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
 		return
 	}
 
 	const prefix = "/category/"
 	path := r.URL.Path
 	if !strings.HasPrefix(path, prefix) {
-		log.Printf("PostsByCategory: path doesn't have category prefix: %s", path)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Page not found"})
+		utils.Logger(r.Context()).Warn("PostsByCategory: path doesn't have category prefix", "path", path)
+		respond.NotFound(w, r, "Page not found")
+		return
+	}
+
+	r.SetPathValue("id", strings.TrimPrefix(path, prefix))
+	h.CategoryPosts(w, r)
+}
+
+// CategoryPosts returns the posts filed under a category, along with the
+// category list for the sidebar
+func (h *DashboardHandler) CategoryPosts(w http.ResponseWriter, r *http.Request) {
+	// Try to get user from session header, but don't fail if not found
+	user := utils.GetUserFromContext(r.Context())
+	userID := ""
+	if user != nil {
+		userID = user.ID
+	}
+
+	categories, err := h.categoriesService.GetCategoriesForUser(r.Context(), userID)
+	if err != nil {
+		utils.Logger(r.Context()).Error("CategoryPosts: failed to fetch categories", "err", err)
+		respond.Internal(w, r, "Failed to fetch categories")
 		return
 	}
-	categoryID := strings.TrimPrefix(path, prefix)
+
+	categoryID := r.PathValue("id")
 	if categoryID == "" {
-		log.Printf("PostsByCategory: empty category ID")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid category ID"})
+		utils.Logger(r.Context()).Warn("CategoryPosts: empty category ID")
+		respond.BadRequest(w, r, "Invalid category ID")
 		return
 	}
 
-	posts, err := h.postService.GetPostsByCategory(r.Context(), categoryID)
+	// Archived categories stay browsable, only unknown IDs are rejected
+	category, err := h.categoriesService.GetCategoryForUser(r.Context(), categoryID, userID)
 	if err != nil {
-		log.Printf("PostsByCategory: failed to fetch posts for category %s: %v", categoryID, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch posts"})
+		utils.Logger(r.Context()).Warn("CategoryPosts: failed to fetch category", "category_id", categoryID, "err", err)
+		respond.Error(w, r, err, "Failed to fetch categories")
 		return
 	}
 
-	// Try to get user from session header, but don't fail if not found
-	user := utils.GetUserFromContext(r.Context())
+	posts, err := h.postService.GetPostsByCategory(r.Context(), categoryID)
+	if err != nil {
+		utils.Logger(r.Context()).Error("CategoryPosts: failed to fetch posts for category", "category_id", categoryID, "err", err)
+		respond.Internal(w, r, "Failed to fetch posts")
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"user":       user,
+		"category":   category,
 		"posts":      posts,
 		"categories": categories,
 	})
@@ -120,10 +154,8 @@ func (h *DashboardHandler) PostsByCategory(w http.ResponseWriter, r *http.Reques
 
 func (h *DashboardHandler) UserPosts(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		log.Printf("UserPosts: invalid method %s", r.Method)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		utils.Logger(r.Context()).Warn("UserPosts: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
 		return
 	}
 
@@ -131,10 +163,8 @@ func (h *DashboardHandler) UserPosts(w http.ResponseWriter, r *http.Request) {
 
 	posts, err := h.postService.GetUserPosts(r.Context(), user.ID)
 	if err != nil {
-		log.Printf("UserPosts: failed to fetch user posts: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch user posts"})
+		utils.Logger(r.Context()).Error("UserPosts: failed to fetch user posts", "err", err)
+		respond.Internal(w, r, "Failed to fetch user posts")
 		return
 	}
 
@@ -146,33 +176,26 @@ func (h *DashboardHandler) UserPosts(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DashboardHandler) AllUsers(w http.ResponseWriter, r *http.Request) {
-	log.Printf("AllUsers endpoint called")
 	if r.Method != http.MethodGet {
-		log.Printf("AllUsers: invalid method %s", r.Method)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		utils.Logger(r.Context()).Warn("AllUsers: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
 		return
 	}
 
 	sessionID := r.Header.Get("X-Session-ID")
 	if sessionID == "" {
-		log.Printf("AllUsers: missing session ID")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+		utils.Logger(r.Context()).Warn("AllUsers: missing session ID")
+		respond.Unauthorized(w, r, "Unauthorized")
 		return
 	}
 
 	allUsers, err := h.userService.GetAllUsers(r.Context())
 	if err != nil {
-		log.Printf("AllUsers: failed to fetch all users: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch all users"})
+		utils.Logger(r.Context()).Error("AllUsers: failed to fetch all users", "err", err)
+		respond.Internal(w, r, "Failed to fetch all users")
 		return
 	}
-	log.Printf("AllUsers: retrieved %d users: %v", len(allUsers), allUsers)
+	utils.Logger(r.Context()).Debug("AllUsers: retrieved users", "count", len(allUsers))
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"real-time-forum/models"
+	"testing"
+)
+
+func newDashboardFixture() (*DashboardHandler, *fakePostService) {
+	posts := &fakePostService{posts: []models.Post{{ID: "p1"}, {ID: "p2"}}}
+	categories := &fakeCategoriesService{categories: []models.Category{{ID: "1", Name: "General Discussion"}}}
+	users := &fakeUserService{users: []models.User{{ID: "u1", Nickname: "alice"}}}
+	return NewDashboardHandler(posts, categories, users), posts
+}
+
+func TestHomeFeeds(t *testing.T) {
+	user := &models.User{ID: "u1", Nickname: "alice"}
+	cases := []struct {
+		target   string
+		user     *models.User
+		want     int
+		wantCall string
+	}{
+		{"/dashboard", nil, http.StatusOK, "all"},
+		{"/?feed=all", user, http.StatusOK, "all"},
+		{"/dashboard?feed=subscribed", user, http.StatusOK, "subscribed:u1"},
+		{"/dashboard?feed=subscribed", nil, http.StatusUnauthorized, ""},
+		{"/dashboard?feed=popular", user, http.StatusBadRequest, ""},
+		{"/elsewhere", user, http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.target, func(t *testing.T) {
+			handler, posts := newDashboardFixture()
+			r := httptest.NewRequest(http.MethodGet, c.target, nil)
+			if c.user != nil {
+				r = asUser(r, c.user)
+			}
+
+			w := httptest.NewRecorder()
+			handler.Home(w, r)
+
+			if w.Code != c.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, c.want, w.Body)
+			}
+			if c.wantCall == "" {
+				if len(posts.calls) != 0 {
+					t.Errorf("posts were fetched: %v", posts.calls)
+				}
+				return
+			}
+			if len(posts.calls) != 1 || posts.calls[0] != c.wantCall {
+				t.Errorf("calls = %v, want %s", posts.calls, c.wantCall)
+			}
+			if got, _ := decodeBody(t, w.Body)["posts"].([]interface{}); len(got) != 2 {
+				t.Errorf("posts = %v", got)
+			}
+		})
+	}
+}
+
+func TestPostsByCategory(t *testing.T) {
+	handler, posts := newDashboardFixture()
+	w := httptest.NewRecorder()
+	handler.PostsByCategory(w, httptest.NewRequest(http.MethodGet, "/category/1", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if len(posts.calls) != 1 || posts.calls[0] != "category:1" {
+		t.Errorf("calls = %v", posts.calls)
+	}
+	category, _ := decodeBody(t, w.Body)["category"].(map[string]interface{})
+	if category["Name"] != "General Discussion" {
+		t.Errorf("category = %v", category)
+	}
+
+	for target, want := range map[string]int{
+		"/category/":   http.StatusBadRequest,
+		"/category/99": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		handler.PostsByCategory(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != want {
+			t.Errorf("GET %s: status = %d, want %d", target, w.Code, want)
+		}
+	}
+}
+
+func TestCategoryPosts(t *testing.T) {
+	handler, posts := newDashboardFixture()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/categories/1/posts", nil)
+	r.SetPathValue("id", "1")
+
+	w := httptest.NewRecorder()
+	handler.CategoryPosts(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if len(posts.calls) != 1 || posts.calls[0] != "category:1" {
+		t.Errorf("calls = %v", posts.calls)
+	}
+}
+
+func TestUserPosts(t *testing.T) {
+	handler, posts := newDashboardFixture()
+	w := httptest.NewRecorder()
+	handler.UserPosts(w, asUser(httptest.NewRequest(http.MethodGet, "/dashboard/my-posts", nil), &models.User{ID: "u1"}))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if len(posts.calls) != 1 || posts.calls[0] != "user:u1" {
+		t.Errorf("calls = %v", posts.calls)
+	}
+}
+
+func TestAllUsers(t *testing.T) {
+	handler, _ := newDashboardFixture()
+
+	w := httptest.NewRecorder()
+	handler.AllUsers(w, httptest.NewRequest(http.MethodGet, "/dashboard/all-users", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("without a session header: status = %d, want 401", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/dashboard/all-users", nil)
+	r.Header.Set("X-Session-ID", "s1")
+	w = httptest.NewRecorder()
+	handler.AllUsers(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if users, _ := decodeBody(t, w.Body)["users"].([]interface{}); len(users) != 1 {
+		t.Errorf("users = %v", users)
+	}
+}
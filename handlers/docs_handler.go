@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+	"real-time-forum/apispec"
+)
+
+// DocsHandler serves the API contract
+type DocsHandler struct{}
+
+func NewDocsHandler() *DocsHandler {
+	return &DocsHandler{}
+}
+
+// OpenAPI handles GET /api/v1/openapi.json
+func (h *DocsHandler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(apispec.OpenAPI())
+}
+
+// WebSocketSchema handles GET /api/v1/schemas/websocket.json
+func (h *DocsHandler) WebSocketSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(apispec.WebSocketSchema())
+}
@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"real-time-forum/models"
+	"real-time-forum/services"
+	"real-time-forum/utils"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The fakes embed the service interface they stand in for, so a test that
+// reaches a method the fake does not implement panics instead of passing by
+// accident.
+
+type fakeAuthService struct {
+	services.AuthService
+	registerErr error
+	registered  *models.User
+	user        *models.User
+	loginErr    error
+	loginInput  *models.User
+}
+
+func (f *fakeAuthService) Register(ctx context.Context, user *models.User) error {
+	f.registered = user
+	return f.registerErr
+}
+
+func (f *fakeAuthService) LoginUser(ctx context.Context, input *models.User) (*models.User, error) {
+	f.loginInput = input
+	return f.user, f.loginErr
+}
+
+type fakeSessionService struct {
+	services.SessionService
+	validateErr error
+	expired     []string
+}
+
+func (f *fakeSessionService) GenerateSession(ctx context.Context, user *models.User) (models.Session, error) {
+	now := time.Now()
+	return models.Session{ID: "session-" + user.ID, UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, nil
+}
+
+func (f *fakeSessionService) ExpireSession(ctx context.Context, userID string) error {
+	f.expired = append(f.expired, userID)
+	return nil
+}
+
+func (f *fakeSessionService) ValidateSession(ctx context.Context, sessionID string) error {
+	return f.validateErr
+}
+
+type fakeSanctionService struct {
+	services.SanctionService
+	ban      *models.Sanction
+	sanction *models.Sanction
+	err      error
+}
+
+func (f *fakeSanctionService) ActiveBan(ctx context.Context, userID string) (*models.Sanction, error) {
+	return f.ban, nil
+}
+
+func (f *fakeSanctionService) SanctionUser(ctx context.Context, moderator *models.User, identifier, sanctionType string, duration time.Duration, reason string) (*models.Sanction, error) {
+	return f.sanction, f.err
+}
+
+func (f *fakeSanctionService) RevokeSanction(ctx context.Context, moderator *models.User, sanctionID string) (*models.Sanction, error) {
+	return f.sanction, f.err
+}
+
+func (f *fakeSanctionService) ListSanctions(ctx context.Context, identifier string, filter models.SanctionFilter) ([]models.Sanction, error) {
+	return []models.Sanction{*f.sanction}, f.err
+}
+
+type fakeAuditService struct {
+	services.AuditService
+	events []models.AuditEvent
+}
+
+func (f *fakeAuditService) Record(ctx context.Context, event models.AuditEvent) {
+	f.events = append(f.events, event)
+}
+
+func (f *fakeAuditService) ListEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	return f.events, nil
+}
+
+type fakeUserService struct {
+	services.UserService
+	users   []models.User
+	roleErr error
+}
+
+func (f *fakeUserService) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	return f.users, nil
+}
+
+func (f *fakeUserService) SetUserRole(ctx context.Context, identifier, role string) (*models.User, error) {
+	if f.roleErr != nil {
+		return nil, f.roleErr
+	}
+	return &models.User{ID: "u2", Nickname: identifier, Email: identifier + "@example.com", Role: role}, nil
+}
+
+type fakePostService struct {
+	services.PostService
+	posts      []models.Post
+	createErr  error
+	created    *models.Post
+	createdBy  *models.User
+	categories []string
+	// calls records the list queries made, e.g. "subscribed:u1"
+	calls []string
+}
+
+func (f *fakePostService) CreatePost(ctx context.Context, user *models.User, post *models.Post, categoryIDs []string) error {
+	f.created, f.createdBy, f.categories = post, user, categoryIDs
+	if f.createErr != nil {
+		return f.createErr
+	}
+	post.ID = "p-new"
+	return nil
+}
+
+func (f *fakePostService) GetAllPosts(ctx context.Context) ([]models.Post, error) {
+	f.calls = append(f.calls, "all")
+	return f.posts, nil
+}
+
+func (f *fakePostService) GetPostByID(ctx context.Context, postID string) (*models.Post, error) {
+	for i := range f.posts {
+		if f.posts[i].ID == postID {
+			return &f.posts[i], nil
+		}
+	}
+	return nil, services.ErrPostNotFound
+}
+
+func (f *fakePostService) GetPostsByCategory(ctx context.Context, categoryID string) ([]models.Post, error) {
+	f.calls = append(f.calls, "category:"+categoryID)
+	return f.posts, nil
+}
+
+func (f *fakePostService) GetUserPosts(ctx context.Context, userID string) ([]models.Post, error) {
+	f.calls = append(f.calls, "user:"+userID)
+	return f.posts, nil
+}
+
+func (f *fakePostService) GetSubscribedPosts(ctx context.Context, userID string) ([]models.Post, error) {
+	f.calls = append(f.calls, "subscribed:"+userID)
+	return f.posts, nil
+}
+
+type fakeCommentsService struct {
+	services.CommentsService
+	comments  []models.Comment
+	createErr error
+	created   *models.Comment
+}
+
+func (f *fakeCommentsService) CreateComment(ctx context.Context, comment *models.Comment) error {
+	f.created = comment
+	if f.createErr != nil {
+		return f.createErr
+	}
+	comment.ID = "c-new"
+	return nil
+}
+
+func (f *fakeCommentsService) GetPostComments(ctx context.Context, postID string) ([]models.Comment, error) {
+	return f.comments, nil
+}
+
+type fakeCategoriesService struct {
+	services.CategoriesService
+	categories  []models.Category
+	validateErr error
+	// err fails the admin and subscription calls, which are recorded in
+	// calls, e.g. "archive:3" or "update:3:name:desc:true"
+	err   error
+	calls []string
+}
+
+func (f *fakeCategoriesService) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	return f.categories, nil
+}
+
+func (f *fakeCategoriesService) GetCategoriesForUser(ctx context.Context, userID string) ([]models.Category, error) {
+	return f.categories, nil
+}
+
+func (f *fakeCategoriesService) GetCategoryForUser(ctx context.Context, categoryID, userID string) (*models.Category, error) {
+	for i := range f.categories {
+		if f.categories[i].ID == categoryID {
+			return &f.categories[i], nil
+		}
+	}
+	return nil, services.ErrCategoryNotFound
+}
+
+func (f *fakeCategoriesService) ValidateCategoryIDs(ctx context.Context, categoryIDs []string) error {
+	return f.validateErr
+}
+
+func (f *fakeCategoriesService) GetAllCategoriesWithArchived(ctx context.Context) ([]models.Category, error) {
+	return f.categories, nil
+}
+
+func (f *fakeCategoriesService) GetSubscribedCategories(ctx context.Context, userID string) ([]models.Category, error) {
+	return f.categories, nil
+}
+
+func (f *fakeCategoriesService) CreateCategory(ctx context.Context, category *models.Category) error {
+	f.calls = append(f.calls, "create:"+category.Name+":"+category.Description)
+	if f.err != nil {
+		return f.err
+	}
+	category.ID = "8"
+	return nil
+}
+
+func (f *fakeCategoriesService) UpdateCategory(ctx context.Context, categoryID, name, description string, setDescription bool) (*models.Category, error) {
+	f.calls = append(f.calls, fmt.Sprintf("update:%s:%s:%s:%t", categoryID, name, description, setDescription))
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.Category{ID: categoryID, Name: name, Description: description}, nil
+}
+
+func (f *fakeCategoriesService) SetCategoryArchived(ctx context.Context, categoryID string, archived bool) (*models.Category, error) {
+	op := "restore:"
+	if archived {
+		op = "archive:"
+	}
+	f.calls = append(f.calls, op+categoryID)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.Category{ID: categoryID, Archived: archived}, nil
+}
+
+func (f *fakeCategoriesService) ReorderCategories(ctx context.Context, categoryIDs []string) error {
+	f.calls = append(f.calls, "reorder:"+strings.Join(categoryIDs, ","))
+	return f.err
+}
+
+func (f *fakeCategoriesService) Subscribe(ctx context.Context, userID, categoryID string) error {
+	f.calls = append(f.calls, "subscribe:"+userID+":"+categoryID)
+	return f.err
+}
+
+func (f *fakeCategoriesService) Unsubscribe(ctx context.Context, userID, categoryID string) error {
+	f.calls = append(f.calls, "unsubscribe:"+userID+":"+categoryID)
+	return f.err
+}
+
+type fakeMediaService struct {
+	services.MediaService
+	err     error
+	removed []string
+}
+
+func (f *fakeMediaService) SaveImage(ctx context.Context, r io.Reader) (string, bool, error) {
+	if f.err != nil {
+		return "", false, f.err
+	}
+	return "ab/cdef.png", true, nil
+}
+
+func (f *fakeMediaService) RemoveImage(ctx context.Context, key string) {
+	f.removed = append(f.removed, key)
+}
+
+// fakeChatService hands the frames it gets to the test through channels, as
+// the WebSocket pumps call it from their own goroutines
+type fakeChatService struct {
+	services.ChatService
+	blocked   bool
+	history   []models.Message
+	processed chan *models.Message
+	errors    chan string
+	// historyArgs is the limit and offset of the last history query
+	historyArgs []int
+	// blockedUsers is the block list; blockErr fails blocking and unblocking
+	blockedUsers []models.BlockedUser
+	blockErr     error
+}
+
+func (f *fakeChatService) ProcessMessage(msg *models.Message) {
+	f.processed <- msg
+}
+
+func (f *fakeChatService) SendError(username, code, content string) {
+	f.errors <- code
+}
+
+func (f *fakeChatService) IsBlocked(ctx context.Context, user1, user2 string) (bool, error) {
+	return f.blocked, nil
+}
+
+func (f *fakeChatService) BlockUser(ctx context.Context, user *models.User, nickname string) error {
+	return f.blockErr
+}
+
+func (f *fakeChatService) UnblockUser(ctx context.Context, user *models.User, nickname string) error {
+	return f.blockErr
+}
+
+func (f *fakeChatService) GetBlockedUsers(ctx context.Context, userID string) ([]models.BlockedUser, error) {
+	return f.blockedUsers, nil
+}
+
+func (f *fakeChatService) GetChatHistoryWithPagination(ctx context.Context, user1, user2 string, limit, offset int) ([]models.Message, error) {
+	f.historyArgs = []int{limit, offset}
+	if len(f.history) > limit {
+		return f.history[:limit], nil
+	}
+	return f.history, nil
+}
+
+type fakeFeedService struct {
+	services.FeedService
+	mu     sync.Mutex
+	topics []string
+}
+
+func (f *fakeFeedService) Subscribe(client *models.Client, topic string) error {
+	if topic != "post:1" {
+		return services.ErrInvalidTopic
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.topics = append(f.topics, topic)
+	return nil
+}
+
+// fakeModerationService answers every call with the same report or error
+type fakeModerationService struct {
+	services.ModerationService
+	report *models.Report
+	err    error
+}
+
+func (f *fakeModerationService) ReportContent(ctx context.Context, reporter *models.User, contentType, contentID, reason string) (*models.Report, error) {
+	return f.report, f.err
+}
+
+func (f *fakeModerationService) ListReports(ctx context.Context, filter models.ReportFilter) ([]models.Report, error) {
+	return []models.Report{*f.report}, f.err
+}
+
+func (f *fakeModerationService) GetReport(ctx context.Context, reportID string) (*models.Report, error) {
+	return f.report, f.err
+}
+
+func (f *fakeModerationService) ClaimReport(ctx context.Context, moderator *models.User, reportID string) (*models.Report, error) {
+	return f.report, f.err
+}
+
+func (f *fakeModerationService) ResolveReport(ctx context.Context, moderator *models.User, reportID, action, note string) (*models.Report, error) {
+	return f.report, f.err
+}
+
+type fakeNotificationService struct {
+	services.NotificationService
+	notifications []models.Notification
+}
+
+func (f *fakeNotificationService) ListNotifications(ctx context.Context, userID string, unreadOnly bool, limit, offset int) ([]models.Notification, int, error) {
+	return f.notifications, len(f.notifications), nil
+}
+
+func (f *fakeNotificationService) MarkRead(ctx context.Context, userID string, ids []string) (int64, int, error) {
+	return int64(len(ids)), 0, nil
+}
+
+type fakePushService struct {
+	services.PushService
+	key string
+	err error
+}
+
+func (f *fakePushService) PublicKey() (string, error) {
+	return f.key, f.err
+}
+
+func (f *fakePushService) Subscribe(ctx context.Context, userID string, sub *models.PushSubscription) error {
+	return f.err
+}
+
+func (f *fakePushService) Unsubscribe(ctx context.Context, userID, endpoint string) error {
+	return f.err
+}
+
+type fakeSearchService struct {
+	results []models.SearchResult
+	err     error
+}
+
+func (f *fakeSearchService) Search(ctx context.Context, q models.SearchQuery) ([]models.SearchResult, error) {
+	return f.results, f.err
+}
+
+// fakeSearchRepository backs the real search service in handler tests and
+// records the match expressions it is asked for
+type fakeSearchRepository struct {
+	matches []string
+}
+
+func (f *fakeSearchRepository) EnsureIndex(ctx context.Context) error { return nil }
+
+func (f *fakeSearchRepository) Search(ctx context.Context, match string, q models.SearchQuery) ([]models.SearchResult, error) {
+	f.matches = append(f.matches, match)
+	return nil, nil
+}
+
+// asUser puts the user in the request context the way AuthMiddleware does
+func asUser(r *http.Request, user *models.User) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), utils.ContextUser, user))
+}
+
+// decodeBody decodes a JSON response body into a generic map
+func decodeBody(t *testing.T, body io.Reader) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&out); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+	"real-time-forum/respond"
+	"real-time-forum/services"
+	"strings"
+)
+
+type MediaHandler struct {
+	mediaService services.MediaService
+}
+
+func NewMediaHandler(ms services.MediaService) *MediaHandler {
+	return &MediaHandler{
+		mediaService: ms,
+	}
+}
+
+// ServeMedia serves uploaded images from /media/{key}. Files are
+// content-addressed, so they never change and can be cached indefinitely.
+func (h *MediaHandler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, services.MediaURLPrefix)
+	filePath, ok := h.mediaService.MediaPath(key)
+	if !ok {
+		respond.NotFound(w, r, "Not found")
+		return
+	}
+
+	// The file name is the content hash
+	etag := strings.TrimSuffix(path.Base(key), path.Ext(key))
+
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, filePath)
+}
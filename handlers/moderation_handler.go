@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"real-time-forum/models"
+	"real-time-forum/respond"
+	"real-time-forum/services"
+	"real-time-forum/utils"
+	"strconv"
+	"strings"
+)
+
+type ModerationHandler struct {
+	moderationService services.ModerationService
+}
+
+func NewModerationHandler(ms services.ModerationService) *ModerationHandler {
+	return &ModerationHandler{
+		moderationService: ms,
+	}
+}
+
+// ReportPost handles POST /api/v1/posts/{id}/reports with reason=..., and
+// the legacy POST /report/post with id=<post id>&reason=...
+func (h *ModerationHandler) ReportPost(w http.ResponseWriter, r *http.Request) {
+	h.report(w, r, models.ReportTypePost)
+}
+
+// ReportComment handles POST /api/v1/comments/{id}/reports, and the legacy
+// POST /report/comment with id=<comment id>&reason=...
+func (h *ModerationHandler) ReportComment(w http.ResponseWriter, r *http.Request) {
+	h.report(w, r, models.ReportTypeComment)
+}
+
+// ReportMessage handles POST /api/v1/messages/{id}/reports, and the legacy
+// POST /report/message with id=<message id>&reason=...
+func (h *ModerationHandler) ReportMessage(w http.ResponseWriter, r *http.Request) {
+	h.report(w, r, models.ReportTypeMessage)
+}
+
+func (h *ModerationHandler) report(w http.ResponseWriter, r *http.Request, contentType string) {
+	if r.Method != http.MethodPost {
+		utils.Logger(r.Context()).Warn("Report: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	user := utils.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.Logger(r.Context()).Warn("Report: no user found in context")
+		respond.Unauthorized(w, r, "Not logged in")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		utils.Logger(r.Context()).Warn("Report: invalid form data", "err", err)
+		respond.BadRequest(w, r, "Invalid form data")
+		return
+	}
+
+	contentID := r.PathValue("id")
+	if contentID == "" {
+		contentID = r.FormValue("id")
+	}
+
+	report, err := h.moderationService.ReportContent(r.Context(), user, contentType, contentID, r.FormValue("reason"))
+	if err != nil {
+		writeModerationError(w, r, "Report", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Report submitted successfully",
+		"report":  report,
+	})
+}
+
+// Reports handles GET /moderation/reports, the moderator queue. Filters:
+// status=open|claimed|resolved|all (default: open and claimed),
+// type=post|comment|message, claimed=me|none, limit and offset.
+func (h *ModerationHandler) Reports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.Logger(r.Context()).Warn("Reports: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	user := utils.GetUserFromContext(r.Context())
+	query := r.URL.Query()
+
+	filter := models.ReportFilter{
+		Status:      strings.TrimSpace(query.Get("status")),
+		ContentType: strings.TrimSpace(query.Get("type")),
+		Limit:       10,
+		Offset:      0,
+	}
+
+	switch query.Get("claimed") {
+	case "":
+	case "me":
+		filter.ClaimedBy = user.ID
+	case "none":
+		filter.Unclaimed = true
+	default:
+		respond.BadRequest(w, r, "Claimed filter must be me or none")
+		return
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 50 {
+			filter.Limit = parsedLimit
+		}
+	}
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			filter.Offset = parsedOffset
+		}
+	}
+
+	reports, err := h.moderationService.ListReports(r.Context(), filter)
+	if err != nil {
+		writeModerationError(w, r, "Reports", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reports": reports,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+		"hasMore": len(reports) == filter.Limit,
+	})
+}
+
+// Report handles the legacy /moderation/reports/ subtree:
+//
+//	GET  /moderation/reports/{id}
+//	POST /moderation/reports/{id}/claim
+//	POST /moderation/reports/{id}/resolve  action=hide|delete|warn|dismiss&note=...
+//
+// The same routes live under /api/v1 with method patterns.
+func (h *ModerationHandler) Report(w http.ResponseWriter, r *http.Request) {
+	const prefix = "/moderation/reports/"
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+	r.SetPathValue("id", parts[0])
+
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		h.ViewReport(w, r)
+	case len(parts) == 2 && parts[1] == "claim":
+		h.ClaimReport(w, r)
+	case len(parts) == 2 && parts[1] == "resolve":
+		h.ResolveReport(w, r)
+	default:
+		utils.Logger(r.Context()).Warn("Report: unknown path", "path", r.URL.Path)
+		respond.NotFound(w, r, "Page not found")
+	}
+}
+
+// ViewReport returns a report with the reported content
+func (h *ModerationHandler) ViewReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.Logger(r.Context()).Warn("ViewReport: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	report, err := h.moderationService.GetReport(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeModerationError(w, r, "ViewReport", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"report": report,
+	})
+}
+
+// ClaimReport assigns a report to the current moderator
+func (h *ModerationHandler) ClaimReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.Logger(r.Context()).Warn("ClaimReport: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	user := utils.GetUserFromContext(r.Context())
+	report, err := h.moderationService.ClaimReport(r.Context(), user, r.PathValue("id"))
+	if err != nil {
+		writeModerationError(w, r, "ClaimReport", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Report claimed successfully",
+		"report":  report,
+	})
+}
+
+// ResolveReport closes a report with action=hide|delete|warn|dismiss&note=...
+func (h *ModerationHandler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.Logger(r.Context()).Warn("ResolveReport: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		utils.Logger(r.Context()).Warn("ResolveReport: invalid form data", "err", err)
+		respond.BadRequest(w, r, "Invalid form data")
+		return
+	}
+
+	user := utils.GetUserFromContext(r.Context())
+	report, err := h.moderationService.ResolveReport(r.Context(), user, r.PathValue("id"), strings.TrimSpace(r.FormValue("action")), r.FormValue("note"))
+	if err != nil {
+		writeModerationError(w, r, "ResolveReport", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Report resolved successfully",
+		"report":  report,
+	})
+}
+
+// writeModerationError maps moderation service errors to HTTP responses
+func writeModerationError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	utils.Logger(r.Context()).Warn(op, "err", err)
+	respond.Error(w, r, err, "Failed to process report")
+}
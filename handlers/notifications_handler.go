@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"real-time-forum/respond"
+	"real-time-forum/services"
+	"real-time-forum/utils"
+	"strconv"
+	"strings"
+)
+
+type NotificationsHandler struct {
+	notificationService services.NotificationService
+}
+
+func NewNotificationsHandler(ns services.NotificationService) *NotificationsHandler {
+	return &NotificationsHandler{
+		notificationService: ns,
+	}
+}
+
+// Notifications handles GET /notifications for the current user, newest
+// first. unread=1 limits the list to unread ones; limit and offset page it.
+func (h *NotificationsHandler) Notifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.Logger(r.Context()).Warn("Notifications: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	user := utils.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.Logger(r.Context()).Warn("Notifications: no user found in context")
+		respond.Unauthorized(w, r, "Not logged in")
+		return
+	}
+
+	query := r.URL.Query()
+	limit := 10
+	offset := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 50 {
+			limit = parsedLimit
+		}
+	}
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	notifications, unread, err := h.notificationService.ListNotifications(r.Context(), user.ID, query.Get("unread") == "1", limit, offset)
+	if err != nil {
+		respond.Internal(w, r, "Failed to fetch notifications")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notifications": notifications,
+		"unread":        unread,
+		"limit":         limit,
+		"offset":        offset,
+		"hasMore":       len(notifications) == limit,
+	})
+}
+
+// MarkRead handles POST /notifications/read with one or more id=<notification
+// id> values, or all=1 to mark every notification as read
+func (h *NotificationsHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.Logger(r.Context()).Warn("MarkRead: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	user := utils.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.Logger(r.Context()).Warn("MarkRead: no user found in context")
+		respond.Unauthorized(w, r, "Not logged in")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		utils.Logger(r.Context()).Warn("MarkRead: invalid form data", "err", err)
+		respond.BadRequest(w, r, "Invalid form data")
+		return
+	}
+
+	var ids []string
+	for _, id := range r.Form["id"] {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 && r.FormValue("all") != "1" {
+		respond.BadRequest(w, r, "Give at least one id or all=1")
+		return
+	}
+
+	updated, unread, err := h.notificationService.MarkRead(r.Context(), user.ID, ids)
+	if err != nil {
+		respond.Internal(w, r, "Failed to update notifications")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"updated": updated,
+		"unread":  unread,
+	})
+}
@@ -2,9 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 	"real-time-forum/models"
+	"real-time-forum/respond"
 	"real-time-forum/services"
 	"real-time-forum/utils"
 )
@@ -14,123 +15,157 @@ type PostHandler struct {
 	categoriesService services.CategoriesService
 	commentService    services.CommentsService
 	userService       services.UserService
+	mediaService      services.MediaService
 }
 
-func NewPostHandler(ps services.PostService, cs services.CategoriesService, coms services.CommentsService, us services.UserService) *PostHandler {
+func NewPostHandler(ps services.PostService, cs services.CategoriesService, coms services.CommentsService, us services.UserService, ms services.MediaService) *PostHandler {
 	return &PostHandler{
 		postService:       ps,
 		categoriesService: cs,
 		commentService:    coms,
 		userService:       us,
+		mediaService:      ms,
 	}
 }
 
+// CreatePost handles /createpost, the legacy alias of GET /api/v1/categories
+// and POST /api/v1/posts
 func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		categories, err := h.categoriesService.GetAllCategories(r.Context())
-		if err != nil {
-			log.Printf("CreatePost GET: failed to fetch categories: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch categories"})
-			return
-		}
+		h.ListCategories(w, r)
+	case http.MethodPost:
+		h.AddPost(w, r)
+	default:
+		utils.Logger(r.Context()).Warn("CreatePost: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+	}
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"categories": categories,
-		})
+// ListCategories returns the categories a post can be filed under
+func (h *PostHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.categoriesService.GetAllCategories(r.Context())
+	if err != nil {
+		utils.Logger(r.Context()).Error("ListCategories: failed to fetch categories", "err", err)
+		respond.Internal(w, r, "Failed to fetch categories")
+		return
+	}
 
-	case http.MethodPost:
-		user := utils.GetUserFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"categories": categories,
+	})
+}
 
-		if err := r.ParseMultipartForm(20 << 20); err != nil {
-			log.Printf("CreatePost: invalid form: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid form"})
-			return
-		}
+// AddPost creates a post from a multipart form with a title, content, one or
+// more categories and an optional image
+func (h *PostHandler) AddPost(w http.ResponseWriter, r *http.Request) {
+	user := utils.GetUserFromContext(r.Context())
 
-		post := models.Post{
-			Title:   r.FormValue("title"),
-			Content: r.FormValue("content"),
-		}
+	if err := r.ParseMultipartForm(20 << 20); err != nil {
+		utils.Logger(r.Context()).Warn("AddPost: invalid form", "err", err)
+		respond.BadRequest(w, r, "Invalid form")
+		return
+	}
 
-		var catIDs []string
-		catIDs = append(catIDs, r.Form["categories"]...)
+	post := models.Post{
+		Title:   r.FormValue("title"),
+		Content: r.FormValue("content"),
+	}
+
+	var catIDs []string
+	catIDs = append(catIDs, r.Form["categories"]...)
+
+	if len(catIDs) == 0 {
+		utils.Logger(r.Context()).Warn("AddPost: no categories selected")
+		respond.BadRequest(w, r, "Pick at least one category")
+		return
+	}
 
-		if len(catIDs) == 0 {
-			log.Printf("CreatePost: no categories selected")
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Pick at least one category"})
+	if err := h.categoriesService.ValidateCategoryIDs(r.Context(), catIDs); err != nil {
+		utils.Logger(r.Context()).Warn("AddPost: invalid categories", "category_ids", catIDs, "err", err)
+		if errors.Is(err, services.ErrCategoryNotFound) {
+			respond.Invalid(w, r, "categories", "Unknown category")
 			return
 		}
+		respond.Internal(w, r, "Failed to fetch categories")
+		return
+	}
+
+	// Optional image attachment
+	var newImage bool
+	file, _, err := r.FormFile("image")
+	if err == nil {
+		defer file.Close()
 
-		if err := h.postService.CreatePost(r.Context(), user, &post, catIDs); err != nil {
-			log.Printf("CreatePost: failed to create post: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create post"})
+		key, created, err := h.mediaService.SaveImage(r.Context(), file)
+		if err != nil {
+			utils.Logger(r.Context()).Warn("AddPost: failed to save image", "err", err)
+			respond.Error(w, r, err, "Failed to store image")
 			return
 		}
+		post.Image = key
+		newImage = created
+	} else if !errors.Is(err, http.ErrMissingFile) {
+		utils.Logger(r.Context()).Warn("AddPost: invalid image part", "err", err)
+		respond.BadRequest(w, r, "Invalid image upload")
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": true,
-			"message": "Post created successfully",
-			"post":    post,
-		})
-
-	default:
-		log.Printf("CreatePost: invalid method %s", r.Method)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+	if err := h.postService.CreatePost(r.Context(), user, &post, catIDs); err != nil {
+		utils.Logger(r.Context()).Warn("AddPost: failed to create post", "err", err)
+		// Don't leave behind an image no post points to
+		if newImage {
+			h.mediaService.RemoveImage(r.Context(), post.Image)
+		}
+		respond.Error(w, r, err, "Failed to create post")
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Post created successfully",
+		"post":    post,
+	})
 }
 
+// ViewPost handles /post?id=, the legacy alias of GET /api/v1/posts/{id}
 func (h *PostHandler) ViewPost(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		log.Printf("ViewPost: invalid method %s", r.Method)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		utils.Logger(r.Context()).Warn("ViewPost: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
 		return
 	}
 
+	r.SetPathValue("id", r.URL.Query().Get("id"))
+	h.GetPost(w, r)
+}
+
+// GetPost returns a post with its comments
+func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 	user := utils.GetUserFromContext(r.Context())
 
-	query := r.URL.Query()
-	postIDStr := query.Get("id")
-	log.Printf("ViewPost: received post ID: '%s'", postIDStr)
+	postIDStr := r.PathValue("id")
 	if postIDStr == "" {
-		log.Printf("ViewPost: missing post ID")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Post ID is required"})
+		utils.Logger(r.Context()).Warn("GetPost: missing post ID")
+		respond.BadRequest(w, r, "Post ID is required")
 		return
 	}
 
 	post, err := h.postService.GetPostByID(r.Context(), postIDStr)
 	if err != nil {
-		log.Printf("ViewPost: failed to fetch post %s: %v", postIDStr, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Post not found"})
+		utils.Logger(r.Context()).Warn("GetPost: failed to fetch post", "post_id", postIDStr, "err", err)
+		respond.Error(w, r, err, "Failed to fetch post")
 		return
 	}
 
 	commentDisplay, err := h.commentService.GetPostComments(r.Context(), post.ID)
 	if err != nil {
-		log.Printf("ViewPost: failed to fetch comments for post %s: %v", post.ID, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch comments"})
+		utils.Logger(r.Context()).Error("GetPost: failed to fetch comments for post", "post_id", post.ID, "err", err)
+		respond.Internal(w, r, "Failed to fetch comments")
 		return
 	}
 
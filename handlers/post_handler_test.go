@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"real-time-forum/models"
+	"real-time-forum/services"
+	"testing"
+)
+
+type postFixture struct {
+	handler    *PostHandler
+	posts      *fakePostService
+	categories *fakeCategoriesService
+	comments   *fakeCommentsService
+	media      *fakeMediaService
+}
+
+func newPostFixture() *postFixture {
+	f := &postFixture{
+		posts:      &fakePostService{},
+		categories: &fakeCategoriesService{categories: []models.Category{{ID: "1", Name: "General Discussion"}}},
+		comments:   &fakeCommentsService{},
+		media:      &fakeMediaService{},
+	}
+	f.handler = NewPostHandler(f.posts, f.categories, f.comments, &fakeUserService{}, f.media)
+	return f
+}
+
+// multipartRequest builds a multipart POST with the fields and, when image
+// is not nil, an image file part
+func multipartRequest(t *testing.T, target string, fields map[string][]string, image []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, values := range fields {
+		for _, value := range values {
+			mw.WriteField(name, value)
+		}
+	}
+	if image != nil {
+		part, err := mw.CreateFormFile("image", "image.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(image)
+	}
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, target, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return asUser(r, &models.User{ID: "u1", Nickname: "alice"})
+}
+
+func TestCreatePost(t *testing.T) {
+	f := newPostFixture()
+	r := multipartRequest(t, "/createpost", map[string][]string{
+		"title":      {"Hello"},
+		"content":    {"First post"},
+		"categories": {"1", "2"},
+	}, []byte("png"))
+
+	w := httptest.NewRecorder()
+	f.handler.CreatePost(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body)
+	}
+	if f.posts.createdBy.ID != "u1" || f.posts.created.Title != "Hello" || f.posts.created.Image != "ab/cdef.png" {
+		t.Errorf("created %+v by %+v", f.posts.created, f.posts.createdBy)
+	}
+	if len(f.posts.categories) != 2 {
+		t.Errorf("categories = %v", f.posts.categories)
+	}
+	post, _ := decodeBody(t, w.Body)["post"].(map[string]interface{})
+	if post["ID"] != "p-new" {
+		t.Errorf("response post = %v", post)
+	}
+}
+
+func TestCreatePostRejected(t *testing.T) {
+	cases := []struct {
+		name       string
+		setup      func(f *postFixture)
+		categories []string
+		image      []byte
+		want       int
+	}{
+		{"no categories", func(f *postFixture) {}, nil, nil, http.StatusBadRequest},
+		{"unknown category", func(f *postFixture) { f.categories.validateErr = services.ErrCategoryNotFound }, []string{"99"}, nil, http.StatusBadRequest},
+		{"image too large", func(f *postFixture) { f.media.err = services.ErrImageTooLarge }, []string{"1"}, []byte("big"), http.StatusRequestEntityTooLarge},
+		{"unsupported image", func(f *postFixture) { f.media.err = services.ErrUnsupportedImageType }, []string{"1"}, []byte("bmp"), http.StatusBadRequest},
+		{"content filter", func(f *postFixture) {
+			f.posts.createErr = &services.ContentRejectedError{Filter: "words", Reason: "contains a banned word"}
+		}, []string{"1"}, nil, http.StatusUnprocessableEntity},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := newPostFixture()
+			c.setup(f)
+			r := multipartRequest(t, "/createpost", map[string][]string{
+				"title":      {"Hello"},
+				"content":    {"First post"},
+				"categories": c.categories,
+			}, c.image)
+
+			w := httptest.NewRecorder()
+			f.handler.CreatePost(w, r)
+
+			if w.Code != c.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, c.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestCreatePostFailureRemovesImage(t *testing.T) {
+	f := newPostFixture()
+	f.posts.createErr = &services.ContentRejectedError{Filter: "words", Reason: "contains a banned word"}
+	r := multipartRequest(t, "/createpost", map[string][]string{"title": {"x"}, "content": {"y"}, "categories": {"1"}}, []byte("png"))
+
+	w := httptest.NewRecorder()
+	f.handler.CreatePost(w, r)
+
+	if len(f.media.removed) != 1 || f.media.removed[0] != "ab/cdef.png" {
+		t.Errorf("removed = %v, want the uploaded image", f.media.removed)
+	}
+}
+
+func TestCreatePostContentRejectedBody(t *testing.T) {
+	f := newPostFixture()
+	f.posts.createErr = &services.ContentRejectedError{Filter: "words", Reason: "contains a banned word"}
+	r := multipartRequest(t, "/createpost", map[string][]string{"title": {"x"}, "content": {"y"}, "categories": {"1"}}, nil)
+
+	w := httptest.NewRecorder()
+	f.handler.CreatePost(w, r)
+
+	body := decodeBody(t, w.Body)
+	if body["code"] != "content_rejected" || body["filter"] != "words" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestCreatePostForm(t *testing.T) {
+	f := newPostFixture()
+	w := httptest.NewRecorder()
+	f.handler.CreatePost(w, httptest.NewRequest(http.MethodGet, "/createpost", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if categories, _ := decodeBody(t, w.Body)["categories"].([]interface{}); len(categories) != 1 {
+		t.Errorf("categories = %v", categories)
+	}
+}
+
+func TestViewPost(t *testing.T) {
+	f := newPostFixture()
+	f.posts.posts = []models.Post{{ID: "p1", Title: "Hello"}}
+	f.comments.comments = []models.Comment{{ID: "c1", PostID: "p1"}, {ID: "c2", PostID: "p1"}}
+
+	w := httptest.NewRecorder()
+	f.handler.ViewPost(w, asUser(httptest.NewRequest(http.MethodGet, "/post?id=p1", nil), &models.User{ID: "u1"}))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := decodeBody(t, w.Body)
+	if comments, _ := body["comments"].([]interface{}); len(comments) != 2 {
+		t.Errorf("comments = %v", body["comments"])
+	}
+}
+
+func TestGetPost(t *testing.T) {
+	f := newPostFixture()
+	f.posts.posts = []models.Post{{ID: "p1", Title: "Hello"}}
+
+	r := asUser(httptest.NewRequest(http.MethodGet, "/api/v1/posts/p1", nil), &models.User{ID: "u1"})
+	r.SetPathValue("id", "p1")
+	w := httptest.NewRecorder()
+	f.handler.GetPost(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if post, _ := decodeBody(t, w.Body)["post"].(map[string]interface{}); post["Title"] != "Hello" {
+		t.Errorf("post = %v", post)
+	}
+}
+
+func TestViewPostErrors(t *testing.T) {
+	f := newPostFixture()
+	for target, want := range map[string]int{
+		"/post":         http.StatusBadRequest,
+		"/post?id=nope": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		f.handler.ViewPost(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != want {
+			t.Errorf("GET %s: status = %d, want %d", target, w.Code, want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	f.handler.ViewPost(w, httptest.NewRequest(http.MethodDelete, "/post?id=p1", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: status = %d, want 405", w.Code)
+	}
+}
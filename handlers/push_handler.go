@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"real-time-forum/models"
+	"real-time-forum/respond"
+	"real-time-forum/services"
+	"real-time-forum/utils"
+)
+
+type PushHandler struct {
+	pushService services.PushService
+}
+
+func NewPushHandler(ps services.PushService) *PushHandler {
+	return &PushHandler{
+		pushService: ps,
+	}
+}
+
+// PublicKey handles GET /push/key, the VAPID key browsers subscribe with
+func (h *PushHandler) PublicKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.Logger(r.Context()).Warn("PublicKey: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	key, err := h.pushService.PublicKey()
+	if err != nil {
+		respond.Fail(w, r, http.StatusServiceUnavailable, respond.CodeUnavailable, "Push notifications are not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"publicKey": key,
+	})
+}
+
+// Subscriptions handles /push/subscriptions for the current user: POST
+// registers the browser with the JSON of its PushSubscription and DELETE
+// /push/subscriptions?endpoint=<url> removes it again
+func (h *PushHandler) Subscriptions(w http.ResponseWriter, r *http.Request) {
+	user := utils.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.Logger(r.Context()).Warn("Subscriptions: no user found in context")
+		respond.Unauthorized(w, r, "Not logged in")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var body struct {
+			Endpoint string `json:"endpoint"`
+			Keys     struct {
+				P256dh string `json:"p256dh"`
+				Auth   string `json:"auth"`
+			} `json:"keys"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8<<10)).Decode(&body); err != nil {
+			utils.Logger(r.Context()).Warn("Subscriptions: invalid JSON body", "err", err)
+			respond.BadRequest(w, r, "Invalid subscription")
+			return
+		}
+
+		sub := &models.PushSubscription{
+			Endpoint: body.Endpoint,
+			P256dh:   body.Keys.P256dh,
+			Auth:     body.Keys.Auth,
+		}
+		if err := h.pushService.Subscribe(r.Context(), user.ID, sub); err != nil {
+			if errors.Is(err, services.ErrPushDisabled) {
+				respond.Fail(w, r, http.StatusServiceUnavailable, respond.CodeUnavailable, "Push notifications are not available")
+				return
+			}
+			respond.Error(w, r, err, "Failed to save subscription")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Push notifications enabled",
+		})
+
+	case http.MethodDelete:
+		endpoint := r.URL.Query().Get("endpoint")
+		if endpoint == "" {
+			respond.BadRequest(w, r, "Endpoint is required")
+			return
+		}
+
+		if err := h.pushService.Unsubscribe(r.Context(), user.ID, endpoint); err != nil {
+			respond.Error(w, r, err, "Failed to delete subscription")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Push notifications disabled",
+		})
+
+	default:
+		utils.Logger(r.Context()).Warn("Subscriptions: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+	}
+}
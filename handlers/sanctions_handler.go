@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"real-time-forum/models"
+	"real-time-forum/respond"
+	"real-time-forum/services"
+	"real-time-forum/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SanctionsHandler struct {
+	sanctionService services.SanctionService
+}
+
+func NewSanctionsHandler(ss services.SanctionService) *SanctionsHandler {
+	return &SanctionsHandler{
+		sanctionService: ss,
+	}
+}
+
+// Sanctions handles /moderation/sanctions: GET lists sanctions (user=, all=1
+// to include expired and revoked ones, limit, offset), POST creates one with
+// user=, type=ban|suspension|mute, duration= (e.g. 3d, 12h) and reason=.
+func (h *SanctionsHandler) Sanctions(w http.ResponseWriter, r *http.Request) {
+	user := utils.GetUserFromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		filter := models.SanctionFilter{
+			ActiveOnly: query.Get("all") != "1",
+			Limit:      10,
+			Offset:     0,
+		}
+		if limitStr := query.Get("limit"); limitStr != "" {
+			if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 50 {
+				filter.Limit = parsedLimit
+			}
+		}
+		if offsetStr := query.Get("offset"); offsetStr != "" {
+			if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+				filter.Offset = parsedOffset
+			}
+		}
+
+		sanctions, err := h.sanctionService.ListSanctions(r.Context(), query.Get("user"), filter)
+		if err != nil {
+			writeSanctionError(w, r, "Sanctions GET", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sanctions": sanctions,
+			"limit":     filter.Limit,
+			"offset":    filter.Offset,
+			"hasMore":   len(sanctions) == filter.Limit,
+		})
+
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			utils.Logger(r.Context()).Warn("Sanctions POST: invalid form data", "err", err)
+			respond.BadRequest(w, r, "Invalid form data")
+			return
+		}
+
+		duration, err := parseSanctionDuration(r.FormValue("duration"))
+		if err != nil {
+			utils.Logger(r.Context()).Warn("Sanctions POST: invalid duration", "duration", r.FormValue("duration"), "err", err)
+			respond.BadRequest(w, r, "Duration must look like 30m, 12h or 7d")
+			return
+		}
+
+		sanction, err := h.sanctionService.SanctionUser(r.Context(), user, r.FormValue("user"), strings.TrimSpace(r.FormValue("type")), duration, r.FormValue("reason"))
+		if err != nil {
+			writeSanctionError(w, r, "Sanctions POST", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"message":  "Sanction applied successfully",
+			"sanction": sanction,
+		})
+
+	default:
+		utils.Logger(r.Context()).Warn("Sanctions: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+	}
+}
+
+// Sanction handles the legacy /moderation/sanctions/ subtree, where the only
+// route is POST /moderation/sanctions/{id}/revoke
+func (h *SanctionsHandler) Sanction(w http.ResponseWriter, r *http.Request) {
+	const prefix = "/moderation/sanctions/"
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "revoke" {
+		utils.Logger(r.Context()).Warn("Sanction: unknown path", "path", r.URL.Path)
+		respond.NotFound(w, r, "Page not found")
+		return
+	}
+
+	r.SetPathValue("id", parts[0])
+	h.RevokeSanction(w, r)
+}
+
+// RevokeSanction lifts a sanction before it expires
+func (h *SanctionsHandler) RevokeSanction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.Logger(r.Context()).Warn("RevokeSanction: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	user := utils.GetUserFromContext(r.Context())
+	sanction, err := h.sanctionService.RevokeSanction(r.Context(), user, r.PathValue("id"))
+	if err != nil {
+		writeSanctionError(w, r, "RevokeSanction", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Sanction revoked successfully",
+		"sanction": sanction,
+	})
+}
+
+// parseSanctionDuration accepts Go durations (90m, 12h) plus whole days (7d).
+// An empty value means no duration.
+func parseSanctionDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, errors.New("invalid number of days")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if duration <= 0 {
+		return 0, errors.New("duration must be positive")
+	}
+	return duration, nil
+}
+
+// writeSanctionError maps sanction service errors to HTTP responses
+func writeSanctionError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	utils.Logger(r.Context()).Warn(op, "err", err)
+	respond.Error(w, r, err, "Failed to process sanction")
+}
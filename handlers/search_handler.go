@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"real-time-forum/models"
+	"real-time-forum/respond"
+	"real-time-forum/services"
+	"real-time-forum/utils"
+	"strconv"
+	"strings"
+)
+
+type SearchHandler struct {
+	searchService services.SearchService
+}
+
+func NewSearchHandler(ss services.SearchService) *SearchHandler {
+	return &SearchHandler{
+		searchService: ss,
+	}
+}
+
+// Search handles GET /search?q=&type=&category=&author=&limit=&offset=
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.Logger(r.Context()).Warn("Search: invalid method", "method", r.Method)
+		respond.MethodNotAllowed(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	text := strings.TrimSpace(query.Get("q"))
+	if text == "" {
+		respond.BadRequest(w, r, "Search query is required")
+		return
+	}
+
+	// Result types can be repeated (?type=post&type=user) or comma separated
+	var types []string
+	for _, value := range query["type"] {
+		for _, t := range strings.Split(value, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				continue
+			}
+			if t != models.SearchTypePost && t != models.SearchTypeComment && t != models.SearchTypeUser {
+				respond.BadRequest(w, r, "Invalid result type")
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	// Default values for pagination
+	limit := 10
+	offset := 0
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 50 {
+			limit = parsedLimit
+		}
+	}
+
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	results, err := h.searchService.Search(r.Context(), models.SearchQuery{
+		Text:       text,
+		Types:      types,
+		CategoryID: strings.TrimSpace(query.Get("category")),
+		Author:     strings.TrimSpace(query.Get("author")),
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		utils.Logger(r.Context()).Warn("Search: failed to search", "err", err)
+		if errors.Is(err, services.ErrSearchUnavailable) {
+			respond.Fail(w, r, http.StatusServiceUnavailable, respond.CodeUnavailable, "Search is unavailable")
+			return
+		}
+		respond.Error(w, r, err, "Failed to search")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   text,
+		"results": results,
+		"limit":   limit,
+		"offset":  offset,
+		"hasMore": len(results) == limit, // If we got exactly 'limit' results, there might be more
+	})
+}
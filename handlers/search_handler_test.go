@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"real-time-forum/services"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	cases := []struct {
+		name   string
+		target string
+		want   int
+		match  string
+	}{
+		{"words", "/search?q=go+gophers", http.StatusOK, `"go"* "gophers"*`},
+		{"no query", "/search", http.StatusBadRequest, ""},
+		{"punctuation only", "/search?q=!!!", http.StatusBadRequest, ""},
+		{"operators only", "/search?q=%22*+-+:", http.StatusBadRequest, ""},
+		{"invalid type", "/search?q=go&type=thread", http.StatusBadRequest, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &fakeSearchRepository{}
+			handler := NewSearchHandler(services.NewSearchService(repo))
+
+			w := httptest.NewRecorder()
+			handler.Search(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+
+			if w.Code != tc.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+			if tc.match == "" {
+				if len(repo.matches) != 0 {
+					t.Errorf("searched for %q, want no search", repo.matches)
+				}
+				return
+			}
+			if len(repo.matches) != 1 || repo.matches[0] != tc.match {
+				t.Errorf("searched for %q, want %q", repo.matches, tc.match)
+			}
+		})
+	}
+}
+
+func TestSearchUnavailable(t *testing.T) {
+	handler := NewSearchHandler(&fakeSearchService{err: services.ErrSearchUnavailable})
+
+	w := httptest.NewRecorder()
+	handler.Search(w, httptest.NewRequest(http.MethodGet, "/search?q=go", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if body := decodeBody(t, w.Body); body["code"] != "unavailable" {
+		t.Errorf("body = %v, want the unavailable code", body)
+	}
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"real-time-forum/apispec"
+	"real-time-forum/models"
+	"real-time-forum/services"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadSpec parses the API contract the responses are checked against
+func loadSpec(t *testing.T) *apispec.Spec {
+	t.Helper()
+	spec, err := apispec.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// withPath sets a path wildcard the way the /api/v1 mux does
+func withPath(r *http.Request, name, value string) *http.Request {
+	r.SetPathValue(name, value)
+	return r
+}
+
+// TestResponsesMatchSpec fails when a handler response drifts from
+// apispec/openapi.json: a new or missing field, a changed type or an
+// undocumented status code.
+func TestResponsesMatchSpec(t *testing.T) {
+	spec := loadSpec(t)
+	alice := &models.User{ID: "u1", Nickname: "alice", Email: "alice@example.com", Role: models.RoleUser}
+	now := time.Now()
+	post := models.Post{ID: "p1", AuthorID: "u1", AuthorName: "alice", Title: "Hello", Content: "World", CreatedAt: now, Image: "3f/3f2a9c.png",
+		Categories: []string{"General Discussion"}, Mentions: []models.Mention{{UserID: "u2", Nickname: "bobby"}}}
+	categories := []models.Category{{ID: "1", Name: "General Discussion", LastPostAt: &now}}
+
+	auth := newAuthFixture()
+	auth.auth.user = alice
+	conflict := newAuthFixture()
+	conflict.auth.registerErr = services.ErrNicknameTaken
+	badLogin := newAuthFixture()
+	badLogin.auth.loginErr = services.ErrInvalidCredentials
+	expired := newAuthFixture()
+	expired.sessions.validateErr = services.ErrInvalidSession
+
+	posts := newPostFixture()
+	posts.posts.posts = []models.Post{post}
+	posts.comments.comments = []models.Comment{{ID: "c1", PostID: "p1", Content: "Nice", CreatedAt: now}}
+	rejected := newPostFixture()
+	rejected.posts.createErr = &services.ContentRejectedError{Filter: "links", Reason: "too many links"}
+
+	dashboard := NewDashboardHandler(&fakePostService{posts: []models.Post{post}}, &fakeCategoriesService{categories: categories},
+		&fakeUserService{users: []models.User{*alice}})
+	comments, _ := newCommentsFixture()
+	chat := NewWebSocketHandler(&fakeChatService{history: []models.Message{{ID: 1, Type: "chat_message", From: "alice", To: "bobby", Content: "hi", Timestamp: now}}},
+		&fakeFeedService{}, models.NewHub())
+	blockedChat := NewWebSocketHandler(&fakeChatService{blocked: true}, &fakeFeedService{}, models.NewHub())
+	search := NewSearchHandler(&fakeSearchService{results: []models.SearchResult{{Type: models.SearchTypePost, ID: "p1", Title: "Hello", CreatedAt: now}}})
+	searchDown := NewSearchHandler(&fakeSearchService{err: services.ErrSearchUnavailable})
+	admin := NewAdminHandler(&fakeUserService{}, &fakeAuditService{}, map[string]string{"server.addr": ":8080"},
+		func() map[string]sql.DBStats { return map[string]sql.DBStats{"read": {MaxOpenConnections: 4}} })
+	docs := NewDocsHandler()
+
+	moderator := &models.User{ID: "u3", Nickname: "mod", Email: "mod@example.com", Role: models.RoleModerator}
+	report := &models.Report{ID: "r1", ContentType: models.ReportTypePost, ContentID: "p1", ContentPreview: "World",
+		AuthorID: "u2", AuthorName: "bobby", ReporterID: "u1", ReporterName: "alice", Reason: "spam",
+		Status: models.ReportStatusOpen, CreatedAt: now, UpdatedAt: now}
+	moderation := NewModerationHandler(&fakeModerationService{report: report})
+	duplicateReport := NewModerationHandler(&fakeModerationService{err: services.ErrReportExists})
+	missingReport := NewModerationHandler(&fakeModerationService{err: services.ErrReportNotFound})
+	claimedReport := NewModerationHandler(&fakeModerationService{err: services.ErrReportClaimed})
+	expires := now.Add(24 * time.Hour)
+	sanction := &models.Sanction{ID: "s1", UserID: "u2", UserName: "bobby", Type: models.SanctionSuspension, Reason: "spam",
+		ModeratorID: "u3", ModeratorName: "mod", CreatedAt: now, ExpiresAt: &expires}
+	sanctions := NewSanctionsHandler(&fakeSanctionService{sanction: sanction})
+	missingSanction := NewSanctionsHandler(&fakeSanctionService{err: services.ErrSanctionNotFound})
+	notifications := NewNotificationsHandler(&fakeNotificationService{notifications: []models.Notification{{ID: "n1", UserID: "u1",
+		Type: models.NotificationComment, ActorID: "u2", ActorName: "bobby", PostID: "p1", PostTitle: "Hello", CommentID: "c1",
+		Preview: "Nice", CreatedAt: now}}})
+	push := NewPushHandler(&fakePushService{key: "BPublicKey"})
+	pushDisabled := NewPushHandler(&fakePushService{err: services.ErrPushDisabled})
+	pushUnknown := NewPushHandler(&fakePushService{err: services.ErrPushSubscriptionNotFound})
+	blocks := NewBlocksHandler(&fakeChatService{blockedUsers: []models.BlockedUser{{UserID: "u2", Nickname: "bobby", CreatedAt: now}}})
+	unknownBlock := NewBlocksHandler(&fakeChatService{blockErr: services.ErrUserNotFound})
+	adminCategories := NewCategoriesHandler(&fakeCategoriesService{categories: categories})
+	takenCategory := NewCategoriesHandler(&fakeCategoriesService{categories: categories, err: services.ErrCategoryExists})
+	audited := NewAdminHandler(&fakeUserService{}, &fakeAuditService{events: []models.AuditEvent{{ID: 1, Action: models.AuditRoleChanged,
+		ActorID: "u0", ActorName: "root", TargetType: "user", TargetID: "u2", IP: "192.0.2.1",
+		Metadata: map[string]interface{}{"new_role": "moderator"}, CreatedAt: now}}}, nil, nil)
+	lastAdmin := NewAdminHandler(&fakeUserService{roleErr: services.ErrLastAdmin}, &fakeAuditService{}, nil, nil)
+
+	sessionRequest := func(sessionID string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/session", nil)
+		r.Header.Set("X-Session-ID", sessionID)
+		return r
+	}
+	usersRequest := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	usersRequest.Header.Set("X-Session-ID", "s1")
+	postForm := func(target string, form url.Values) *http.Request {
+		return asUser(postForm(target, form), alice)
+	}
+	get := func(target string) *http.Request {
+		return asUser(httptest.NewRequest(http.MethodGet, target, nil), alice)
+	}
+	postRequest := func(fields map[string][]string) *http.Request {
+		return multipartRequest(t, "/api/v1/posts", fields, nil)
+	}
+	validPost := map[string][]string{"title": {"Hello"}, "content": {"World"}, "categories": {"1"}}
+	as := func(user *models.User, method, target string, form url.Values) *http.Request {
+		return asUser(formRequest(method, target, form), user)
+	}
+	pushSubscription := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/push/subscriptions", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		return asUser(r, alice)
+	}
+	reason := url.Values{"reason": {"spam"}}
+
+	cases := []struct {
+		method, pattern string
+		handler         http.HandlerFunc
+		request         *http.Request
+		want            int
+	}{
+		{"GET", "/openapi.json", docs.OpenAPI, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil), http.StatusOK},
+		{"GET", "/schemas/websocket.json", docs.WebSocketSchema, httptest.NewRequest(http.MethodGet, "/api/v1/schemas/websocket.json", nil), http.StatusOK},
+
+		{"POST", "/users", auth.handler.Register, postForm("/api/v1/users", registrationForm()), http.StatusCreated},
+		{"POST", "/users", conflict.handler.Register, postForm("/api/v1/users", registrationForm()), http.StatusConflict},
+		{"POST", "/users", auth.handler.Register, postForm("/api/v1/users", url.Values{"age": {"old"}}), http.StatusBadRequest},
+		{"GET", "/users", dashboard.AllUsers, usersRequest, http.StatusOK},
+		{"GET", "/users/me/posts", dashboard.UserPosts, get("/api/v1/users/me/posts"), http.StatusOK},
+		{"POST", "/sessions", auth.handler.Login, postForm("/api/v1/sessions", url.Values{"nickname": {"alice"}, "password": {"secret1"}}), http.StatusOK},
+		{"POST", "/sessions", badLogin.handler.Login, postForm("/api/v1/sessions", url.Values{"nickname": {"alice"}, "password": {"nope"}}), http.StatusUnauthorized},
+		{"GET", "/session", auth.handler.CheckSession, sessionRequest("s1"), http.StatusOK},
+		{"GET", "/session", expired.handler.CheckSession, sessionRequest("gone"), http.StatusUnauthorized},
+		{"DELETE", "/session", auth.handler.DeleteSession, asUser(httptest.NewRequest(http.MethodDelete, "/api/v1/session", nil), alice), http.StatusOK},
+
+		{"GET", "/posts", dashboard.ListPosts, get("/api/v1/posts"), http.StatusOK},
+		{"GET", "/posts", dashboard.ListPosts, httptest.NewRequest(http.MethodGet, "/api/v1/posts", nil), http.StatusOK},
+		{"GET", "/posts", dashboard.ListPosts, get("/api/v1/posts?feed=popular"), http.StatusBadRequest},
+		{"POST", "/posts", posts.handler.AddPost, postRequest(validPost), http.StatusCreated},
+		{"POST", "/posts", posts.handler.AddPost, postRequest(map[string][]string{"title": {"Hello"}}), http.StatusBadRequest},
+		{"POST", "/posts", rejected.handler.AddPost, postRequest(validPost), http.StatusUnprocessableEntity},
+		{"GET", "/posts/{id}", posts.handler.GetPost, withPath(get("/api/v1/posts/p1"), "id", "p1"), http.StatusOK},
+		{"GET", "/posts/{id}", posts.handler.GetPost, withPath(get("/api/v1/posts/nope"), "id", "nope"), http.StatusNotFound},
+		{"POST", "/posts/{id}/comments", comments.AddComment, withPath(postForm("/api/v1/posts/p1/comments", url.Values{"comment": {"Nice"}}), "id", "p1"), http.StatusCreated},
+		{"POST", "/posts/{id}/comments", comments.AddComment, withPath(postForm("/api/v1/posts/p1/comments", url.Values{"comment": {" "}}), "id", "p1"), http.StatusBadRequest},
+		{"GET", "/categories", posts.handler.ListCategories, get("/api/v1/categories"), http.StatusOK},
+		{"GET", "/categories/{id}/posts", dashboard.CategoryPosts, withPath(get("/api/v1/categories/1/posts"), "id", "1"), http.StatusOK},
+		{"GET", "/categories/{id}/posts", dashboard.CategoryPosts, withPath(get("/api/v1/categories/9/posts"), "id", "9"), http.StatusNotFound},
+
+		{"GET", "/conversations/{user}/messages", chat.ConversationMessages, withPath(get("/api/v1/conversations/bobby/messages"), "user", "bobby"), http.StatusOK},
+		{"GET", "/conversations/{user}/messages", blockedChat.ConversationMessages, withPath(get("/api/v1/conversations/bobby/messages"), "user", "bobby"), http.StatusForbidden},
+		{"GET", "/search", search.Search, get("/api/v1/search?q=hello"), http.StatusOK},
+		{"GET", "/search", search.Search, get("/api/v1/search"), http.StatusBadRequest},
+		{"GET", "/search", searchDown.Search, get("/api/v1/search?q=hello"), http.StatusServiceUnavailable},
+
+		{"GET", "/admin/config", admin.Config, get("/api/v1/admin/config"), http.StatusOK},
+		{"GET", "/admin/db/stats", admin.DBStats, get("/api/v1/admin/db/stats"), http.StatusOK},
+		{"GET", "/admin/audit", admin.AuditEvents, get("/api/v1/admin/audit?since=yesterday"), http.StatusBadRequest},
+		{"GET", "/admin/audit", audited.AuditEvents, get("/api/v1/admin/audit?action=moderation.*"), http.StatusOK},
+		{"PUT", "/admin/users/{user}/role", admin.UpdateUserRole, withPath(as(alice, http.MethodPut, "/api/v1/admin/users/bobby/role", url.Values{"role": {"moderator"}}), "user", "bobby"), http.StatusOK},
+		{"PUT", "/admin/users/{user}/role", lastAdmin.UpdateUserRole, withPath(as(alice, http.MethodPut, "/api/v1/admin/users/root/role", url.Values{"role": {"user"}}), "user", "root"), http.StatusConflict},
+		{"GET", "/admin/categories", adminCategories.Categories, get("/api/v1/admin/categories"), http.StatusOK},
+		{"POST", "/admin/categories", adminCategories.Categories, as(alice, http.MethodPost, "/api/v1/admin/categories", url.Values{"name": {"Games"}}), http.StatusCreated},
+		{"POST", "/admin/categories", takenCategory.Categories, as(alice, http.MethodPost, "/api/v1/admin/categories", url.Values{"name": {"Sports"}}), http.StatusConflict},
+		{"PATCH", "/admin/categories", adminCategories.ReorderCategories, as(alice, http.MethodPatch, "/api/v1/admin/categories", url.Values{"ids": {"1"}}), http.StatusOK},
+		{"PATCH", "/admin/categories/{id}", adminCategories.UpdateCategory, withPath(as(alice, http.MethodPatch, "/api/v1/admin/categories/1", url.Values{"name": {"General"}}), "id", "1"), http.StatusOK},
+		{"PATCH", "/admin/categories/{id}", takenCategory.UpdateCategory, withPath(as(alice, http.MethodPatch, "/api/v1/admin/categories/1", url.Values{"name": {"Sports"}}), "id", "1"), http.StatusConflict},
+		{"POST", "/admin/categories/{id}/archive", adminCategories.ArchiveCategory, withPath(as(alice, http.MethodPost, "/api/v1/admin/categories/1/archive", nil), "id", "1"), http.StatusOK},
+		{"POST", "/admin/categories/{id}/restore", adminCategories.RestoreCategory, withPath(as(alice, http.MethodPost, "/api/v1/admin/categories/1/restore", nil), "id", "1"), http.StatusOK},
+
+		{"POST", "/posts/{id}/reports", moderation.ReportPost, withPath(postForm("/api/v1/posts/p1/reports", reason), "id", "p1"), http.StatusCreated},
+		{"POST", "/posts/{id}/reports", duplicateReport.ReportPost, withPath(postForm("/api/v1/posts/p1/reports", reason), "id", "p1"), http.StatusConflict},
+		{"POST", "/comments/{id}/reports", moderation.ReportComment, withPath(postForm("/api/v1/comments/c1/reports", reason), "id", "c1"), http.StatusCreated},
+		{"POST", "/messages/{id}/reports", moderation.ReportMessage, withPath(postForm("/api/v1/messages/1/reports", reason), "id", "1"), http.StatusCreated},
+		{"GET", "/moderation/reports", moderation.Reports, asUser(get("/api/v1/moderation/reports?claimed=me"), moderator), http.StatusOK},
+		{"GET", "/moderation/reports", moderation.Reports, asUser(get("/api/v1/moderation/reports?claimed=someone"), moderator), http.StatusBadRequest},
+		{"GET", "/moderation/reports/{id}", moderation.ViewReport, withPath(asUser(get("/api/v1/moderation/reports/r1"), moderator), "id", "r1"), http.StatusOK},
+		{"GET", "/moderation/reports/{id}", missingReport.ViewReport, withPath(asUser(get("/api/v1/moderation/reports/r9"), moderator), "id", "r9"), http.StatusNotFound},
+		{"POST", "/moderation/reports/{id}/claim", moderation.ClaimReport, withPath(as(moderator, http.MethodPost, "/api/v1/moderation/reports/r1/claim", nil), "id", "r1"), http.StatusOK},
+		{"POST", "/moderation/reports/{id}/claim", claimedReport.ClaimReport, withPath(as(moderator, http.MethodPost, "/api/v1/moderation/reports/r1/claim", nil), "id", "r1"), http.StatusConflict},
+		{"POST", "/moderation/reports/{id}/resolve", moderation.ResolveReport, withPath(as(moderator, http.MethodPost, "/api/v1/moderation/reports/r1/resolve", url.Values{"action": {"hide"}}), "id", "r1"), http.StatusOK},
+		{"GET", "/moderation/sanctions", sanctions.Sanctions, asUser(get("/api/v1/moderation/sanctions"), moderator), http.StatusOK},
+		{"POST", "/moderation/sanctions", sanctions.Sanctions, as(moderator, http.MethodPost, "/api/v1/moderation/sanctions", url.Values{"user": {"bobby"}, "type": {"suspension"}, "duration": {"1d"}, "reason": {"spam"}}), http.StatusCreated},
+		{"POST", "/moderation/sanctions", sanctions.Sanctions, as(moderator, http.MethodPost, "/api/v1/moderation/sanctions", url.Values{"user": {"bobby"}, "duration": {"soon"}}), http.StatusBadRequest},
+		{"POST", "/moderation/sanctions/{id}/revoke", sanctions.RevokeSanction, withPath(as(moderator, http.MethodPost, "/api/v1/moderation/sanctions/s1/revoke", nil), "id", "s1"), http.StatusOK},
+		{"POST", "/moderation/sanctions/{id}/revoke", missingSanction.RevokeSanction, withPath(as(moderator, http.MethodPost, "/api/v1/moderation/sanctions/s9/revoke", nil), "id", "s9"), http.StatusNotFound},
+
+		{"GET", "/subscriptions", adminCategories.Subscriptions, get("/api/v1/subscriptions"), http.StatusOK},
+		{"POST", "/subscriptions", adminCategories.Subscriptions, postForm("/api/v1/subscriptions", url.Values{"category_id": {"1"}}), http.StatusOK},
+		{"POST", "/subscriptions", adminCategories.Subscriptions, postForm("/api/v1/subscriptions", nil), http.StatusBadRequest},
+		{"DELETE", "/subscriptions", adminCategories.Subscriptions, as(alice, http.MethodDelete, "/api/v1/subscriptions?category_id=1", nil), http.StatusOK},
+		{"GET", "/blocks", blocks.Blocks, get("/api/v1/blocks"), http.StatusOK},
+		{"POST", "/blocks", blocks.Blocks, postForm("/api/v1/blocks", url.Values{"user": {"bobby"}}), http.StatusOK},
+		{"POST", "/blocks", unknownBlock.Blocks, postForm("/api/v1/blocks", url.Values{"user": {"nobody"}}), http.StatusNotFound},
+		{"DELETE", "/blocks", blocks.Blocks, as(alice, http.MethodDelete, "/api/v1/blocks?user=bobby", nil), http.StatusOK},
+		{"DELETE", "/blocks", blocks.Blocks, as(alice, http.MethodDelete, "/api/v1/blocks", nil), http.StatusBadRequest},
+		{"GET", "/notifications", notifications.Notifications, get("/api/v1/notifications"), http.StatusOK},
+		{"POST", "/notifications/read", notifications.MarkRead, postForm("/api/v1/notifications/read", url.Values{"id": {"n1"}}), http.StatusOK},
+		{"POST", "/notifications/read", notifications.MarkRead, postForm("/api/v1/notifications/read", nil), http.StatusBadRequest},
+		{"GET", "/push/key", push.PublicKey, get("/api/v1/push/key"), http.StatusOK},
+		{"GET", "/push/key", pushDisabled.PublicKey, get("/api/v1/push/key"), http.StatusServiceUnavailable},
+		{"POST", "/push/subscriptions", push.Subscriptions, pushSubscription(`{"endpoint":"https://fcm.googleapis.com/fcm/send/abc","keys":{"p256dh":"BKey","auth":"secret"}}`), http.StatusCreated},
+		{"POST", "/push/subscriptions", push.Subscriptions, pushSubscription(`{"endpoint":`), http.StatusBadRequest},
+		{"POST", "/push/subscriptions", pushDisabled.Subscriptions, pushSubscription(`{"endpoint":"https://fcm.googleapis.com/fcm/send/abc","keys":{"p256dh":"BKey","auth":"secret"}}`), http.StatusServiceUnavailable},
+		{"DELETE", "/push/subscriptions", push.Subscriptions, as(alice, http.MethodDelete, "/api/v1/push/subscriptions?endpoint=https://fcm.googleapis.com/fcm/send/abc", nil), http.StatusOK},
+		{"DELETE", "/push/subscriptions", pushUnknown.Subscriptions, as(alice, http.MethodDelete, "/api/v1/push/subscriptions?endpoint=https://fcm.googleapis.com/fcm/send/x", nil), http.StatusNotFound},
+	}
+
+	// Every documented operation needs a case. The WebSocket upgrade answers
+	// 101 without a body; its frames are checked by the WebSocket tests.
+	validated := map[string]bool{"GET /ws": true}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		c.handler(w, c.request)
+
+		if w.Code != c.want {
+			t.Errorf("%s %s: status = %d, want %d: %s", c.method, c.request.URL, w.Code, c.want, w.Body)
+			continue
+		}
+		if err := spec.ValidateResponse(c.method, c.pattern, w.Code, w.Body.Bytes()); err != nil {
+			t.Error(err)
+		}
+		validated[c.method+" "+c.pattern] = true
+	}
+
+	for _, op := range spec.Operations() {
+		if !validated[op] {
+			t.Errorf("%s has no response validation case", op)
+		}
+	}
+}
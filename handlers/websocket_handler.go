@@ -2,22 +2,29 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"real-time-forum/models"
+	"real-time-forum/respond"
 	"real-time-forum/services"
 	"real-time-forum/utils"
 	"strconv"
 	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
 )
 
 type WebSocketHandler struct {
-	chatService *services.ChatService
+	chatService services.ChatService
+	feedService services.FeedService
+	hub         *models.Hub
 }
 
-func NewWebSocketHandler(chatService *services.ChatService) *WebSocketHandler {
-	return &WebSocketHandler{chatService: chatService}
+func NewWebSocketHandler(chatService services.ChatService, feedService services.FeedService, hub *models.Hub) *WebSocketHandler {
+	return &WebSocketHandler{chatService: chatService, feedService: feedService, hub: hub}
 }
 
 // WebSocket upgrades the HTTP connection
@@ -26,71 +33,60 @@ func (h *WebSocketHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
 
 	conn, err := models.Upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		http.Error(w, "Failed to upgrade WebSocket", http.StatusInternalServerError)
+		// The upgrader has already answered the request
+		utils.Logger(r.Context()).Error("WebSocket: upgrade failed", "err", err)
 		return
 	}
 
 	client := &models.Client{
 		Username: user.Nickname,
+		Role:     user.Role,
 		Conn:     conn,
 		Send:     make(chan []byte, 256),
+		Done:     make(chan struct{}),
 	}
 
-	h.chatService.Hub.Register <- client
-
-	// Send initial online users list after client is registered
-	// Small delay to ensure registration is processed
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		h.sendInitialOnlineUsers(client)
-	}()
+	select {
+	case h.hub.Register <- client:
+	case <-h.hub.Stopped():
+		// The server is shutting down
+		closeMessage := websocket.FormatCloseMessage(websocket.CloseServiceRestart, models.ShutdownReason)
+		conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
 
 	// Start read/write pumps
 	go h.readPump(client)
 	go h.writePump(client)
 }
 
-// sendInitialOnlineUsers sends the current list of online users to a newly connected client
-func (h *WebSocketHandler) sendInitialOnlineUsers(client *models.Client) {
-	// Get online users excluding the current client
-	onlineUsers := h.chatService.Hub.GetOnlineUsersExcluding(client.Username)
-
-	initialMessage := map[string]interface{}{
-		"type":         "initial_online_users",
-		"from":         "system",
-		"to":           client.Username,
-		"online_users": onlineUsers,
-		"timestamp":    fmt.Sprintf("%v", time.Now()),
-	}
-
-	messageBytes, err := json.Marshal(initialMessage)
-	if err != nil {
-		log.Printf("Error marshaling initial online users message: %v", err)
-		return
-	}
-
-	// Send directly to the client's connection
-	if err := client.Conn.WriteMessage(1, messageBytes); err != nil {
-		log.Printf("Error sending initial online users: %v", err)
-	}
-}
-
 func (h *WebSocketHandler) readPump(c *models.Client) {
 	defer func() {
-		h.chatService.Hub.Unregister <- c
+		select {
+		case h.hub.Unregister <- c:
+		case <-h.hub.Stopped():
+		}
 		c.Conn.Close()
 	}()
 
 	for {
 		_, msgBytes, err := c.Conn.ReadMessage()
 		if err != nil {
-			log.Printf("Read error: %v", err)
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				slog.Warn("WebSocket: read failed", "nickname", c.Username, "err", err)
+			}
 			break
 		}
 
 		var msg models.Message
 		if err := json.Unmarshal(msgBytes, &msg); err != nil {
-			log.Printf("JSON unmarshal error: %v", err)
+			slog.Warn("WebSocket: invalid frame", "nickname", c.Username, "err", err)
+			continue
+		}
+
+		if msg.Type == "subscribe" || msg.Type == "unsubscribe" {
+			h.handleTopicFrame(c, msgBytes)
 			continue
 		}
 
@@ -99,25 +95,105 @@ func (h *WebSocketHandler) readPump(c *models.Client) {
 	}
 }
 
+// handleTopicFrame subscribes the client to a topic or unsubscribes it, and
+// confirms with a subscribed/unsubscribed frame
+func (h *WebSocketHandler) handleTopicFrame(c *models.Client, msgBytes []byte) {
+	var frame models.TopicFrame
+	if err := json.Unmarshal(msgBytes, &frame); err != nil {
+		slog.Warn("WebSocket: invalid frame", "nickname", c.Username, "err", err)
+		return
+	}
+
+	var err error
+	if frame.Type == "subscribe" {
+		err = h.feedService.Subscribe(c, frame.Topic)
+	} else {
+		err = h.feedService.Unsubscribe(c, frame.Topic)
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidTopic):
+		h.chatService.SendError(c.Username, "invalid_topic", "Unknown topic: "+frame.Topic)
+		return
+	case errors.Is(err, services.ErrTooManyTopics):
+		h.chatService.SendError(c.Username, "too_many_topics", fmt.Sprintf("You can follow at most %d topics at once", models.MaxClientTopics))
+		return
+	}
+
+	confirmation := map[string]interface{}{
+		"type":      frame.Type + "d",
+		"from":      "system",
+		"to":        c.Username,
+		"topic":     frame.Topic,
+		"timestamp": time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(confirmation)
+	if err != nil {
+		slog.Error("Error marshaling confirmation", "type", frame.Type, "err", err)
+		return
+	}
+	h.hub.SendToUser(c.Username, messageBytes)
+}
+
 func (h *WebSocketHandler) writePump(c *models.Client) {
-	defer c.Conn.Close()
+	defer func() {
+		c.Conn.Close()
+		close(c.Done)
+	}()
 	for msg := range c.Send {
 		if err := c.Conn.WriteMessage(1, msg); err != nil {
-			log.Printf("Write error: %v", err)
-			break
+			slog.Error("Write error", "err", err)
+			return
 		}
 	}
+
+	// The hub closed the channel: say goodbye properly, with the reason when
+	// the client was dropped on purpose
+	code, reason := websocket.CloseNormalClosure, c.CloseReason
+	switch {
+	case c.CloseCode != 0:
+		code = c.CloseCode
+	case reason != "":
+		code = websocket.ClosePolicyViolation
+	}
+	// Control frames carry at most 123 bytes of reason text
+	for len(reason) > 123 {
+		_, size := utf8.DecodeLastRuneInString(reason)
+		reason = reason[:len(reason)-size]
+	}
+	closeMessage := websocket.FormatCloseMessage(code, reason)
+	if err := c.Conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second)); err != nil {
+		slog.Error("Close error", "err", err)
+	}
 }
 
+// ChatHistory handles /chathistory?user2=, the legacy alias of
+// GET /api/v1/conversations/{user}/messages
 func (h *WebSocketHandler) ChatHistory(w http.ResponseWriter, r *http.Request) {
-	fmt.Print("ChatHistory handler called\n")
+	r.SetPathValue("user", r.URL.Query().Get("user2"))
+	h.ConversationMessages(w, r)
+}
+
+// ConversationMessages returns a page of the messages between the current
+// user and the one in the path. Takes limit and offset.
+func (h *WebSocketHandler) ConversationMessages(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
-	user2 := query.Get("user2")
+	user2 := r.PathValue("user")
 	limitStr := query.Get("limit")
 	offsetStr := query.Get("offset")
 
 	user := utils.GetUserFromContext(r.Context())
 
+	blocked, err := h.chatService.IsBlocked(r.Context(), user.Nickname, user2)
+	if err != nil {
+		respond.Internal(w, r, "Failed to get chat history")
+		return
+	}
+	if blocked {
+		respond.Fail(w, r, http.StatusForbidden, respond.CodeForbidden, "Chat history with this user is not available")
+		return
+	}
+
 	// Default values for pagination
 	limit := 10
 	offset := 0
@@ -136,7 +212,6 @@ func (h *WebSocketHandler) ChatHistory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var history []models.Message
-	var err error
 
 	// Use pagination if offset is provided, otherwise use the old method for initial load
 	if offset > 0 {
@@ -147,7 +222,7 @@ func (h *WebSocketHandler) ChatHistory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		http.Error(w, "Failed to get chat history", http.StatusInternalServerError)
+		respond.Internal(w, r, "Failed to get chat history")
 		return
 	}
 
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"real-time-forum/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestChatHistory(t *testing.T) {
+	chat := &fakeChatService{history: make([]models.Message, 15)}
+	handler := NewWebSocketHandler(chat, &fakeFeedService{}, models.NewHub())
+	user := &models.User{ID: "u1", Nickname: "alice"}
+
+	cases := []struct {
+		query      string
+		wantArgs   [2]int
+		wantMore   bool
+		wantLength int
+	}{
+		{"user2=bobby", [2]int{10, 0}, true, 10},
+		{"user2=bobby&limit=500&offset=-3", [2]int{10, 0}, true, 10},
+		{"user2=bobby&limit=20&offset=10", [2]int{20, 10}, false, 15},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ChatHistory(w, asUser(httptest.NewRequest(http.MethodGet, "/chathistory?"+c.query, nil), user))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200", c.query, w.Code)
+		}
+		if chat.historyArgs[0] != c.wantArgs[0] || chat.historyArgs[1] != c.wantArgs[1] {
+			t.Errorf("%s: limit, offset = %v, want %v", c.query, chat.historyArgs, c.wantArgs)
+		}
+		body := decodeBody(t, w.Body)
+		history, _ := body["history"].([]interface{})
+		if len(history) != c.wantLength || body["hasMore"] != c.wantMore {
+			t.Errorf("%s: %d messages, hasMore = %v", c.query, len(history), body["hasMore"])
+		}
+	}
+}
+
+func TestChatHistoryBlocked(t *testing.T) {
+	chat := &fakeChatService{blocked: true}
+	handler := NewWebSocketHandler(chat, &fakeFeedService{}, models.NewHub())
+
+	w := httptest.NewRecorder()
+	handler.ChatHistory(w, asUser(httptest.NewRequest(http.MethodGet, "/chathistory?user2=bobby", nil), &models.User{ID: "u1", Nickname: "alice"}))
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", w.Code)
+	}
+	if chat.historyArgs != nil {
+		t.Error("history was loaded for a blocked user")
+	}
+}
+
+// dialWebSocket serves the handler for alice on a running hub and connects
+// to it. Calling stop shuts the hub down.
+func dialWebSocket(t *testing.T, chat *fakeChatService, feed *fakeFeedService) (conn *websocket.Conn, stop func()) {
+	t.Helper()
+	hub := models.NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	hubDone := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(hubDone)
+	}()
+
+	handler := NewWebSocketHandler(chat, feed, hub)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.WebSocket(w, asUser(r, &models.User{ID: "u1", Nickname: "alice", Role: models.RoleUser}))
+	}))
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop = func() {
+		cancel()
+		<-hubDone
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		stop()
+		server.Close()
+	})
+	return conn, stop
+}
+
+// readFrame reads frames until one of the given type arrives
+func readFrame(t *testing.T, conn *websocket.Conn, frameType string) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var frame map[string]interface{}
+		if err := conn.ReadJSON(&frame); err != nil {
+			t.Fatalf("waiting for a %s frame: %v", frameType, err)
+		}
+		if frame["type"] == frameType {
+			return frame
+		}
+	}
+}
+
+func TestWebSocketChat(t *testing.T) {
+	chat := &fakeChatService{processed: make(chan *models.Message, 1)}
+	conn, _ := dialWebSocket(t, chat, &fakeFeedService{})
+
+	if joined := readFrame(t, conn, "user_joined"); joined["content"] != "alice" {
+		t.Errorf("user_joined = %v", joined)
+	}
+	readFrame(t, conn, "initial_online_users")
+
+	// The sender is whoever owns the connection, not what the frame claims
+	frame, _ := json.Marshal(map[string]string{"type": "message", "from": "mallory", "to": "bobby", "content": "hi"})
+	if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-chat.processed:
+		if msg.From != "alice" || msg.To != "bobby" || msg.Content != "hi" {
+			t.Errorf("processed %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the message never reached the chat service")
+	}
+}
+
+func TestWebSocketTopics(t *testing.T) {
+	chat := &fakeChatService{errors: make(chan string, 1)}
+	feed := &fakeFeedService{}
+	conn, _ := dialWebSocket(t, chat, feed)
+	readFrame(t, conn, "user_joined")
+
+	if err := conn.WriteJSON(models.TopicFrame{Type: "subscribe", Topic: "post:1"}); err != nil {
+		t.Fatal(err)
+	}
+	if confirmed := readFrame(t, conn, "subscribed"); confirmed["topic"] != "post:1" {
+		t.Errorf("subscribed = %v", confirmed)
+	}
+	feed.mu.Lock()
+	topics := feed.topics
+	feed.mu.Unlock()
+	if len(topics) != 1 || topics[0] != "post:1" {
+		t.Errorf("feed topics = %v", topics)
+	}
+
+	if err := conn.WriteJSON(models.TopicFrame{Type: "subscribe", Topic: "nonsense"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case code := <-chat.errors:
+		if code != "invalid_topic" {
+			t.Errorf("error code = %q, want invalid_topic", code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("an invalid topic was not refused")
+	}
+}
+
+func TestWebSocketClosedOnShutdown(t *testing.T) {
+	conn, stop := dialWebSocket(t, &fakeChatService{}, &fakeFeedService{})
+	readFrame(t, conn, "user_joined")
+	readFrame(t, conn, "initial_online_users")
+
+	// The hub waits for the close frame to be written, so stop in the
+	// background while reading
+	go stop()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if !websocket.IsCloseError(err, websocket.CloseServiceRestart) {
+			t.Fatalf("read error = %v, want a service restart close", err)
+		}
+		return
+	}
+}
@@ -204,6 +204,9 @@ class AuthManager {
 
         const sessionId = this.getCookie('session_id');
 
+        // Stop pushes to this browser while the session can still say so
+        await this.app.push.forget();
+
         try {
             const response = await fetch('/logout', {
                 method: 'POST',
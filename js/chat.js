@@ -68,6 +68,11 @@ class ChatManager {
         this.websocket.onopen = () => {
             console.log('WebSocket connected');
             this.isWebSocketConnected = true;
+            // Subscriptions live on the connection, so renew them after a reconnect
+            this.subscribe('feed');
+            if (this.app.posts.selectedPostId) {
+                this.subscribe(`post:${this.app.posts.selectedPostId}`);
+            }
         };
 
         this.websocket.onmessage = (event) => {
@@ -91,6 +96,31 @@ class ChatManager {
                     case 'online_users_update':
                         this.handleOnlineUsersUpdate(message);
                         break;
+                    case 'error':
+                        // A frame we sent was refused (muted, blocked, ...)
+                        this.app.ui.showToast(message.content, 'error');
+                        break;
+                    case 'warning':
+                    case 'muted':
+                    case 'unmuted':
+                        this.app.ui.showToast(message.content, 'warning');
+                        break;
+                    case 'notification':
+                        this.handleNotification(message);
+                        break;
+                    case 'post_created':
+                        this.app.posts.handlePostCreated(message);
+                        break;
+                    case 'comment_created':
+                        this.app.posts.handleCommentCreated(message);
+                        break;
+                    case 'subscribed':
+                    case 'unsubscribed':
+                    case 'report_created':
+                    case 'report_claimed':
+                    case 'report_resolved':
+                        // Moderation queue updates, not shown in the chat
+                        break;
                     default:
                         // Default to chat message for backward compatibility
                         this.displayChatMessage(message);
@@ -130,6 +160,25 @@ class ChatManager {
         }
     }
 
+    /**
+     * Follow a hub topic ("feed", "category:{id}" or "post:{id}"). Quietly
+     * does nothing while disconnected; onopen subscribes again.
+     */
+    subscribe(topic) {
+        if (this.websocket && this.websocket.readyState === WebSocket.OPEN) {
+            this.websocket.send(JSON.stringify({ type: 'subscribe', topic }));
+        }
+    }
+
+    /**
+     * Stop following a hub topic
+     */
+    unsubscribe(topic) {
+        if (this.websocket && this.websocket.readyState === WebSocket.OPEN) {
+            this.websocket.send(JSON.stringify({ type: 'unsubscribe', topic }));
+        }
+    }
+
     /**
      * Send message via WebSocket
      */
@@ -635,6 +684,31 @@ class ChatManager {
         this.isLoadingHistory = false;
     }
 
+    /**
+     * Handle a live notification about a comment, reply or new post
+     */
+    handleNotification(message) {
+        const n = message.notification || {};
+        let text;
+        switch (n.Type) {
+            case 'comment':
+                text = `${n.ActorName} commented on your post: ${n.Preview}`;
+                break;
+            case 'reply':
+                text = `${n.ActorName} replied in a thread you follow: ${n.Preview}`;
+                break;
+            case 'category_post':
+                text = `${n.ActorName} posted: ${n.Preview}`;
+                break;
+            case 'mention':
+                text = `${n.ActorName} mentioned you: ${n.Preview}`;
+                break;
+            default:
+                text = n.Preview || 'You have a new notification';
+        }
+        this.app.ui.showToast(text, 'info');
+    }
+
     /**
      * Handle user joined event
      */
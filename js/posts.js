@@ -186,7 +186,11 @@ class PostsManager {
      */
     async viewPost(postId) {
         console.log('ViewPost called with postId:', postId, 'type:', typeof postId);
+        if (this.selectedPostId && this.selectedPostId !== postId) {
+            this.app.chat.unsubscribe(`post:${this.selectedPostId}`);
+        }
         this.selectedPostId = postId;
+        this.app.chat.subscribe(`post:${postId}`);
         this.app.ui.showLoading();
 
         try {
@@ -228,15 +232,7 @@ class PostsManager {
         const categoriesHtml = post.Categories ? 
             post.Categories.map(cat => `<span class="category-tag">${cat}</span>`).join('') : '';
 
-        const commentsHtml = comments.map(comment => `
-            <div class="comment">
-                <div class="comment-header">
-                    <span class="comment-author">${this.app.ui.escapeHtml(comment.AuthorName || comment.authorName)}</span>
-                    <span class="comment-date">${this.app.ui.formatDate(comment.CreatedAt || comment.createdAt)}</span>
-                </div>
-                <div class="comment-content">${this.app.ui.escapeHtml(comment.Content || comment.content)}</div>
-            </div>
-        `).join('');
+        const commentsHtml = comments.map(comment => this.createCommentHtml(comment)).join('');
 
         const currentUser = this.app.auth.getCurrentUser();
 
@@ -259,7 +255,7 @@ class PostsManager {
             </div>
             
             <div class="comments-section">
-                <h3>Comments (${comments.length})</h3>
+                <h3>Comments (<span class="comments-count">${comments.length}</span>)</h3>
                 
                 ${currentUser ? `
                     <form class="comment-form" onsubmit="app.posts.handleCreateComment(event)">
@@ -275,6 +271,57 @@ class PostsManager {
         `;
     }
 
+    /**
+     * Render one comment of the post detail view
+     */
+    createCommentHtml(comment) {
+        return `
+            <div class="comment" data-comment-id="${this.app.ui.escapeHtml(comment.ID || '')}">
+                <div class="comment-header">
+                    <span class="comment-author">${this.app.ui.escapeHtml(comment.AuthorName || comment.authorName)}</span>
+                    <span class="comment-date">${this.app.ui.formatDate(comment.CreatedAt || comment.createdAt)}</span>
+                </div>
+                <div class="comment-content">${this.app.ui.escapeHtml(comment.Content || comment.content)}</div>
+            </div>
+        `;
+    }
+
+    /**
+     * Add a post pushed over the feed topic to the dashboard
+     */
+    handlePostCreated(message) {
+        const post = message.post;
+        if (!post || this.posts.some(p => p.ID === post.ID)) {
+            return;
+        }
+        this.posts.unshift(post);
+        this.renderPosts();
+    }
+
+    /**
+     * Append a comment pushed over the post topic to the open post
+     */
+    handleCommentCreated(message) {
+        const comment = message.comment;
+        if (!comment || message.post_id !== this.selectedPostId || this.app.ui.getCurrentView() !== 'post') {
+            return;
+        }
+
+        const list = document.querySelector('#post-detail .comments-list');
+        if (!list || list.querySelector(`[data-comment-id="${CSS.escape(comment.ID)}"]`)) {
+            return;
+        }
+        if (!list.querySelector('.comment')) {
+            list.innerHTML = '';
+        }
+        list.insertAdjacentHTML('beforeend', this.createCommentHtml(comment));
+
+        const count = document.querySelector('#post-detail .comments-count');
+        if (count) {
+            count.textContent = list.querySelectorAll('.comment').length;
+        }
+    }
+
     /**
      * Handle creating a new comment
      */
@@ -0,0 +1,144 @@
+/**
+ * Push Module
+ * Registers the browser for Web Push so messages and notifications reach
+ * the user while they are offline
+ */
+class PushNotifications {
+    constructor(app) {
+        this.app = app;
+        this.registration = null;
+        this.button = null;
+    }
+
+    /**
+     * Register the service worker and show the toggle when push is supported
+     */
+    async init() {
+        this.button = document.getElementById('push-btn');
+        if (!('serviceWorker' in navigator) || !('PushManager' in window) || !('Notification' in window)) {
+            return;
+        }
+
+        try {
+            this.registration = await navigator.serviceWorker.register('/sw.js');
+        } catch (error) {
+            console.error('Service worker registration failed:', error);
+            return;
+        }
+
+        if (!this.button.dataset.bound) {
+            this.button.addEventListener('click', () => this.toggle());
+            this.button.dataset.bound = 'true';
+        }
+        this.button.style.display = 'inline-block';
+
+        // Keep the server in sync with a subscription made in an earlier visit
+        const subscription = await this.registration.pushManager.getSubscription();
+        if (subscription && Notification.permission === 'granted') {
+            await this.register(subscription);
+        }
+        this.renderButton(!!subscription);
+    }
+
+    /**
+     * Turn push notifications on or off for this browser
+     */
+    async toggle() {
+        const subscription = await this.registration.pushManager.getSubscription();
+        if (subscription) {
+            await this.forget();
+            this.app.ui.showToast('Push notifications disabled', 'info');
+            return;
+        }
+
+        const permission = await Notification.requestPermission();
+        if (permission !== 'granted') {
+            this.app.ui.showToast('Notifications are blocked in this browser', 'warning');
+            return;
+        }
+
+        try {
+            const response = await fetch('/push/key', {
+                credentials: 'include',
+                headers: { 'X-Session-ID': this.app.auth.getCookie('session_id') }
+            });
+            const data = await response.json();
+            if (!response.ok) {
+                this.app.ui.showToast(data.error || 'Push notifications are not available', 'error');
+                return;
+            }
+
+            const created = await this.registration.pushManager.subscribe({
+                userVisibleOnly: true,
+                applicationServerKey: this.decodeKey(data.publicKey)
+            });
+            if (await this.register(created)) {
+                this.renderButton(true);
+                this.app.ui.showToast('Push notifications enabled', 'success');
+            }
+        } catch (error) {
+            console.error('Push subscription failed:', error);
+            this.app.ui.showToast('Could not enable push notifications', 'error');
+        }
+    }
+
+    /**
+     * Send a browser subscription to the server
+     */
+    async register(subscription) {
+        const response = await fetch('/push/subscriptions', {
+            method: 'POST',
+            credentials: 'include',
+            headers: {
+                'X-Session-ID': this.app.auth.getCookie('session_id'),
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify(subscription.toJSON())
+        });
+        return response.ok;
+    }
+
+    /**
+     * Drop this browser's subscription, e.g. before logging out so the next
+     * user of the browser doesn't get our messages
+     */
+    async forget() {
+        if (!this.registration) {
+            return;
+        }
+
+        try {
+            const subscription = await this.registration.pushManager.getSubscription();
+            if (!subscription) {
+                return;
+            }
+            await fetch(`/push/subscriptions?endpoint=${encodeURIComponent(subscription.endpoint)}`, {
+                method: 'DELETE',
+                credentials: 'include',
+                headers: { 'X-Session-ID': this.app.auth.getCookie('session_id') }
+            });
+            await subscription.unsubscribe();
+        } catch (error) {
+            console.error('Push unsubscribe failed:', error);
+        }
+        this.renderButton(false);
+    }
+
+    renderButton(enabled) {
+        if (!this.button) {
+            return;
+        }
+        this.button.innerHTML = enabled
+            ? '<i class="fa-solid fa-bell"></i>'
+            : '<i class="fa-solid fa-bell-slash"></i>';
+        this.button.title = enabled ? 'Disable push notifications' : 'Enable push notifications';
+    }
+
+    /**
+     * Turn the base64url VAPID key into the bytes pushManager expects
+     */
+    decodeKey(key) {
+        const padded = (key + '='.repeat((4 - key.length % 4) % 4)).replace(/-/g, '+').replace(/_/g, '/');
+        return Uint8Array.from(atob(padded), c => c.charCodeAt(0));
+    }
+}
@@ -2,37 +2,66 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"real-time-forum/config"
+	"real-time-forum/database"
 	"real-time-forum/handlers"
 	"real-time-forum/middleware"
 	"real-time-forum/models"
 	"real-time-forum/repositories"
 	"real-time-forum/services"
+	"real-time-forum/utils"
 	"strings"
+	"syscall"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 type Dependencies struct {
-	AuthService       services.AuthService
-	UserService       services.UserService
-	SessionService    services.SessionService
-	PostService       services.PostService
-	CategoriesService services.CategoriesService
-	CommentService    services.CommentsService
-	ChatService       services.ChatService
+	Config              *config.Config
+	Store               *Store
+	Hub                 *models.Hub
+	AuthService         services.AuthService
+	UserService         services.UserService
+	SessionService      services.SessionService
+	PostService         services.PostService
+	CategoriesService   services.CategoriesService
+	CommentService      services.CommentsService
+	ChatService         services.ChatService
+	SearchService       services.SearchService
+	MediaService        services.MediaService
+	ModerationService   services.ModerationService
+	SanctionService     services.SanctionService
+	AuditService        services.AuditService
+	NotificationService services.NotificationService
+	FeedService         services.FeedService
+	PushService         services.PushService
 }
 
 type Handlers struct {
-	AuthHandler      *handlers.AuthHandler
-	DashboardHandler *handlers.DashboardHandler
-	PostHandler      *handlers.PostHandler
-	CommentsHandler  *handlers.CommentsHandler
-	WebSocketHandler *handlers.WebSocketHandler
+	AuthHandler          *handlers.AuthHandler
+	DashboardHandler     *handlers.DashboardHandler
+	PostHandler          *handlers.PostHandler
+	CommentsHandler      *handlers.CommentsHandler
+	WebSocketHandler     *handlers.WebSocketHandler
+	SearchHandler        *handlers.SearchHandler
+	MediaHandler         *handlers.MediaHandler
+	CategoriesHandler    *handlers.CategoriesHandler
+	AdminHandler         *handlers.AdminHandler
+	ModerationHandler    *handlers.ModerationHandler
+	SanctionsHandler     *handlers.SanctionsHandler
+	BlocksHandler        *handlers.BlocksHandler
+	NotificationsHandler *handlers.NotificationsHandler
+	PushHandler          *handlers.PushHandler
+	DocsHandler          *handlers.DocsHandler
 }
 
 type Middlewares struct {
@@ -41,39 +70,123 @@ type Middlewares struct {
 }
 
 func main() {
+	// Flags, environment and config file; what is left is a subcommand
+	cfg, args, err := config.Load(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, commandUsage)
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	slog.SetDefault(utils.NewLogger(os.Stderr, cfg.Server.Level(), cfg.Server.LogFormat == "json"))
+
 	// Initialize database
-	db, err := sql.Open("sqlite3", "./database/forum.db")
+	store, err := OpenStore(context.Background(), cfg.Database)
 	if err != nil {
-		panic(err)
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	if len(args) > 0 {
+		code := runCommand(store.DB, store.Dialect, args)
+		store.Close()
+		os.Exit(code)
+	}
+
+	slog.Info("Configuration", "settings", cfg.Redacted())
+
+	// Bring the schema up to date before anything touches it
+	if cfg.Database.AutoMigrate {
+		migrator, err := database.NewMigrator(store.DB, store.Dialect)
+		if err != nil {
+			log.Fatalf("Failed to load migrations: %v", err)
+		}
+		if _, err := migrator.Up(context.Background()); err != nil {
+			log.Fatalf("Database migration failed: %v", err)
+		}
 	}
-	defer db.Close()
 
 	// Setup dependencies and handlers
-	deps := SetupDependencies(db)
+	deps := SetupDependencies(store, cfg)
 
-	go deps.ChatService.Hub.Run() // start the hub
+	// Promote the first admin when the forum has none yet
+	if err := deps.UserService.BootstrapAdmin(context.Background(), cfg.Security.BootstrapAdmin); err != nil {
+		slog.Error("Admin bootstrap failed", "err", err)
+	}
+
+	// SIGINT and SIGTERM cancel ctx, which stops the hub and background tasks
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// start the hub
+	hubDone := make(chan struct{})
+	go func() {
+		deps.Hub.Run(ctx)
+		close(hubDone)
+	}()
 
-	handlerInstances := SetupHandlers(deps)
-	middlewareInstances := SetupMiddleware(deps)
+	handlerInstances := SetupHandlers(deps, cfg)
+	middlewareInstances := SetupMiddleware(deps, cfg)
 
 	// Setup routes
 	mux := http.NewServeMux()
 	Configure(mux, handlerInstances, deps, middlewareInstances)
 
 	// Start background tasks
-	go BackgroundTasks(deps.SessionService, deps.UserService)
+	tasksDone := make(chan struct{})
+	go func() {
+		BackgroundTasks(ctx, deps.SessionService, deps.UserService, cfg.Server.CleanupInterval)
+		close(tasksDone)
+	}()
+
+	server := &http.Server{Addr: cfg.Server.Addr, Handler: mux}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	println("Server listening on", cfg.Server.Addr)
+	println("Open " + browseURL(cfg.Server.Addr) + " in your browser to view the forum")
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("Server failed: %v", err)
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process right away
+
+	slog.Info("Shutting down", "timeout", cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and drain in-flight requests. WebSocket
+	// connections are hijacked, so the hub closes those itself.
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("HTTP shutdown incomplete", "err", err)
+	}
+	waits := []struct {
+		name string
+		done chan struct{}
+	}{{"hub", hubDone}, {"background tasks", tasksDone}}
+	for _, wait := range waits {
+		select {
+		case <-wait.done:
+		case <-shutdownCtx.Done():
+			slog.Warn("Gave up waiting", "for", wait.name)
+		}
+	}
 
-	port := ":8080"
-	println("Server listening on", port)
-	println("Open http://localhost:8080 in your browser to view the forum")
-	if err := http.ListenAndServe(port, mux); err != nil {
-		panic(err)
+	if err := store.Close(); err != nil {
+		slog.Error("Failed to close database", "err", err)
 	}
+	slog.Info("Server stopped")
 }
 
 func Configure(mux *http.ServeMux, h *Handlers, deps *Dependencies, m *Middlewares) {
+	configureAPI(mux, h, m)
 
-	// API routes
+	// Legacy API routes, aliases of /api/v1 kept while the frontend moves over
 	mux.Handle("/register", m.LoggingMiddleware.Log(http.HandlerFunc(h.AuthHandler.Register)))
 	mux.Handle("/login", m.LoggingMiddleware.Log(http.HandlerFunc(h.AuthHandler.Login)))
 	mux.Handle("/logout", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.AuthHandler.LogOut))))
@@ -84,36 +197,63 @@ func Configure(mux *http.ServeMux, h *Handlers, deps *Dependencies, m *Middlewar
 	mux.Handle("/post", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.PostHandler.ViewPost))))
 	mux.Handle("/post/createcomment", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.CommentsHandler.CreateComment))))
 	mux.Handle("/category/", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.DashboardHandler.PostsByCategory))))
+	mux.Handle("/subscriptions", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.CategoriesHandler.Subscriptions))))
+	mux.Handle("/search", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.SearchHandler.Search))))
+	mux.Handle("/blocks", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.BlocksHandler.Blocks))))
+	mux.Handle("/notifications", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.NotificationsHandler.Notifications))))
+	mux.Handle("/notifications/read", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.NotificationsHandler.MarkRead))))
+	mux.Handle("/push/key", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.PushHandler.PublicKey))))
+	mux.Handle("/push/subscriptions", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.PushHandler.Subscriptions))))
+	mux.Handle("/report/post", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.ModerationHandler.ReportPost))))
+	mux.Handle("/report/comment", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.ModerationHandler.ReportComment))))
+	mux.Handle("/report/message", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.ModerationHandler.ReportMessage))))
+
+	// Moderation routes
+	mux.Handle("/moderation/reports", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(m.AuthMiddleware.RequireRole(models.RoleModerator)(http.HandlerFunc(h.ModerationHandler.Reports)))))
+	mux.Handle("/moderation/reports/", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(m.AuthMiddleware.RequireRole(models.RoleModerator)(http.HandlerFunc(h.ModerationHandler.Report)))))
+	mux.Handle("/moderation/sanctions", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(m.AuthMiddleware.RequireRole(models.RoleModerator)(http.HandlerFunc(h.SanctionsHandler.Sanctions)))))
+	mux.Handle("/moderation/sanctions/", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(m.AuthMiddleware.RequireRole(models.RoleModerator)(http.HandlerFunc(h.SanctionsHandler.Sanction)))))
+
+	// Admin routes
+	mux.Handle("/admin/categories", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(m.AuthMiddleware.RequireRole(models.RoleAdmin)(http.HandlerFunc(h.CategoriesHandler.Categories)))))
+	mux.Handle("/admin/categories/", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(m.AuthMiddleware.RequireRole(models.RoleAdmin)(http.HandlerFunc(h.CategoriesHandler.Category)))))
+	mux.Handle("/admin/users/role", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(m.AuthMiddleware.RequireRole(models.RoleAdmin)(http.HandlerFunc(h.AdminHandler.SetUserRole)))))
+	mux.Handle("/admin/audit", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(m.AuthMiddleware.RequireRole(models.RoleAdmin)(http.HandlerFunc(h.AdminHandler.AuditEvents)))))
+	mux.Handle("/admin/db/stats", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(m.AuthMiddleware.RequireRole(models.RoleAdmin)(http.HandlerFunc(h.AdminHandler.DBStats)))))
+	mux.Handle("/admin/config", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(m.AuthMiddleware.RequireRole(models.RoleAdmin)(http.HandlerFunc(h.AdminHandler.Config)))))
 
 	mux.Handle("/validate-session", m.LoggingMiddleware.Log(http.HandlerFunc(h.AuthHandler.CheckSession)))
 
 	// WebSocket routes
-	mux.Handle("/chathistory",
-		m.AuthMiddleware.Authorize(
-			m.LoggingMiddleware.Log(http.HandlerFunc(h.WebSocketHandler.ChatHistory)),
-		),
-	)
-	mux.Handle("/ws", m.AuthMiddleware.Authorize(m.LoggingMiddleware.Log(http.HandlerFunc(h.WebSocketHandler.WebSocket))))
+	mux.Handle("/chathistory", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.WebSocketHandler.ChatHistory))))
+	mux.Handle("/ws", m.LoggingMiddleware.Log(m.AuthMiddleware.Authorize(http.HandlerFunc(h.WebSocketHandler.WebSocket))))
 
 	// Static files
+	staticDir := deps.Config.Server.StaticDir
 	mux.HandleFunc("/style.css", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "style.css")
+		http.ServeFile(w, r, filepath.Join(staticDir, "style.css"))
 	})
 	mux.HandleFunc("/app.js", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "app.js")
+		http.ServeFile(w, r, filepath.Join(staticDir, "app.js"))
 	})
+	// The service worker lives at the root so its scope covers the whole app
+	mux.HandleFunc("/sw.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript")
+		http.ServeFile(w, r, filepath.Join(staticDir, "sw.js"))
+	})
+
+	// Uploaded images
+	mux.Handle("/media/", http.HandlerFunc(h.MediaHandler.ServeMedia))
 
 	// JavaScript modules directory
 	mux.HandleFunc("/js/", func(w http.ResponseWriter, r *http.Request) {
 		// Set proper MIME type for JavaScript files
 		w.Header().Set("Content-Type", "application/javascript")
-		http.ServeFile(w, r, r.URL.Path[1:]) // Remove leading slash
+		http.ServeFile(w, r, filepath.Join(staticDir, filepath.FromSlash(r.URL.Path[1:]))) // Remove leading slash
 	})
 
 	// Root handler
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Printf("Root handler: %s %s %s\n", r.Method, r.URL.Path, r.Header.Get("Accept"))
-
+	mux.Handle("/", m.LoggingMiddleware.Log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// If this is a request for JSON data (either through Accept header or query param)
 		wantsJSON := strings.Contains(r.Header.Get("Accept"), "application/json") ||
 			r.URL.Query().Get("format") == "json"
@@ -124,67 +264,145 @@ func Configure(mux *http.ServeMux, h *Handlers, deps *Dependencies, m *Middlewar
 		}
 
 		// Serve SPA for HTML requests or when no specific format is requested
-		http.ServeFile(w, r, "index.html")
-	})
+		http.ServeFile(w, r, filepath.Join(staticDir, "index.html"))
+	})))
 }
 
-func SetupDependencies(db *sql.DB) *Dependencies {
+func SetupDependencies(store *Store, cfg *config.Config) *Dependencies {
 
 	// Models
 	hub := models.NewHub()
 
 	// Repositories
-	userRepo := repositories.NewUserRepository(db)
-	sessionRepo := repositories.NewSessionRepository(db)
-	postRepo := repositories.NewPostRepository(db)
-	categoriesRepo := repositories.NewCategoriesRepository(db)
-	commentRepo := repositories.NewCommentRepository(db)
-	messagesRepo := repositories.NewMessageRepository(db)
+	repos := store.Repositories
 
 	// Services
-	userService := services.NewUserService(*userRepo)
-	authService := services.NewAuthService(*userRepo)
-	sessionService := services.NewSessionService(*sessionRepo)
-	postService := services.NewPostService(*postRepo)
-	categoriesService := services.NewCategoriesService(*categoriesRepo)
-	commentService := services.NewCommentsService(*commentRepo)
-	chatService := services.NewChatService(messagesRepo, hub)
+	auditService := services.NewAuditService(repos.Audit)
+	userService := services.NewUserService(repos.Users, auditService, hub)
+	authService := services.NewAuthService(repos.Users, auditService)
+	sessionService := services.NewSessionService(repos.Sessions, auditService, cfg.Security.SessionTTL)
+	moderationService := services.NewModerationService(repos.Reports, repos.Posts, repos.Comments, repos.Messages, auditService, hub)
+	contentFilterService := services.NewContentFilterService(moderationService,
+		services.NewWordListFilter(wordList(cfg.Filters.RejectedWords, services.DefaultRejectedWords), wordList(cfg.Filters.FlaggedWords, services.DefaultFlaggedWords)),
+		services.NewLinkLimitFilter(cfg.Filters.LinkFlagAbove, cfg.Filters.LinkRejectAbove),
+		services.NewRepeatedMessageFilter(cfg.Filters.RepeatWindow, cfg.Filters.RepeatMax),
+		services.NewDuplicatePostFilter(repos.Posts, cfg.Filters.DuplicateWindow),
+	)
+	pushService := setupPushService(repos.Push, hub, cfg.Push)
+	notificationService := services.NewNotificationService(repos.Notifications, pushService, hub)
+	mentionService := services.NewMentionService(repos.Mentions, notificationService)
+	feedService := services.NewFeedService(hub)
+	postService := services.NewPostService(repos.Posts, contentFilterService, notificationService, mentionService, feedService)
+	categoriesService := services.NewCategoriesService(repos.Categories)
+	commentService := services.NewCommentsService(repos.Comments, contentFilterService, notificationService, mentionService, feedService)
+	chatService := services.NewChatService(repos.Messages, repos.Sanctions, repos.Blocks, repos.Users, contentFilterService, mentionService, pushService, hub)
+	searchService := services.NewSearchService(repos.Search)
+	mediaService := services.NewMediaService(cfg.Server.MediaDir)
+	sanctionService := services.NewSanctionService(repos.Sanctions, repos.Users, sessionService, auditService, hub)
 
 	return &Dependencies{
-		UserService:       *userService,
-		AuthService:       *authService,
-		SessionService:    *sessionService,
-		PostService:       *postService,
-		CategoriesService: *categoriesService,
-		CommentService:    *commentService,
-		ChatService:       *chatService,
+		Config:              cfg,
+		Store:               store,
+		Hub:                 hub,
+		UserService:         userService,
+		AuthService:         authService,
+		SessionService:      sessionService,
+		PostService:         postService,
+		CategoriesService:   categoriesService,
+		CommentService:      commentService,
+		ChatService:         chatService,
+		SearchService:       searchService,
+		MediaService:        mediaService,
+		ModerationService:   moderationService,
+		SanctionService:     sanctionService,
+		AuditService:        auditService,
+		NotificationService: notificationService,
+		FeedService:         feedService,
+		PushService:         pushService,
+	}
+}
+
+// setupPushService uses the configured VAPID keys, or loads them from the
+// database and creates them on first boot. Without keys the forum still runs,
+// only without Web Push.
+func setupPushService(repo repositories.PushRepository, hub *models.Hub, cfg config.PushConfig) services.PushService {
+	keys := &models.VAPIDKeys{PublicKey: cfg.VAPIDPublicKey, PrivateKey: cfg.VAPIDPrivateKey}
+	if keys.PublicKey == "" {
+		var err error
+		keys, err = services.LoadVAPIDKeys(context.Background(), repo)
+		if err != nil {
+			slog.Warn("Web Push disabled: failed to load VAPID keys", "err", err)
+			return services.NewPushService(repo, nil, hub, "")
+		}
+	}
+
+	sender, err := services.NewWebPushSender(*keys, cfg.VAPIDSubject, nil)
+	if err != nil {
+		slog.Warn("Web Push disabled", "err", err)
+		return services.NewPushService(repo, nil, hub, "")
+	}
+	return services.NewPushService(repo, sender, hub, keys.PublicKey)
+}
+
+// wordList is the configured filter word list, or the built-in one when none
+// is configured
+func wordList(configured config.WordList, builtin []string) []string {
+	if len(configured) == 0 {
+		return builtin
 	}
+	return configured
 }
 
-func SetupHandlers(deps *Dependencies) *Handlers {
+func SetupHandlers(deps *Dependencies, cfg *config.Config) *Handlers {
 	// Handlers
 	return &Handlers{
-		AuthHandler:      handlers.NewAuthHandler(deps.AuthService, deps.SessionService),
-		CommentsHandler:  handlers.NewCommentsHandler(deps.PostService, deps.CommentService, deps.CategoriesService, deps.UserService),
-		DashboardHandler: handlers.NewDashboardHandler(deps.PostService, deps.CategoriesService, deps.UserService),
-		PostHandler:      handlers.NewPostHandler(deps.PostService, deps.CategoriesService, deps.CommentService, deps.UserService),
-		WebSocketHandler: handlers.NewWebSocketHandler(&deps.ChatService),
+		AuthHandler:          handlers.NewAuthHandler(deps.AuthService, deps.SessionService, deps.SanctionService, deps.AuditService, cfg.Security.SecureCookies),
+		CommentsHandler:      handlers.NewCommentsHandler(deps.PostService, deps.CommentService, deps.CategoriesService, deps.UserService),
+		DashboardHandler:     handlers.NewDashboardHandler(deps.PostService, deps.CategoriesService, deps.UserService),
+		PostHandler:          handlers.NewPostHandler(deps.PostService, deps.CategoriesService, deps.CommentService, deps.UserService, deps.MediaService),
+		WebSocketHandler:     handlers.NewWebSocketHandler(deps.ChatService, deps.FeedService, deps.Hub),
+		SearchHandler:        handlers.NewSearchHandler(deps.SearchService),
+		MediaHandler:         handlers.NewMediaHandler(deps.MediaService),
+		CategoriesHandler:    handlers.NewCategoriesHandler(deps.CategoriesService),
+		AdminHandler:         handlers.NewAdminHandler(deps.UserService, deps.AuditService, cfg.Redacted(), deps.Store.Stats),
+		ModerationHandler:    handlers.NewModerationHandler(deps.ModerationService),
+		SanctionsHandler:     handlers.NewSanctionsHandler(deps.SanctionService),
+		BlocksHandler:        handlers.NewBlocksHandler(deps.ChatService),
+		NotificationsHandler: handlers.NewNotificationsHandler(deps.NotificationService),
+		PushHandler:          handlers.NewPushHandler(deps.PushService),
+		DocsHandler:          handlers.NewDocsHandler(),
 	}
 }
 
-func SetupMiddleware(deps *Dependencies) *Middlewares {
+func SetupMiddleware(deps *Dependencies, cfg *config.Config) *Middlewares {
 	return &Middlewares{
-		LoggingMiddleware: middleware.NewLoggingMiddleware(),
-		AuthMiddleware:    middleware.NewAuthMiddleware(deps.UserService),
+		LoggingMiddleware: middleware.NewLoggingMiddleware(slog.Default(), cfg.Security.TrustProxy),
+		AuthMiddleware:    middleware.NewAuthMiddleware(deps.UserService, deps.SanctionService),
 	}
 }
-func BackgroundTasks(sessionService services.SessionService, userService services.UserService) {
-	ticker := time.NewTicker(5 * time.Minute)
+
+// BackgroundTasks runs the periodic cleanup until ctx is cancelled
+func BackgroundTasks(ctx context.Context, sessionService services.SessionService, userService services.UserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		if err := sessionService.CleanupExpiredSessions(context.Background()); err != nil {
-			log.Printf("Session cleanup error: %v", err)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := sessionService.CleanupExpiredSessions(ctx); err != nil {
+				slog.Error("Session cleanup failed", "err", err)
+			}
 		}
 	}
 }
+
+// browseURL turns the listen address into a URL for the startup message
+func browseURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
@@ -2,19 +2,22 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"net/http"
+	"real-time-forum/respond"
 	"real-time-forum/services"
 	"real-time-forum/utils"
 )
 
 type AuthMiddleware struct{
-	UserService services.UserService
+	UserService     services.UserService
+	SanctionService services.SanctionService
 }
 
-func NewAuthMiddleware(us services.UserService) *AuthMiddleware {
+func NewAuthMiddleware(us services.UserService, ss services.SanctionService) *AuthMiddleware {
 	return &AuthMiddleware{
-		UserService: us,
+		UserService:     us,
+		SanctionService: ss,
 	}
 }
 
@@ -28,24 +31,57 @@ func (m *AuthMiddleware) Authorize(next http.Handler) http.Handler {
         }
 
 		if sessionID == "" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			respond.Unauthorized(w, r, "Unauthorized")
 			return
 		}
 		// Get user from session
 		user, err := m.UserService.GetUserBySessionID(r.Context(), sessionID)
+		if errors.Is(err, services.ErrInvalidSession) {
+			respond.Unauthorized(w, r, "Invalid or expired session")
+			return
+		}
+		if err != nil {
+			respond.Internal(w, r, "Internal server error")
+			return
+		}
+
+		// Banned and suspended users are locked out of everything
+		ban, err := m.SanctionService.ActiveBan(r.Context(), user.ID)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
+			respond.Internal(w, r, "Internal server error")
+			return
+		}
+		if ban != nil {
+			respond.Banned(w, r, ban)
 			return
 		}
 
 		// Add user to context
 		ctx := context.WithValue(r.Context(), utils.ContextUser, user)
+		ctx = setUser(ctx, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
-	
 
+// RequireRole only lets through users holding at least the given role. It
+// must be wrapped by Authorize so the user is already in the context:
+//
+//	m.Authorize(m.RequireRole(models.RoleAdmin)(handler))
+func (m *AuthMiddleware) RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := utils.GetUserFromContext(r.Context())
+			if user == nil {
+				respond.Unauthorized(w, r, "Unauthorized")
+				return
+			}
+
+			if !user.HasRole(role) {
+				respond.Fail(w, r, http.StatusForbidden, respond.CodeForbidden, "Forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"real-time-forum/models"
+	"real-time-forum/utils"
+	"testing"
+)
+
+func TestRequireRole(t *testing.T) {
+	cases := []struct {
+		name string
+		user *models.User
+		role string
+		want int
+	}{
+		{"no user", nil, models.RoleModerator, http.StatusUnauthorized},
+		{"user below moderator", &models.User{ID: "u1", Role: models.RoleUser}, models.RoleModerator, http.StatusForbidden},
+		{"moderator", &models.User{ID: "u1", Role: models.RoleModerator}, models.RoleModerator, http.StatusOK},
+		{"admin outranks moderator", &models.User{ID: "u1", Role: models.RoleAdmin}, models.RoleModerator, http.StatusOK},
+		{"moderator below admin", &models.User{ID: "u1", Role: models.RoleModerator}, models.RoleAdmin, http.StatusForbidden},
+	}
+	m := NewAuthMiddleware(nil, nil)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/moderation/reports", nil)
+			if c.user != nil {
+				r = r.WithContext(context.WithValue(r.Context(), utils.ContextUser, c.user))
+			}
+			w := httptest.NewRecorder()
+			m.RequireRole(c.role)(ok).ServeHTTP(w, r)
+
+			if w.Code != c.want {
+				t.Errorf("status = %d, want %d", w.Code, c.want)
+			}
+		})
+	}
+}
@@ -1,21 +1,180 @@
 package middleware
 
 import (
-	"fmt"
+	"bufio"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"real-time-forum/models"
+	"real-time-forum/utils"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
 )
 
-type LoggingMiddleware struct{}
+type LoggingMiddleware struct {
+	logger     *slog.Logger
+	trustProxy bool
+}
 
-func NewLoggingMiddleware() *LoggingMiddleware {
-	return &LoggingMiddleware{}
+// NewLoggingMiddleware returns the logging middleware, which writes one
+// record per request to logger. With trustProxy the client address is taken
+// from the X-Forwarded-For header set by a reverse proxy in front of the
+// forum.
+func NewLoggingMiddleware(logger *slog.Logger, trustProxy bool) *LoggingMiddleware {
+	return &LoggingMiddleware{logger: logger, trustProxy: trustProxy}
 }
 
-// Log wraps an http.Handler and returns a new http.Handler that logs the request
-// before delegating to the next handler
+// Log wraps an http.Handler and returns a new http.Handler that logs the
+// request once it has been served: status, bytes written, latency, user and
+// client address. It gives the request an ID that is echoed in the
+// X-Request-ID header and in error responses, and puts a logger tagged with
+// that ID in the request context for handlers and services. The client
+// address also goes into the context for the audit log.
 func (m *LoggingMiddleware) Log(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Printf("%s %s %s\n", r.Method, r.URL.Path, r.Proto)
-		next.ServeHTTP(w, r)
+		start := time.Now()
+		id := requestID(r)
+		ip := m.clientIP(r)
+		entry := &accessEntry{logger: m.logger.With("request_id", id)}
+
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), utils.ContextClientIP, ip)
+		ctx = context.WithValue(ctx, utils.ContextRequestID, id)
+		ctx = context.WithValue(ctx, contextAccessEntry, entry)
+		ctx = utils.WithLogger(ctx, entry.logger)
+		if user := utils.GetUserFromContext(ctx); user != nil {
+			ctx = setUser(ctx, user)
+		}
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if rec.statusCode() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		entry.logger.LogAttrs(ctx, level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("proto", r.Proto),
+			slog.Int("status", rec.statusCode()),
+			slog.Int("bytes", rec.bytes),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("remote_addr", ip),
+		)
 	})
-}
\ No newline at end of file
+}
+
+const contextAccessEntry contextKey = "access_entry"
+
+type contextKey string
+
+// accessEntry is shared with the middleware further in, so the request is
+// logged with the user Authorize found
+type accessEntry struct {
+	logger *slog.Logger
+	userID string
+}
+
+// setUser tags the request logger, and the access log record, with the
+// authenticated user
+func setUser(ctx context.Context, user *models.User) context.Context {
+	entry, ok := ctx.Value(contextAccessEntry).(*accessEntry)
+	if !ok || entry.userID != "" {
+		return ctx
+	}
+	entry.userID = user.ID
+	entry.logger = entry.logger.With("user_id", user.ID)
+	return utils.WithLogger(ctx, entry.logger)
+}
+
+// responseRecorder notes the status and size of a response. It passes
+// Hijack through for the WebSocket upgrade and Flush for streaming.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// statusCode is the status sent, 200 when the handler wrote nothing
+func (r *responseRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// requestID keeps the X-Request-ID a client or proxy sent, as long as it is
+// short and plain enough to log, and makes up a new one otherwise
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-ID"); id != "" && len(id) <= 64 && strings.Trim(id, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.") == "" {
+		return id
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "-"
+	}
+	return id.String()
+}
+
+// clientIP returns the address of the client. Behind a trusted proxy it is
+// the last X-Forwarded-For entry, the one the proxy appended itself; earlier
+// entries come from the client and can be forged.
+func (m *LoggingMiddleware) clientIP(r *http.Request) string {
+	if m.trustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); net.ParseIP(ip) != nil {
+				return ip
+			}
+		}
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
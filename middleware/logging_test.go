@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"real-time-forum/models"
+	"real-time-forum/utils"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	var out bytes.Buffer
+	m := NewLoggingMiddleware(utils.NewLogger(&out, slog.LevelDebug, true), false)
+
+	var handlerID string
+	handler := m.Log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := setUser(r.Context(), &models.User{ID: "u1"})
+		handlerID = utils.GetRequestIDFromContext(ctx)
+		utils.Logger(ctx).Info("creating comment", "content", "my phone number is 555-0100")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/posts/p1/comments", nil)
+	r.Header.Set("X-Request-ID", "req-1")
+	r.RemoteAddr = "192.0.2.1:4321"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if handlerID != "req-1" || w.Header().Get("X-Request-ID") != "req-1" {
+		t.Fatalf("request ID was not propagated: context %q, header %q", handlerID, w.Header().Get("X-Request-ID"))
+	}
+
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var record map[string]interface{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want the handler's and the access log", len(records))
+	}
+
+	service, access := records[0], records[1]
+	if service["request_id"] != "req-1" || service["user_id"] != "u1" || service["content"] != "[redacted]" {
+		t.Errorf("handler record = %v", service)
+	}
+	want := map[string]interface{}{
+		"msg": "request", "request_id": "req-1", "user_id": "u1", "method": "POST",
+		"path": "/api/v1/posts/p1/comments", "status": 201.0, "bytes": 5.0, "remote_addr": "192.0.2.1",
+	}
+	for key, value := range want {
+		if access[key] != value {
+			t.Errorf("access record %s = %v, want %v", key, access[key], value)
+		}
+	}
+	if _, ok := access["latency"]; !ok {
+		t.Error("access record has no latency")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	cases := []struct {
+		header string
+		keep   bool
+	}{
+		{"abc-123_x.y", true},
+		{"", false},
+		{"bad id\nwith a newline", false},
+		{string(bytes.Repeat([]byte("a"), 65)), false},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Request-ID", c.header)
+		id := requestID(r)
+		if (id == c.header) != c.keep || id == "" {
+			t.Errorf("requestID(%q) = %q, want kept = %v", c.header, id, c.keep)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// Audit event actions, grouped by area
+const (
+	AuditRegister        = "auth.register"
+	AuditLogin           = "auth.login"
+	AuditLoginFailed     = "auth.login_failed"
+	AuditLoginRefused    = "auth.login_refused"
+	AuditLogout          = "auth.logout"
+	AuditSessionsRevoked = "session.revoked"
+	AuditRoleChanged     = "user.role_changed"
+	AuditReportClaimed   = "moderation.report_claimed"
+	AuditReportResolved  = "moderation.report_resolved"
+	AuditUserWarned      = "moderation.user_warned"
+	AuditSanctionCreated = "moderation.sanction_created"
+	AuditSanctionRevoked = "moderation.sanction_revoked"
+	AuditContentHidden   = "content.hidden"
+	AuditContentDeleted  = "content.deleted"
+)
+
+type AuditEvent struct {
+	ID         int64
+	Action     string
+	ActorID    string
+	ActorName  string
+	TargetType string
+	TargetID   string
+	IP         string
+	Metadata   map[string]interface{}
+	CreatedAt  time.Time
+}
+
+type AuditFilter struct {
+	Action     string
+	Actor      string
+	TargetType string
+	TargetID   string
+	IP         string
+	Since      *time.Time
+	Until      *time.Time
+	Limit      int
+	Offset     int
+}
@@ -0,0 +1,11 @@
+package models
+
+import (
+	"time"
+)
+
+type BlockedUser struct {
+	UserID    string
+	Nickname  string
+	CreatedAt time.Time
+}
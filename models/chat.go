@@ -2,6 +2,7 @@ package models
 
 import (
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -14,13 +15,31 @@ type Message struct {
 	To        string    `json:"to"`
 	Content   string    `json:"content"`
 	Timestamp time.Time `json:"timestamp"`
+	Mentions  []Mention `json:"mentions,omitempty"`
+}
+
+// TopicFrame is sent by clients to subscribe to or unsubscribe from a topic,
+// e.g. {"type":"subscribe","topic":"post:42"}
+type TopicFrame struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
 }
 
 type Client struct {
 	ID       string
 	Username string
+	Role     string
 	Conn     *websocket.Conn
 	Send     chan []byte
+	// CloseReason is set when the server drops the client on purpose, e.g.
+	// after a ban, and is sent to it in the close frame
+	CloseReason string
+	// CloseCode overrides the close frame status code, e.g. on shutdown
+	CloseCode int
+	// Done is closed by the write pump once the connection is closed
+	Done chan struct{}
+	// dropped is set, under the hub lock, once Send is closed
+	dropped bool
 }
 
 type Hub struct {
@@ -30,6 +49,57 @@ type Hub struct {
 	Broadcast   chan []byte
 	UserClients map[string]*Client
 	UserSorter  UserSorter // Function to sort users based on chat history
+	UserFilter  UserFilter // Function to hide users from each other, e.g. blocked ones
+
+	// mu guards Clients, UserClients and topics. Only Run adds and drops
+	// clients, but the read pumps and request handlers look them up, and
+	// subscribe and publish to topics.
+	mu     sync.RWMutex
+	topics map[string]map[*Client]bool
+
+	// deliveries carries messages for some of the clients to Run, the only
+	// goroutine allowed to drop a client that cannot keep up
+	deliveries chan delivery
+	// disconnects asks Run to drop every connection of a user
+	disconnects chan disconnection
+	// roleChanges asks Run to update the role of a user's connections
+	roleChanges chan roleChange
+
+	// presence queues joins and leaves for announcePresence, which runs the
+	// block list and sort queries they need away from Run. The queue has no
+	// bound so Run never waits on the database.
+	presenceMu    sync.Mutex
+	presence      []presenceEvent
+	presenceReady chan struct{}
+
+	// done is closed when Run starts shutting down; senders on Register,
+	// Unregister and Broadcast select on it so they never block forever
+	done chan struct{}
+}
+
+// delivery is a message for the connections of one user or of one role
+type delivery struct {
+	username string
+	role     string
+	message  []byte
+}
+
+// disconnection drops the connections of a user, telling them why
+type disconnection struct {
+	username string
+	reason   string
+}
+
+// roleChange gives the connections of a user a new role
+type roleChange struct {
+	username string
+	role     string
+}
+
+// presenceEvent is a user joining or leaving the chat
+type presenceEvent struct {
+	username  string
+	eventType string
 }
 
 var Upgrader = websocket.Upgrader{
@@ -11,8 +11,10 @@ type Comment struct {
 	PostTitle     string
 	AuthorName    string
 	Content       string
+	ContentHTML   string
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
+	Mentions      []Mention
 }
 
 
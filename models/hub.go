@@ -3,22 +3,34 @@ package models
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"sort"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 // UserSorter is a function type for sorting users based on chat history
 type UserSorter func(ctx context.Context, currentUser string, users []string) ([]string, error)
 
+// UserFilter is a function type returning the users that currentUser may see
+type UserFilter func(ctx context.Context, currentUser string, users []string) ([]string, error)
+
 func NewHub() *Hub {
 	return &Hub{
-		Clients:     make(map[*Client]bool),
-		Register:    make(chan *Client),
-		Unregister:  make(chan *Client),
-		Broadcast:   make(chan []byte),
-		UserClients: make(map[string]*Client),
-		UserSorter:  nil, // Will be set later by the service
+		Clients:       make(map[*Client]bool),
+		Register:      make(chan *Client),
+		Unregister:    make(chan *Client),
+		Broadcast:     make(chan []byte),
+		deliveries:    make(chan delivery, 256),
+		disconnects:   make(chan disconnection),
+		roleChanges:   make(chan roleChange),
+		presenceReady: make(chan struct{}, 1),
+		UserClients:   make(map[string]*Client),
+		topics:        make(map[string]map[*Client]bool),
+		done:          make(chan struct{}),
+		UserSorter:    nil, // Will be set later by the service
+		UserFilter:    nil, // Will be set later by the service
 	}
 }
 
@@ -27,26 +39,52 @@ func (h *Hub) SetUserSorter(sorter UserSorter) {
 	h.UserSorter = sorter
 }
 
-func (h *Hub) Run() {
+// SetUserFilter sets the function deciding which online users a user may see
+func (h *Hub) SetUserFilter(filter UserFilter) {
+	h.UserFilter = filter
+}
+
+// ShutdownReason is sent to every client in the close frame when the server
+// stops
+const ShutdownReason = "server restarting"
+
+// Run serves the hub until ctx is cancelled, then closes every connection
+// with a service restart close frame and returns once they are all closed
+func (h *Hub) Run(ctx context.Context) {
+	go h.announcePresence()
+
 	for {
 		select {
+		case <-ctx.Done():
+			h.shutdown()
+			return
+
 		case client := <-h.Register:
+			h.mu.Lock()
 			h.Clients[client] = true
 			h.UserClients[client.Username] = client
-			log.Printf("%s connected", client.Username)
+			h.mu.Unlock()
+			slog.Info("Chat client connected", "nickname", client.Username)
 
 			// Broadcast user join event
 			h.broadcastUserStatusChange(client.Username, "user_joined")
 
 		case client := <-h.Unregister:
-			if _, ok := h.Clients[client]; ok {
-				delete(h.Clients, client)
-				delete(h.UserClients, client.Username)
-				close(client.Send)
-				log.Printf("%s disconnected", client.Username)
-
-				// Broadcast user leave event
-				h.broadcastUserStatusChange(client.Username, "user_left")
+			h.unregister(client)
+
+		case d := <-h.disconnects:
+			for client := range h.Clients {
+				if client.Username == d.username {
+					client.CloseReason = d.reason
+					h.unregister(client)
+				}
+			}
+
+		case c := <-h.roleChanges:
+			for client := range h.Clients {
+				if client.Username == c.username {
+					client.Role = c.role
+				}
 			}
 
 		case message := <-h.Broadcast:
@@ -54,28 +92,244 @@ func (h *Hub) Run() {
 				select {
 				case client.Send <- message:
 				default:
-					close(client.Send)
-					delete(h.Clients, client)
+					h.dropClient(client)
 				}
 			}
+
+		case d := <-h.deliveries:
+			h.deliver(d)
+		}
+	}
+}
+
+// unregister drops a client that is still connected and tells the others
+func (h *Hub) unregister(client *Client) {
+	if _, ok := h.Clients[client]; !ok {
+		return
+	}
+	h.dropClient(client)
+	slog.Info("Chat client disconnected", "nickname", client.Username)
+
+	// Broadcast user leave event
+	h.broadcastUserStatusChange(client.Username, "user_left")
+}
+
+// Stopped is closed once the hub shuts down and no longer takes clients or
+// messages
+func (h *Hub) Stopped() <-chan struct{} {
+	return h.done
+}
+
+func (h *Hub) shutdown() {
+	close(h.done)
+
+	clients := make([]*Client, 0, len(h.Clients))
+	for client := range h.Clients {
+		client.CloseCode = websocket.CloseServiceRestart
+		client.CloseReason = ShutdownReason
+		clients = append(clients, client)
+		h.dropClient(client)
+	}
+
+	// Wait for the write pumps to flush and send their close frames
+	for _, client := range clients {
+		if client.Done != nil {
+			<-client.Done
 		}
 	}
+	slog.Info("Hub stopped", "closed", len(clients))
 }
 
+// dropClient forgets a client and closes its send channel, which ends its
+// write pump. Its topic subscriptions go first so Publish never sends on the
+// closed channel. Only Run may call it.
+func (h *Hub) dropClient(client *Client) {
+	h.mu.Lock()
+	client.dropped = true
+	delete(h.Clients, client)
+	if h.UserClients[client.Username] == client {
+		delete(h.UserClients, client.Username)
+	}
+	for topic, clients := range h.topics {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	h.mu.Unlock()
+	close(client.Send)
+}
+
+// SendToUser sends a message to every connection of the user. It is safe to
+// call from any goroutine but Run's.
 func (h *Hub) SendToUser(username string, message []byte) {
-	if client, ok := h.UserClients[username]; ok {
+	h.queue(delivery{username: username, message: message})
+}
+
+// IsOnline reports whether the user has a live connection
+func (h *Hub) IsOnline(username string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.UserClients[username]
+	return ok
+}
+
+// DisconnectUser drops every live connection of the user. The reason is sent
+// to the clients in the close frame.
+func (h *Hub) DisconnectUser(username, reason string) {
+	select {
+	case h.disconnects <- disconnection{username: username, reason: reason}:
+	case <-h.done:
+	}
+}
+
+// SetUserRole changes the role of every live connection of the user, so
+// SendToRole follows promotions and demotions without a reconnect
+func (h *Hub) SetUserRole(username, role string) {
+	select {
+	case h.roleChanges <- roleChange{username: username, role: role}:
+	case <-h.done:
+	}
+}
+
+// MaxClientTopics caps how many topics one connection may subscribe to
+const MaxClientTopics = 50
+
+// Subscribe adds the client to a topic. It returns false when the client is
+// already subscribed to MaxClientTopics other topics. A client the hub has
+// already dropped is not subscribed, since its send channel is closed.
+func (h *Hub) Subscribe(client *Client, topic string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if client.dropped {
+		return true
+	}
+
+	if h.topics[topic][client] {
+		return true
+	}
+	count := 0
+	for _, clients := range h.topics {
+		if clients[client] {
+			count++
+		}
+	}
+	if count >= MaxClientTopics {
+		return false
+	}
+
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[*Client]bool)
+	}
+	h.topics[topic][client] = true
+	return true
+}
+
+// Unsubscribe removes the client from a topic
+func (h *Hub) Unsubscribe(client *Client, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.topics[topic], client)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+// Publish sends a message to every client subscribed to any of the topics.
+// A client subscribed to several of them still gets the message once.
+// Clients too slow to keep up miss the message rather than being dropped,
+// since publishing happens outside the hub loop.
+func (h *Hub) Publish(topics []string, message []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	recipients := make(map[*Client]bool)
+	for _, topic := range topics {
+		for client := range h.topics[topic] {
+			recipients[client] = true
+		}
+	}
+	for client := range recipients {
 		select {
 		case client.Send <- message:
 		default:
-			close(client.Send)
-			delete(h.Clients, client)
-			delete(h.UserClients, username)
+			slog.Warn("Publish: dropping message for slow client", "nickname", client.Username)
+		}
+	}
+}
+
+// SendToRole sends a message to every connected client holding at least the
+// given role, e.g. all online moderators and admins
+func (h *Hub) SendToRole(role string, message []byte) {
+	h.queue(delivery{role: role, message: message})
+}
+
+// queue hands a delivery to Run. Once the hub has stopped the message is
+// dropped.
+func (h *Hub) queue(d delivery) {
+	select {
+	case h.deliveries <- d:
+	case <-h.done:
+	}
+}
+
+// deliver sends a queued message to its recipients, dropping the ones too
+// slow to keep up
+func (h *Hub) deliver(d delivery) {
+	for client := range h.Clients {
+		if d.username != "" && client.Username != d.username {
+			continue
+		}
+		if d.role != "" && !RoleAtLeast(client.Role, d.role) {
+			continue
+		}
+		select {
+		case client.Send <- d.message:
+		default:
+			h.dropClient(client)
 		}
 	}
 }
 
-// broadcastUserStatusChange sends user join/leave notifications to all connected clients
+// broadcastUserStatusChange queues a user join/leave notification for
+// announcePresence
 func (h *Hub) broadcastUserStatusChange(username, eventType string) {
+	h.presenceMu.Lock()
+	h.presence = append(h.presence, presenceEvent{username: username, eventType: eventType})
+	h.presenceMu.Unlock()
+
+	select {
+	case h.presenceReady <- struct{}{}:
+	default:
+	}
+}
+
+// announcePresence sends the queued join/leave notifications, in order,
+// until the hub stops
+func (h *Hub) announcePresence() {
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-h.presenceReady:
+		}
+
+		h.presenceMu.Lock()
+		events := h.presence
+		h.presence = nil
+		h.presenceMu.Unlock()
+
+		for _, event := range events {
+			h.announce(event)
+		}
+	}
+}
+
+// announce sends a user join/leave notification to all connected users
+func (h *Hub) announce(event presenceEvent) {
+	username, eventType := event.username, event.eventType
 	statusMessage := Message{
 		Type:      eventType,
 		From:      "system",
@@ -84,10 +338,22 @@ func (h *Hub) broadcastUserStatusChange(username, eventType string) {
 		Timestamp: time.Now(),
 	}
 
-	// Send to all connected clients, but customize online users list for each
-	for client := range h.Clients {
-		// Get online users list excluding the current client
-		onlineUsers := h.GetOnlineUsersExcluding(client.Username)
+	h.mu.RLock()
+	recipients := make([]string, 0, len(h.UserClients))
+	for recipient := range h.UserClients {
+		recipients = append(recipients, recipient)
+	}
+	h.mu.RUnlock()
+
+	// Send to all connected users, but customize online users list for each
+	for _, recipient := range recipients {
+		// Users hidden from each other don't learn about each other's presence
+		if recipient != username && len(h.visibleUsers(recipient, []string{username})) == 0 {
+			continue
+		}
+
+		// Get online users list excluding the recipient
+		onlineUsers := h.GetOnlineUsersExcluding(recipient)
 
 		messageData := map[string]interface{}{
 			"type":         eventType,
@@ -100,26 +366,46 @@ func (h *Hub) broadcastUserStatusChange(username, eventType string) {
 
 		messageBytes, err := json.Marshal(messageData)
 		if err != nil {
-			log.Printf("Error marshaling status message: %v", err)
+			slog.Error("Error marshaling status message", "err", err)
 			continue
 		}
+		h.SendToUser(recipient, messageBytes)
+	}
 
-		select {
-		case client.Send <- messageBytes:
-		default:
-			close(client.Send)
-			delete(h.Clients, client)
-			delete(h.UserClients, client.Username)
-		}
+	// The user who joined also gets the list of who is already online
+	if eventType == "user_joined" {
+		h.sendInitialOnlineUsers(username)
+	}
+}
+
+// sendInitialOnlineUsers sends the current list of online users to a newly
+// connected user. It goes through the send queue like every other frame, as
+// only the write pump may write to the connection.
+func (h *Hub) sendInitialOnlineUsers(username string) {
+	initialMessage := map[string]interface{}{
+		"type":         "initial_online_users",
+		"from":         "system",
+		"to":           username,
+		"online_users": h.GetOnlineUsersExcluding(username),
+		"timestamp":    time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(initialMessage)
+	if err != nil {
+		slog.Error("Error marshaling initial online users message", "err", err)
+		return
 	}
+	h.SendToUser(username, messageBytes)
 }
 
 // GetOnlineUsers returns a list of currently online users
 func (h *Hub) GetOnlineUsers() []string {
+	h.mu.RLock()
 	users := make([]string, 0, len(h.UserClients))
 	for username := range h.UserClients {
 		users = append(users, username)
 	}
+	h.mu.RUnlock()
 
 	// Sort alphabetically as default
 	sort.Strings(users)
@@ -128,12 +414,15 @@ func (h *Hub) GetOnlineUsers() []string {
 
 // GetOnlineUsersExcluding returns a list of currently online users excluding the specified username
 func (h *Hub) GetOnlineUsersExcluding(excludeUsername string) []string {
+	h.mu.RLock()
 	users := make([]string, 0, len(h.UserClients))
 	for username := range h.UserClients {
 		if username != excludeUsername {
 			users = append(users, username)
 		}
 	}
+	h.mu.RUnlock()
+	users = h.visibleUsers(excludeUsername, users)
 
 	// Use custom sorter if available, otherwise sort alphabetically
 	if h.UserSorter != nil {
@@ -142,7 +431,7 @@ func (h *Hub) GetOnlineUsersExcluding(excludeUsername string) []string {
 
 		sortedUsers, err := h.UserSorter(ctx, excludeUsername, users)
 		if err != nil {
-			log.Printf("Error sorting users: %v, falling back to alphabetical", err)
+			slog.Error("Error sorting users, falling back to alphabetical", "err", err)
 			sort.Strings(users)
 			return users
 		}
@@ -153,3 +442,21 @@ func (h *Hub) GetOnlineUsersExcluding(excludeUsername string) []string {
 	sort.Strings(users)
 	return users
 }
+
+// visibleUsers drops the users that currentUser may not see. When the filter
+// fails nobody is shown, so a block is never bypassed.
+func (h *Hub) visibleUsers(currentUser string, users []string) []string {
+	if h.UserFilter == nil {
+		return users
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	visible, err := h.UserFilter(ctx, currentUser, users)
+	if err != nil {
+		slog.Error("Error filtering users, hiding all users", "err", err)
+		return []string{}
+	}
+	return visible
+}
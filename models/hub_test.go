@@ -0,0 +1,156 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runHub starts a hub and stops it when the test ends
+func runHub(t *testing.T) *Hub {
+	t.Helper()
+	hub := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return hub
+}
+
+func connect(t *testing.T, hub *Hub, username, role string, buffer int) *Client {
+	t.Helper()
+	client := &Client{Username: username, Role: role, Send: make(chan []byte, buffer)}
+	hub.Register <- client
+	return client
+}
+
+// receive waits for message on the client, skipping presence frames. It
+// reports false when the client is dropped or nothing arrives.
+func receive(client *Client, message string) bool {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case got, ok := <-client.Send:
+			if !ok {
+				return false
+			}
+			if string(got) == message {
+				return true
+			}
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+func TestSendToRole(t *testing.T) {
+	hub := runHub(t)
+	moderator := connect(t, hub, "mod", RoleModerator, 16)
+	admin := connect(t, hub, "root", RoleAdmin, 16)
+	user := connect(t, hub, "alice", RoleUser, 16)
+
+	hub.SendToRole(RoleModerator, []byte("report"))
+	if !receive(moderator, "report") || !receive(admin, "report") {
+		t.Error("a moderator or admin missed the message")
+	}
+	hub.SendToUser("alice", []byte("direct"))
+	if !receive(user, "direct") {
+		t.Fatal("alice missed her direct message")
+	}
+	for len(user.Send) > 0 {
+		if string(<-user.Send) == "report" {
+			t.Error("a regular user got a moderators' message")
+		}
+	}
+}
+
+func TestSetUserRole(t *testing.T) {
+	hub := runHub(t)
+	tabs := []*Client{connect(t, hub, "mod", RoleModerator, 16), connect(t, hub, "mod", RoleModerator, 16)}
+	user := connect(t, hub, "alice", RoleUser, 16)
+
+	hub.SetUserRole("mod", RoleUser)
+	hub.SetUserRole("alice", RoleModerator)
+	hub.SendToRole(RoleModerator, []byte("report"))
+	if !receive(user, "report") {
+		t.Error("the promoted user missed the moderators' message")
+	}
+
+	// The direct message is queued after the report, so every frame before it
+	// has been seen once it arrives
+	hub.SendToUser("mod", []byte("direct"))
+	for _, tab := range tabs {
+		for got := ""; got != "direct"; {
+			select {
+			case message := <-tab.Send:
+				got = string(message)
+				if got == "report" {
+					t.Error("a demoted moderator got a moderators' message")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("the demoted moderator missed the direct message")
+			}
+		}
+	}
+}
+
+func TestDisconnectUser(t *testing.T) {
+	hub := runHub(t)
+	first := connect(t, hub, "alice", RoleUser, 16)
+	second := connect(t, hub, "alice", RoleUser, 16)
+	bob := connect(t, hub, "bobby", RoleUser, 16)
+
+	hub.DisconnectUser("alice", "banned")
+	for _, client := range []*Client{first, second} {
+		for range client.Send {
+		}
+		if client.CloseReason != "banned" {
+			t.Errorf("close reason = %q, want banned", client.CloseReason)
+		}
+	}
+	if hub.IsOnline("alice") || !hub.IsOnline("bobby") {
+		t.Error("the wrong users were disconnected")
+	}
+	hub.SendToUser("bobby", []byte("still here"))
+	if !receive(bob, "still here") {
+		t.Error("bobby was dropped too")
+	}
+}
+
+// TestHubConcurrency sends from many goroutines to clients too slow to keep
+// up, so the hub drops them while it is being used. Run it with -race.
+func TestHubConcurrency(t *testing.T) {
+	hub := runHub(t)
+	for i := 0; i < 10; i++ {
+		connect(t, hub, fmt.Sprintf("user%d", i), RoleModerator, 1)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			username := fmt.Sprintf("user%d", i)
+			client := &Client{Username: username, Role: RoleUser, Send: make(chan []byte, 1)}
+			hub.Register <- client
+			hub.Subscribe(client, "post:1")
+			for j := 0; j < 20; j++ {
+				hub.SendToUser(username, []byte("hi"))
+				hub.SendToRole(RoleModerator, []byte("report"))
+				hub.Publish([]string{"post:1"}, []byte("comment"))
+				hub.IsOnline(username)
+				hub.GetOnlineUsersExcluding(username)
+			}
+			hub.DisconnectUser(username, "bye")
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,8 @@
+package models
+
+// Mention is a user referenced as @nickname in a post, comment or chat
+// message. It carries json tags because it also travels in chat frames.
+type Mention struct {
+	UserID   string `json:"user_id"`
+	Nickname string `json:"nickname"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification types
+const (
+	NotificationComment      = "comment"
+	NotificationReply        = "reply"
+	NotificationCategoryPost = "category_post"
+	NotificationMention      = "mention"
+)
+
+type Notification struct {
+	ID        string
+	UserID    string
+	Type      string
+	ActorID   string
+	ActorName string
+	PostID    string
+	PostTitle string
+	CommentID string
+	Preview   string
+	CreatedAt time.Time
+	ReadAt    *time.Time
+}
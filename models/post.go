@@ -10,15 +10,24 @@ type Post struct {
 	AuthorName    string
 	Title         string
 	Content       string
+	ContentHTML   string
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	Categories    []string
 	Image         string
+	Mentions      []Mention
 }
 
 type Category struct {
-	ID        string
-	Name      string
+	ID          string
+	Name        string
+	Description string
+	Position    int
+	Archived    bool
+	PostCount   int
+	LastPostAt  *time.Time
+	LastPoster  string
+	Subscribed  bool
 }
 
 type PostCategory struct {
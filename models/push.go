@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// PushSubscription is one browser registered for Web Push. Endpoint is the
+// push service URL, P256dh the browser's public key and Auth its auth secret,
+// both base64url encoded as the browser hands them out.
+type PushSubscription struct {
+	ID        string
+	UserID    string
+	Endpoint  string
+	P256dh    string
+	Auth      string
+	CreatedAt time.Time
+}
+
+// PushMessage is what the service worker shows when a push arrives
+type PushMessage struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url,omitempty"`
+	Tag   string `json:"tag,omitempty"`
+}
+
+// VAPIDKeys identify this server to push services. Both keys are base64url
+// encoded: the public key as an uncompressed P-256 point, the private key as
+// its raw 32-byte scalar.
+type VAPIDKeys struct {
+	PublicKey  string
+	PrivateKey string
+}
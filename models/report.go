@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+)
+
+// Content types that can be reported
+const (
+	ReportTypePost    = "post"
+	ReportTypeComment = "comment"
+	ReportTypeMessage = "message"
+)
+
+// Report states: open reports wait in the queue, claimed ones are being
+// handled by a moderator and resolved ones are closed
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusClaimed  = "claimed"
+	ReportStatusResolved = "resolved"
+)
+
+// Resolution actions a moderator can take on a report
+const (
+	ReportActionHide    = "hide"
+	ReportActionDelete  = "delete"
+	ReportActionWarn    = "warn"
+	ReportActionDismiss = "dismiss"
+)
+
+type Report struct {
+	ID             string
+	ContentType    string
+	ContentID      string
+	ContentPreview string
+	AuthorID       string
+	AuthorName     string
+	ReporterID     string
+	ReporterName   string
+	Reason         string
+	Status         string
+	ClaimedBy      string
+	ClaimedByName  string
+	Action         string
+	Note           string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ResolvedAt     *time.Time
+}
+
+type ReportFilter struct {
+	Status      string
+	ContentType string
+	ClaimedBy   string
+	Unclaimed   bool
+	Limit       int
+	Offset      int
+}
+
+// ReportedContent identifies the author of a piece of reported content
+type ReportedContent struct {
+	AuthorID   string
+	AuthorName string
+}
+
+type Warning struct {
+	ID          string
+	UserID      string
+	ModeratorID string
+	ReportID    string
+	Reason      string
+	CreatedAt   time.Time
+}
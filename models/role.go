@@ -0,0 +1,88 @@
+package models
+
+// Roles a user can hold, from least to most privileged
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// Permission names an action that is restricted to some roles
+type Permission string
+
+const (
+	PermEditAnyPost      Permission = "post:edit:any"
+	PermDeleteAnyPost    Permission = "post:delete:any"
+	PermEditAnyComment   Permission = "comment:edit:any"
+	PermDeleteAnyComment Permission = "comment:delete:any"
+	PermModerate         Permission = "moderation"
+	PermSanctionUsers    Permission = "user:sanction"
+	PermManageCategories Permission = "category:manage"
+	PermManageRoles      Permission = "user:role:manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleModerator: {
+		PermDeleteAnyPost,
+		PermDeleteAnyComment,
+		PermModerate,
+		PermSanctionUsers,
+	},
+	RoleAdmin: {
+		PermEditAnyPost,
+		PermDeleteAnyPost,
+		PermEditAnyComment,
+		PermDeleteAnyComment,
+		PermModerate,
+		PermSanctionUsers,
+		PermManageCategories,
+		PermManageRoles,
+	},
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// RoleAtLeast reports whether role is at least as privileged as min
+func RoleAtLeast(role, min string) bool {
+	return roleRank[role] >= roleRank[min] && roleRank[min] > 0
+}
+
+// HasRole reports whether the user holds at least the given role
+func (u *User) HasRole(role string) bool {
+	if u == nil {
+		return false
+	}
+	return RoleAtLeast(u.Role, role)
+}
+
+// Can reports whether the user's role grants the permission
+func (u *User) Can(p Permission) bool {
+	if u == nil {
+		return false
+	}
+	for _, granted := range rolePermissions[u.Role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// CanModify reports whether the user may act on content written by authorID:
+// authors may always act on their own content, others need the permission.
+func (u *User) CanModify(authorID string, p Permission) bool {
+	if u == nil {
+		return false
+	}
+	return u.ID == authorID || u.Can(p)
+}
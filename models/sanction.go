@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// Sanction types: bans are permanent, suspensions are timed bans and mutes
+// only stop the user from chatting
+const (
+	SanctionBan        = "ban"
+	SanctionSuspension = "suspension"
+	SanctionMute       = "mute"
+)
+
+type Sanction struct {
+	ID            string
+	UserID        string
+	UserName      string
+	Type          string
+	Reason        string
+	ModeratorID   string
+	ModeratorName string
+	CreatedAt     time.Time
+	ExpiresAt     *time.Time
+	RevokedAt     *time.Time
+}
+
+type SanctionFilter struct {
+	UserID     string
+	ActiveOnly bool
+	Limit      int
+	Offset     int
+}
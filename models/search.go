@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Search result types
+const (
+	SearchTypePost    = "post"
+	SearchTypeComment = "comment"
+	SearchTypeUser    = "user"
+)
+
+type SearchQuery struct {
+	Text       string
+	Types      []string
+	CategoryID string
+	Author     string
+	Limit      int
+	Offset     int
+}
+
+type SearchResult struct {
+	Type       string    `json:"type"`
+	ID         string    `json:"id"`
+	PostID     string    `json:"post_id,omitempty"`
+	Title      string    `json:"title"`
+	Snippet    string    `json:"snippet"`
+	AuthorName string    `json:"author_name"`
+	CreatedAt  time.Time `json:"created_at"`
+}
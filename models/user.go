@@ -1,12 +1,21 @@
-package models
-
-type User struct {
-	ID        string
-	Nickname  string
-	Age       int
-	Gender    string
-	FirstName string
-	LastName  string
-	Email     string
-	Password  string
-}
+package models
+
+import "log/slog"
+
+type User struct {
+	ID        string
+	Nickname  string
+	Age       int
+	Gender    string
+	FirstName string
+	LastName  string
+	Email     string
+	Password  string
+	Role      string
+}
+
+// LogValue keeps a logged user down to the ID and nickname, so the email,
+// name and password hash never end up in the logs
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("id", u.ID), slog.String("nickname", u.Nickname))
+}
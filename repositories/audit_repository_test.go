@@ -0,0 +1,18 @@
+package repositories
+
+import "testing"
+
+func TestEscapeLike(t *testing.T) {
+	cases := map[string]string{
+		"moderation.": "moderation.",
+		"mod_x.":      `mod\_x.`,
+		"100%":        `100\%`,
+		`back\slash`:  `back\\slash`,
+		"":            "",
+	}
+	for in, want := range cases {
+		if got := escapeLike(in); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"context"
+	"path/filepath"
+	"real-time-forum/database"
+	"real-time-forum/database/sqlite"
+	"real-time-forum/models"
+	"testing"
+	"time"
+)
+
+// openTestDB opens a migrated database in a temporary directory
+func openTestDB(t *testing.T) *sqlite.DB {
+	t.Helper()
+	ctx := context.Background()
+
+	db, err := sqlite.Open(ctx, sqlite.Options{
+		Path:        filepath.Join(t.TempDir(), "forum.db"),
+		BusyTimeout: time.Second,
+		ReadConns:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := database.NewMigrator(db.Write, database.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func createTestUser(t *testing.T, db *sqlite.DB, id, nickname string) *models.User {
+	t.Helper()
+	user := &models.User{
+		ID:        id,
+		Nickname:  nickname,
+		Age:       30,
+		Gender:    "Other",
+		FirstName: "Test",
+		LastName:  "User",
+		Email:     nickname + "@example.com",
+		Password:  "hash",
+	}
+	if err := NewSQLiteUserRepository(db).CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func createTestPost(t *testing.T, db *sqlite.DB, author *models.User, id string) *models.Post {
+	t.Helper()
+	post := &models.Post{ID: id, Title: "Title " + id, Content: "Content", CreatedAt: time.Now()}
+	if err := NewSQLitePostRepository(db).CreatePost(context.Background(), author, post, []string{"1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	return post
+}
+
+func createTestComment(t *testing.T, db *sqlite.DB, author *models.User, post *models.Post, id string) *models.Comment {
+	t.Helper()
+	comment := &models.Comment{ID: id, PostID: post.ID, AuthorID: author.ID, Content: "A comment"}
+	if err := NewSQLiteCommentRepository(db).CreateComment(context.Background(), comment); err != nil {
+		t.Fatal(err)
+	}
+	return comment
+}
+
+// countRows counts the rows of a table matching a condition
+func countRows(t *testing.T, db *sqlite.DB, table, where string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := db.Read.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE `+where, args...).Scan(&n); err != nil {
+		t.Fatalf("counting %s: %v", table, err)
+	}
+	return n
+}
+
+func TestDeletingUserCascades(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	alice := createTestUser(t, db, "user-alice", "alice")
+	bob := createTestUser(t, db, "user-bob", "bob")
+
+	if err := NewSQLiteSessionRepository(db).CreateSession(ctx, models.Session{
+		ID: "session-alice", UserID: alice.ID, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	alicePost := createTestPost(t, db, alice, "post-alice")
+	bobPost := createTestPost(t, db, bob, "post-bob")
+	createTestComment(t, db, alice, bobPost, "comment-alice")
+	createTestComment(t, db, bob, alicePost, "comment-bob")
+
+	messages := NewSQLiteMessageRepository(db)
+	for _, msg := range []models.Message{
+		{From: alice.Nickname, To: bob.Nickname, Content: "hi bob", Timestamp: time.Now()},
+		{From: bob.Nickname, To: alice.Nickname, Content: "hi alice", Timestamp: time.Now()},
+		{From: bob.Nickname, To: "all", Content: "hi all", Timestamp: time.Now()},
+	} {
+		if err := messages.SaveMessage(ctx, &msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := NewSQLiteNotificationRepository(db).CreateNotifications(ctx, []models.Notification{
+		{ID: "notification-alice", UserID: alice.ID, Type: models.NotificationComment, ActorID: bob.ID, PostID: alicePost.ID, CreatedAt: time.Now()},
+		{ID: "notification-bob", UserID: bob.ID, Type: models.NotificationComment, ActorID: alice.ID, PostID: bobPost.ID, CreatedAt: time.Now()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSQLiteBlockRepository(db).Block(ctx, bob.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSQLiteCategoriesRepository(db).Subscribe(ctx, alice.ID, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Write.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, alice.ID); err != nil {
+		t.Fatalf("deleting user: %v", err)
+	}
+
+	gone := []struct {
+		table, where string
+		args         []interface{}
+	}{
+		{"sessions", "user_id = ?", []interface{}{alice.ID}},
+		{"posts", "author_id = ?", []interface{}{alice.ID}},
+		{"comments", "author_id = ?", []interface{}{alice.ID}},
+		{"comments", "post_id = ?", []interface{}{alicePost.ID}},
+		{"post_categories", "post_id = ?", []interface{}{alicePost.ID}},
+		{"messages", "from_user = ? OR to_user = ?", []interface{}{alice.Nickname, alice.Nickname}},
+		{"notifications", "user_id = ?", []interface{}{alice.ID}},
+		{"user_blocks", "blocked_id = ?", []interface{}{alice.ID}},
+		{"category_subscriptions", "user_id = ?", []interface{}{alice.ID}},
+	}
+	for _, g := range gone {
+		if n := countRows(t, db, g.table, g.where, g.args...); n != 0 {
+			t.Errorf("%s where %s: %d rows left after deleting the user", g.table, g.where, n)
+		}
+	}
+
+	// Bob's own content stays; the notification Alice caused loses its actor
+	if n := countRows(t, db, "posts", "id = ?", bobPost.ID); n != 1 {
+		t.Errorf("bob's post: got %d rows, want 1", n)
+	}
+	if n := countRows(t, db, "messages", "from_user = ? AND to_user = 'all'", bob.Nickname); n != 1 {
+		t.Errorf("bob's public message: got %d rows, want 1", n)
+	}
+	if n := countRows(t, db, "notifications", "id = 'notification-bob' AND actor_id IS NULL"); n != 1 {
+		t.Errorf("bob's notification: got %d rows with a cleared actor, want 1", n)
+	}
+}
+
+func TestDeletePostCascades(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	alice := createTestUser(t, db, "user-alice", "alice")
+	bob := createTestUser(t, db, "user-bob", "bob")
+	post := createTestPost(t, db, alice, "post-1")
+	other := createTestPost(t, db, alice, "post-2")
+	comment := createTestComment(t, db, bob, post, "comment-1")
+	createTestComment(t, db, bob, other, "comment-2")
+
+	if err := NewSQLiteNotificationRepository(db).CreateNotifications(ctx, []models.Notification{
+		{ID: "notification-1", UserID: alice.ID, Type: models.NotificationComment, ActorID: bob.ID, PostID: post.ID, CommentID: comment.ID, CreatedAt: time.Now()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewSQLitePostRepository(db).DeletePost(ctx, post.ID); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+
+	for table, where := range map[string]string{
+		"posts":           "id = ?",
+		"comments":        "post_id = ?",
+		"post_categories": "post_id = ?",
+		"notifications":   "post_id = ?",
+	} {
+		if n := countRows(t, db, table, where, post.ID); n != 0 {
+			t.Errorf("%s: %d rows left after deleting the post", table, n)
+		}
+	}
+	if n := countRows(t, db, "comments", "post_id = ?", other.ID); n != 1 {
+		t.Errorf("comments of the other post: got %d rows, want 1", n)
+	}
+}
+
+func TestForeignKeysRejectOrphans(t *testing.T) {
+	db := openTestDB(t)
+
+	comment := &models.Comment{ID: "comment-1", PostID: "missing-post", AuthorID: "missing-user", Content: "orphan"}
+	if err := NewSQLiteCommentRepository(db).CreateComment(context.Background(), comment); err == nil {
+		t.Fatal("CreateComment accepted a comment on a post that does not exist")
+	}
+}
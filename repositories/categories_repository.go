@@ -3,20 +3,77 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"real-time-forum/database/sqlite"
 	"real-time-forum/models"
+	"strings"
+	"time"
 )
 
-type CategoriesRepository struct {
-	db *sql.DB
+type SQLiteCategoriesRepository struct {
+	db   *sql.DB
+	read *sql.DB
 }
 
-func NewCategoriesRepository(db *sql.DB) *CategoriesRepository {
-	return &CategoriesRepository{db: db}
+func NewSQLiteCategoriesRepository(db *sqlite.DB) *SQLiteCategoriesRepository {
+	return &SQLiteCategoriesRepository{db: db.Write, read: db.Read}
 }
 
 
-func (p *CategoriesRepository) GetAllCategories(ctx context.Context) ([]models.Category, error) {
-	rows, err := p.db.QueryContext(ctx, "SELECT id, name FROM categories")
+// categorySelect loads categories together with their activity metadata.
+// The single placeholder is the user ID used for the Subscribed flag.
+const categorySelect = `
+	SELECT
+	c.id,
+	c.name,
+	c.description,
+	c.position,
+	c.archived_at IS NOT NULL,
+	(SELECT COUNT(*) FROM post_categories pc JOIN posts p ON p.id = pc.post_id WHERE pc.category_id = c.id AND p.hidden_at IS NULL),
+	lp.created_at,
+	COALESCE(lp.nickname, ''),
+	EXISTS(SELECT 1 FROM category_subscriptions cs WHERE cs.category_id = c.id AND cs.user_id = ?)
+	FROM categories c
+	LEFT JOIN (
+		SELECT
+		pc.category_id,
+		p.created_at,
+		COALESCE(u.nickname, 'Unknown') AS nickname,
+		ROW_NUMBER() OVER (PARTITION BY pc.category_id ORDER BY p.created_at DESC) AS rn
+		FROM post_categories pc
+		JOIN posts p ON p.id = pc.post_id
+		LEFT JOIN users u ON u.id = p.author_id
+		WHERE p.hidden_at IS NULL
+	) lp ON lp.category_id = c.id AND lp.rn = 1`
+
+// GetAllCategories returns the active categories in display order
+func (p *SQLiteCategoriesRepository) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	return p.GetCategoriesForUser(ctx, "")
+}
+
+// GetCategoriesForUser returns the active categories in display order, with
+// Subscribed set for the ones the user follows
+func (p *SQLiteCategoriesRepository) GetCategoriesForUser(ctx context.Context, userID string) ([]models.Category, error) {
+	return p.queryCategories(ctx, categorySelect+`
+		WHERE c.archived_at IS NULL
+		ORDER BY c.position, c.id`, userID)
+}
+
+// GetAllCategoriesWithArchived returns every category, archived ones included
+func (p *SQLiteCategoriesRepository) GetAllCategoriesWithArchived(ctx context.Context) ([]models.Category, error) {
+	return p.queryCategories(ctx, categorySelect+`
+		ORDER BY c.position, c.id`, "")
+}
+
+// GetSubscribedCategories returns the categories the user follows
+func (p *SQLiteCategoriesRepository) GetSubscribedCategories(ctx context.Context, userID string) ([]models.Category, error) {
+	return p.queryCategories(ctx, categorySelect+`
+		JOIN category_subscriptions s ON s.category_id = c.id AND s.user_id = ?
+		ORDER BY c.position, c.id`, userID, userID)
+}
+
+func (p *SQLiteCategoriesRepository) queryCategories(ctx context.Context, query string, args ...interface{}) ([]models.Category, error) {
+	rows, err := p.read.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -24,11 +81,11 @@ func (p *CategoriesRepository) GetAllCategories(ctx context.Context) ([]models.C
 
 	var categories []models.Category
 	for rows.Next() {
-		var category models.Category
-		if err := rows.Scan(&category.ID, &category.Name); err != nil {
+		category, err := scanCategory(rows)
+		if err != nil {
 			return nil, err
 		}
-		categories = append(categories, category)
+		categories = append(categories, *category)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -38,3 +95,156 @@ func (p *CategoriesRepository) GetAllCategories(ctx context.Context) ([]models.C
 	return categories, nil
 }
 
+func (p *SQLiteCategoriesRepository) GetCategoryByID(ctx context.Context, categoryID, userID string) (*models.Category, error) {
+	row := p.read.QueryRowContext(ctx, categorySelect+`
+		WHERE c.id = ?`, userID, categoryID)
+	return scanCategory(row)
+}
+
+// scanCategory reads one row produced by categorySelect
+func scanCategory(row interface{ Scan(...interface{}) error }) (*models.Category, error) {
+	var category models.Category
+	var lastPostAt sql.NullTime
+
+	if err := row.Scan(
+		&category.ID,
+		&category.Name,
+		&category.Description,
+		&category.Position,
+		&category.Archived,
+		&category.PostCount,
+		&lastPostAt,
+		&category.LastPoster,
+		&category.Subscribed,
+	); err != nil {
+		return nil, err
+	}
+
+	if lastPostAt.Valid {
+		category.LastPostAt = &lastPostAt.Time
+	}
+	return &category, nil
+}
+
+// Subscribe makes the user follow a category. Subscribing twice is a no-op.
+func (p *SQLiteCategoriesRepository) Subscribe(ctx context.Context, userID, categoryID string) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO category_subscriptions (user_id, category_id, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`, userID, categoryID, time.Now())
+	return err
+}
+
+func (p *SQLiteCategoriesRepository) Unsubscribe(ctx context.Context, userID, categoryID string) error {
+	_, err := p.db.ExecContext(ctx, "DELETE FROM category_subscriptions WHERE user_id = ? AND category_id = ?", userID, categoryID)
+	return err
+}
+
+// CategoryNameExists reports whether another category already uses the name
+func (p *SQLiteCategoriesRepository) CategoryNameExists(ctx context.Context, name, excludeID string) (bool, error) {
+	var exists int
+	err := p.read.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE name = ? COLLATE NOCASE AND id != ?)", name, excludeID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists == 1, nil
+}
+
+// CountActiveCategories counts how many of the given IDs are existing,
+// non-archived categories
+func (p *SQLiteCategoriesRepository) CountActiveCategories(ctx context.Context, categoryIDs []string) (int, error) {
+	if len(categoryIDs) == 0 {
+		return 0, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(categoryIDs)), ",")
+	args := make([]interface{}, len(categoryIDs))
+	for i, id := range categoryIDs {
+		args[i] = id
+	}
+
+	var count int
+	err := p.read.QueryRowContext(ctx, "SELECT COUNT(*) FROM categories WHERE archived_at IS NULL AND id IN ("+placeholders+")", args...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CreateCategory inserts a category at the end of the display order
+func (p *SQLiteCategoriesRepository) CreateCategory(ctx context.Context, category *models.Category) error {
+	res, err := p.db.ExecContext(ctx, `
+		INSERT INTO categories (name, description, position)
+		VALUES (?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM categories))`,
+		category.Name, category.Description)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return p.read.QueryRowContext(ctx, "SELECT id, position FROM categories WHERE id = ?", id).Scan(&category.ID, &category.Position)
+}
+
+func (p *SQLiteCategoriesRepository) UpdateCategory(ctx context.Context, category *models.Category) error {
+	res, err := p.db.ExecContext(ctx, "UPDATE categories SET name = ?, description = ? WHERE id = ?", category.Name, category.Description, category.ID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+func (p *SQLiteCategoriesRepository) SetCategoryArchived(ctx context.Context, categoryID string, archived bool) error {
+	var archivedAt interface{}
+	if archived {
+		archivedAt = time.Now()
+	}
+
+	res, err := p.db.ExecContext(ctx, "UPDATE categories SET archived_at = ? WHERE id = ?", archivedAt, categoryID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// ReorderCategories stores the given order as the display position of each
+// category
+func (p *SQLiteCategoriesRepository) ReorderCategories(ctx context.Context, categoryIDs []string) (err error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ReorderCategories: could not begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for i, id := range categoryIDs {
+		res, err := tx.ExecContext(ctx, "UPDATE categories SET position = ? WHERE id = ?", i+1, id)
+		if err != nil {
+			return fmt.Errorf("ReorderCategories: updating category %s: %w", id, err)
+		}
+		if err := expectOneRow(res); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// expectOneRow turns an update that matched nothing into sql.ErrNoRows
+func expectOneRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
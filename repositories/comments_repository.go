@@ -3,18 +3,21 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"real-time-forum/database/sqlite"
 	"real-time-forum/models"
+	"time"
 )
 
-type CommentRepository struct {
-	db *sql.DB
+type SQLiteCommentRepository struct {
+	db   *sql.DB
+	read *sql.DB
 }
 
-func NewCommentRepository(db *sql.DB) *CommentRepository {
-	return &CommentRepository{db: db}
+func NewSQLiteCommentRepository(db *sqlite.DB) *SQLiteCommentRepository {
+	return &SQLiteCommentRepository{db: db.Write, read: db.Read}
 }
 
-func (r *CommentRepository) CreateComment(ctx context.Context, comment *models.Comment) error {
+func (r *SQLiteCommentRepository) CreateComment(ctx context.Context, comment *models.Comment) error {
 	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO comments 
 		(id, post_id, author_id, content) 
@@ -27,10 +30,10 @@ func (r *CommentRepository) CreateComment(ctx context.Context, comment *models.C
 	return nil
 }
 
-func (r *CommentRepository) GetCommentByID(ctx context.Context, commentID string) (*models.Comment, error) {
+func (r *SQLiteCommentRepository) GetCommentByID(ctx context.Context, commentID string) (*models.Comment, error) {
 	var comment models.Comment
 	query := "SELECT id, post_id, author_id, content, created_at, updated_at FROM comments WHERE id = ?"
-	err := r.db.QueryRowContext(ctx, query, commentID).Scan(&comment.ID, &comment.PostID, &comment.AuthorID, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt)
+	err := r.read.QueryRowContext(ctx, query, commentID).Scan(&comment.ID, &comment.PostID, &comment.AuthorID, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -38,12 +41,12 @@ func (r *CommentRepository) GetCommentByID(ctx context.Context, commentID string
 }
 
 
-func (r *CommentRepository) GetPostComments(ctx context.Context, postID string) ([]models.Comment, error) {
-	rows, err := r.db.QueryContext(ctx, `
+func (r *SQLiteCommentRepository) GetPostComments(ctx context.Context, postID string) ([]models.Comment, error) {
+	rows, err := r.read.QueryContext(ctx, `
 		SELECT c.id, COALESCE(u.nickname, 'Unknown') as author_name, c.content, c.created_at
 		FROM comments c
 		LEFT JOIN users u ON c.author_id = u.id
-		WHERE c.post_id = ?
+		WHERE c.post_id = ? AND c.hidden_at IS NULL
 		ORDER BY c.created_at DESC;
 	`, postID)
 	if err != nil {
@@ -67,7 +70,32 @@ func (r *CommentRepository) GetPostComments(ctx context.Context, postID string)
 	return comments, nil
 }
 
-func (r *CommentRepository) CommentsListByUser(ctx context.Context, userID string, limit, offset int) ([]models.Comment, error) {
+// SetCommentHidden hides a comment or makes it visible again. It returns
+// sql.ErrNoRows when the comment does not exist.
+func (r *SQLiteCommentRepository) SetCommentHidden(ctx context.Context, commentID string, hidden bool) error {
+	var hiddenAt interface{}
+	if hidden {
+		hiddenAt = time.Now()
+	}
+
+	res, err := r.db.ExecContext(ctx, `UPDATE comments SET hidden_at = ? WHERE id = ?`, hiddenAt, commentID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// DeleteComment removes a comment. It returns sql.ErrNoRows when the comment
+// does not exist.
+func (r *SQLiteCommentRepository) DeleteComment(ctx context.Context, commentID string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM comments WHERE id = ?`, commentID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+func (r *SQLiteCommentRepository) CommentsListByUser(ctx context.Context, userID string, limit, offset int) ([]models.Comment, error) {
 	const query = `
         SELECT c.id, c.post_id, p.title, c.content, c.created_at
         FROM comments c
@@ -76,7 +104,7 @@ func (r *CommentRepository) CommentsListByUser(ctx context.Context, userID strin
         ORDER BY c.created_at DESC
         LIMIT $2 OFFSET $3;
     `
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	rows, err := r.read.QueryContext(ctx, query, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"real-time-forum/database/sqlite"
+	"real-time-forum/models"
+	"strings"
+	"time"
+)
+
+type SQLiteMentionRepository struct {
+	db   *sql.DB
+	read *sql.DB
+}
+
+func NewSQLiteMentionRepository(db *sqlite.DB) *SQLiteMentionRepository {
+	return &SQLiteMentionRepository{db: db.Write, read: db.Read}
+}
+
+// ResolveNicknames looks up the mentioned nicknames, ignoring case. The
+// author and anyone with a block with the author are left out.
+func (r *SQLiteMentionRepository) ResolveNicknames(ctx context.Context, authorID string, nicknames []string) ([]models.Mention, error) {
+	if len(nicknames) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, 0, len(nicknames)+3)
+	for _, nickname := range nicknames {
+		args = append(args, nickname)
+	}
+	args = append(args, authorID, authorID, authorID)
+
+	rows, err := r.read.QueryContext(ctx, `
+		SELECT u.id, u.nickname
+		FROM users u
+		WHERE u.nickname COLLATE NOCASE IN (`+strings.TrimSuffix(strings.Repeat("?,", len(nicknames)), ",")+`)
+		AND u.id != ? AND `+notBlockedWith, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mentions []models.Mention
+	for rows.Next() {
+		var mention models.Mention
+		if err := rows.Scan(&mention.UserID, &mention.Nickname); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, mention)
+	}
+	return mentions, rows.Err()
+}
+
+// CreateMentions stores the users mentioned in one piece of content
+func (r *SQLiteMentionRepository) CreateMentions(ctx context.Context, contentType, contentID, authorID string, mentions []models.Mention) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("CreateMentions: could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, mention := range mentions {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO mentions (content_type, content_id, user_id, author_id, created_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING`,
+			contentType, contentID, mention.UserID, authorID, now); err != nil {
+			return fmt.Errorf("CreateMentions: inserting mention: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetMentions returns the mentions of each of the given pieces of content,
+// keyed by content ID
+func (r *SQLiteMentionRepository) GetMentions(ctx context.Context, contentType string, contentIDs []string) (map[string][]models.Mention, error) {
+	mentions := make(map[string][]models.Mention)
+	if len(contentIDs) == 0 {
+		return mentions, nil
+	}
+
+	args := make([]interface{}, 0, len(contentIDs)+1)
+	args = append(args, contentType)
+	for _, id := range contentIDs {
+		args = append(args, id)
+	}
+
+	rows, err := r.read.QueryContext(ctx, `
+		SELECT m.content_id, u.id, u.nickname
+		FROM mentions m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.content_type = ? AND m.content_id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(contentIDs)), ",")+`)
+		ORDER BY m.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var contentID string
+		var mention models.Mention
+		if err := rows.Scan(&contentID, &mention.UserID, &mention.Nickname); err != nil {
+			return nil, err
+		}
+		mentions[contentID] = append(mentions[contentID], mention)
+	}
+	return mentions, rows.Err()
+}
@@ -3,39 +3,52 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"real-time-forum/database/sqlite"
 	"real-time-forum/models"
+	"time"
 )
 
-type MessageRepository struct {
-	db *sql.DB
+type SQLiteMessageRepository struct {
+	db   *sql.DB
+	read *sql.DB
 }
 
-func NewMessageRepository(db *sql.DB) *MessageRepository {
-	return &MessageRepository{db: db}
+func NewSQLiteMessageRepository(db *sqlite.DB) *SQLiteMessageRepository {
+	return &SQLiteMessageRepository{db: db.Write, read: db.Read}
 }
 
 // SaveMessage saves a chat message to the database
-func (r *MessageRepository) SaveMessage(ctx context.Context, message *models.Message) error {
+func (r *SQLiteMessageRepository) SaveMessage(ctx context.Context, message *models.Message) error {
 	query := `
 		INSERT INTO messages (from_user, to_user, body, created_at)
 		VALUES (?, ?, ?, ?)
 	`
 
-	_, err := r.db.ExecContext(ctx, query, message.From, message.To, message.Content, message.Timestamp)
-	return err
+	res, err := r.db.ExecContext(ctx, query, message.From, message.To, message.Content, message.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	message.ID = int(id)
+	return nil
 }
 
 // GetMessagesWithPagination retrieves chat messages with pagination support
-func (r *MessageRepository) GetMessagesWithPagination(ctx context.Context, user1, user2 string, limit, offset int) ([]models.Message, error) {
+func (r *SQLiteMessageRepository) GetMessagesWithPagination(ctx context.Context, user1, user2 string, limit, offset int) ([]models.Message, error) {
 	query := `
 		SELECT id, from_user, to_user, body, created_at
 		FROM messages 
-		WHERE (from_user = ? AND to_user = ?) OR (from_user = ? AND to_user = ?)
+		WHERE ((from_user = ? AND to_user = ?) OR (from_user = ? AND to_user = ?))
+		AND hidden_at IS NULL
 		ORDER BY created_at DESC
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, user1, user2, user2, user1, limit, offset)
+	rows, err := r.read.QueryContext(ctx, query, user1, user2, user2, user1, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -60,8 +73,33 @@ func (r *MessageRepository) GetMessagesWithPagination(ctx context.Context, user1
 	return messages, rows.Err()
 }
 
+// SetMessageHidden hides a chat message from the history or makes it visible
+// again. It returns sql.ErrNoRows when the message does not exist.
+func (r *SQLiteMessageRepository) SetMessageHidden(ctx context.Context, messageID string, hidden bool) error {
+	var hiddenAt interface{}
+	if hidden {
+		hiddenAt = time.Now()
+	}
+
+	res, err := r.db.ExecContext(ctx, `UPDATE messages SET hidden_at = ? WHERE id = ?`, hiddenAt, messageID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// DeleteMessage removes a chat message. It returns sql.ErrNoRows when the
+// message does not exist.
+func (r *SQLiteMessageRepository) DeleteMessage(ctx context.Context, messageID string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM messages WHERE id = ?`, messageID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
 // GetLastMessageTimestamps gets the last message timestamp for each user the current user has chatted with
-func (r *MessageRepository) GetLastMessageTimestamps(ctx context.Context, currentUser string) (map[string]string, error) {
+func (r *SQLiteMessageRepository) GetLastMessageTimestamps(ctx context.Context, currentUser string) (map[string]string, error) {
 	query := `
 		SELECT 
 			CASE 
@@ -74,7 +112,7 @@ func (r *MessageRepository) GetLastMessageTimestamps(ctx context.Context, curren
 		GROUP BY other_user
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, currentUser, currentUser, currentUser)
+	rows, err := r.read.QueryContext(ctx, query, currentUser, currentUser, currentUser)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,228 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"real-time-forum/database/sqlite"
+	"real-time-forum/models"
+	"strings"
+	"time"
+)
+
+type SQLiteNotificationRepository struct {
+	db   *sql.DB
+	read *sql.DB
+}
+
+func NewSQLiteNotificationRepository(db *sqlite.DB) *SQLiteNotificationRepository {
+	return &SQLiteNotificationRepository{db: db.Write, read: db.Read}
+}
+
+// notificationSelect loads notifications with the actor's nickname and the
+// title of the post they point to
+const notificationSelect = `
+	SELECT
+	n.id,
+	n.user_id,
+	n.type,
+	COALESCE(n.actor_id, ''),
+	COALESCE(a.nickname, 'Unknown'),
+	COALESCE(n.post_id, ''),
+	COALESCE(p.title, ''),
+	COALESCE(n.comment_id, ''),
+	n.preview,
+	n.created_at,
+	n.read_at
+	FROM notifications n
+	LEFT JOIN users a ON a.id = n.actor_id
+	LEFT JOIN posts p ON p.id = n.post_id`
+
+// notificationVisible skips notifications about posts that have since been
+// hidden or deleted
+const notificationVisible = `(n.post_id IS NULL OR (p.id IS NOT NULL AND p.hidden_at IS NULL))`
+
+// notBlockedWith matches users (aliased u) with no block in either direction
+// with the actor. Both placeholders are the actor's ID.
+const notBlockedWith = `NOT EXISTS (
+		SELECT 1 FROM user_blocks b
+		WHERE (b.blocker_id = u.id AND b.blocked_id = ?) OR (b.blocker_id = ? AND b.blocked_id = u.id)
+	)`
+
+// CreateNotifications stores a batch of notifications in one transaction
+func (r *SQLiteNotificationRepository) CreateNotifications(ctx context.Context, notifications []models.Notification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("CreateNotifications: could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO notifications (id, user_id, type, actor_id, post_id, comment_id, preview, created_at)
+		VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("CreateNotifications: preparing insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, n := range notifications {
+		if _, err := stmt.ExecContext(ctx, n.ID, n.UserID, n.Type, n.ActorID, n.PostID, n.CommentID, n.Preview, n.CreatedAt); err != nil {
+			return fmt.Errorf("CreateNotifications: inserting notification: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListNotifications returns the user's notifications, newest first
+func (r *SQLiteNotificationRepository) ListNotifications(ctx context.Context, userID string, unreadOnly bool, limit, offset int) ([]models.Notification, error) {
+	query := notificationSelect + `
+		WHERE n.user_id = ? AND ` + notificationVisible
+	if unreadOnly {
+		query += ` AND n.read_at IS NULL`
+	}
+	query += `
+		ORDER BY n.created_at DESC
+		LIMIT ? OFFSET ?`
+
+	rows, err := r.read.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, *notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *SQLiteNotificationRepository) CountUnread(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.read.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM notifications n
+		LEFT JOIN posts p ON p.id = n.post_id
+		WHERE n.user_id = ? AND n.read_at IS NULL AND `+notificationVisible, userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks the given notifications of the user as read, or all of them
+// when no IDs are given. It returns how many changed.
+func (r *SQLiteNotificationRepository) MarkRead(ctx context.Context, userID string, ids []string) (int64, error) {
+	query := `UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL`
+	args := []interface{}{time.Now(), userID}
+	if len(ids) > 0 {
+		query += ` AND id IN (` + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetPostAuthorRecipient returns the author of the post as a notification
+// recipient. It returns sql.ErrNoRows when the author is the actor, has a
+// block with the actor, or the post does not exist.
+func (r *SQLiteNotificationRepository) GetPostAuthorRecipient(ctx context.Context, postID, actorID string) (*models.User, error) {
+	var user models.User
+	err := r.read.QueryRowContext(ctx, `
+		SELECT u.id, u.nickname
+		FROM posts p
+		JOIN users u ON u.id = p.author_id
+		WHERE p.id = ? AND u.id != ? AND `+notBlockedWith,
+		postID, actorID, actorID, actorID).Scan(&user.ID, &user.Nickname)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetCommenterRecipients returns everyone else who commented on the post,
+// apart from its author, the actor and anyone with a block with the actor
+func (r *SQLiteNotificationRepository) GetCommenterRecipients(ctx context.Context, postID, actorID string) ([]models.User, error) {
+	return r.queryRecipients(ctx, `
+		SELECT DISTINCT u.id, u.nickname
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		JOIN users u ON u.id = c.author_id
+		WHERE c.post_id = ? AND c.hidden_at IS NULL AND u.id != p.author_id AND u.id != ? AND `+notBlockedWith,
+		postID, actorID, actorID, actorID)
+}
+
+// GetSubscriberRecipients returns the subscribers of any of the categories,
+// apart from the actor and anyone with a block with the actor
+func (r *SQLiteNotificationRepository) GetSubscriberRecipients(ctx context.Context, categoryIDs []string, actorID string) ([]models.User, error) {
+	if len(categoryIDs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, 0, len(categoryIDs)+3)
+	for _, id := range categoryIDs {
+		args = append(args, id)
+	}
+	args = append(args, actorID, actorID, actorID)
+
+	return r.queryRecipients(ctx, `
+		SELECT DISTINCT u.id, u.nickname
+		FROM category_subscriptions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.category_id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(categoryIDs)), ",")+`)
+		AND u.id != ? AND `+notBlockedWith, args...)
+}
+
+func (r *SQLiteNotificationRepository) queryRecipients(ctx context.Context, query string, args ...interface{}) ([]models.User, error) {
+	rows, err := r.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Nickname); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// scanNotification reads one row produced by notificationSelect
+func scanNotification(row interface{ Scan(...interface{}) error }) (*models.Notification, error) {
+	var notification models.Notification
+	var readAt sql.NullTime
+	if err := row.Scan(
+		&notification.ID,
+		&notification.UserID,
+		&notification.Type,
+		&notification.ActorID,
+		&notification.ActorName,
+		&notification.PostID,
+		&notification.PostTitle,
+		&notification.CommentID,
+		&notification.Preview,
+		&notification.CreatedAt,
+		&readAt,
+	); err != nil {
+		return nil, err
+	}
+	if readAt.Valid {
+		notification.ReadAt = &readAt.Time
+	}
+	return &notification, nil
+}
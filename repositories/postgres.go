@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// The PostgreSQL repositories write their queries with ? placeholders like
+// the SQLite ones, so query fragments can be shared, and number them with
+// rebind before running them.
+
+// rebind replaces each ? outside a string literal with $1, $2, ...
+func rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+
+	n := 0
+	inLiteral := false
+	for _, c := range query {
+		switch {
+		case c == '\'':
+			inLiteral = !inLiteral
+		case c == '?' && !inLiteral:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// serialID parses the ID of a row with an integer key, such as a category or
+// chat message. The API passes these around as strings; SQLite compares a
+// malformed one as "no such row" while PostgreSQL rejects the query, so the
+// PostgreSQL repositories check first and treat it as missing.
+func serialID(id string) (int64, bool) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	return n, err == nil
+}
+
+// serialIDs keeps the well-formed IDs of a list
+func serialIDs(ids []string) []int64 {
+	parsed := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if n, ok := serialID(id); ok {
+			parsed = append(parsed, n)
+		}
+	}
+	return parsed
+}
+
+// placeholders returns n comma separated ? placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// postgresDuplicate turns a unique_violation into ErrDuplicate
+func postgresDuplicate(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
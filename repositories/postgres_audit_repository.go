@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"real-time-forum/models"
+	"strings"
+)
+
+type PostgresAuditRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresAuditRepository(db *sql.DB) *PostgresAuditRepository {
+	return &PostgresAuditRepository{db: db}
+}
+
+// InsertEvent appends an event to the audit log. The table refuses updates
+// and deletes, so this is the only way it changes.
+func (r *PostgresAuditRepository) InsertEvent(ctx context.Context, event *models.AuditEvent) error {
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return err
+	}
+	if event.Metadata == nil {
+		metadata = []byte("{}")
+	}
+
+	return r.db.QueryRowContext(ctx, rebind(`
+		INSERT INTO audit_events (action, actor_id, actor_name, target_type, target_id, ip, metadata, created_at)
+		VALUES (?, NULLIF(?, ''), ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		event.Action, event.ActorID, event.ActorName, event.TargetType, event.TargetID,
+		event.IP, string(metadata), event.CreatedAt).Scan(&event.ID)
+}
+
+// ListEvents returns the events matching the filter, newest first. An action
+// ending in ".*" matches a whole group, e.g. "moderation.*".
+func (r *PostgresAuditRepository) ListEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	query := `
+		SELECT id, action, COALESCE(actor_id, ''), actor_name, target_type, target_id, ip, metadata, created_at
+		FROM audit_events
+		WHERE 1 = 1`
+	var args []interface{}
+
+	if filter.Action != "" {
+		if group, ok := strings.CutSuffix(filter.Action, ".*"); ok && group != "" {
+			query += ` AND action LIKE ? ESCAPE '\'`
+			args = append(args, escapeLike(group+".")+"%")
+		} else {
+			query += ` AND action = ?`
+			args = append(args, filter.Action)
+		}
+	}
+	if filter.Actor != "" {
+		query += ` AND (actor_id = ? OR actor_name = ?)`
+		args = append(args, filter.Actor, filter.Actor)
+	}
+	if filter.TargetType != "" {
+		query += ` AND target_type = ?`
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query += ` AND target_id = ?`
+		args = append(args, filter.TargetID)
+	}
+	if filter.IP != "" {
+		query += ` AND ip = ?`
+		args = append(args, filter.IP)
+	}
+	if filter.Since != nil {
+		query += ` AND created_at >= ?`
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.Until != nil {
+		query += ` AND created_at < ?`
+		args = append(args, filter.Until.UTC())
+	}
+
+	query += `
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		var metadata string
+		if err := rows.Scan(
+			&event.ID,
+			&event.Action,
+			&event.ActorID,
+			&event.ActorName,
+			&event.TargetType,
+			&event.TargetID,
+			&event.IP,
+			&metadata,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(metadata), &event.Metadata); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
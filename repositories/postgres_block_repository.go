@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"real-time-forum/models"
+	"time"
+)
+
+type PostgresBlockRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresBlockRepository(db *sql.DB) *PostgresBlockRepository {
+	return &PostgresBlockRepository{db: db}
+}
+
+// Block adds blockedID to the block list of blockerID. Blocking someone twice
+// is not an error.
+func (r *PostgresBlockRepository) Block(ctx context.Context, blockerID, blockedID string) error {
+	_, err := r.db.ExecContext(ctx, rebind(`
+		INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`), blockerID, blockedID, time.Now())
+	return err
+}
+
+func (r *PostgresBlockRepository) Unblock(ctx context.Context, blockerID, blockedID string) error {
+	_, err := r.db.ExecContext(ctx, rebind(`
+		DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?`), blockerID, blockedID)
+	return err
+}
+
+// GetBlockedUsers returns the users on the block list of userID, most
+// recently blocked first
+func (r *PostgresBlockRepository) GetBlockedUsers(ctx context.Context, userID string) ([]models.BlockedUser, error) {
+	rows, err := r.db.QueryContext(ctx, rebind(`
+		SELECT u.id, u.nickname, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = ?
+		ORDER BY b.created_at DESC`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocked []models.BlockedUser
+	for rows.Next() {
+		var b models.BlockedUser
+		if err := rows.Scan(&b.UserID, &b.Nickname, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return blocked, nil
+}
+
+// IsBlockedBetween reports whether either of the two users, given by
+// nickname, has blocked the other
+func (r *PostgresBlockRepository) IsBlockedBetween(ctx context.Context, nickname1, nickname2 string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, rebind(`
+		SELECT EXISTS(
+			SELECT 1 FROM user_blocks b
+			JOIN users u1 ON u1.id = b.blocker_id
+			JOIN users u2 ON u2.id = b.blocked_id
+			WHERE (u1.nickname = ? AND u2.nickname = ?) OR (u1.nickname = ? AND u2.nickname = ?)
+		)`), nickname1, nickname2, nickname2, nickname1).Scan(&exists)
+	return exists, err
+}
+
+// GetBlockedNicknames returns the nicknames of everyone the user has blocked
+// or been blocked by
+func (r *PostgresBlockRepository) GetBlockedNicknames(ctx context.Context, nickname string) (map[string]bool, error) {
+	rows, err := r.db.QueryContext(ctx, rebind(`
+		SELECT ub.nickname
+		FROM user_blocks b
+		JOIN users ua ON ua.id = b.blocker_id
+		JOIN users ub ON ub.id = b.blocked_id
+		WHERE ua.nickname = ?
+		UNION
+		SELECT ua.nickname
+		FROM user_blocks b
+		JOIN users ua ON ua.id = b.blocker_id
+		JOIN users ub ON ub.id = b.blocked_id
+		WHERE ub.nickname = ?`), nickname, nickname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocked := make(map[string]bool)
+	for rows.Next() {
+		var other string
+		if err := rows.Scan(&other); err != nil {
+			return nil, err
+		}
+		blocked[other] = true
+	}
+	return blocked, rows.Err()
+}
@@ -0,0 +1,203 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"real-time-forum/models"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type PostgresCategoriesRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresCategoriesRepository(db *sql.DB) *PostgresCategoriesRepository {
+	return &PostgresCategoriesRepository{db: db}
+}
+
+// GetAllCategories returns the active categories in display order
+func (r *PostgresCategoriesRepository) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	return r.GetCategoriesForUser(ctx, "")
+}
+
+// GetCategoriesForUser returns the active categories in display order, with
+// Subscribed set for the ones the user follows
+func (r *PostgresCategoriesRepository) GetCategoriesForUser(ctx context.Context, userID string) ([]models.Category, error) {
+	return r.queryCategories(ctx, categorySelect+`
+		WHERE c.archived_at IS NULL
+		ORDER BY c.position, c.id`, userID)
+}
+
+// GetAllCategoriesWithArchived returns every category, archived ones included
+func (r *PostgresCategoriesRepository) GetAllCategoriesWithArchived(ctx context.Context) ([]models.Category, error) {
+	return r.queryCategories(ctx, categorySelect+`
+		ORDER BY c.position, c.id`, "")
+}
+
+// GetSubscribedCategories returns the categories the user follows
+func (r *PostgresCategoriesRepository) GetSubscribedCategories(ctx context.Context, userID string) ([]models.Category, error) {
+	return r.queryCategories(ctx, categorySelect+`
+		JOIN category_subscriptions s ON s.category_id = c.id AND s.user_id = ?
+		ORDER BY c.position, c.id`, userID, userID)
+}
+
+func (r *PostgresCategoriesRepository) queryCategories(ctx context.Context, query string, args ...interface{}) ([]models.Category, error) {
+	rows, err := r.db.QueryContext(ctx, rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *PostgresCategoriesRepository) GetCategoryByID(ctx context.Context, categoryID, userID string) (*models.Category, error) {
+	id, ok := serialID(categoryID)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	row := r.db.QueryRowContext(ctx, rebind(categorySelect+`
+		WHERE c.id = ?`), userID, id)
+	return scanCategory(row)
+}
+
+// Subscribe makes the user follow a category. Subscribing twice is a no-op.
+func (r *PostgresCategoriesRepository) Subscribe(ctx context.Context, userID, categoryID string) error {
+	id, ok := serialID(categoryID)
+	if !ok {
+		return fmt.Errorf("Subscribe: invalid category %q", categoryID)
+	}
+	_, err := r.db.ExecContext(ctx, rebind(`
+		INSERT INTO category_subscriptions (user_id, category_id, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`), userID, id, time.Now())
+	return err
+}
+
+func (r *PostgresCategoriesRepository) Unsubscribe(ctx context.Context, userID, categoryID string) error {
+	id, ok := serialID(categoryID)
+	if !ok {
+		return nil
+	}
+	_, err := r.db.ExecContext(ctx, rebind(`
+		DELETE FROM category_subscriptions WHERE user_id = ? AND category_id = ?`), userID, id)
+	return err
+}
+
+// CategoryNameExists reports whether another category already uses the name,
+// ignoring case
+func (r *PostgresCategoriesRepository) CategoryNameExists(ctx context.Context, name, excludeID string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM categories WHERE lower(name) = lower(?)`
+	args := []interface{}{name}
+	if id, ok := serialID(excludeID); ok {
+		query += ` AND id != ?`
+		args = append(args, id)
+	}
+	query += `)`
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, rebind(query), args...).Scan(&exists)
+	return exists, err
+}
+
+// CountActiveCategories counts how many of the given IDs are existing,
+// non-archived categories
+func (r *PostgresCategoriesRepository) CountActiveCategories(ctx context.Context, categoryIDs []string) (int, error) {
+	ids := serialIDs(categoryIDs)
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM categories WHERE archived_at IS NULL AND id = ANY($1)`,
+		pq.Array(ids)).Scan(&count)
+	return count, err
+}
+
+// CreateCategory inserts a category at the end of the display order
+func (r *PostgresCategoriesRepository) CreateCategory(ctx context.Context, category *models.Category) error {
+	var id int64
+	err := r.db.QueryRowContext(ctx, rebind(`
+		INSERT INTO categories (name, description, position)
+		VALUES (?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM categories))
+		RETURNING id, position`),
+		category.Name, category.Description).Scan(&id, &category.Position)
+	if err != nil {
+		return err
+	}
+	category.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (r *PostgresCategoriesRepository) UpdateCategory(ctx context.Context, category *models.Category) error {
+	id, ok := serialID(category.ID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	res, err := r.db.ExecContext(ctx, rebind(`
+		UPDATE categories SET name = ?, description = ? WHERE id = ?`),
+		category.Name, category.Description, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+func (r *PostgresCategoriesRepository) SetCategoryArchived(ctx context.Context, categoryID string, archived bool) error {
+	id, ok := serialID(categoryID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	var archivedAt interface{}
+	if archived {
+		archivedAt = time.Now()
+	}
+
+	res, err := r.db.ExecContext(ctx, rebind(`UPDATE categories SET archived_at = ? WHERE id = ?`), archivedAt, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// ReorderCategories stores the given order as the display position of each
+// category
+func (r *PostgresCategoriesRepository) ReorderCategories(ctx context.Context, categoryIDs []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ReorderCategories: could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, categoryID := range categoryIDs {
+		id, ok := serialID(categoryID)
+		if !ok {
+			return sql.ErrNoRows
+		}
+		res, err := tx.ExecContext(ctx, rebind(`UPDATE categories SET position = ? WHERE id = ?`), i+1, id)
+		if err != nil {
+			return fmt.Errorf("ReorderCategories: updating category %s: %w", categoryID, err)
+		}
+		if err := expectOneRow(res); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
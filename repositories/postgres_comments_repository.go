@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"real-time-forum/models"
+	"time"
+)
+
+type PostgresCommentRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresCommentRepository(db *sql.DB) *PostgresCommentRepository {
+	return &PostgresCommentRepository{db: db}
+}
+
+func (r *PostgresCommentRepository) CreateComment(ctx context.Context, comment *models.Comment) error {
+	_, err := r.db.ExecContext(ctx, rebind(`
+		INSERT INTO comments (id, post_id, author_id, content)
+		VALUES (?, ?, ?, ?)`),
+		comment.ID, comment.PostID, comment.AuthorID, comment.Content)
+	return err
+}
+
+func (r *PostgresCommentRepository) GetPostComments(ctx context.Context, postID string) ([]models.Comment, error) {
+	rows, err := r.db.QueryContext(ctx, rebind(`
+		SELECT c.id, COALESCE(u.nickname, 'Unknown'), c.content, c.created_at
+		FROM comments c
+		LEFT JOIN users u ON c.author_id = u.id
+		WHERE c.post_id = ? AND c.hidden_at IS NULL
+		ORDER BY c.created_at DESC`), postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []models.Comment
+	for rows.Next() {
+		var comment models.Comment
+		if err := rows.Scan(&comment.ID, &comment.AuthorName, &comment.Content, &comment.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// SetCommentHidden hides a comment or makes it visible again. It returns
+// sql.ErrNoRows when the comment does not exist.
+func (r *PostgresCommentRepository) SetCommentHidden(ctx context.Context, commentID string, hidden bool) error {
+	var hiddenAt interface{}
+	if hidden {
+		hiddenAt = time.Now()
+	}
+
+	res, err := r.db.ExecContext(ctx, rebind(`UPDATE comments SET hidden_at = ? WHERE id = ?`), hiddenAt, commentID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// DeleteComment removes a comment. It returns sql.ErrNoRows when the comment
+// does not exist.
+func (r *PostgresCommentRepository) DeleteComment(ctx context.Context, commentID string) error {
+	res, err := r.db.ExecContext(ctx, rebind(`DELETE FROM comments WHERE id = ?`), commentID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"real-time-forum/models"
+	"time"
+)
+
+type PostgresMessageRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresMessageRepository(db *sql.DB) *PostgresMessageRepository {
+	return &PostgresMessageRepository{db: db}
+}
+
+// SaveMessage saves a chat message and sets its ID
+func (r *PostgresMessageRepository) SaveMessage(ctx context.Context, message *models.Message) error {
+	return r.db.QueryRowContext(ctx, rebind(`
+		INSERT INTO messages (from_user, to_user, body, created_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING id`),
+		message.From, message.To, message.Content, message.Timestamp).Scan(&message.ID)
+}
+
+// GetMessagesWithPagination returns one page of the conversation between two
+// users, counting back from the newest message, in chronological order
+func (r *PostgresMessageRepository) GetMessagesWithPagination(ctx context.Context, user1, user2 string, limit, offset int) ([]models.Message, error) {
+	rows, err := r.db.QueryContext(ctx, rebind(`
+		SELECT id, from_user, to_user, body, created_at
+		FROM messages
+		WHERE ((from_user = ? AND to_user = ?) OR (from_user = ? AND to_user = ?))
+		AND hidden_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?`), user1, user2, user2, user1, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	for rows.Next() {
+		var msg models.Message
+		if err := rows.Scan(&msg.ID, &msg.From, &msg.To, &msg.Content, &msg.Timestamp); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, rows.Err()
+}
+
+// SetMessageHidden hides a chat message from the history or makes it visible
+// again. It returns sql.ErrNoRows when the message does not exist.
+func (r *PostgresMessageRepository) SetMessageHidden(ctx context.Context, messageID string, hidden bool) error {
+	id, ok := serialID(messageID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	var hiddenAt interface{}
+	if hidden {
+		hiddenAt = time.Now()
+	}
+
+	res, err := r.db.ExecContext(ctx, rebind(`UPDATE messages SET hidden_at = ? WHERE id = ?`), hiddenAt, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// DeleteMessage removes a chat message. It returns sql.ErrNoRows when the
+// message does not exist.
+func (r *PostgresMessageRepository) DeleteMessage(ctx context.Context, messageID string) error {
+	id, ok := serialID(messageID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	res, err := r.db.ExecContext(ctx, rebind(`DELETE FROM messages WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// GetLastMessageTimestamps gets the last message timestamp for each user the
+// current user has chatted with. The times are fixed-width UTC so they sort
+// as strings.
+func (r *PostgresMessageRepository) GetLastMessageTimestamps(ctx context.Context, currentUser string) (map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx, rebind(`
+		SELECT
+			CASE WHEN from_user = ? THEN to_user ELSE from_user END AS other_user,
+			to_char(MAX(created_at) AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS.US')
+		FROM messages
+		WHERE from_user = ? OR to_user = ?
+		GROUP BY other_user`), currentUser, currentUser, currentUser)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timestamps := make(map[string]string)
+	for rows.Next() {
+		var otherUser, lastMessageTime string
+		if err := rows.Scan(&otherUser, &lastMessageTime); err != nil {
+			return nil, err
+		}
+		timestamps[otherUser] = lastMessageTime
+	}
+	return timestamps, rows.Err()
+}
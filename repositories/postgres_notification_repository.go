@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"real-time-forum/models"
+	"time"
+)
+
+type PostgresNotificationRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresNotificationRepository(db *sql.DB) *PostgresNotificationRepository {
+	return &PostgresNotificationRepository{db: db}
+}
+
+// CreateNotifications stores a batch of notifications in one transaction
+func (r *PostgresNotificationRepository) CreateNotifications(ctx context.Context, notifications []models.Notification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("CreateNotifications: could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, rebind(`
+		INSERT INTO notifications (id, user_id, type, actor_id, post_id, comment_id, preview, created_at)
+		VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?, ?)`))
+	if err != nil {
+		return fmt.Errorf("CreateNotifications: preparing insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, n := range notifications {
+		if _, err := stmt.ExecContext(ctx, n.ID, n.UserID, n.Type, n.ActorID, n.PostID, n.CommentID, n.Preview, n.CreatedAt); err != nil {
+			return fmt.Errorf("CreateNotifications: inserting notification: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListNotifications returns the user's notifications, newest first
+func (r *PostgresNotificationRepository) ListNotifications(ctx context.Context, userID string, unreadOnly bool, limit, offset int) ([]models.Notification, error) {
+	query := notificationSelect + `
+		WHERE n.user_id = ? AND ` + notificationVisible
+	if unreadOnly {
+		query += ` AND n.read_at IS NULL`
+	}
+	query += `
+		ORDER BY n.created_at DESC
+		LIMIT ? OFFSET ?`
+
+	rows, err := r.db.QueryContext(ctx, rebind(query), userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, *notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *PostgresNotificationRepository) CountUnread(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, rebind(`
+		SELECT COUNT(*)
+		FROM notifications n
+		LEFT JOIN posts p ON p.id = n.post_id
+		WHERE n.user_id = ? AND n.read_at IS NULL AND `+notificationVisible), userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks the given notifications of the user as read, or all of them
+// when no IDs are given. It returns how many changed.
+func (r *PostgresNotificationRepository) MarkRead(ctx context.Context, userID string, ids []string) (int64, error) {
+	query := `UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL`
+	args := []interface{}{time.Now(), userID}
+	if len(ids) > 0 {
+		query += ` AND id IN (` + placeholders(len(ids)) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	res, err := r.db.ExecContext(ctx, rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetPostAuthorRecipient returns the author of the post as a notification
+// recipient. It returns sql.ErrNoRows when the author is the actor, has a
+// block with the actor, or the post does not exist.
+func (r *PostgresNotificationRepository) GetPostAuthorRecipient(ctx context.Context, postID, actorID string) (*models.User, error) {
+	var user models.User
+	err := r.db.QueryRowContext(ctx, rebind(`
+		SELECT u.id, u.nickname
+		FROM posts p
+		JOIN users u ON u.id = p.author_id
+		WHERE p.id = ? AND u.id != ? AND `+notBlockedWith),
+		postID, actorID, actorID, actorID).Scan(&user.ID, &user.Nickname)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetCommenterRecipients returns everyone else who commented on the post,
+// apart from its author, the actor and anyone with a block with the actor
+func (r *PostgresNotificationRepository) GetCommenterRecipients(ctx context.Context, postID, actorID string) ([]models.User, error) {
+	return r.queryRecipients(ctx, `
+		SELECT DISTINCT u.id, u.nickname
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		JOIN users u ON u.id = c.author_id
+		WHERE c.post_id = ? AND c.hidden_at IS NULL AND u.id != p.author_id AND u.id != ? AND `+notBlockedWith,
+		postID, actorID, actorID, actorID)
+}
+
+// GetSubscriberRecipients returns the subscribers of any of the categories,
+// apart from the actor and anyone with a block with the actor
+func (r *PostgresNotificationRepository) GetSubscriberRecipients(ctx context.Context, categoryIDs []string, actorID string) ([]models.User, error) {
+	ids := serialIDs(categoryIDs)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, 0, len(ids)+3)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, actorID, actorID, actorID)
+
+	return r.queryRecipients(ctx, `
+		SELECT DISTINCT u.id, u.nickname
+		FROM category_subscriptions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.category_id IN (`+placeholders(len(ids))+`)
+		AND u.id != ? AND `+notBlockedWith, args...)
+}
+
+func (r *PostgresNotificationRepository) queryRecipients(ctx context.Context, query string, args ...interface{}) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx, rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Nickname); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
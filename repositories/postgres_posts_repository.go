@@ -0,0 +1,232 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"real-time-forum/models"
+	"real-time-forum/utils"
+	"strings"
+	"time"
+)
+
+type PostgresPostRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresPostRepository(db *sql.DB) *PostgresPostRepository {
+	return &PostgresPostRepository{db: db}
+}
+
+// postgresPostColumns are the columns of a post listing; the category names
+// come from whichever post_categories rows the query joins
+const postgresPostColumns = `
+	SELECT
+	p.id,
+	COALESCE(u.nickname, 'Unknown'),
+	p.title,
+	p.content,
+	p.created_at,
+	COALESCE(p.image, ''),
+	string_agg(c.name, ',' ORDER BY c.position, c.id)
+	FROM posts p
+	LEFT JOIN users u ON p.author_id = u.id`
+
+func (r *PostgresPostRepository) CreatePost(ctx context.Context, user *models.User, post *models.Post, categoryIDs []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("CreatePost: could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	utils.Logger(ctx).Debug("CreatePost: inserting post", "post_id", post.ID, "author_id", user.ID)
+
+	if _, err := tx.ExecContext(ctx, rebind(`
+		INSERT INTO posts (id, author_id, title, content, created_at, image)
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''))`),
+		post.ID, user.ID, post.Title, post.Content, post.CreatedAt, post.Image); err != nil {
+		return fmt.Errorf("CreatePost: inserting post: %w", err)
+	}
+
+	for _, cid := range categoryIDs {
+		id, ok := serialID(cid)
+		if !ok {
+			return fmt.Errorf("CreatePost: invalid category %q", cid)
+		}
+		if _, err := tx.ExecContext(ctx, rebind(`
+			INSERT INTO post_categories (post_id, category_id)
+			VALUES (?, ?)
+			ON CONFLICT DO NOTHING`), post.ID, id); err != nil {
+			return fmt.Errorf("CreatePost: inserting post_category link for category %s: %w", cid, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("CreatePost: could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresPostRepository) GetAllPosts(ctx context.Context) ([]models.Post, error) {
+	return r.queryPosts(ctx, postgresPostColumns+`
+		LEFT JOIN post_categories pc ON p.id = pc.post_id
+		LEFT JOIN categories c ON pc.category_id = c.id
+		WHERE p.hidden_at IS NULL
+		GROUP BY p.id, u.nickname
+		ORDER BY p.created_at DESC`)
+}
+
+func (r *PostgresPostRepository) GetPostByID(ctx context.Context, postID string) (*models.Post, error) {
+	var pv models.Post
+	var cats sql.NullString
+	err := r.db.QueryRowContext(ctx, rebind(`
+		SELECT
+		p.id,
+		p.author_id,
+		COALESCE(u.nickname, 'Unknown'),
+		p.title,
+		p.content,
+		p.created_at,
+		COALESCE(p.image, ''),
+		string_agg(c.name, ',' ORDER BY c.position, c.id)
+		FROM posts p
+		LEFT JOIN users u ON p.author_id = u.id
+		LEFT JOIN post_categories pc ON p.id = pc.post_id
+		LEFT JOIN categories c ON pc.category_id = c.id
+		WHERE p.id = ? AND p.hidden_at IS NULL
+		GROUP BY p.id, u.nickname`), postID).Scan(
+		&pv.ID,
+		&pv.AuthorID,
+		&pv.AuthorName,
+		&pv.Title,
+		&pv.Content,
+		&pv.CreatedAt,
+		&pv.Image,
+		&cats,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.Logger(ctx).Debug("GetPostByID: post not found", "post_id", postID)
+		return nil, fmt.Errorf("post with ID %s not found: %w", postID, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cats.Valid {
+		pv.Categories = strings.Split(cats.String, ",")
+	}
+	return &pv, nil
+}
+
+func (r *PostgresPostRepository) GetPostsByCategory(ctx context.Context, categoryID string) ([]models.Post, error) {
+	id, ok := serialID(categoryID)
+	if !ok {
+		return nil, nil
+	}
+	return r.queryPosts(ctx, postgresPostColumns+`
+		JOIN post_categories pc ON p.id = pc.post_id
+		LEFT JOIN categories c ON pc.category_id = c.id
+		WHERE pc.category_id = ? AND p.hidden_at IS NULL
+		GROUP BY p.id, u.nickname
+		ORDER BY p.created_at DESC`, id)
+}
+
+// GetSubscribedPosts returns posts filed under any category the user is
+// subscribed to
+func (r *PostgresPostRepository) GetSubscribedPosts(ctx context.Context, userID string) ([]models.Post, error) {
+	return r.queryPosts(ctx, postgresPostColumns+`
+		JOIN post_categories pc ON p.id = pc.post_id
+		LEFT JOIN categories c ON pc.category_id = c.id
+		WHERE EXISTS (
+			SELECT 1 FROM post_categories spc
+			JOIN category_subscriptions cs ON cs.category_id = spc.category_id
+			WHERE spc.post_id = p.id AND cs.user_id = ?
+		) AND p.hidden_at IS NULL
+		GROUP BY p.id, u.nickname
+		ORDER BY p.created_at DESC`, userID)
+}
+
+func (r *PostgresPostRepository) GetUserPosts(ctx context.Context, userID string) ([]models.Post, error) {
+	return r.queryPosts(ctx, postgresPostColumns+`
+		JOIN post_categories pc ON p.id = pc.post_id
+		LEFT JOIN categories c ON pc.category_id = c.id
+		WHERE p.author_id = ? AND p.hidden_at IS NULL
+		GROUP BY p.id, u.nickname
+		ORDER BY p.created_at DESC`, userID)
+}
+
+func (r *PostgresPostRepository) queryPosts(ctx context.Context, query string, args ...interface{}) ([]models.Post, error) {
+	rows, err := r.db.QueryContext(ctx, rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		var pv models.Post
+		var cats sql.NullString
+		if err := rows.Scan(
+			&pv.ID,
+			&pv.AuthorName,
+			&pv.Title,
+			&pv.Content,
+			&pv.CreatedAt,
+			&pv.Image,
+			&cats,
+		); err != nil {
+			return nil, err
+		}
+
+		if cats.Valid {
+			pv.Categories = strings.Split(cats.String, ",")
+		} else {
+			pv.Categories = []string{}
+		}
+		posts = append(posts, pv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// SetPostHidden hides a post from every listing or makes it visible again.
+// It returns sql.ErrNoRows when the post does not exist.
+func (r *PostgresPostRepository) SetPostHidden(ctx context.Context, postID string, hidden bool) error {
+	var hiddenAt interface{}
+	if hidden {
+		hiddenAt = time.Now()
+	}
+
+	res, err := r.db.ExecContext(ctx, rebind(`UPDATE posts SET hidden_at = ? WHERE id = ?`), hiddenAt, postID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// DeletePost removes a post. Its comments, category links and notifications
+// go with it through ON DELETE CASCADE. It returns sql.ErrNoRows when the
+// post does not exist.
+func (r *PostgresPostRepository) DeletePost(ctx context.Context, postID string) error {
+	res, err := r.db.ExecContext(ctx, rebind(`DELETE FROM posts WHERE id = ?`), postID)
+	if err != nil {
+		return fmt.Errorf("DeletePost: deleting post: %w", err)
+	}
+	return expectOneRow(res)
+}
+
+// CountRecentDuplicates counts posts created since the given time that repeat
+// a new post: by the same author with the same title or content, and by other
+// authors with the same content
+func (r *PostgresPostRepository) CountRecentDuplicates(ctx context.Context, authorID, title, content string, since time.Time) (own int, others int, err error) {
+	err = r.db.QueryRowContext(ctx, rebind(`
+		SELECT
+		COUNT(*) FILTER (WHERE author_id = ? AND (btrim(title, E' \t\n\r') = ? OR btrim(content, E' \t\n\r') = ?)),
+		COUNT(*) FILTER (WHERE author_id != ? AND btrim(content, E' \t\n\r') = ?)
+		FROM posts
+		WHERE created_at >= ?`),
+		authorID, title, content, authorID, content, since).Scan(&own, &others)
+	return own, others, err
+}
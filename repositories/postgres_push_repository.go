@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"real-time-forum/models"
+)
+
+type PostgresPushRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresPushRepository(db *sql.DB) *PostgresPushRepository {
+	return &PostgresPushRepository{db: db}
+}
+
+// SaveSubscription stores a subscription. Browsers keep their endpoint when
+// they register again, so an existing endpoint is moved to the new user and
+// gets the new keys.
+func (r *PostgresPushRepository) SaveSubscription(ctx context.Context, sub *models.PushSubscription) error {
+	_, err := r.db.ExecContext(ctx, rebind(`
+		INSERT INTO push_subscriptions (id, user_id, endpoint, p256dh, auth, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (endpoint) DO UPDATE SET
+			user_id = excluded.user_id,
+			p256dh = excluded.p256dh,
+			auth = excluded.auth`),
+		sub.ID, sub.UserID, sub.Endpoint, sub.P256dh, sub.Auth, sub.CreatedAt)
+	return err
+}
+
+func (r *PostgresPushRepository) DeleteSubscription(ctx context.Context, userID, endpoint string) (int64, error) {
+	res, err := r.db.ExecContext(ctx, rebind(`
+		DELETE FROM push_subscriptions WHERE user_id = ? AND endpoint = ?`), userID, endpoint)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteEndpoint drops a subscription the push service reported as gone
+func (r *PostgresPushRepository) DeleteEndpoint(ctx context.Context, endpoint string) error {
+	_, err := r.db.ExecContext(ctx, rebind(`
+		DELETE FROM push_subscriptions WHERE endpoint = ?`), endpoint)
+	return err
+}
+
+// GetSubscriptionsByNickname returns every browser the user registered
+func (r *PostgresPushRepository) GetSubscriptionsByNickname(ctx context.Context, nickname string) ([]models.PushSubscription, error) {
+	rows, err := r.db.QueryContext(ctx, rebind(`
+		SELECT s.id, s.user_id, s.endpoint, s.p256dh, s.auth, s.created_at
+		FROM push_subscriptions s
+		JOIN users u ON u.id = s.user_id
+		WHERE u.nickname = ?
+		ORDER BY s.created_at`), nickname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []models.PushSubscription
+	for rows.Next() {
+		var sub models.PushSubscription
+		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.Endpoint, &sub.P256dh, &sub.Auth, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// GetVAPIDKeys returns the server's VAPID keys, or sql.ErrNoRows before they
+// were generated
+func (r *PostgresPushRepository) GetVAPIDKeys(ctx context.Context) (*models.VAPIDKeys, error) {
+	var keys models.VAPIDKeys
+	err := r.db.QueryRowContext(ctx, `
+		SELECT public_key, private_key FROM vapid_keys WHERE id = 1`).Scan(&keys.PublicKey, &keys.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &keys, nil
+}
+
+// SaveVAPIDKeys stores freshly generated keys unless another process got
+// there first; the keys that won are returned either way
+func (r *PostgresPushRepository) SaveVAPIDKeys(ctx context.Context, keys *models.VAPIDKeys) (*models.VAPIDKeys, error) {
+	_, err := r.db.ExecContext(ctx, rebind(`
+		INSERT INTO vapid_keys (id, public_key, private_key) VALUES (1, ?, ?)
+		ON CONFLICT (id) DO NOTHING`), keys.PublicKey, keys.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetVAPIDKeys(ctx)
+}
@@ -0,0 +1,233 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"real-time-forum/models"
+	"time"
+)
+
+type PostgresReportRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresReportRepository(db *sql.DB) *PostgresReportRepository {
+	return &PostgresReportRepository{db: db}
+}
+
+// postgresReportSelect is reportSelect for PostgreSQL, which will not compare
+// the integer message ID with the text content ID without a cast
+const postgresReportSelect = `
+	SELECT
+	r.id,
+	r.content_type,
+	r.content_id,
+	COALESCE(substr(CASE r.content_type
+		WHEN 'post' THEN (SELECT p.title FROM posts p WHERE p.id = r.content_id)
+		WHEN 'comment' THEN (SELECT c.content FROM comments c WHERE c.id = r.content_id)
+		WHEN 'message' THEN (SELECT m.body FROM messages m WHERE m.id::text = r.content_id)
+	END, 1, 200), ''),
+	r.author_id,
+	COALESCE(au.nickname, 'Unknown'),
+	COALESCE(r.reporter_id, ''),
+	CASE WHEN r.reporter_id IS NULL THEN 'system' ELSE COALESCE(ru.nickname, 'Unknown') END,
+	r.reason,
+	r.status,
+	COALESCE(r.claimed_by, ''),
+	COALESCE(cu.nickname, ''),
+	COALESCE(r.action, ''),
+	r.note,
+	r.created_at,
+	r.updated_at,
+	r.resolved_at
+	FROM reports r
+	LEFT JOIN users au ON au.id = r.author_id
+	LEFT JOIN users ru ON ru.id = r.reporter_id
+	LEFT JOIN users cu ON cu.id = r.claimed_by`
+
+// CreateReport stores a report. An empty ReporterID files it on behalf of the
+// content filter.
+func (r *PostgresReportRepository) CreateReport(ctx context.Context, report *models.Report) error {
+	_, err := r.db.ExecContext(ctx, rebind(`
+		INSERT INTO reports
+		(id, content_type, content_id, author_id, reporter_id, reason, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?)`),
+		report.ID, report.ContentType, report.ContentID, report.AuthorID, report.ReporterID,
+		report.Reason, report.Status, report.CreatedAt, report.UpdatedAt)
+	return err
+}
+
+// OpenReportExists reports whether the reporter already has an unresolved
+// report on the same content
+func (r *PostgresReportRepository) OpenReportExists(ctx context.Context, contentType, contentID, reporterID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, rebind(`
+		SELECT EXISTS(
+			SELECT 1 FROM reports
+			WHERE content_type = ? AND content_id = ? AND reporter_id = ? AND status != ?
+		)`), contentType, contentID, reporterID, models.ReportStatusResolved).Scan(&exists)
+	return exists, err
+}
+
+func (r *PostgresReportRepository) GetReportByID(ctx context.Context, reportID string) (*models.Report, error) {
+	row := r.db.QueryRowContext(ctx, rebind(postgresReportSelect+`
+		WHERE r.id = ?`), reportID)
+	return scanReport(row)
+}
+
+// ListReports returns the reports matching the filter, oldest first so the
+// queue is worked in the order reports came in
+func (r *PostgresReportRepository) ListReports(ctx context.Context, filter models.ReportFilter) ([]models.Report, error) {
+	query := postgresReportSelect + `
+		WHERE 1 = 1`
+	var args []interface{}
+
+	switch filter.Status {
+	case "":
+		query += ` AND r.status != ?`
+		args = append(args, models.ReportStatusResolved)
+	case "all":
+	default:
+		query += ` AND r.status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.ContentType != "" {
+		query += ` AND r.content_type = ?`
+		args = append(args, filter.ContentType)
+	}
+	if filter.ClaimedBy != "" {
+		query += ` AND r.claimed_by = ?`
+		args = append(args, filter.ClaimedBy)
+	}
+	if filter.Unclaimed {
+		query += ` AND r.claimed_by IS NULL`
+	}
+
+	query += `
+		ORDER BY r.created_at ASC, r.id
+		LIMIT ? OFFSET ?`
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []models.Report
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// ClaimReport assigns an open report to a moderator. It returns
+// sql.ErrNoRows when the report is not open any more.
+func (r *PostgresReportRepository) ClaimReport(ctx context.Context, reportID, moderatorID string) error {
+	res, err := r.db.ExecContext(ctx, rebind(`
+		UPDATE reports
+		SET status = ?, claimed_by = ?, updated_at = ?
+		WHERE id = ? AND status = ?`),
+		models.ReportStatusClaimed, moderatorID, time.Now(), reportID, models.ReportStatusOpen)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// ResolveReport closes a report that is open or claimed by the moderator.
+// When allForContent is set every other unresolved report on the same
+// content is closed with it. It returns sql.ErrNoRows when the report could
+// not be resolved by this moderator.
+func (r *PostgresReportRepository) ResolveReport(ctx context.Context, report *models.Report, moderatorID, action, note string, allForContent bool) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	res, err := tx.ExecContext(ctx, rebind(`
+		UPDATE reports
+		SET status = ?, claimed_by = ?, action = ?, note = ?, updated_at = ?, resolved_at = ?
+		WHERE id = ? AND status != ? AND (claimed_by IS NULL OR claimed_by = ?)`),
+		models.ReportStatusResolved, moderatorID, action, note, now, now,
+		report.ID, models.ReportStatusResolved, moderatorID)
+	if err != nil {
+		return err
+	}
+	if err := expectOneRow(res); err != nil {
+		return err
+	}
+
+	if allForContent {
+		_, err = tx.ExecContext(ctx, rebind(`
+			UPDATE reports
+			SET status = ?, claimed_by = COALESCE(claimed_by, ?), action = ?, note = ?, updated_at = ?, resolved_at = ?
+			WHERE content_type = ? AND content_id = ? AND status != ?`),
+			models.ReportStatusResolved, moderatorID, action, note, now, now,
+			report.ContentType, report.ContentID, models.ReportStatusResolved)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetContentAuthor returns the author of a post, comment or chat message. It
+// returns sql.ErrNoRows when the content does not exist.
+func (r *PostgresReportRepository) GetContentAuthor(ctx context.Context, contentType, contentID string) (*models.ReportedContent, error) {
+	var query string
+	var id interface{} = contentID
+	switch contentType {
+	case models.ReportTypePost:
+		query = `SELECT u.id, u.nickname FROM posts p JOIN users u ON u.id = p.author_id WHERE p.id = ?`
+	case models.ReportTypeComment:
+		query = `SELECT u.id, u.nickname FROM comments c JOIN users u ON u.id = c.author_id WHERE c.id = ?`
+	case models.ReportTypeMessage:
+		messageID, ok := serialID(contentID)
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		id = messageID
+		// Chat messages store nicknames rather than user IDs
+		query = `SELECT u.id, u.nickname FROM messages m JOIN users u ON u.nickname = m.from_user WHERE m.id = ?`
+	default:
+		return nil, sql.ErrNoRows
+	}
+
+	var content models.ReportedContent
+	if err := r.db.QueryRowContext(ctx, rebind(query), id).Scan(&content.AuthorID, &content.AuthorName); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// IsMessageParticipant reports whether the user sent or received the message
+func (r *PostgresReportRepository) IsMessageParticipant(ctx context.Context, messageID, nickname string) (bool, error) {
+	id, ok := serialID(messageID)
+	if !ok {
+		return false, nil
+	}
+	var exists bool
+	err := r.db.QueryRowContext(ctx, rebind(`
+		SELECT EXISTS(SELECT 1 FROM messages WHERE id = ? AND (from_user = ? OR to_user = ?))`),
+		id, nickname, nickname).Scan(&exists)
+	return exists, err
+}
+
+func (r *PostgresReportRepository) CreateWarning(ctx context.Context, warning *models.Warning) error {
+	_, err := r.db.ExecContext(ctx, rebind(`
+		INSERT INTO warnings (id, user_id, moderator_id, report_id, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`),
+		warning.ID, warning.UserID, warning.ModeratorID, warning.ReportID, warning.Reason, warning.CreatedAt)
+	return err
+}
@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"real-time-forum/models"
+	"time"
+)
+
+type PostgresSanctionRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresSanctionRepository(db *sql.DB) *PostgresSanctionRepository {
+	return &PostgresSanctionRepository{db: db}
+}
+
+func (r *PostgresSanctionRepository) CreateSanction(ctx context.Context, sanction *models.Sanction) error {
+	_, err := r.db.ExecContext(ctx, rebind(`
+		INSERT INTO user_sanctions (id, user_id, type, reason, moderator_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		sanction.ID, sanction.UserID, sanction.Type, sanction.Reason, sanction.ModeratorID,
+		sanction.CreatedAt, sanction.ExpiresAt)
+	return err
+}
+
+func (r *PostgresSanctionRepository) GetSanctionByID(ctx context.Context, sanctionID string) (*models.Sanction, error) {
+	row := r.db.QueryRowContext(ctx, rebind(sanctionSelect+`
+		WHERE s.id = ?`), sanctionID)
+	return scanSanction(row)
+}
+
+// GetActiveSanction returns the longest lasting active sanction of one of the
+// given types for the user. It returns sql.ErrNoRows when there is none.
+func (r *PostgresSanctionRepository) GetActiveSanction(ctx context.Context, userID string, types ...string) (*models.Sanction, error) {
+	return r.getActiveSanction(ctx, "s.user_id", userID, types)
+}
+
+// GetActiveSanctionByNickname is GetActiveSanction for callers that only know
+// the nickname, such as the chat
+func (r *PostgresSanctionRepository) GetActiveSanctionByNickname(ctx context.Context, nickname string, types ...string) (*models.Sanction, error) {
+	return r.getActiveSanction(ctx, "u.nickname", nickname, types)
+}
+
+func (r *PostgresSanctionRepository) getActiveSanction(ctx context.Context, column, value string, types []string) (*models.Sanction, error) {
+	if len(types) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	args := []interface{}{value, time.Now().UTC()}
+	for _, t := range types {
+		args = append(args, t)
+	}
+
+	row := r.db.QueryRowContext(ctx, rebind(sanctionSelect+`
+		WHERE `+column+` = ? AND `+sanctionActive+` AND s.type IN (`+placeholders(len(types))+`)
+		ORDER BY s.expires_at IS NULL DESC, s.expires_at DESC
+		LIMIT 1`), args...)
+	return scanSanction(row)
+}
+
+// ListSanctions returns sanctions newest first, optionally only those of one
+// user or only the active ones
+func (r *PostgresSanctionRepository) ListSanctions(ctx context.Context, filter models.SanctionFilter) ([]models.Sanction, error) {
+	query := sanctionSelect + `
+		WHERE 1 = 1`
+	var args []interface{}
+
+	if filter.UserID != "" {
+		query += ` AND s.user_id = ?`
+		args = append(args, filter.UserID)
+	}
+	if filter.ActiveOnly {
+		query += ` AND ` + sanctionActive
+		args = append(args, time.Now().UTC())
+	}
+
+	query += `
+		ORDER BY s.created_at DESC
+		LIMIT ? OFFSET ?`
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sanctions []models.Sanction
+	for rows.Next() {
+		sanction, err := scanSanction(rows)
+		if err != nil {
+			return nil, err
+		}
+		sanctions = append(sanctions, *sanction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sanctions, nil
+}
+
+// RevokeSanction lifts a sanction before it expires. It returns sql.ErrNoRows
+// when the sanction does not exist or was already revoked.
+func (r *PostgresSanctionRepository) RevokeSanction(ctx context.Context, sanctionID string) error {
+	res, err := r.db.ExecContext(ctx, rebind(`
+		UPDATE user_sanctions SET revoked_at = ?
+		WHERE id = ? AND revoked_at IS NULL`), time.Now().UTC(), sanctionID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"real-time-forum/models"
+	"regexp"
+	"strings"
+)
+
+type PostgresSearchRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresSearchRepository(db *sql.DB) *PostgresSearchRepository {
+	return &PostgresSearchRepository{db: db}
+}
+
+// EnsureIndex has nothing to do: PostgreSQL searches the base tables through
+// the GIN indexes the migrations create, so there is nothing to rebuild
+func (r *PostgresSearchRepository) EnsureIndex(ctx context.Context) error {
+	return nil
+}
+
+// matchTerm is one quoted prefix term of an FTS5 match expression
+var matchTerm = regexp.MustCompile(`"([^"]+)"\*`)
+
+// tsQuery turns the FTS5 match expression the search service builds into the
+// equivalent tsquery: every term must match, each as a prefix. The service
+// only lets letters and digits into a term, so none needs escaping.
+func tsQuery(match string) string {
+	var terms []string
+	for _, m := range matchTerm.FindAllStringSubmatch(match, -1) {
+		terms = append(terms, m[1]+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+// headlineOptions make ts_headline mark matches like the FTS5 snippets do
+var headlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=16, MinWords=8, MaxFragments=1, FragmentDelimiter=…",
+	SnippetMatchStart, SnippetMatchEnd)
+
+// Search runs the match expression against the requested result types and
+// returns one page of results ordered by relevance, newest first on ties.
+// Scores are negated ranks so the best result sorts first, as with bm25.
+func (r *PostgresSearchRepository) Search(ctx context.Context, match string, q models.SearchQuery) ([]models.SearchResult, error) {
+	query := tsQuery(match)
+	if query == "" {
+		return []models.SearchResult{}, nil
+	}
+
+	var parts []string
+	var args []interface{}
+
+	for _, t := range q.Types {
+		switch t {
+		case models.SearchTypePost:
+			part := `
+				SELECT 'post' AS type, p.id AS id, p.id AS post_id, p.title AS title,
+				ts_headline('simple', p.title || ' ' || p.content, to_tsquery('simple', ?), ?) AS snippet,
+				COALESCE(u.nickname, 'Unknown') AS author_name,
+				p.created_at AS created_at,
+				-ts_rank(setweight(to_tsvector('simple', p.title), 'A') || to_tsvector('simple', p.content), to_tsquery('simple', ?)) AS score
+				FROM posts p
+				LEFT JOIN users u ON u.id = p.author_id
+				WHERE to_tsvector('simple', p.title || ' ' || p.content) @@ to_tsquery('simple', ?) AND p.hidden_at IS NULL`
+			args = append(args, query, headlineOptions, query, query)
+			if id, ok := serialID(q.CategoryID); ok {
+				part += ` AND EXISTS (SELECT 1 FROM post_categories pc WHERE pc.post_id = p.id AND pc.category_id = ?)`
+				args = append(args, id)
+			} else if q.CategoryID != "" {
+				continue
+			}
+			if q.Author != "" {
+				part += ` AND u.nickname = ?`
+				args = append(args, q.Author)
+			}
+			parts = append(parts, part)
+
+		case models.SearchTypeComment:
+			part := `
+				SELECT 'comment' AS type, c.id AS id, c.post_id AS post_id, p.title AS title,
+				ts_headline('simple', c.content, to_tsquery('simple', ?), ?) AS snippet,
+				COALESCE(u.nickname, 'Unknown') AS author_name,
+				c.created_at AS created_at,
+				-ts_rank(to_tsvector('simple', c.content), to_tsquery('simple', ?)) AS score
+				FROM comments c
+				JOIN posts p ON p.id = c.post_id
+				LEFT JOIN users u ON u.id = c.author_id
+				WHERE to_tsvector('simple', c.content) @@ to_tsquery('simple', ?) AND c.hidden_at IS NULL AND p.hidden_at IS NULL`
+			args = append(args, query, headlineOptions, query, query)
+			if id, ok := serialID(q.CategoryID); ok {
+				part += ` AND EXISTS (SELECT 1 FROM post_categories pc WHERE pc.post_id = c.post_id AND pc.category_id = ?)`
+				args = append(args, id)
+			} else if q.CategoryID != "" {
+				continue
+			}
+			if q.Author != "" {
+				part += ` AND u.nickname = ?`
+				args = append(args, q.Author)
+			}
+			parts = append(parts, part)
+
+		case models.SearchTypeUser:
+			// Users have no category, so a category filter excludes them
+			if q.CategoryID != "" {
+				continue
+			}
+			part := `
+				SELECT 'user' AS type, u.id AS id, '' AS post_id, u.nickname AS title,
+				ts_headline('simple', u.nickname, to_tsquery('simple', ?), ?) AS snippet,
+				u.nickname AS author_name,
+				u.created_at AS created_at,
+				-ts_rank(to_tsvector('simple', u.nickname), to_tsquery('simple', ?)) AS score
+				FROM users u
+				WHERE to_tsvector('simple', u.nickname) @@ to_tsquery('simple', ?)`
+			args = append(args, query, headlineOptions, query, query)
+			if q.Author != "" {
+				part += ` AND u.nickname = ?`
+				args = append(args, q.Author)
+			}
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) == 0 {
+		return []models.SearchResult{}, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, rebind(strings.Join(parts, "\nUNION ALL\n")+`
+		ORDER BY score, created_at DESC
+		LIMIT ? OFFSET ?`), append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var res models.SearchResult
+		var score float64
+		if err := rows.Scan(
+			&res.Type,
+			&res.ID,
+			&res.PostID,
+			&res.Title,
+			&res.Snippet,
+			&res.AuthorName,
+			&res.CreatedAt,
+			&score,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"real-time-forum/models"
+	"real-time-forum/utils"
+	"time"
+)
+
+type PostgresSessionRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresSessionRepository(db *sql.DB) *PostgresSessionRepository {
+	return &PostgresSessionRepository{db: db}
+}
+
+func (r *PostgresSessionRepository) CreateSession(ctx context.Context, session models.Session) error {
+	_, err := r.db.ExecContext(ctx, rebind(`
+		INSERT INTO sessions (session_id, user_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			session_id = excluded.session_id,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at`),
+		session.ID, session.UserID, session.CreatedAt, session.ExpiresAt)
+	return err
+}
+
+func (r *PostgresSessionRepository) CheckSession(ctx context.Context, sessionID string) error {
+	var id string
+	err := r.db.QueryRowContext(ctx, rebind(`SELECT session_id FROM sessions WHERE session_id = ?`), sessionID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("session not found")
+	}
+	return err
+}
+
+func (r *PostgresSessionRepository) CleanupExpiredSessions(ctx context.Context) error {
+	res, err := r.db.ExecContext(ctx, rebind(`DELETE FROM sessions WHERE expires_at < ?`), time.Now())
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	utils.Logger(ctx).Info("Deleted expired sessions", "count", n)
+	return nil
+}
+
+// DeleteUserSessions removes every session of the user. Unlike DeleteSession
+// it is not an error when there is none.
+func (r *PostgresSessionRepository) DeleteUserSessions(ctx context.Context, userID string) (int64, error) {
+	res, err := r.db.ExecContext(ctx, rebind(`DELETE FROM sessions WHERE user_id = ?`), userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *PostgresSessionRepository) DeleteSession(ctx context.Context, userID string) error {
+	res, err := r.db.ExecContext(ctx, rebind(`DELETE FROM sessions WHERE user_id = ?`), userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("no session found to delete")
+	}
+	return nil
+}
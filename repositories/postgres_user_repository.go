@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"real-time-forum/models"
+)
+
+type PostgresUserRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresUserRepository(db *sql.DB) *PostgresUserRepository {
+	return &PostgresUserRepository{db: db}
+}
+
+func (r *PostgresUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	_, err := r.db.ExecContext(ctx, rebind(`
+		INSERT INTO users (id, nickname, age, gender, first_name, last_name, email, password)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		user.ID, user.Nickname, user.Age, user.Gender, user.FirstName, user.LastName, user.Email, user.Password)
+	return postgresDuplicate(err)
+}
+
+func (r *PostgresUserRepository) GetUserByEmailorName(ctx context.Context, email, name string) (*models.User, error) {
+	var user models.User
+	err := r.db.QueryRowContext(ctx, rebind(`
+		SELECT id, nickname, email, password, role FROM users WHERE nickname = ? OR email = ?`),
+		name, email).Scan(&user.ID, &user.Nickname, &user.Email, &user.Password, &user.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *PostgresUserRepository) GetUserBySessionID(ctx context.Context, sessionID string) (*models.User, error) {
+	var user models.User
+	err := r.db.QueryRowContext(ctx, rebind(`
+		SELECT u.id, u.nickname, u.email, u.role
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.session_id = ?`), sessionID).Scan(&user.ID, &user.Nickname, &user.Email, &user.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *PostgresUserRepository) SetUserRole(ctx context.Context, userID, role string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the admins so two demotions cannot each see the other as the
+	// remaining admin
+	rows, err := tx.QueryContext(ctx, rebind(`SELECT id FROM users WHERE role = ? FOR UPDATE`), models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	admins := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		admins[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if admins[userID] && role != models.RoleAdmin && len(admins) <= 1 {
+		return ErrLastAdmin
+	}
+
+	res, err := tx.ExecContext(ctx, rebind(`UPDATE users SET role = ? WHERE id = ?`), role, userID)
+	if err != nil {
+		return err
+	}
+	if err := expectOneRow(res); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresUserRepository) CountUsersWithRole(ctx context.Context, role string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, rebind(`SELECT COUNT(*) FROM users WHERE role = ?`), role).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresUserRepository) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT nickname FROM users`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.Nickname); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
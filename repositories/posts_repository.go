@@ -4,20 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"real-time-forum/database/sqlite"
 	"real-time-forum/models"
+	"real-time-forum/utils"
 	"strings"
+	"time"
 )
 
-type PostRepository struct {
-	db *sql.DB
+type SQLitePostRepository struct {
+	db   *sql.DB
+	read *sql.DB
 }
 
-func NewPostRepository(db *sql.DB) *PostRepository {
-	return &PostRepository{db: db}
+func NewSQLitePostRepository(db *sqlite.DB) *SQLitePostRepository {
+	return &SQLitePostRepository{db: db.Write, read: db.Read}
 }
 
-func (r *PostRepository) CreatePost(ctx context.Context, user *models.User, post *models.Post, categoryIDs []string) error {
+func (r *SQLitePostRepository) CreatePost(ctx context.Context, user *models.User, post *models.Post, categoryIDs []string) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("CreatePost: could not begin transaction: %w", err)
@@ -38,12 +41,12 @@ func (r *PostRepository) CreatePost(ctx context.Context, user *models.User, post
 	}()
 
 	insertPostQuery := `
-		INSERT INTO posts (id, author_id, title, content, created_at) 
-		VALUES (?, ?, ?, ?, ?)`
+		INSERT INTO posts (id, author_id, title, content, created_at, image) 
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''))`
 
-	log.Printf("CreatePost: inserting post with ID='%s', AuthorID='%s', Title='%s'", post.ID, user.ID, post.Title)
+	utils.Logger(ctx).Debug("CreatePost: inserting post", "post_id", post.ID, "author_id", user.ID)
 
-	_, err = tx.ExecContext(ctx, insertPostQuery, post.ID, user.ID, post.Title, post.Content, post.CreatedAt)
+	_, err = tx.ExecContext(ctx, insertPostQuery, post.ID, user.ID, post.Title, post.Content, post.CreatedAt, post.Image)
 	if err != nil {
 		return fmt.Errorf("CreatePost: inserting post: %w", err)
 	}
@@ -72,19 +75,21 @@ func (r *PostRepository) CreatePost(ctx context.Context, user *models.User, post
 	return nil
 }
 
-func (r *PostRepository) GetAllPosts(ctx context.Context) ([]models.Post, error) {
-	rows, err := r.db.QueryContext(ctx, `
+func (r *SQLitePostRepository) GetAllPosts(ctx context.Context) ([]models.Post, error) {
+	rows, err := r.read.QueryContext(ctx, `
 		SELECT
 		p.id,
 		COALESCE(u.nickname, 'Unknown') as author_name,
 		p.title,
 		p.content,
 		p.created_at,
+		COALESCE(p.image, ''),
 		GROUP_CONCAT(c.name, ',')
 		FROM posts p
 		LEFT JOIN users u ON p.author_id = u.id
 		LEFT JOIN post_categories pc ON p.id = pc.post_id
 		LEFT JOIN categories c ON pc.category_id = c.id
+		WHERE p.hidden_at IS NULL
 		GROUP BY p.id
 		ORDER BY p.created_at DESC
 	`)
@@ -105,6 +110,7 @@ func (r *PostRepository) GetAllPosts(ctx context.Context) ([]models.Post, error)
 			&pv.Title,
 			&pv.Content,
 			&pv.CreatedAt,
+			&pv.Image,
 			&cats,
 		); err != nil {
 			return nil, err
@@ -122,8 +128,8 @@ func (r *PostRepository) GetAllPosts(ctx context.Context) ([]models.Post, error)
 	return posts, nil
 }
 
-func (r *PostRepository) GetPostByID(ctx context.Context, postID string) (*models.Post, error) {
-	row := r.db.QueryRowContext(ctx, `
+func (r *SQLitePostRepository) GetPostByID(ctx context.Context, postID string) (*models.Post, error) {
+	row := r.read.QueryRowContext(ctx, `
 		SELECT
 		p.id,
 		p.author_id,
@@ -131,12 +137,13 @@ func (r *PostRepository) GetPostByID(ctx context.Context, postID string) (*model
 		p.title,
 		p.content,
 		p.created_at,
+		COALESCE(p.image, ''),
 		GROUP_CONCAT(c.name, ',')
 		FROM posts p
 		LEFT JOIN users u ON p.author_id = u.id
 		LEFT JOIN post_categories pc ON p.id = pc.post_id
 		LEFT JOIN categories c     ON pc.category_id = c.id
-		WHERE p.id = ?
+		WHERE p.id = ? AND p.hidden_at IS NULL
 		GROUP BY p.id
 	`, postID)
 
@@ -150,11 +157,12 @@ func (r *PostRepository) GetPostByID(ctx context.Context, postID string) (*model
 		&pv.Title,
 		&pv.Content,
 		&pv.CreatedAt,
+		&pv.Image,
 		&cats,
 	); err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("GetPostByID: post with ID %s not found", postID)
-			return nil, fmt.Errorf("post with ID %s not found", postID)
+			utils.Logger(ctx).Debug("GetPostByID: post not found", "post_id", postID)
+			return nil, fmt.Errorf("post with ID %s not found: %w", postID, err)
 		}
 		return nil, err
 	}
@@ -164,20 +172,21 @@ func (r *PostRepository) GetPostByID(ctx context.Context, postID string) (*model
 	return &pv, nil
 }
 
-func (r *PostRepository) GetPostsByCategory(ctx context.Context, categoryID string) ([]models.Post, error) {
-	rows, err := r.db.QueryContext(ctx, `
+func (r *SQLitePostRepository) GetPostsByCategory(ctx context.Context, categoryID string) ([]models.Post, error) {
+	rows, err := r.read.QueryContext(ctx, `
 		SELECT
 		p.id,
 		COALESCE(u.nickname, 'Unknown') as author_name,
 		p.title,
 		p.content,
 		p.created_at,
+		COALESCE(p.image, ''),
 		GROUP_CONCAT(c.name, ',')
 		FROM posts p
 		LEFT JOIN users u ON p.author_id = u.id
 		JOIN post_categories pc ON p.id = pc.post_id
 		LEFT JOIN categories c ON pc.category_id = c.id
-		WHERE pc.category_id = ?
+		WHERE pc.category_id = ? AND p.hidden_at IS NULL
 		GROUP BY p.id
 		ORDER BY p.created_at DESC;
 	`, categoryID)
@@ -197,6 +206,7 @@ func (r *PostRepository) GetPostsByCategory(ctx context.Context, categoryID stri
 			&pv.Title,
 			&pv.Content,
 			&pv.CreatedAt,
+			&pv.Image,
 			&cats,
 		); err != nil {
 			return nil, err
@@ -218,20 +228,27 @@ func (r *PostRepository) GetPostsByCategory(ctx context.Context, categoryID stri
 	return posts, nil
 }
 
-func (r *PostRepository) GetUserPosts(ctx context.Context, userID string) ([]models.Post, error) {
-	rows, err := r.db.QueryContext(ctx, `
+// GetSubscribedPosts returns posts filed under any category the user is
+// subscribed to
+func (r *SQLitePostRepository) GetSubscribedPosts(ctx context.Context, userID string) ([]models.Post, error) {
+	rows, err := r.read.QueryContext(ctx, `
 		SELECT
 		p.id,
 		COALESCE(u.nickname, 'Unknown') as author_name,
 		p.title,
 		p.content,
 		p.created_at,
+		COALESCE(p.image, ''),
 		GROUP_CONCAT(c.name, ',')
 		FROM posts p
 		LEFT JOIN users u ON p.author_id = u.id
 		JOIN post_categories pc ON p.id = pc.post_id
 		LEFT JOIN categories c ON pc.category_id = c.id
-		WHERE p.author_id = ?
+		WHERE EXISTS (
+			SELECT 1 FROM post_categories spc
+			JOIN category_subscriptions cs ON cs.category_id = spc.category_id
+			WHERE spc.post_id = p.id AND cs.user_id = ?
+		) AND p.hidden_at IS NULL
 		GROUP BY p.id
 		ORDER BY p.created_at DESC;
 	`, userID)
@@ -251,6 +268,7 @@ func (r *PostRepository) GetUserPosts(ctx context.Context, userID string) ([]mod
 			&pv.Title,
 			&pv.Content,
 			&pv.CreatedAt,
+			&pv.Image,
 			&cats,
 		); err != nil {
 			return nil, err
@@ -269,15 +287,108 @@ func (r *PostRepository) GetUserPosts(ctx context.Context, userID string) ([]mod
 	return posts, nil
 }
 
-func (r *PostRepository) ListByAuthor(ctx context.Context, authorID string, limit, offset int) ([]models.Post, error) {
+func (r *SQLitePostRepository) GetUserPosts(ctx context.Context, userID string) ([]models.Post, error) {
+	rows, err := r.read.QueryContext(ctx, `
+		SELECT
+		p.id,
+		COALESCE(u.nickname, 'Unknown') as author_name,
+		p.title,
+		p.content,
+		p.created_at,
+		COALESCE(p.image, ''),
+		GROUP_CONCAT(c.name, ',')
+		FROM posts p
+		LEFT JOIN users u ON p.author_id = u.id
+		JOIN post_categories pc ON p.id = pc.post_id
+		LEFT JOIN categories c ON pc.category_id = c.id
+		WHERE p.author_id = ? AND p.hidden_at IS NULL
+		GROUP BY p.id
+		ORDER BY p.created_at DESC;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		var pv models.Post
+		var cats sql.NullString
+
+		if err := rows.Scan(
+			&pv.ID,
+			&pv.AuthorName,
+			&pv.Title,
+			&pv.Content,
+			&pv.CreatedAt,
+			&pv.Image,
+			&cats,
+		); err != nil {
+			return nil, err
+		}
+
+		if cats.Valid {
+			pv.Categories = strings.Split(cats.String, ",")
+		} else {
+			pv.Categories = []string{}
+		}
+		posts = append(posts, pv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// SetPostHidden hides a post from every listing or makes it visible again.
+// It returns sql.ErrNoRows when the post does not exist.
+func (r *SQLitePostRepository) SetPostHidden(ctx context.Context, postID string, hidden bool) error {
+	var hiddenAt interface{}
+	if hidden {
+		hiddenAt = time.Now()
+	}
+
+	res, err := r.db.ExecContext(ctx, `UPDATE posts SET hidden_at = ? WHERE id = ?`, hiddenAt, postID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// DeletePost removes a post. Its comments, category links and notifications
+// go with it through ON DELETE CASCADE. It returns sql.ErrNoRows when the
+// post does not exist.
+func (r *SQLitePostRepository) DeletePost(ctx context.Context, postID string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM posts WHERE id = ?`, postID)
+	if err != nil {
+		return fmt.Errorf("DeletePost: deleting post: %w", err)
+	}
+	return expectOneRow(res)
+}
+
+// CountRecentDuplicates counts posts created since the given time that repeat
+// a new post: by the same author with the same title or content, and by other
+// authors with the same content
+func (r *SQLitePostRepository) CountRecentDuplicates(ctx context.Context, authorID, title, content string, since time.Time) (own int, others int, err error) {
+	err = r.read.QueryRowContext(ctx, `
+		SELECT
+		COALESCE(SUM(author_id = ? AND (trim(title, char(32, 9, 10, 13)) = ? OR trim(content, char(32, 9, 10, 13)) = ?)), 0),
+		COALESCE(SUM(author_id != ? AND trim(content, char(32, 9, 10, 13)) = ?), 0)
+		FROM posts
+		WHERE created_at >= ?`,
+		authorID, title, content, authorID, content, since).Scan(&own, &others)
+	return own, others, err
+}
+
+func (r *SQLitePostRepository) ListByAuthor(ctx context.Context, authorID string, limit, offset int) ([]models.Post, error) {
 	const query = `
-		SELECT id, title, created_at, updated_at
+		SELECT id, title, created_at, updated_at, COALESCE(image, '')
         FROM posts
         WHERE author_id = $1
         ORDER BY created_at DESC
         LIMIT $2 OFFSET $3;
     `
-	rows, err := r.db.QueryContext(ctx, query, authorID, limit, offset)
+	rows, err := r.read.QueryContext(ctx, query, authorID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -287,7 +398,7 @@ func (r *PostRepository) ListByAuthor(ctx context.Context, authorID string, limi
 	var results []models.Post
 	for rows.Next() {
 		var p models.Post
-		if err := rows.Scan(&p.ID, &p.Title, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.Title, &p.CreatedAt, &p.UpdatedAt, &p.Image); err != nil {
 			return nil, err
 		}
 		results = append(results, p)
@@ -0,0 +1,210 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"real-time-forum/database/sqlite"
+	"real-time-forum/models"
+	"time"
+)
+
+// The interfaces below are what the services depend on. Each has a SQLite
+// implementation (SQLiteXRepository) and a PostgreSQL one
+// (PostgresXRepository) that behave the same, which the contract tests check.
+// Lookups of a single row return sql.ErrNoRows when nothing matches.
+
+// ErrDuplicate is returned when an insert would break a unique constraint
+var ErrDuplicate = errors.New("duplicate row")
+
+// ErrLastAdmin is returned when a change would leave no admin
+var ErrLastAdmin = errors.New("last admin")
+
+type UserRepository interface {
+	// CreateUser returns ErrDuplicate when the nickname or email is taken
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByEmailorName(ctx context.Context, email, name string) (*models.User, error)
+	GetUserBySessionID(ctx context.Context, sessionID string) (*models.User, error)
+	// SetUserRole returns ErrLastAdmin rather than demote the only admin. The
+	// check and the update are one atomic write.
+	SetUserRole(ctx context.Context, userID, role string) error
+	CountUsersWithRole(ctx context.Context, role string) (int, error)
+	GetAllUsers(ctx context.Context) ([]models.User, error)
+}
+
+type SessionRepository interface {
+	// CreateSession stores the session, replacing any other of the same user
+	CreateSession(ctx context.Context, session models.Session) error
+	CheckSession(ctx context.Context, sessionID string) error
+	CleanupExpiredSessions(ctx context.Context) error
+	DeleteUserSessions(ctx context.Context, userID string) (int64, error)
+	DeleteSession(ctx context.Context, userID string) error
+}
+
+type PostRepository interface {
+	CreatePost(ctx context.Context, user *models.User, post *models.Post, categoryIDs []string) error
+	GetAllPosts(ctx context.Context) ([]models.Post, error)
+	GetPostByID(ctx context.Context, postID string) (*models.Post, error)
+	GetPostsByCategory(ctx context.Context, categoryID string) ([]models.Post, error)
+	GetSubscribedPosts(ctx context.Context, userID string) ([]models.Post, error)
+	GetUserPosts(ctx context.Context, userID string) ([]models.Post, error)
+	SetPostHidden(ctx context.Context, postID string, hidden bool) error
+	DeletePost(ctx context.Context, postID string) error
+	CountRecentDuplicates(ctx context.Context, authorID, title, content string, since time.Time) (own int, others int, err error)
+}
+
+type CommentRepository interface {
+	CreateComment(ctx context.Context, comment *models.Comment) error
+	GetPostComments(ctx context.Context, postID string) ([]models.Comment, error)
+	SetCommentHidden(ctx context.Context, commentID string, hidden bool) error
+	DeleteComment(ctx context.Context, commentID string) error
+}
+
+type CategoriesRepository interface {
+	GetAllCategories(ctx context.Context) ([]models.Category, error)
+	GetCategoriesForUser(ctx context.Context, userID string) ([]models.Category, error)
+	GetAllCategoriesWithArchived(ctx context.Context) ([]models.Category, error)
+	GetSubscribedCategories(ctx context.Context, userID string) ([]models.Category, error)
+	GetCategoryByID(ctx context.Context, categoryID, userID string) (*models.Category, error)
+	Subscribe(ctx context.Context, userID, categoryID string) error
+	Unsubscribe(ctx context.Context, userID, categoryID string) error
+	CategoryNameExists(ctx context.Context, name, excludeID string) (bool, error)
+	CountActiveCategories(ctx context.Context, categoryIDs []string) (int, error)
+	CreateCategory(ctx context.Context, category *models.Category) error
+	UpdateCategory(ctx context.Context, category *models.Category) error
+	SetCategoryArchived(ctx context.Context, categoryID string, archived bool) error
+	ReorderCategories(ctx context.Context, categoryIDs []string) error
+}
+
+type MessageRepository interface {
+	SaveMessage(ctx context.Context, message *models.Message) error
+	GetMessagesWithPagination(ctx context.Context, user1, user2 string, limit, offset int) ([]models.Message, error)
+	SetMessageHidden(ctx context.Context, messageID string, hidden bool) error
+	DeleteMessage(ctx context.Context, messageID string) error
+	// GetLastMessageTimestamps maps each chat partner to the time of the
+	// latest message, formatted so that later times sort after earlier ones
+	GetLastMessageTimestamps(ctx context.Context, currentUser string) (map[string]string, error)
+}
+
+type SearchRepository interface {
+	EnsureIndex(ctx context.Context) error
+	// Search takes an FTS5 match expression of quoted prefix terms, as built
+	// by the search service
+	Search(ctx context.Context, match string, q models.SearchQuery) ([]models.SearchResult, error)
+}
+
+type ReportRepository interface {
+	CreateReport(ctx context.Context, report *models.Report) error
+	OpenReportExists(ctx context.Context, contentType, contentID, reporterID string) (bool, error)
+	GetReportByID(ctx context.Context, reportID string) (*models.Report, error)
+	ListReports(ctx context.Context, filter models.ReportFilter) ([]models.Report, error)
+	ClaimReport(ctx context.Context, reportID, moderatorID string) error
+	ResolveReport(ctx context.Context, report *models.Report, moderatorID, action, note string, allForContent bool) error
+	GetContentAuthor(ctx context.Context, contentType, contentID string) (*models.ReportedContent, error)
+	IsMessageParticipant(ctx context.Context, messageID, nickname string) (bool, error)
+	CreateWarning(ctx context.Context, warning *models.Warning) error
+}
+
+type SanctionRepository interface {
+	CreateSanction(ctx context.Context, sanction *models.Sanction) error
+	GetSanctionByID(ctx context.Context, sanctionID string) (*models.Sanction, error)
+	GetActiveSanction(ctx context.Context, userID string, types ...string) (*models.Sanction, error)
+	GetActiveSanctionByNickname(ctx context.Context, nickname string, types ...string) (*models.Sanction, error)
+	ListSanctions(ctx context.Context, filter models.SanctionFilter) ([]models.Sanction, error)
+	RevokeSanction(ctx context.Context, sanctionID string) error
+}
+
+type BlockRepository interface {
+	Block(ctx context.Context, blockerID, blockedID string) error
+	Unblock(ctx context.Context, blockerID, blockedID string) error
+	GetBlockedUsers(ctx context.Context, userID string) ([]models.BlockedUser, error)
+	IsBlockedBetween(ctx context.Context, nickname1, nickname2 string) (bool, error)
+	GetBlockedNicknames(ctx context.Context, nickname string) (map[string]bool, error)
+}
+
+type AuditRepository interface {
+	InsertEvent(ctx context.Context, event *models.AuditEvent) error
+	ListEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+type NotificationRepository interface {
+	CreateNotifications(ctx context.Context, notifications []models.Notification) error
+	ListNotifications(ctx context.Context, userID string, unreadOnly bool, limit, offset int) ([]models.Notification, error)
+	CountUnread(ctx context.Context, userID string) (int, error)
+	MarkRead(ctx context.Context, userID string, ids []string) (int64, error)
+	GetPostAuthorRecipient(ctx context.Context, postID, actorID string) (*models.User, error)
+	GetCommenterRecipients(ctx context.Context, postID, actorID string) ([]models.User, error)
+	GetSubscriberRecipients(ctx context.Context, categoryIDs []string, actorID string) ([]models.User, error)
+}
+
+type MentionRepository interface {
+	ResolveNicknames(ctx context.Context, authorID string, nicknames []string) ([]models.Mention, error)
+	CreateMentions(ctx context.Context, contentType, contentID, authorID string, mentions []models.Mention) error
+	GetMentions(ctx context.Context, contentType string, contentIDs []string) (map[string][]models.Mention, error)
+}
+
+type PushRepository interface {
+	SaveSubscription(ctx context.Context, sub *models.PushSubscription) error
+	DeleteSubscription(ctx context.Context, userID, endpoint string) (int64, error)
+	DeleteEndpoint(ctx context.Context, endpoint string) error
+	GetSubscriptionsByNickname(ctx context.Context, nickname string) ([]models.PushSubscription, error)
+	GetVAPIDKeys(ctx context.Context) (*models.VAPIDKeys, error)
+	SaveVAPIDKeys(ctx context.Context, keys *models.VAPIDKeys) (*models.VAPIDKeys, error)
+}
+
+// Repositories is one implementation of every repository, all on the same
+// database
+type Repositories struct {
+	Users         UserRepository
+	Sessions      SessionRepository
+	Posts         PostRepository
+	Comments      CommentRepository
+	Categories    CategoriesRepository
+	Messages      MessageRepository
+	Search        SearchRepository
+	Reports       ReportRepository
+	Sanctions     SanctionRepository
+	Blocks        BlockRepository
+	Audit         AuditRepository
+	Notifications NotificationRepository
+	Mentions      MentionRepository
+	Push          PushRepository
+}
+
+func NewSQLiteRepositories(db *sqlite.DB) *Repositories {
+	return &Repositories{
+		Users:         NewSQLiteUserRepository(db),
+		Sessions:      NewSQLiteSessionRepository(db),
+		Posts:         NewSQLitePostRepository(db),
+		Comments:      NewSQLiteCommentRepository(db),
+		Categories:    NewSQLiteCategoriesRepository(db),
+		Messages:      NewSQLiteMessageRepository(db),
+		Search:        NewSQLiteSearchRepository(db),
+		Reports:       NewSQLiteReportRepository(db),
+		Sanctions:     NewSQLiteSanctionRepository(db),
+		Blocks:        NewSQLiteBlockRepository(db),
+		Audit:         NewSQLiteAuditRepository(db),
+		Notifications: NewSQLiteNotificationRepository(db),
+		Mentions:      NewSQLiteMentionRepository(db),
+		Push:          NewSQLitePushRepository(db),
+	}
+}
+
+func NewPostgresRepositories(db *sql.DB) *Repositories {
+	return &Repositories{
+		Users:         NewPostgresUserRepository(db),
+		Sessions:      NewPostgresSessionRepository(db),
+		Posts:         NewPostgresPostRepository(db),
+		Comments:      NewPostgresCommentRepository(db),
+		Categories:    NewPostgresCategoriesRepository(db),
+		Messages:      NewPostgresMessageRepository(db),
+		Search:        NewPostgresSearchRepository(db),
+		Reports:       NewPostgresReportRepository(db),
+		Sanctions:     NewPostgresSanctionRepository(db),
+		Blocks:        NewPostgresBlockRepository(db),
+		Audit:         NewPostgresAuditRepository(db),
+		Notifications: NewPostgresNotificationRepository(db),
+		Mentions:      NewPostgresMentionRepository(db),
+		Push:          NewPostgresPushRepository(db),
+	}
+}
@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"real-time-forum/database/sqlite"
+	"real-time-forum/models"
+	"strings"
+	"time"
+)
+
+type SQLiteSanctionRepository struct {
+	db   *sql.DB
+	read *sql.DB
+}
+
+func NewSQLiteSanctionRepository(db *sqlite.DB) *SQLiteSanctionRepository {
+	return &SQLiteSanctionRepository{db: db.Write, read: db.Read}
+}
+
+// sanctionSelect loads sanctions together with the nicknames of the user and
+// the moderator who issued them
+const sanctionSelect = `
+	SELECT
+	s.id,
+	s.user_id,
+	COALESCE(u.nickname, 'Unknown'),
+	s.type,
+	s.reason,
+	s.moderator_id,
+	COALESCE(m.nickname, 'Unknown'),
+	s.created_at,
+	s.expires_at,
+	s.revoked_at
+	FROM user_sanctions s
+	LEFT JOIN users u ON u.id = s.user_id
+	LEFT JOIN users m ON m.id = s.moderator_id`
+
+// sanctionActive matches sanctions that are neither revoked nor expired. The
+// single placeholder is the current time in UTC.
+const sanctionActive = `s.revoked_at IS NULL AND (s.expires_at IS NULL OR s.expires_at > ?)`
+
+func (r *SQLiteSanctionRepository) CreateSanction(ctx context.Context, sanction *models.Sanction) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_sanctions (id, user_id, type, reason, moderator_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sanction.ID, sanction.UserID, sanction.Type, sanction.Reason, sanction.ModeratorID,
+		sanction.CreatedAt, sanction.ExpiresAt)
+	return err
+}
+
+func (r *SQLiteSanctionRepository) GetSanctionByID(ctx context.Context, sanctionID string) (*models.Sanction, error) {
+	row := r.read.QueryRowContext(ctx, sanctionSelect+`
+		WHERE s.id = ?`, sanctionID)
+	return scanSanction(row)
+}
+
+// GetActiveSanction returns the longest lasting active sanction of one of the
+// given types for the user. It returns sql.ErrNoRows when there is none.
+func (r *SQLiteSanctionRepository) GetActiveSanction(ctx context.Context, userID string, types ...string) (*models.Sanction, error) {
+	return r.getActiveSanction(ctx, "s.user_id", userID, types)
+}
+
+// GetActiveSanctionByNickname is GetActiveSanction for callers that only know
+// the nickname, such as the chat
+func (r *SQLiteSanctionRepository) GetActiveSanctionByNickname(ctx context.Context, nickname string, types ...string) (*models.Sanction, error) {
+	return r.getActiveSanction(ctx, "u.nickname", nickname, types)
+}
+
+func (r *SQLiteSanctionRepository) getActiveSanction(ctx context.Context, column, value string, types []string) (*models.Sanction, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(types)), ",")
+	args := []interface{}{value, time.Now().UTC()}
+	for _, t := range types {
+		args = append(args, t)
+	}
+
+	row := r.read.QueryRowContext(ctx, sanctionSelect+`
+		WHERE `+column+` = ? AND `+sanctionActive+` AND s.type IN (`+placeholders+`)
+		ORDER BY s.expires_at IS NULL DESC, s.expires_at DESC
+		LIMIT 1`, args...)
+	return scanSanction(row)
+}
+
+// ListSanctions returns sanctions newest first, optionally only those of one
+// user or only the active ones
+func (r *SQLiteSanctionRepository) ListSanctions(ctx context.Context, filter models.SanctionFilter) ([]models.Sanction, error) {
+	query := sanctionSelect + `
+		WHERE 1 = 1`
+	var args []interface{}
+
+	if filter.UserID != "" {
+		query += ` AND s.user_id = ?`
+		args = append(args, filter.UserID)
+	}
+	if filter.ActiveOnly {
+		query += ` AND ` + sanctionActive
+		args = append(args, time.Now().UTC())
+	}
+
+	query += `
+		ORDER BY s.created_at DESC
+		LIMIT ? OFFSET ?`
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := r.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sanctions []models.Sanction
+	for rows.Next() {
+		sanction, err := scanSanction(rows)
+		if err != nil {
+			return nil, err
+		}
+		sanctions = append(sanctions, *sanction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sanctions, nil
+}
+
+// RevokeSanction lifts a sanction before it expires. It returns sql.ErrNoRows
+// when the sanction does not exist or was already revoked.
+func (r *SQLiteSanctionRepository) RevokeSanction(ctx context.Context, sanctionID string) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE user_sanctions SET revoked_at = ?
+		WHERE id = ? AND revoked_at IS NULL`, time.Now().UTC(), sanctionID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// scanSanction reads one row produced by sanctionSelect
+func scanSanction(row interface{ Scan(...interface{}) error }) (*models.Sanction, error) {
+	var sanction models.Sanction
+	var expiresAt, revokedAt sql.NullTime
+	if err := row.Scan(
+		&sanction.ID,
+		&sanction.UserID,
+		&sanction.UserName,
+		&sanction.Type,
+		&sanction.Reason,
+		&sanction.ModeratorID,
+		&sanction.ModeratorName,
+		&sanction.CreatedAt,
+		&expiresAt,
+		&revokedAt,
+	); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		sanction.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		sanction.RevokedAt = &revokedAt.Time
+	}
+	return &sanction, nil
+}
@@ -0,0 +1,420 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"real-time-forum/database/sqlite"
+	"real-time-forum/models"
+	"regexp"
+	"strings"
+)
+
+// Markers wrapped around matched terms in snippets. They are control
+// characters so they can never clash with user content and are swapped for
+// real markup once the snippet has been escaped.
+const (
+	SnippetMatchStart = "\x02"
+	SnippetMatchEnd   = "\x03"
+)
+
+// searchIndexDDL creates the FTS5 index tables and the triggers that keep them
+// in sync with posts, comments and users. FTS5 is only compiled into
+// go-sqlite3 when building with -tags sqlite_fts5.
+var searchIndexDDL = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(post_id UNINDEXED, title, content)`,
+	`CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(comment_id UNINDEXED, content)`,
+	`CREATE VIRTUAL TABLE IF NOT EXISTS users_fts USING fts5(user_id UNINDEXED, nickname)`,
+
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+		INSERT INTO posts_fts (post_id, title, content) VALUES (new.id, new.title, new.content);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+		DELETE FROM posts_fts WHERE post_id = old.id;
+		INSERT INTO posts_fts (post_id, title, content) VALUES (new.id, new.title, new.content);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+		DELETE FROM posts_fts WHERE post_id = old.id;
+	END`,
+
+	`CREATE TRIGGER IF NOT EXISTS comments_fts_insert AFTER INSERT ON comments BEGIN
+		INSERT INTO comments_fts (comment_id, content) VALUES (new.id, new.content);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS comments_fts_update AFTER UPDATE OF content ON comments BEGIN
+		DELETE FROM comments_fts WHERE comment_id = old.id;
+		INSERT INTO comments_fts (comment_id, content) VALUES (new.id, new.content);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS comments_fts_delete AFTER DELETE ON comments BEGIN
+		DELETE FROM comments_fts WHERE comment_id = old.id;
+	END`,
+
+	`CREATE TRIGGER IF NOT EXISTS users_fts_insert AFTER INSERT ON users BEGIN
+		INSERT INTO users_fts (user_id, nickname) VALUES (new.id, new.nickname);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS users_fts_update AFTER UPDATE OF nickname ON users BEGIN
+		DELETE FROM users_fts WHERE user_id = old.id;
+		INSERT INTO users_fts (user_id, nickname) VALUES (new.id, new.nickname);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS users_fts_delete AFTER DELETE ON users BEGIN
+		DELETE FROM users_fts WHERE user_id = old.id;
+	END`,
+}
+
+// searchIndexRebuild repopulates the index from the base tables
+var searchIndexRebuild = []string{
+	`DELETE FROM posts_fts`,
+	`INSERT INTO posts_fts (post_id, title, content) SELECT id, title, content FROM posts`,
+	`DELETE FROM comments_fts`,
+	`INSERT INTO comments_fts (comment_id, content) SELECT id, content FROM comments`,
+	`DELETE FROM users_fts`,
+	`INSERT INTO users_fts (user_id, nickname) SELECT id, nickname FROM users`,
+}
+
+type SQLiteSearchRepository struct {
+	db   *sql.DB
+	read *sql.DB
+	// fts is set once EnsureIndex has the FTS5 index in place; without it
+	// Search falls back to substring matching on the base tables
+	fts bool
+}
+
+func NewSQLiteSearchRepository(db *sqlite.DB) *SQLiteSearchRepository {
+	return &SQLiteSearchRepository{db: db.Write, read: db.Read}
+}
+
+// EnsureIndex creates the search index if it is missing. The index is rebuilt
+// from the base tables only when it was just created or some of its sync
+// triggers were missing, i.e. writes may have been made while it was
+// disabled. When the SQLite build has no FTS5 support the triggers are
+// dropped and Search matches substrings instead.
+func (r *SQLiteSearchRepository) EnsureIndex(ctx context.Context) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("EnsureIndex: could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var existing int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE (type = 'table' AND name IN ('posts_fts', 'comments_fts', 'users_fts'))
+		OR (type = 'trigger' AND name LIKE '%\_fts\_%' ESCAPE '\')`).Scan(&existing)
+	if err != nil {
+		return fmt.Errorf("EnsureIndex: checking index: %w", err)
+	}
+
+	for _, stmt := range searchIndexDDL {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			if strings.Contains(err.Error(), "no such module: fts5") {
+				tx.Rollback()
+				slog.Warn("EnsureIndex: FTS5 unavailable, falling back to substring search (build with -tags sqlite_fts5)")
+				return r.dropIndexTriggers(ctx)
+			}
+			return fmt.Errorf("EnsureIndex: creating index: %w", err)
+		}
+	}
+
+	// Three tables and nine triggers make a complete index
+	if existing < 12 {
+		for _, stmt := range searchIndexRebuild {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("EnsureIndex: rebuilding index: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("EnsureIndex: could not commit transaction: %w", err)
+	}
+	r.fts = true
+	return nil
+}
+
+// dropIndexTriggers removes the sync triggers. A database indexed by an FTS5
+// build would otherwise reject every write to posts, comments and users when
+// opened by a build without FTS5.
+func (r *SQLiteSearchRepository) dropIndexTriggers(ctx context.Context) error {
+	for _, table := range []string{"posts", "comments", "users"} {
+		for _, op := range []string{"insert", "update", "delete"} {
+			if _, err := r.db.ExecContext(ctx, fmt.Sprintf("DROP TRIGGER IF EXISTS %s_fts_%s", table, op)); err != nil {
+				return fmt.Errorf("EnsureIndex: dropping index triggers: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Search runs an FTS5 match expression against the requested result types and
+// returns one page of results ordered by relevance, newest first on ties.
+func (r *SQLiteSearchRepository) Search(ctx context.Context, match string, q models.SearchQuery) ([]models.SearchResult, error) {
+	if !r.fts {
+		return r.searchSubstrings(ctx, match, q)
+	}
+
+	var parts []string
+	var args []interface{}
+
+	for _, t := range q.Types {
+		switch t {
+		case models.SearchTypePost:
+			part := `
+				SELECT 'post' AS type, p.id AS id, p.id AS post_id, p.title AS title,
+				snippet(posts_fts, -1, ?, ?, '…', 16) AS snippet,
+				COALESCE(u.nickname, 'Unknown') AS author_name,
+				p.created_at AS created_at,
+				bm25(posts_fts, 0.0, 5.0, 1.0) AS score
+				FROM posts_fts
+				JOIN posts p ON p.id = posts_fts.post_id
+				LEFT JOIN users u ON u.id = p.author_id
+				WHERE posts_fts MATCH ? AND p.hidden_at IS NULL`
+			args = append(args, SnippetMatchStart, SnippetMatchEnd, match)
+			if q.CategoryID != "" {
+				part += ` AND EXISTS (SELECT 1 FROM post_categories pc WHERE pc.post_id = p.id AND pc.category_id = ?)`
+				args = append(args, q.CategoryID)
+			}
+			if q.Author != "" {
+				part += ` AND u.nickname = ?`
+				args = append(args, q.Author)
+			}
+			parts = append(parts, part)
+
+		case models.SearchTypeComment:
+			part := `
+				SELECT 'comment' AS type, c.id AS id, c.post_id AS post_id, p.title AS title,
+				snippet(comments_fts, 1, ?, ?, '…', 16) AS snippet,
+				COALESCE(u.nickname, 'Unknown') AS author_name,
+				c.created_at AS created_at,
+				bm25(comments_fts) AS score
+				FROM comments_fts
+				JOIN comments c ON c.id = comments_fts.comment_id
+				JOIN posts p ON p.id = c.post_id
+				LEFT JOIN users u ON u.id = c.author_id
+				WHERE comments_fts MATCH ? AND c.hidden_at IS NULL AND p.hidden_at IS NULL`
+			args = append(args, SnippetMatchStart, SnippetMatchEnd, match)
+			if q.CategoryID != "" {
+				part += ` AND EXISTS (SELECT 1 FROM post_categories pc WHERE pc.post_id = c.post_id AND pc.category_id = ?)`
+				args = append(args, q.CategoryID)
+			}
+			if q.Author != "" {
+				part += ` AND u.nickname = ?`
+				args = append(args, q.Author)
+			}
+			parts = append(parts, part)
+
+		case models.SearchTypeUser:
+			// Users have no category, so a category filter excludes them
+			if q.CategoryID != "" {
+				continue
+			}
+			part := `
+				SELECT 'user' AS type, u.id AS id, '' AS post_id, u.nickname AS title,
+				snippet(users_fts, 1, ?, ?, '…', 16) AS snippet,
+				u.nickname AS author_name,
+				u.created_at AS created_at,
+				bm25(users_fts) AS score
+				FROM users_fts
+				JOIN users u ON u.id = users_fts.user_id
+				WHERE users_fts MATCH ?`
+			args = append(args, SnippetMatchStart, SnippetMatchEnd, match)
+			if q.Author != "" {
+				part += ` AND u.nickname = ?`
+				args = append(args, q.Author)
+			}
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) == 0 {
+		return []models.SearchResult{}, nil
+	}
+
+	return r.runSearch(ctx, parts, args, q, nil)
+}
+
+// searchSubstrings is the fallback for builds without FTS5: every term must
+// appear somewhere in the searched columns, and the newest results come
+// first. Snippets are cut around the first match in Go.
+func (r *SQLiteSearchRepository) searchSubstrings(ctx context.Context, match string, q models.SearchQuery) ([]models.SearchResult, error) {
+	var terms []string
+	for _, m := range matchTerm.FindAllStringSubmatch(match, -1) {
+		terms = append(terms, m[1])
+	}
+	if len(terms) == 0 {
+		return []models.SearchResult{}, nil
+	}
+
+	// like requires every term in one of the columns
+	like := func(args []interface{}, columns ...string) (string, []interface{}) {
+		var clauses []string
+		for _, term := range terms {
+			var alts []string
+			for _, column := range columns {
+				alts = append(alts, column+` LIKE ? ESCAPE '\'`)
+				args = append(args, "%"+escapeLike(term)+"%")
+			}
+			clauses = append(clauses, "("+strings.Join(alts, " OR ")+")")
+		}
+		return strings.Join(clauses, " AND "), args
+	}
+
+	var parts []string
+	var args []interface{}
+	var where string
+
+	for _, t := range q.Types {
+		switch t {
+		case models.SearchTypePost:
+			where, args = like(args, "p.title", "p.content")
+			part := `
+				SELECT 'post' AS type, p.id AS id, p.id AS post_id, p.title AS title,
+				p.title || ' ' || p.content AS snippet,
+				COALESCE(u.nickname, 'Unknown') AS author_name,
+				p.created_at AS created_at,
+				0 AS score
+				FROM posts p
+				LEFT JOIN users u ON u.id = p.author_id
+				WHERE ` + where + ` AND p.hidden_at IS NULL`
+			if q.CategoryID != "" {
+				part += ` AND EXISTS (SELECT 1 FROM post_categories pc WHERE pc.post_id = p.id AND pc.category_id = ?)`
+				args = append(args, q.CategoryID)
+			}
+			if q.Author != "" {
+				part += ` AND u.nickname = ?`
+				args = append(args, q.Author)
+			}
+			parts = append(parts, part)
+
+		case models.SearchTypeComment:
+			where, args = like(args, "c.content")
+			part := `
+				SELECT 'comment' AS type, c.id AS id, c.post_id AS post_id, p.title AS title,
+				c.content AS snippet,
+				COALESCE(u.nickname, 'Unknown') AS author_name,
+				c.created_at AS created_at,
+				0 AS score
+				FROM comments c
+				JOIN posts p ON p.id = c.post_id
+				LEFT JOIN users u ON u.id = c.author_id
+				WHERE ` + where + ` AND c.hidden_at IS NULL AND p.hidden_at IS NULL`
+			if q.CategoryID != "" {
+				part += ` AND EXISTS (SELECT 1 FROM post_categories pc WHERE pc.post_id = c.post_id AND pc.category_id = ?)`
+				args = append(args, q.CategoryID)
+			}
+			if q.Author != "" {
+				part += ` AND u.nickname = ?`
+				args = append(args, q.Author)
+			}
+			parts = append(parts, part)
+
+		case models.SearchTypeUser:
+			// Users have no category, so a category filter excludes them
+			if q.CategoryID != "" {
+				continue
+			}
+			where, args = like(args, "u.nickname")
+			part := `
+				SELECT 'user' AS type, u.id AS id, '' AS post_id, u.nickname AS title,
+				u.nickname AS snippet,
+				u.nickname AS author_name,
+				u.created_at AS created_at,
+				0 AS score
+				FROM users u
+				WHERE ` + where
+			if q.Author != "" {
+				part += ` AND u.nickname = ?`
+				args = append(args, q.Author)
+			}
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) == 0 {
+		return []models.SearchResult{}, nil
+	}
+
+	return r.runSearch(ctx, parts, args, q, func(text string) string {
+		return substringSnippet(text, terms)
+	})
+}
+
+// runSearch pages through the union of the per-type queries. snippet, when
+// set, turns the raw text each query returns into the snippet.
+func (r *SQLiteSearchRepository) runSearch(ctx context.Context, parts []string, args []interface{}, q models.SearchQuery, snippet func(string) string) ([]models.SearchResult, error) {
+	query := strings.Join(parts, "\nUNION ALL\n") + `
+		ORDER BY score, created_at DESC
+		LIMIT ? OFFSET ?`
+	args = append(args, q.Limit, q.Offset)
+
+	rows, err := r.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var res models.SearchResult
+		var score float64
+		if err := rows.Scan(
+			&res.Type,
+			&res.ID,
+			&res.PostID,
+			&res.Title,
+			&res.Snippet,
+			&res.AuthorName,
+			&res.CreatedAt,
+			&score,
+		); err != nil {
+			return nil, err
+		}
+		if snippet != nil {
+			res.Snippet = snippet(res.Snippet)
+		}
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// snippetWords is how many words a substring snippet keeps, as with the FTS5
+// snippets
+const snippetWords = 16
+
+// substringSnippet cuts the words around the first match of any term out of
+// text and wraps every match in the snippet markers
+func substringSnippet(text string, terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	re := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+
+	words := strings.Fields(text)
+	first := 0
+	for i, w := range words {
+		if re.MatchString(w) {
+			first = i
+			break
+		}
+	}
+	start := first - snippetWords/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetWords
+	if end > len(words) {
+		end = len(words)
+	}
+
+	out := re.ReplaceAllString(strings.Join(words[start:end], " "), SnippetMatchStart+"$0"+SnippetMatchEnd)
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(words) {
+		out += "…"
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSubstringSnippet(t *testing.T) {
+	cases := []struct {
+		text  string
+		terms []string
+		want  string
+	}{
+		{"Tomato soup", []string{"tomat"}, "\x02Tomat\x03o soup"},
+		{"a b c d e f g h i j k l m n o p q r s t tomato", []string{"tomato"}, "…q r s t \x02tomato\x03"},
+		{"one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen", []string{"one"}, "\x02one\x03 two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen…"},
+		{"Soup and more soup", []string{"soup", "more"}, "\x02Soup\x03 and \x02more\x03 \x02soup\x03"},
+	}
+	for _, c := range cases {
+		if got := substringSnippet(c.text, c.terms); got != c.want {
+			t.Errorf("substringSnippet(%q, %v) = %q, want %q", c.text, c.terms, got, c.want)
+		}
+	}
+}
+
+// TestEnsureIndexRebuildsOnlyWhenIncomplete needs a build with
+// -tags sqlite_fts5
+func TestEnsureIndexRebuildsOnlyWhenIncomplete(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repo := NewSQLiteSearchRepository(db)
+	if err := repo.EnsureIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !repo.fts {
+		t.Skip("SQLite build has no FTS5")
+	}
+	createTestUser(t, db, "u1", "alice")
+
+	count := func() int {
+		t.Helper()
+		var n int
+		if err := db.Write.QueryRowContext(ctx, `SELECT COUNT(*) FROM users_fts`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// A stray index row survives a restart with a complete index...
+	if _, err := db.Write.ExecContext(ctx, `INSERT INTO users_fts (user_id, nickname) VALUES ('ghost', 'ghost')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSQLiteSearchRepository(db).EnsureIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("index rows after restart = %d, want 2 (no rebuild)", n)
+	}
+
+	// ...but a missing trigger means writes may have been missed, so rebuild
+	if _, err := db.Write.ExecContext(ctx, `DROP TRIGGER users_fts_insert`); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSQLiteSearchRepository(db).EnsureIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("index rows after rebuild = %d, want 1", n)
+	}
+}
@@ -4,20 +4,22 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"real-time-forum/database/sqlite"
 	"real-time-forum/models"
+	"real-time-forum/utils"
 	"time"
 )
 
-type SessionRepository struct {
-	db *sql.DB
+type SQLiteSessionRepository struct {
+	db   *sql.DB
+	read *sql.DB
 }
 
-func NewSessionRepository(db *sql.DB) *SessionRepository {
-	return &SessionRepository{db: db}
+func NewSQLiteSessionRepository(db *sqlite.DB) *SQLiteSessionRepository {
+	return &SQLiteSessionRepository{db: db.Write, read: db.Read}
 }
 
-func (r *SessionRepository) CreateSession(ctx context.Context, session models.Session) error {
+func (r *SQLiteSessionRepository) CreateSession(ctx context.Context, session models.Session) error {
 	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO sessions (session_id, user_id, created_at, expires_at)
 		VALUES (?, ?, ?, ?)
@@ -30,9 +32,9 @@ func (r *SessionRepository) CreateSession(ctx context.Context, session models.Se
 	return err
 }
 
-func (r *SessionRepository) CheckSession(ctx context.Context, sessionID string)  error {
+func (r *SQLiteSessionRepository) CheckSession(ctx context.Context, sessionID string)  error {
 	s := models.Session{}
-	err := r.db.QueryRowContext(ctx, "SELECT session_id, user_id, created_at, expires_at FROM sessions WHERE session_id = ?", sessionID).Scan(&s.ID, &s.UserID, &s.CreatedAt, &s.ExpiresAt)
+	err := r.read.QueryRowContext(ctx, "SELECT session_id, user_id, created_at, expires_at FROM sessions WHERE session_id = ?", sessionID).Scan(&s.ID, &s.UserID, &s.CreatedAt, &s.ExpiresAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return errors.New("session not found")
@@ -42,17 +44,27 @@ func (r *SessionRepository) CheckSession(ctx context.Context, sessionID string)
 	return nil
 }
 
-func (r *SessionRepository) CleanupExpiredSessions(ctx context.Context) error {
+func (r *SQLiteSessionRepository) CleanupExpiredSessions(ctx context.Context) error {
 	res, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at < ?`, time.Now())
 	if err != nil {
 		return err
 	}
 	n, _ := res.RowsAffected()
-	log.Printf("Deleted %d expired sessions", n)
+	utils.Logger(ctx).Info("Deleted expired sessions", "count", n)
 	return err
 }
 
-func (r *SessionRepository) DeleteSession(ctx context.Context, userID string) error {
+// DeleteUserSessions removes every session of the user. Unlike DeleteSession
+// it is not an error when there is none.
+func (r *SQLiteSessionRepository) DeleteUserSessions(ctx context.Context, userID string) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *SQLiteSessionRepository) DeleteSession(ctx context.Context, userID string) error {
 	res, err := r.db.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
 	if err != nil {
 		return err
@@ -3,42 +3,47 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"errors"
 
+	"real-time-forum/database/sqlite"
 	"real-time-forum/models"
+
+	"github.com/mattn/go-sqlite3"
 )
 
-type UserRepository struct {
-	db *sql.DB
+type SQLiteUserRepository struct {
+	db   *sql.DB
+	read *sql.DB
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+func NewSQLiteUserRepository(db *sqlite.DB) *SQLiteUserRepository {
+	return &SQLiteUserRepository{db: db.Write, read: db.Read}
 }
 
-func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+func (r *SQLiteUserRepository) CreateUser(ctx context.Context, user *models.User) error {
 
 	query := `INSERT INTO users (id, nickname, age, gender, first_name, last_name,  email, password)
 	        VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 	_, err := r.db.Exec(query, user.ID, user.Nickname, user.Age, user.Gender, user.FirstName, user.LastName, user.Email, user.Password)
 	if err != nil {
-		return err
+		return sqliteDuplicate(err)
 	}
 	return nil
 }
 
-func (r *UserRepository) CheckUser(ctx context.Context, user *models.User) (bool, error) {
-	var exists int
-	err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE nickname = ? OR email = ?)", user.Nickname, user.Email).Scan(&exists)
-	if err != nil {
-		return false, err // something went wrong with the query
+// sqliteDuplicate turns a unique constraint violation into ErrDuplicate
+func sqliteDuplicate(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey) {
+		return ErrDuplicate
 	}
-	return exists == 1, nil // true if user exists
+	return err
 }
 
-func (r *UserRepository) GetUserByEmailorName(ctx context.Context, email, name string) (*models.User, error) {
+func (r *SQLiteUserRepository) GetUserByEmailorName(ctx context.Context, email, name string) (*models.User, error) {
 	user := models.User{}
 
-	err := r.db.QueryRowContext(ctx, `SELECT id, nickname, email, password FROM users WHERE nickname = ? OR email = ?`, name, email).Scan(&user.ID, &user.Nickname, &user.Email, &user.Password)
+	err := r.read.QueryRowContext(ctx, `SELECT id, nickname, email, password, role FROM users WHERE nickname = ? OR email = ?`, name, email).Scan(&user.ID, &user.Nickname, &user.Email, &user.Password, &user.Role)
 
 	if err != nil {
 
@@ -48,9 +53,9 @@ func (r *UserRepository) GetUserByEmailorName(ctx context.Context, email, name s
 	return &user, nil
 }
 
-func (r *UserRepository) GetUserBySessionID(ctx context.Context, SessionID string) (*models.User, error) {
+func (r *SQLiteUserRepository) GetUserBySessionID(ctx context.Context, SessionID string) (*models.User, error) {
 	user := models.User{}
-	err := r.db.QueryRowContext(ctx, `SELECT u.id, u.nickname, u.email FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.session_id = ?`, SessionID).Scan(&user.ID, &user.Nickname, &user.Email)
+	err := r.read.QueryRowContext(ctx, `SELECT u.id, u.nickname, u.email, u.role FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.session_id = ?`, SessionID).Scan(&user.ID, &user.Nickname, &user.Email, &user.Role)
 	if err != nil {
 
 		return nil, err // return the raw DB error
@@ -58,8 +63,45 @@ func (r *UserRepository) GetUserBySessionID(ctx context.Context, SessionID strin
 	return &user, nil
 }
 
-func (r *UserRepository) GetAllUsers(ctx context.Context) ([]models.User, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT nickname FROM users")
+func (r *SQLiteUserRepository) SetUserRole(ctx context.Context, userID, role string) error {
+	// SQLite runs one write at a time, so the admin count cannot change
+	// between the check and the update of this statement
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users SET role = ?
+		WHERE id = ?
+		AND (role <> ? OR ? = ? OR (SELECT COUNT(*) FROM users WHERE role = ?) > 1)`,
+		role, userID, models.RoleAdmin, role, models.RoleAdmin, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		var exists bool
+		if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, userID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrLastAdmin
+		}
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *SQLiteUserRepository) CountUsersWithRole(ctx context.Context, role string) (int, error) {
+	var count int
+	err := r.read.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE role = ?`, role).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *SQLiteUserRepository) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	rows, err := r.read.QueryContext(ctx, "SELECT nickname FROM users")
 	if err != nil {
 		return nil, err
 	}
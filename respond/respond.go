@@ -0,0 +1,143 @@
+// Package respond writes the JSON error responses shared by the handlers and
+// the middleware.
+package respond
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"real-time-forum/models"
+	"real-time-forum/services"
+	"real-time-forum/utils"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Error codes clients can branch on
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeBanned           = "banned"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeTooLarge         = "too_large"
+	CodeContentRejected  = "content_rejected"
+	CodeInternal         = "internal"
+	CodeUnavailable      = "unavailable"
+)
+
+// Envelope is the body of every error response. Error is the message to show
+// the user, Code says what went wrong and RequestID matches the X-Request-ID
+// header and the server log.
+type Envelope struct {
+	Error     string       `json:"error"`
+	Code      string       `json:"code"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+
+	// Filter names the content filter that rejected a post, comment or
+	// message
+	Filter string `json:"filter,omitempty"`
+	// ExpiresAt is when a ban or suspension ends, nil if it is permanent
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// FieldError tells which input was rejected and why
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Write sends the envelope with the given status, filling in the request ID
+func Write(w http.ResponseWriter, r *http.Request, status int, body Envelope) {
+	body.RequestID = utils.GetRequestIDFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Fail sends an error the handler has already classified
+func Fail(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	Write(w, r, status, Envelope{Error: message, Code: code})
+}
+
+func BadRequest(w http.ResponseWriter, r *http.Request, message string) {
+	Fail(w, r, http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func Unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	Fail(w, r, http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func NotFound(w http.ResponseWriter, r *http.Request, message string) {
+	Fail(w, r, http.StatusNotFound, CodeNotFound, message)
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Fail(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+}
+
+func Internal(w http.ResponseWriter, r *http.Request, message string) {
+	Fail(w, r, http.StatusInternalServerError, CodeInternal, message)
+}
+
+// Banned refuses a banned or suspended user
+func Banned(w http.ResponseWriter, r *http.Request, ban *models.Sanction) {
+	Write(w, r, http.StatusForbidden, Envelope{
+		Error:     services.BanMessage(ban),
+		Code:      CodeBanned,
+		ExpiresAt: ban.ExpiresAt,
+	})
+}
+
+// Invalid reports a single rejected input
+func Invalid(w http.ResponseWriter, r *http.Request, field, message string) {
+	Error(w, r, &services.ValidationError{Field: field, Message: message}, "")
+}
+
+// Error maps a service error to its status code. The messages of the typed
+// service errors are meant for users; anything else is an internal error and
+// the client only gets the fallback message.
+func Error(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	var validation *services.ValidationError
+	var rejected *services.ContentRejectedError
+
+	switch {
+	case errors.As(err, &validation):
+		Write(w, r, http.StatusBadRequest, Envelope{
+			Error:   capitalize(validation.Message),
+			Code:    CodeValidation,
+			Details: []FieldError{{Field: validation.Field, Message: capitalize(validation.Message)}},
+		})
+	case errors.As(err, &rejected):
+		Write(w, r, http.StatusUnprocessableEntity, Envelope{
+			Error:  "Content rejected: " + rejected.Reason,
+			Code:   CodeContentRejected,
+			Filter: rejected.Filter,
+		})
+	case errors.Is(err, services.ErrImageTooLarge):
+		Fail(w, r, http.StatusRequestEntityTooLarge, CodeTooLarge, capitalize(err.Error()))
+	case errors.Is(err, services.ErrValidation):
+		Fail(w, r, http.StatusBadRequest, CodeValidation, capitalize(err.Error()))
+	case errors.Is(err, services.ErrNotFound):
+		Fail(w, r, http.StatusNotFound, CodeNotFound, capitalize(err.Error()))
+	case errors.Is(err, services.ErrConflict):
+		Fail(w, r, http.StatusConflict, CodeConflict, capitalize(err.Error()))
+	case errors.Is(err, services.ErrForbidden):
+		Fail(w, r, http.StatusForbidden, CodeForbidden, capitalize(err.Error()))
+	default:
+		Internal(w, r, fallback)
+	}
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	first, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(first)) + s[size:]
+}
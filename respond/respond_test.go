@@ -0,0 +1,65 @@
+package respond
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"real-time-forum/services"
+	"real-time-forum/utils"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		want      int
+		wantCode  string
+		wantError string
+	}{
+		{"not found", services.ErrCategoryNotFound, http.StatusNotFound, CodeNotFound, "Category not found"},
+		{"conflict", services.ErrReportClaimed, http.StatusConflict, CodeConflict, "Report is claimed by another moderator"},
+		{"validation", services.ErrInvalidRole, http.StatusBadRequest, CodeValidation, "Role must be user, moderator or admin"},
+		{"forbidden", services.ErrCannotSanction, http.StatusForbidden, CodeForbidden, "You cannot sanction this user"},
+		{"too large", services.ErrImageTooLarge, http.StatusRequestEntityTooLarge, CodeTooLarge, "Image must be at most 5 MB"},
+		{"rejected", &services.ContentRejectedError{Filter: "links", Reason: "too many links"}, http.StatusUnprocessableEntity, CodeContentRejected, "Content rejected: too many links"},
+		{"internal", errors.New("database is locked"), http.StatusInternalServerError, CodeInternal, "Failed to save"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Error(w, httptest.NewRequest(http.MethodPost, "/", nil), c.err, "Failed to save")
+
+			if w.Code != c.want {
+				t.Errorf("status = %d, want %d", w.Code, c.want)
+			}
+			var body Envelope
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != c.wantCode || body.Error != c.wantError {
+				t.Errorf("body = %+v", body)
+			}
+		})
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/register", nil)
+	r = r.WithContext(context.WithValue(r.Context(), utils.ContextRequestID, "req-1"))
+	w := httptest.NewRecorder()
+	Invalid(w, r, "age", "Age is required")
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+	var body Envelope
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.RequestID != "req-1" || len(body.Details) != 1 || body.Details[0].Field != "age" {
+		t.Errorf("body = %+v", body)
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"real-time-forum/models"
+	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
+	"time"
+)
+
+// auditTimeout bounds the write of one audit event. Events are written on a
+// context detached from the request, so a client hanging up does not lose
+// them.
+const auditTimeout = 5 * time.Second
+
+type auditService struct {
+	repo repositories.AuditRepository
+}
+
+func NewAuditService(repo repositories.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+// Record appends an event to the audit log. The actor defaults to the user of
+// the current request and the IP is taken from the request context. Failures
+// are only logged: auditing never makes the audited action fail.
+func (s *auditService) Record(ctx context.Context, event models.AuditEvent) {
+	if event.ActorID == "" && event.ActorName == "" {
+		if user := utils.GetUserFromContext(ctx); user != nil {
+			event.ActorID = user.ID
+			event.ActorName = user.Nickname
+		}
+	}
+	if event.IP == "" {
+		event.IP = utils.GetClientIPFromContext(ctx)
+	}
+	event.CreatedAt = time.Now().UTC()
+
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditTimeout)
+	defer cancel()
+	if err := s.repo.InsertEvent(writeCtx, &event); err != nil {
+		utils.Logger(ctx).Error("Record: failed to record audit event", "action", event.Action, "err", err)
+	}
+}
+
+// ListEvents returns audit events matching the filter, newest first
+func (s *auditService) ListEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	events, err := s.repo.ListEvents(ctx, filter)
+	if err != nil {
+		utils.Logger(ctx).Error("ListEvents: failed to fetch audit events", "err", err)
+		return nil, errors.New("failed to fetch audit events")
+	}
+	return events, nil
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"context"
+	"real-time-forum/models"
+	"testing"
+)
+
+func TestRecordOutlivesTheRequest(t *testing.T) {
+	repos := openTestRepos(t)
+	audit := NewAuditService(repos.Audit)
+
+	// The client has already hung up when the event is recorded
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	audit.Record(ctx, models.AuditEvent{Action: models.AuditLogout, ActorName: "alice"})
+
+	events, err := audit.ListEvents(context.Background(), models.AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Action != models.AuditLogout {
+		t.Errorf("events = %+v, want the logout recorded", events)
+	}
+}
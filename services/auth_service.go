@@ -4,80 +4,125 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
 	"real-time-forum/models"
 	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
 	"strings"
 
 	"github.com/gofrs/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type AuthService struct {
-	repo repositories.UserRepository
+var (
+	ErrNicknameTaken = conflict("nickname already exists")
+	ErrEmailTaken    = conflict("email already exists")
+	ErrUserExists    = conflict("user already exists")
+
+	// ErrInvalidCredentials hides whether the user or the password was wrong
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type authService struct {
+	repo         repositories.UserRepository
+	auditService AuditService
 }
 
-func NewAuthService(repo repositories.UserRepository) *AuthService {
-	return &AuthService{repo: repo}
+func NewAuthService(repo repositories.UserRepository, auditService AuditService) AuthService {
+	return &authService{repo: repo, auditService: auditService}
 }
 
-func (s *AuthService) Register(ctx context.Context, user *models.User) error {
+func (s *authService) Register(ctx context.Context, user *models.User) error {
 	err := validateUser(user, true)
 	if err != nil {
-		log.Printf("RegisterUser: validation error: %v", err)
+		utils.Logger(ctx).Warn("RegisterUser: validation error", "err", err)
 		return err
 	}
-	exists, err := s.repo.CheckUser(ctx, user)
-	if err != nil {
-		log.Printf("RegisterUser: error checking user: %v", err)
-		return err
-	}
-	if exists {
-		log.Printf("RegisterUser: user already exists: %s", user.Nickname)
-		return errors.New("user already exists")
+	existing, err := s.repo.GetUserByEmailorName(ctx, user.Email, user.Nickname)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		utils.Logger(ctx).Error("RegisterUser: error checking user", "err", err)
+		return errors.New("failed to check user")
+	case existing.Nickname == user.Nickname:
+		utils.Logger(ctx).Warn("RegisterUser: nickname already exists", "nickname", user.Nickname)
+		return ErrNicknameTaken
+	default:
+		utils.Logger(ctx).Warn("RegisterUser: email already exists", "email", user.Email)
+		return ErrEmailTaken
 	}
 
 	hashedPass, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Printf("RegisterUser: error hashing password: %v", err)
+		utils.Logger(ctx).Error("RegisterUser: error hashing password", "err", err)
 		return err
 	}
 
 	u1, err := uuid.NewV4()
 	if err != nil {
-		log.Printf("RegisterUser: error generating user ID: %v", err)
+		utils.Logger(ctx).Error("RegisterUser: error generating user ID", "err", err)
 		return err
 	}
 	user.ID = u1.String()
 	user.Password = string(hashedPass)
 	if err = s.repo.CreateUser(ctx, user); err != nil {
-		log.Printf("RegisterUser: error creating user: %v", err)
-		return err
+		utils.Logger(ctx).Error("RegisterUser: error creating user", "err", err)
+		// Someone else registered the same nickname or email since the check
+		if errors.Is(err, repositories.ErrDuplicate) {
+			return ErrUserExists
+		}
+		return errors.New("failed to create user")
 	}
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     models.AuditRegister,
+		ActorID:    user.ID,
+		ActorName:  user.Nickname,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
 	return nil
 }
 
 
-func (s *AuthService) LoginUser(ctx context.Context, input *models.User) (*models.User, error) {
+func (s *authService) LoginUser(ctx context.Context, input *models.User) (*models.User, error) {
 	err := validateUser(input, false)
 	if err != nil {
-		log.Printf("LoginUser: validation error: %v", err)
+		utils.Logger(ctx).Warn("LoginUser: validation error", "err", err)
 		return nil, err
 	}
 	user, err := s.repo.GetUserByEmailorName(ctx, input.Email, input.Nickname)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("LoginUser: user not found: %v", err)
-			return nil, errors.New("invalid input credentials")
+			utils.Logger(ctx).Warn("LoginUser: user not found", "err", err)
+			identifier := input.Nickname
+			if identifier == "" {
+				identifier = input.Email
+			}
+			s.auditService.Record(ctx, models.AuditEvent{
+				Action: models.AuditLoginFailed,
+				Metadata: map[string]interface{}{
+					"identifier": identifier,
+					"reason":     "unknown_user",
+				},
+			})
+			return nil, ErrInvalidCredentials
 		}
-		log.Printf("LoginUser: error retrieving user: %v", err)
+		utils.Logger(ctx).Error("LoginUser: error retrieving user", "err", err)
 		return nil, errors.New("error retrieving user")
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
 	if err != nil {
-		log.Printf("LoginUser: invalid credentials for user %s: %v", user.Nickname, err)
-		return nil, err
+		utils.Logger(ctx).Warn("LoginUser: invalid credentials for user", "nickname", user.Nickname, "err", err)
+		s.auditService.Record(ctx, models.AuditEvent{
+			Action:     models.AuditLoginFailed,
+			TargetType: "user",
+			TargetID:   user.ID,
+			Metadata: map[string]interface{}{
+				"identifier": user.Nickname,
+				"reason":     "invalid_password",
+			},
+		})
+		return nil, ErrInvalidCredentials
 	}
 	return user, nil
 }
@@ -87,21 +132,21 @@ func validateUser(user *models.User, strict bool) error {
 	switch strict {
 	case false:
 		if strings.TrimSpace(user.Nickname) == "" && strings.TrimSpace(user.Email) == "" {
-			return errors.New("must provide nickname or email")
+			return &ValidationError{Field: "nickname", Message: "must provide nickname or email"}
 		}
 		if strings.TrimSpace(user.Password) == "" {
-			return errors.New("password cannot be empty")
+			return &ValidationError{Field: "password", Message: "password cannot be empty"}
 		}
 
 	case true:
 		if len(user.Nickname) < 4 {
-			return errors.New("nickname must be longer that four character")
+			return &ValidationError{Field: "nickname", Message: "nickname must be at least four characters long"}
 		} else if len(user.Nickname) > 20 {
-			return errors.New("nickname must be shorter than twenty characters")
+			return &ValidationError{Field: "nickname", Message: "nickname must be at most twenty characters long"}
 		} else if strings.TrimSpace(user.Email) == "" || !strings.Contains(user.Email, "@") {
-			return errors.New("invalid email address")
+			return &ValidationError{Field: "email", Message: "invalid email address"}
 		} else if len(user.Password) < 6 {
-			return errors.New("password must be at least six characters long")
+			return &ValidationError{Field: "password", Message: "password must be at least six characters long"}
 		}
 	}
 	return nil
@@ -2,25 +2,253 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"errors"
-	"log"
 	"real-time-forum/models"
 	repos "real-time-forum/repositories"
+	"real-time-forum/utils"
+	"strings"
 )
 
-type CategoriesService struct {
+var (
+	ErrCategoryNotFound = notFound("category not found")
+	ErrCategoryExists   = conflict("category name already exists")
+	ErrInvalidCategory  = invalid("category name must be 1-25 characters and description at most 255")
+)
+
+const (
+	maxCategoryNameLength        = 25
+	maxCategoryDescriptionLength = 255
+)
+
+type categoriesService struct {
 	repo repos.CategoriesRepository
 }
 
-func NewCategoriesService(repo repos.CategoriesRepository) *CategoriesService {
-	return &CategoriesService{repo: repo}
+func NewCategoriesService(repo repos.CategoriesRepository) CategoriesService {
+	return &categoriesService{repo: repo}
 }
 
-func (s *CategoriesService) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+func (s *categoriesService) GetAllCategories(ctx context.Context) ([]models.Category, error) {
 	categories, err := s.repo.GetAllCategories(ctx)
 	if err != nil {
-		log.Printf("GetAllCategories: failed to retrieve categories AYTO EDW PERNOYME: %v", err)
+		utils.Logger(ctx).Error("GetAllCategories: failed to retrieve categories", "err", err)
 		return nil, errors.New("failed to retrieve categories")
 	}
 	return categories, nil
 }
+
+// GetCategoriesForUser returns the active categories, flagging the ones the
+// user is subscribed to
+func (s *categoriesService) GetCategoriesForUser(ctx context.Context, userID string) ([]models.Category, error) {
+	categories, err := s.repo.GetCategoriesForUser(ctx, userID)
+	if err != nil {
+		utils.Logger(ctx).Error("GetCategoriesForUser: failed to retrieve categories for user", "user_id", userID, "err", err)
+		return nil, errors.New("failed to retrieve categories")
+	}
+	return categories, nil
+}
+
+// GetCategoryForUser returns a single category, archived or not
+func (s *categoriesService) GetCategoryForUser(ctx context.Context, categoryID, userID string) (*models.Category, error) {
+	category, err := s.repo.GetCategoryByID(ctx, categoryID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCategoryNotFound
+		}
+		utils.Logger(ctx).Error("GetCategoryForUser: failed to retrieve category", "category_id", categoryID, "err", err)
+		return nil, errors.New("failed to retrieve category")
+	}
+	return category, nil
+}
+
+func (s *categoriesService) GetSubscribedCategories(ctx context.Context, userID string) ([]models.Category, error) {
+	categories, err := s.repo.GetSubscribedCategories(ctx, userID)
+	if err != nil {
+		utils.Logger(ctx).Error("GetSubscribedCategories: failed to retrieve subscriptions of user", "user_id", userID, "err", err)
+		return nil, errors.New("failed to retrieve subscriptions")
+	}
+	return categories, nil
+}
+
+// Subscribe adds a category to the user's subscribed feed. Archived
+// categories can no longer be subscribed to.
+func (s *categoriesService) Subscribe(ctx context.Context, userID, categoryID string) error {
+	category, err := s.getCategory(ctx, categoryID)
+	if err != nil {
+		return err
+	}
+	if category.Archived {
+		return ErrCategoryNotFound
+	}
+
+	if err := s.repo.Subscribe(ctx, userID, categoryID); err != nil {
+		utils.Logger(ctx).Error("Subscribe: failed to subscribe user to category", "user_id", userID, "category_id", categoryID, "err", err)
+		return errors.New("failed to subscribe")
+	}
+	return nil
+}
+
+func (s *categoriesService) Unsubscribe(ctx context.Context, userID, categoryID string) error {
+	if err := s.repo.Unsubscribe(ctx, userID, categoryID); err != nil {
+		utils.Logger(ctx).Error("Unsubscribe: failed to unsubscribe user from category", "user_id", userID, "category_id", categoryID, "err", err)
+		return errors.New("failed to unsubscribe")
+	}
+	return nil
+}
+
+func (s *categoriesService) GetAllCategoriesWithArchived(ctx context.Context) ([]models.Category, error) {
+	categories, err := s.repo.GetAllCategoriesWithArchived(ctx)
+	if err != nil {
+		utils.Logger(ctx).Error("GetAllCategoriesWithArchived: failed to retrieve categories", "err", err)
+		return nil, errors.New("failed to retrieve categories")
+	}
+	return categories, nil
+}
+
+// ValidateCategoryIDs checks that every ID refers to an existing category
+// that still accepts new posts
+func (s *categoriesService) ValidateCategoryIDs(ctx context.Context, categoryIDs []string) error {
+	unique := make(map[string]bool, len(categoryIDs))
+	ids := make([]string, 0, len(categoryIDs))
+	for _, id := range categoryIDs {
+		if !unique[id] {
+			unique[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	count, err := s.repo.CountActiveCategories(ctx, ids)
+	if err != nil {
+		utils.Logger(ctx).Error("ValidateCategoryIDs: failed to check categories", "category_ids", ids, "err", err)
+		return errors.New("failed to retrieve categories")
+	}
+	if count != len(ids) {
+		utils.Logger(ctx).Warn("ValidateCategoryIDs: unknown or archived category", "category_ids", ids)
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+func (s *categoriesService) CreateCategory(ctx context.Context, category *models.Category) error {
+	if err := s.validateCategory(ctx, category); err != nil {
+		return err
+	}
+
+	if err := s.repo.CreateCategory(ctx, category); err != nil {
+		utils.Logger(ctx).Error("CreateCategory: failed to create category", "name", category.Name, "err", err)
+		return errors.New("failed to create category")
+	}
+	return nil
+}
+
+// UpdateCategory renames and/or re-describes a category. Empty fields keep
+// their current value.
+func (s *categoriesService) UpdateCategory(ctx context.Context, categoryID, name, description string, setDescription bool) (*models.Category, error) {
+	category, err := s.getCategory(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(name) != "" {
+		category.Name = name
+	}
+	if setDescription {
+		category.Description = description
+	}
+
+	if err := s.validateCategory(ctx, category); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateCategory(ctx, category); err != nil {
+		utils.Logger(ctx).Error("UpdateCategory: failed to update category", "category_id", categoryID, "err", err)
+		return nil, errors.New("failed to update category")
+	}
+	return category, nil
+}
+
+func (s *categoriesService) SetCategoryArchived(ctx context.Context, categoryID string, archived bool) (*models.Category, error) {
+	if err := s.repo.SetCategoryArchived(ctx, categoryID, archived); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCategoryNotFound
+		}
+		utils.Logger(ctx).Error("SetCategoryArchived: failed to update category", "category_id", categoryID, "err", err)
+		return nil, errors.New("failed to update category")
+	}
+	return s.getCategory(ctx, categoryID)
+}
+
+func (s *categoriesService) ReorderCategories(ctx context.Context, categoryIDs []string) error {
+	if len(categoryIDs) == 0 {
+		return ErrInvalidCategory
+	}
+
+	seen := make(map[string]bool, len(categoryIDs))
+	for _, id := range categoryIDs {
+		if seen[id] {
+			utils.Logger(ctx).Warn("ReorderCategories: category listed twice", "category_id", id)
+			return ErrInvalidCategory
+		}
+		seen[id] = true
+	}
+
+	// Listed categories move to the front in the given order, the rest
+	// keep their relative order behind them
+	all, err := s.repo.GetAllCategoriesWithArchived(ctx)
+	if err != nil {
+		utils.Logger(ctx).Error("ReorderCategories: failed to retrieve categories", "err", err)
+		return errors.New("failed to reorder categories")
+	}
+	order := append([]string{}, categoryIDs...)
+	for _, category := range all {
+		if !seen[category.ID] {
+			order = append(order, category.ID)
+		}
+	}
+
+	if err := s.repo.ReorderCategories(ctx, order); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCategoryNotFound
+		}
+		utils.Logger(ctx).Error("ReorderCategories: failed to reorder categories", "err", err)
+		return errors.New("failed to reorder categories")
+	}
+	return nil
+}
+
+func (s *categoriesService) getCategory(ctx context.Context, categoryID string) (*models.Category, error) {
+	category, err := s.repo.GetCategoryByID(ctx, categoryID, "")
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCategoryNotFound
+		}
+		utils.Logger(ctx).Error("getCategory: failed to retrieve category", "category_id", categoryID, "err", err)
+		return nil, errors.New("failed to retrieve category")
+	}
+	return category, nil
+}
+
+func (s *categoriesService) validateCategory(ctx context.Context, category *models.Category) error {
+	category.Name = strings.TrimSpace(category.Name)
+	category.Description = strings.TrimSpace(category.Description)
+
+	if category.Name == "" || len(category.Name) > maxCategoryNameLength {
+		utils.Logger(ctx).Warn("validateCategory: invalid name", "name", category.Name)
+		return ErrInvalidCategory
+	}
+	if len(category.Description) > maxCategoryDescriptionLength {
+		utils.Logger(ctx).Warn("validateCategory: description too long", "name", category.Name)
+		return ErrInvalidCategory
+	}
+
+	exists, err := s.repo.CategoryNameExists(ctx, category.Name, category.ID)
+	if err != nil {
+		utils.Logger(ctx).Error("validateCategory: failed to check name", "name", category.Name, "err", err)
+		return errors.New("failed to check category name")
+	}
+	if exists {
+		return ErrCategoryExists
+	}
+	return nil
+}
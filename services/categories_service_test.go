@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"real-time-forum/models"
+	"strings"
+	"testing"
+)
+
+func TestValidateCategoryIDs(t *testing.T) {
+	ctx := context.Background()
+	repos := openTestRepos(t)
+	categories := NewCategoriesService(repos.Categories)
+	if _, err := categories.SetCategoryArchived(ctx, "7", true); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		ids  []string
+		want error
+	}{
+		{"active", []string{"1", "2"}, nil},
+		{"duplicates", []string{"1", "1", "2"}, nil},
+		{"unknown", []string{"1", "99"}, ErrCategoryNotFound},
+		{"not a number", []string{"abc"}, ErrCategoryNotFound},
+		{"archived", []string{"7"}, ErrCategoryNotFound},
+	}
+	for _, c := range cases {
+		if err := categories.ValidateCategoryIDs(ctx, c.ids); !errors.Is(err, c.want) {
+			t.Errorf("%s: ValidateCategoryIDs(%v) = %v, want %v", c.name, c.ids, err, c.want)
+		}
+	}
+}
+
+func TestCreateAndUpdateCategory(t *testing.T) {
+	ctx := context.Background()
+	repos := openTestRepos(t)
+	categories := NewCategoriesService(repos.Categories)
+
+	cases := []struct {
+		name     string
+		category models.Category
+		want     error
+	}{
+		{"blank name", models.Category{Name: "   "}, ErrInvalidCategory},
+		{"long name", models.Category{Name: strings.Repeat("x", 26)}, ErrInvalidCategory},
+		{"long description", models.Category{Name: "Games", Description: strings.Repeat("x", 256)}, ErrInvalidCategory},
+		{"taken name", models.Category{Name: "Sports"}, ErrCategoryExists},
+		{"created", models.Category{Name: "  Games ", Description: "Board and video"}, nil},
+	}
+	for _, c := range cases {
+		category := c.category
+		if err := categories.CreateCategory(ctx, &category); !errors.Is(err, c.want) {
+			t.Errorf("%s: CreateCategory = %v, want %v", c.name, err, c.want)
+		}
+	}
+
+	all, err := categories.GetAllCategoriesWithArchived(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	games := all[len(all)-1]
+	if games.Name != "Games" || games.Description != "Board and video" {
+		t.Fatalf("last category = %+v, want the trimmed new one", games)
+	}
+
+	if _, err := categories.UpdateCategory(ctx, games.ID, "Sports", "", false); !errors.Is(err, ErrCategoryExists) {
+		t.Errorf("UpdateCategory(taken name) = %v, want ErrCategoryExists", err)
+	}
+	updated, err := categories.UpdateCategory(ctx, games.ID, "", "", true)
+	if err != nil || updated.Name != "Games" || updated.Description != "" {
+		t.Errorf("UpdateCategory(clear description) = %+v, %v", updated, err)
+	}
+	if _, err := categories.UpdateCategory(ctx, "99", "Other", "", false); !errors.Is(err, ErrCategoryNotFound) {
+		t.Errorf("UpdateCategory(missing) = %v, want ErrCategoryNotFound", err)
+	}
+}
+
+func TestReorderCategories(t *testing.T) {
+	ctx := context.Background()
+	repos := openTestRepos(t)
+	categories := NewCategoriesService(repos.Categories)
+
+	if err := categories.ReorderCategories(ctx, []string{"3", "3"}); !errors.Is(err, ErrInvalidCategory) {
+		t.Errorf("ReorderCategories(duplicate) = %v, want ErrInvalidCategory", err)
+	}
+	if err := categories.ReorderCategories(ctx, []string{"3", "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := categories.GetAllCategoriesWithArchived(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, category := range all {
+		ids = append(ids, category.ID)
+	}
+	if got := strings.Join(ids, ","); got != "3,1,2,4,5,6,7" {
+		t.Errorf("order = %s, want the listed ones first and the rest behind", got)
+	}
+}
+
+func TestSubscribeArchivedCategory(t *testing.T) {
+	ctx := context.Background()
+	repos := openTestRepos(t)
+	categories := NewCategoriesService(repos.Categories)
+	createUsers(t, repos, "alice")
+	if _, err := categories.SetCategoryArchived(ctx, "2", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := categories.Subscribe(ctx, "id-alice", "2"); !errors.Is(err, ErrCategoryNotFound) {
+		t.Errorf("Subscribe(archived) = %v, want ErrCategoryNotFound", err)
+	}
+	if err := categories.Subscribe(ctx, "id-alice", "1"); err != nil {
+		t.Fatal(err)
+	}
+	subscribed, err := categories.GetSubscribedCategories(ctx, "id-alice")
+	if err != nil || len(subscribed) != 1 || subscribed[0].ID != "1" {
+		t.Errorf("GetSubscribedCategories = %+v, %v", subscribed, err)
+	}
+}
@@ -2,61 +2,187 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"real-time-forum/models"
 	"real-time-forum/repositories"
+	"real-time-forum/utils"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
-type ChatService struct {
-	messageRepo *repositories.MessageRepository
-	Hub         *models.Hub
+var (
+	ErrBlocked      = forbidden("users have blocked each other")
+	ErrInvalidBlock = invalid("you cannot block yourself")
+)
+
+type chatService struct {
+	messageRepo    repositories.MessageRepository
+	sanctionRepo   repositories.SanctionRepository
+	blockRepo      repositories.BlockRepository
+	userRepo       repositories.UserRepository
+	contentFilter  ContentFilterService
+	mentionService MentionService
+	pushService    PushService
+	hub            *models.Hub
 }
 
-func NewChatService(repo *repositories.MessageRepository, Hub *models.Hub) *ChatService {
-	service := &ChatService{messageRepo: repo, Hub: Hub}
+func NewChatService(repo repositories.MessageRepository, sanctionRepo repositories.SanctionRepository, blockRepo repositories.BlockRepository, userRepo repositories.UserRepository, contentFilter ContentFilterService, mentionService MentionService, pushService PushService, hub *models.Hub) ChatService {
+	service := &chatService{messageRepo: repo, sanctionRepo: sanctionRepo, blockRepo: blockRepo, userRepo: userRepo, contentFilter: contentFilter, mentionService: mentionService, pushService: pushService, hub: hub}
 
-	// Set the user sorting function in the Hub
-	Hub.SetUserSorter(service.SortUsersByLastMessage)
+	// Set the user sorting and filtering functions in the Hub
+	hub.SetUserSorter(service.SortUsersByLastMessage)
+	hub.SetUserFilter(service.FilterBlockedUsers)
 
 	return service
 }
 
 // Handle incoming message
-func (s *ChatService) ProcessMessage(msg *models.Message) {
+func (s *chatService) ProcessMessage(msg *models.Message) {
 	msg.Timestamp = time.Now()
+	msg.Mentions = nil // only the server resolves mentions
 
 	// Use custom context to avoid cancellation
 	dbCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := s.messageRepo.SaveMessage(dbCtx, msg)
+	// Muted users keep their connection but may not send anything
+	mute, err := s.sanctionRepo.GetActiveSanctionByNickname(dbCtx, msg.From, models.SanctionMute)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.Logger(dbCtx).Error("Error checking mute", "from", msg.From, "err", err)
+		s.SendError(msg.From, "internal_error", "Message could not be sent, please try again")
+		return
+	}
+	if mute != nil {
+		content := "You are muted and cannot send messages"
+		if mute.ExpiresAt != nil {
+			content = "You are muted until " + mute.ExpiresAt.Format(time.RFC1123)
+		}
+		s.SendError(msg.From, "muted", content)
+		return
+	}
+
+	// Private messages are refused when either side has blocked the other
+	if msg.To != "" && msg.To != "all" {
+		blocked, err := s.blockRepo.IsBlockedBetween(dbCtx, msg.From, msg.To)
+		if err != nil {
+			utils.Logger(dbCtx).Error("Error checking blocks", "from", msg.From, "to", msg.To, "err", err)
+			s.SendError(msg.From, "internal_error", "Message could not be sent, please try again")
+			return
+		}
+		if blocked {
+			s.SendError(msg.From, "blocked", "You cannot message this user")
+			return
+		}
+	}
+
+	screening, err := s.contentFilter.Screen(dbCtx, &FilterContent{
+		Type:     models.ReportTypeMessage,
+		AuthorID: msg.From,
+		Body:     msg.Content,
+	})
+	var rejected *ContentRejectedError
+	if errors.As(err, &rejected) {
+		s.SendError(msg.From, "content_rejected", "Message not sent: "+rejected.Reason)
+		return
+	}
+	if err != nil {
+		utils.Logger(dbCtx).Error("Error screening message", "from", msg.From, "err", err)
+		s.SendError(msg.From, "internal_error", "Message could not be sent, please try again")
+		return
+	}
+
+	err = s.messageRepo.SaveMessage(dbCtx, msg)
 	if err != nil {
-		log.Printf("Error saving message: %v", err)
+		utils.Logger(dbCtx).Error("Error saving message", "err", err)
+	} else {
+		s.contentFilter.Flag(dbCtx, screening, models.ReportTypeMessage, strconv.Itoa(msg.ID))
+		s.recordMentions(dbCtx, msg)
 	}
 
 	// Send to specific user
 	messageBytes, _ := json.Marshal(msg)
 	if msg.To != "" && msg.To != "all" {
-		s.Hub.SendToUser(msg.To, messageBytes)
+		s.hub.SendToUser(msg.To, messageBytes)
 		// Also send back to sender
-		s.Hub.SendToUser(msg.From, messageBytes)
+		s.hub.SendToUser(msg.From, messageBytes)
+		// Recipients who are away get it on their registered browsers
+		s.pushService.NotifyOffline(msg.To, models.PushMessage{
+			Title: "New message from " + msg.From,
+			Body:  notificationPreview(msg.Content),
+			URL:   "/",
+			Tag:   "chat:" + msg.From,
+		})
 
 		// Update online users list for both participants to reflect new conversation order
 		s.refreshOnlineUsersOrder(msg.From, msg.To)
 	} else {
-		// Broadcast
-		s.Hub.Broadcast <- messageBytes
+		// Broadcast, unless the hub already shut down
+		select {
+		case s.hub.Broadcast <- messageBytes:
+		case <-s.hub.Stopped():
+		}
 	}
 }
 
+// recordMentions links the users mentioned in a saved chat message. A private
+// message can only mention its recipient, who gets the message anyway, so
+// only public messages send mention notifications.
+func (s *chatService) recordMentions(ctx context.Context, msg *models.Message) {
+	if !strings.Contains(msg.Content, "@") {
+		return
+	}
+
+	author, err := s.userRepo.GetUserByEmailorName(ctx, msg.From, msg.From)
+	if err != nil {
+		utils.Logger(ctx).Error("Error looking up author for mentions", "from", msg.From, "err", err)
+		return
+	}
+
+	mentions := s.mentionService.Resolve(ctx, author, msg.Content)
+	private := msg.To != "" && msg.To != "all"
+	if private {
+		var recipient []models.Mention
+		for _, mention := range mentions {
+			if mention.Nickname == msg.To {
+				recipient = append(recipient, mention)
+			}
+		}
+		mentions = recipient
+	}
+
+	msg.Mentions = mentions
+	s.mentionService.Record(ctx, author, models.ReportTypeMessage, strconv.Itoa(msg.ID), "", "", msg.Content, mentions, !private)
+}
+
+// SendError tells a client that one of its frames was refused
+func (s *chatService) SendError(username, code, content string) {
+	errorMessage := map[string]interface{}{
+		"type":      "error",
+		"from":      "system",
+		"to":        username,
+		"code":      code,
+		"content":   content,
+		"timestamp": time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(errorMessage)
+	if err != nil {
+		slog.Error("Error marshaling error frame", "err", err)
+		return
+	}
+	s.hub.SendToUser(username, messageBytes)
+}
+
 // refreshOnlineUsersOrder sends updated online users list to participants after a new message
-func (s *ChatService) refreshOnlineUsersOrder(user1, user2 string) {
+func (s *chatService) refreshOnlineUsersOrder(user1, user2 string) {
 	// Send updated online users list to both participants
 	for _, username := range []string{user1, user2} {
-		onlineUsers := s.Hub.GetOnlineUsersExcluding(username)
+		onlineUsers := s.hub.GetOnlineUsersExcluding(username)
 
 		updateMessage := map[string]interface{}{
 			"type":         "online_users_update",
@@ -68,21 +194,118 @@ func (s *ChatService) refreshOnlineUsersOrder(user1, user2 string) {
 
 		messageBytes, err := json.Marshal(updateMessage)
 		if err != nil {
-			log.Printf("Error marshaling online users update: %v", err)
+			slog.Error("Error marshaling online users update", "err", err)
 			continue
 		}
 
-		s.Hub.SendToUser(username, messageBytes)
+		s.hub.SendToUser(username, messageBytes)
+	}
+}
+
+// IsBlocked reports whether either user has blocked the other
+func (s *chatService) IsBlocked(ctx context.Context, user1, user2 string) (bool, error) {
+	blocked, err := s.blockRepo.IsBlockedBetween(ctx, user1, user2)
+	if err != nil {
+		utils.Logger(ctx).Error("IsBlocked: failed to check blocks", "user1", user1, "user2", user2, "err", err)
+		return false, errors.New("failed to check blocks")
 	}
+	return blocked, nil
+}
+
+// BlockUser adds the user with the given nickname to the block list of user.
+// Both stop seeing each other online right away.
+func (s *chatService) BlockUser(ctx context.Context, user *models.User, nickname string) error {
+	target, err := s.userRepo.GetUserByEmailorName(ctx, "", strings.TrimSpace(nickname))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("BlockUser: failed to retrieve user", "nickname", nickname, "err", err)
+		return errors.New("failed to retrieve user")
+	}
+	if target.ID == user.ID {
+		return ErrInvalidBlock
+	}
+
+	if err := s.blockRepo.Block(ctx, user.ID, target.ID); err != nil {
+		utils.Logger(ctx).Error("BlockUser: failed to block", "target_id", target.ID, "user_id", user.ID, "err", err)
+		return errors.New("failed to block user")
+	}
+
+	s.refreshOnlineUsersOrder(user.Nickname, target.Nickname)
+	return nil
+}
+
+// UnblockUser removes the user with the given nickname from the block list
+func (s *chatService) UnblockUser(ctx context.Context, user *models.User, nickname string) error {
+	target, err := s.userRepo.GetUserByEmailorName(ctx, "", strings.TrimSpace(nickname))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("UnblockUser: failed to retrieve user", "nickname", nickname, "err", err)
+		return errors.New("failed to retrieve user")
+	}
+
+	if err := s.blockRepo.Unblock(ctx, user.ID, target.ID); err != nil {
+		utils.Logger(ctx).Error("UnblockUser: failed to unblock", "target_id", target.ID, "user_id", user.ID, "err", err)
+		return errors.New("failed to unblock user")
+	}
+
+	s.refreshOnlineUsersOrder(user.Nickname, target.Nickname)
+	return nil
+}
+
+func (s *chatService) GetBlockedUsers(ctx context.Context, userID string) ([]models.BlockedUser, error) {
+	blocked, err := s.blockRepo.GetBlockedUsers(ctx, userID)
+	if err != nil {
+		utils.Logger(ctx).Error("GetBlockedUsers: failed to fetch block list of user", "user_id", userID, "err", err)
+		return nil, errors.New("failed to fetch blocked users")
+	}
+	return blocked, nil
+}
+
+// FilterBlockedUsers drops the users that have blocked currentUser or that
+// currentUser has blocked
+func (s *chatService) FilterBlockedUsers(ctx context.Context, currentUser string, users []string) ([]string, error) {
+	if len(users) == 0 {
+		return users, nil
+	}
+
+	blocked, err := s.blockRepo.GetBlockedNicknames(ctx, currentUser)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]string, 0, len(users))
+	for _, user := range users {
+		if !blocked[user] {
+			visible = append(visible, user)
+		}
+	}
+	return visible, nil
 }
 
 // Fetch chat history with pagination
-func (s *ChatService) GetChatHistoryWithPagination(ctx context.Context, user1, user2 string, limit, offset int) ([]models.Message, error) {
-	return s.messageRepo.GetMessagesWithPagination(ctx, user1, user2, limit, offset)
+func (s *chatService) GetChatHistoryWithPagination(ctx context.Context, user1, user2 string, limit, offset int) ([]models.Message, error) {
+	messages, err := s.messageRepo.GetMessagesWithPagination(ctx, user1, user2, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(messages))
+	for i := range messages {
+		ids[i] = strconv.Itoa(messages[i].ID)
+	}
+	mentions := s.mentionService.MentionsFor(ctx, models.ReportTypeMessage, ids)
+	for i := range messages {
+		messages[i].Mentions = mentions[ids[i]]
+	}
+	return messages, nil
 }
 
 // SortUsersByLastMessage sorts users by putting those with recent conversations first, then alphabetically
-func (s *ChatService) SortUsersByLastMessage(ctx context.Context, currentUser string, users []string) ([]string, error) {
+func (s *chatService) SortUsersByLastMessage(ctx context.Context, currentUser string, users []string) ([]string, error) {
 	if len(users) == 0 {
 		return users, nil
 	}
@@ -90,7 +313,7 @@ func (s *ChatService) SortUsersByLastMessage(ctx context.Context, currentUser st
 	// Get last message timestamps for the current user
 	timestamps, err := s.messageRepo.GetLastMessageTimestamps(ctx, currentUser)
 	if err != nil {
-		log.Printf("Error getting message timestamps for sorting: %v", err)
+		utils.Logger(ctx).Error("Error getting message timestamps for sorting", "err", err)
 		// Fallback to alphabetical sorting
 		sort.Strings(users)
 		return users, nil
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"real-time-forum/models"
+	"testing"
+	"time"
+)
+
+// screenFilter answers every Screen call with err
+type screenFilter struct {
+	err error
+}
+
+func (f screenFilter) Screen(ctx context.Context, content *FilterContent) (FilterResult, error) {
+	return FilterResult{}, f.err
+}
+
+func (f screenFilter) Flag(ctx context.Context, result FilterResult, contentType, contentID string) {}
+
+func TestProcessMessageNotSavedWhenScreeningFails(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		code string
+	}{
+		"rejected":        {&ContentRejectedError{Filter: "banned_words", Reason: "banned word"}, "content_rejected"},
+		"filter failed":   {errors.New("database is locked"), "internal_error"},
+		"wrapped failure": {errors.Join(errors.New("screen"), context.DeadlineExceeded), "internal_error"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			repos := openTestRepos(t)
+			createUsers(t, repos, "alice", "bob")
+
+			// The service installs its hooks before the hub runs, as in main
+			hub := models.NewHub()
+			chat := NewChatService(repos.Messages, repos.Sanctions, repos.Blocks, repos.Users, screenFilter{tc.err}, nil, nil, hub)
+			startHub(t, hub)
+			alice := &models.Client{Username: "alice", Send: make(chan []byte, 16)}
+			hub.Register <- alice
+
+			chat.ProcessMessage(&models.Message{Type: "message", From: "alice", To: "bob", Content: "hello"})
+
+			if code := errorFrameCode(t, alice); code != tc.code {
+				t.Errorf("error frame code = %q, want %q", code, tc.code)
+			}
+			saved, err := repos.Messages.GetMessagesWithPagination(context.Background(), "alice", "bob", 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(saved) != 0 {
+				t.Errorf("saved %d messages, want none", len(saved))
+			}
+		})
+	}
+}
+
+// errorFrameCode waits for an error frame on the client, skipping presence
+// frames
+func errorFrameCode(t *testing.T, client *models.Client) string {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case raw := <-client.Send:
+			var frame struct {
+				Type string `json:"type"`
+				Code string `json:"code"`
+			}
+			if err := json.Unmarshal(raw, &frame); err == nil && frame.Type == "error" {
+				return frame.Code
+			}
+		case <-timeout:
+			t.Fatal("no error frame")
+			return ""
+		}
+	}
+}
@@ -3,46 +3,78 @@ package services
 import (
 	"context"
 	"errors"
-	"log"
 	"real-time-forum/models"
 	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
 	"strings"
+	"time"
 
 	"github.com/gofrs/uuid"
 )
 
-type CommentsService struct {
-	repo repositories.CommentRepository
+type commentsService struct {
+	repo                repositories.CommentRepository
+	contentFilter       ContentFilterService
+	notificationService NotificationService
+	mentionService      MentionService
+	feedService         FeedService
 }
 
-func NewCommentsService(repo repositories.CommentRepository) *CommentsService {
-	return &CommentsService{repo: repo}
+func NewCommentsService(repo repositories.CommentRepository, contentFilter ContentFilterService, notificationService NotificationService, mentionService MentionService, feedService FeedService) CommentsService {
+	return &commentsService{repo: repo, contentFilter: contentFilter, notificationService: notificationService, mentionService: mentionService, feedService: feedService}
 }
 
-func (s *CommentsService) CreateComment(ctx context.Context, comment *models.Comment) error {
+func (s *commentsService) CreateComment(ctx context.Context, comment *models.Comment) error {
 	if strings.TrimSpace(comment.Content) == "" {
-		log.Printf("CreateComment: comment cannot be empty")
-		return errors.New("comment cannot be empty")
+		utils.Logger(ctx).Warn("CreateComment: comment cannot be empty")
+		return invalid("comment cannot be empty")
 	}
+
+	screening, err := s.contentFilter.Screen(ctx, &FilterContent{
+		Type:     models.ReportTypeComment,
+		AuthorID: comment.AuthorID,
+		Body:     comment.Content,
+	})
+	if err != nil {
+		return err
+	}
+
 	u1, err := uuid.NewV4()
 	if err != nil {
-		log.Printf("CreateComment: failed to generate comment ID: %v", err)
+		utils.Logger(ctx).Error("CreateComment: failed to generate comment ID", "err", err)
 		return errors.New("failed to generate comment ID")
 	}
 
 	comment.ID = u1.String()
+	comment.CreatedAt = time.Now()
 	if err := s.repo.CreateComment(ctx, comment); err != nil {
-		log.Printf("CreateComment: failed to create comment: %v", err)
+		utils.Logger(ctx).Error("CreateComment: failed to create comment", "err", err)
 		return errors.New("failed to create comment")
 	}
+	s.contentFilter.Flag(ctx, screening, models.ReportTypeComment, comment.ID)
+	author := &models.User{ID: comment.AuthorID, Nickname: comment.AuthorName}
+	comment.Mentions = s.mentionService.Resolve(ctx, author, comment.Content)
+	s.mentionService.Record(ctx, author, models.ReportTypeComment, comment.ID, comment.PostID, comment.ID, comment.Content, comment.Mentions, true)
+	s.notificationService.CommentCreated(ctx, comment)
+	comment.ContentHTML = RenderMarkdown(comment.Content)
+	s.feedService.CommentCreated(comment)
 	return nil
 }
 
-func (s *CommentsService) GetPostComments(ctx context.Context, postID string) ([]models.Comment, error) {
+func (s *commentsService) GetPostComments(ctx context.Context, postID string) ([]models.Comment, error) {
 	postComments, err := s.repo.GetPostComments(ctx, postID)
 	if err != nil {
-		log.Printf("GetPostComments: failed to retrieve comments for post %s: %v", postID, err)
+		utils.Logger(ctx).Error("GetPostComments: failed to retrieve comments for post", "post_id", postID, "err", err)
 		return nil, errors.New("failed to retrieve comments")
 	}
+	ids := make([]string, len(postComments))
+	for i := range postComments {
+		postComments[i].ContentHTML = RenderMarkdown(postComments[i].Content)
+		ids[i] = postComments[i].ID
+	}
+	mentions := s.mentionService.MentionsFor(ctx, models.ReportTypeComment, ids)
+	for i := range postComments {
+		postComments[i].Mentions = mentions[postComments[i].ID]
+	}
 	return postComments, nil
 }
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"real-time-forum/utils"
+	"strings"
+)
+
+// ErrContentRejected matches every *ContentRejectedError
+var ErrContentRejected = errors.New("content rejected")
+
+// FilterVerdict is the outcome of running content through a filter
+type FilterVerdict int
+
+const (
+	FilterAllow FilterVerdict = iota
+	FilterFlag
+	FilterReject
+)
+
+// FilterContent is the text a user is about to publish. Type is one of the
+// models.ReportType values and posts also carry a title. Chat messages only
+// know their author by nickname, so AuthorID holds the nickname for them.
+type FilterContent struct {
+	Type     string
+	AuthorID string
+	Title    string
+	Body     string
+}
+
+type FilterResult struct {
+	Verdict FilterVerdict
+	Filter  string
+	Reason  string
+}
+
+// ContentFilter is one step of the filter chain
+type ContentFilter interface {
+	Name() string
+	Check(ctx context.Context, content *FilterContent) FilterResult
+}
+
+// ContentRejectedError tells the author which filter refused their content
+// and why
+type ContentRejectedError struct {
+	Filter string
+	Reason string
+}
+
+func (e *ContentRejectedError) Error() string {
+	return "content rejected by " + e.Filter + ": " + e.Reason
+}
+
+func (e *ContentRejectedError) Is(target error) bool {
+	return target == ErrContentRejected
+}
+
+type contentFilterService struct {
+	filters           []ContentFilter
+	moderationService ModerationService
+}
+
+func NewContentFilterService(moderationService ModerationService, filters ...ContentFilter) ContentFilterService {
+	return &contentFilterService{
+		filters:           filters,
+		moderationService: moderationService,
+	}
+}
+
+// Screen runs content through every filter in order. The first rejection
+// stops the chain and comes back as a *ContentRejectedError. Flags are
+// collected into the returned result so the caller can pass it to Flag once
+// the content has been saved.
+func (s *contentFilterService) Screen(ctx context.Context, content *FilterContent) (FilterResult, error) {
+	var flagged FilterResult
+	var reasons []string
+
+	for _, filter := range s.filters {
+		result := filter.Check(ctx, content)
+		switch result.Verdict {
+		case FilterReject:
+			utils.Logger(ctx).Info("Screen: content rejected", "type", content.Type, "author_id", content.AuthorID, "filter", filter.Name(), "reason", result.Reason)
+			return result, &ContentRejectedError{Filter: filter.Name(), Reason: result.Reason}
+		case FilterFlag:
+			if flagged.Verdict != FilterFlag {
+				flagged = FilterResult{Verdict: FilterFlag, Filter: filter.Name()}
+			}
+			reasons = append(reasons, result.Reason)
+		}
+	}
+
+	flagged.Reason = strings.Join(reasons, "; ")
+	return flagged, nil
+}
+
+// Flag queues saved content for moderator review when Screen flagged it
+func (s *contentFilterService) Flag(ctx context.Context, result FilterResult, contentType, contentID string) {
+	if result.Verdict != FilterFlag {
+		return
+	}
+	if _, err := s.moderationService.FlagContent(ctx, contentType, contentID, "Automatic flag ("+result.Filter+"): "+result.Reason); err != nil {
+		utils.Logger(ctx).Error("Flag: failed to queue for review", "content_type", contentType, "content_id", contentID, "err", err)
+	}
+}
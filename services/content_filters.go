@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"real-time-forum/models"
+	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefaultRejectedWords and DefaultFlaggedWords seed the word-list filter
+var (
+	DefaultRejectedWords = []string{"cunt", "faggot", "nigger", "retard"}
+	DefaultFlaggedWords  = []string{"asshole", "bastard", "bitch", "bullshit", "dick", "fuck", "fucking", "motherfucker", "shit", "slut", "whore"}
+)
+
+// leetReplacer undoes the usual character swaps so "sh1t" and "$hit" match
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// WordListFilter rejects or flags content containing listed words. Words are
+// matched whole, case-insensitively and after undoing common character swaps.
+type WordListFilter struct {
+	rejected map[string]bool
+	flagged  map[string]bool
+}
+
+func NewWordListFilter(rejected, flagged []string) *WordListFilter {
+	f := &WordListFilter{rejected: map[string]bool{}, flagged: map[string]bool{}}
+	for _, word := range rejected {
+		f.rejected[strings.ToLower(word)] = true
+	}
+	for _, word := range flagged {
+		f.flagged[strings.ToLower(word)] = true
+	}
+	return f
+}
+
+func (f *WordListFilter) Name() string {
+	return "word_list"
+}
+
+func (f *WordListFilter) Check(ctx context.Context, content *FilterContent) FilterResult {
+	text := leetReplacer.Replace(strings.ToLower(content.Title + " " + content.Body))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var flagged []string
+	for _, word := range words {
+		if f.rejected[word] {
+			return FilterResult{Verdict: FilterReject, Reason: "contains language that is not allowed"}
+		}
+		if f.flagged[word] {
+			flagged = append(flagged, word)
+		}
+	}
+	if len(flagged) > 0 {
+		return FilterResult{Verdict: FilterFlag, Reason: "contains " + strings.Join(flagged, ", ")}
+	}
+	return FilterResult{Verdict: FilterAllow}
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// LinkLimitFilter flags content with more than flagAbove links and rejects
+// content with more than rejectAbove
+type LinkLimitFilter struct {
+	flagAbove   int
+	rejectAbove int
+}
+
+func NewLinkLimitFilter(flagAbove, rejectAbove int) *LinkLimitFilter {
+	return &LinkLimitFilter{flagAbove: flagAbove, rejectAbove: rejectAbove}
+}
+
+func (f *LinkLimitFilter) Name() string {
+	return "link_limit"
+}
+
+func (f *LinkLimitFilter) Check(ctx context.Context, content *FilterContent) FilterResult {
+	links := len(linkPattern.FindAllString(content.Title+" "+content.Body, -1))
+	switch {
+	case links > f.rejectAbove:
+		return FilterResult{Verdict: FilterReject, Reason: "contains more than " + strconv.Itoa(f.rejectAbove) + " links"}
+	case links > f.flagAbove:
+		return FilterResult{Verdict: FilterFlag, Reason: "contains " + strconv.Itoa(links) + " links"}
+	}
+	return FilterResult{Verdict: FilterAllow}
+}
+
+// RepeatedMessageFilter rejects comments and chat messages that repeat the
+// same text more than maxRepeats times within the window. It keeps its
+// history in memory, so it resets when the server restarts.
+type RepeatedMessageFilter struct {
+	window     time.Duration
+	maxRepeats int
+
+	mu   sync.Mutex
+	seen map[string][]time.Time
+	// now is the clock, swapped out in tests
+	now func() time.Time
+}
+
+func NewRepeatedMessageFilter(window time.Duration, maxRepeats int) *RepeatedMessageFilter {
+	return &RepeatedMessageFilter{
+		window:     window,
+		maxRepeats: maxRepeats,
+		seen:       make(map[string][]time.Time),
+		now:        time.Now,
+	}
+}
+
+func (f *RepeatedMessageFilter) Name() string {
+	return "repeated_message"
+}
+
+func (f *RepeatedMessageFilter) Check(ctx context.Context, content *FilterContent) FilterResult {
+	if content.Type == models.ReportTypePost {
+		return FilterResult{Verdict: FilterAllow}
+	}
+
+	normalized := strings.Join(strings.Fields(strings.ToLower(content.Body)), " ")
+	sum := sha256.Sum256([]byte(normalized))
+	key := content.AuthorID + ":" + content.Type + ":" + string(sum[:])
+
+	now := f.now()
+	cutoff := now.Add(-f.window)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Forget stale entries once the history grows so idle authors don't pile up
+	if len(f.seen) > 10000 {
+		for k, times := range f.seen {
+			if times[len(times)-1].Before(cutoff) {
+				delete(f.seen, k)
+			}
+		}
+	}
+
+	recent := f.seen[key][:0]
+	for _, t := range f.seen[key] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= f.maxRepeats {
+		f.seen[key] = recent
+		return FilterResult{Verdict: FilterReject, Reason: "you are repeating the same message, please slow down"}
+	}
+	f.seen[key] = append(recent, now)
+	return FilterResult{Verdict: FilterAllow}
+}
+
+// DuplicatePostFilter rejects posts that repeat one of the author's own
+// recent posts and flags posts copying someone else's recent content
+type DuplicatePostFilter struct {
+	repo   repositories.PostRepository
+	window time.Duration
+}
+
+func NewDuplicatePostFilter(repo repositories.PostRepository, window time.Duration) *DuplicatePostFilter {
+	return &DuplicatePostFilter{repo: repo, window: window}
+}
+
+func (f *DuplicatePostFilter) Name() string {
+	return "duplicate_post"
+}
+
+func (f *DuplicatePostFilter) Check(ctx context.Context, content *FilterContent) FilterResult {
+	if content.Type != models.ReportTypePost {
+		return FilterResult{Verdict: FilterAllow}
+	}
+
+	own, others, err := f.repo.CountRecentDuplicates(ctx, content.AuthorID, strings.TrimSpace(content.Title), strings.TrimSpace(content.Body), time.Now().Add(-f.window))
+	if err != nil {
+		// A broken duplicate check should not stop people from posting
+		utils.Logger(ctx).Error("DuplicatePostFilter: failed to look for duplicates", "err", err)
+		return FilterResult{Verdict: FilterAllow}
+	}
+	switch {
+	case own > 0:
+		return FilterResult{Verdict: FilterReject, Reason: "you already posted this recently"}
+	case others > 0:
+		return FilterResult{Verdict: FilterFlag, Reason: "same content as a recent post by another user"}
+	}
+	return FilterResult{Verdict: FilterAllow}
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"real-time-forum/models"
+	"real-time-forum/repositories"
+	"testing"
+	"time"
+)
+
+func TestWordListFilter(t *testing.T) {
+	f := NewWordListFilter([]string{"Badword"}, []string{"darn", "heck"})
+	cases := []struct {
+		name  string
+		title string
+		body  string
+		want  FilterVerdict
+	}{
+		{"clean", "Hello", "nothing to see", FilterAllow},
+		{"rejected", "", "what a badword", FilterReject},
+		{"case-insensitive", "BADWORD!", "", FilterReject},
+		{"leet", "", "b4dw0rd", FilterReject},
+		{"leet symbols", "", "d@rn it", FilterFlag},
+		{"whole words only", "", "badwords and darned hecks", FilterAllow},
+		{"inside punctuation", "", "(heck)", FilterFlag},
+		{"reject beats flag", "darn", "badword", FilterReject},
+	}
+	for _, c := range cases {
+		got := f.Check(context.Background(), &FilterContent{Type: models.ReportTypePost, Title: c.title, Body: c.body})
+		if got.Verdict != c.want {
+			t.Errorf("%s: verdict = %v (%s), want %v", c.name, got.Verdict, got.Reason, c.want)
+		}
+	}
+
+	got := f.Check(context.Background(), &FilterContent{Body: "darn, heck"})
+	if got.Reason != "contains darn, heck" {
+		t.Errorf("flag reason = %q", got.Reason)
+	}
+}
+
+func TestLinkLimitFilter(t *testing.T) {
+	f := NewLinkLimitFilter(1, 2)
+	cases := []struct {
+		body string
+		want FilterVerdict
+	}{
+		{"no links", FilterAllow},
+		{"see https://example.com", FilterAllow},
+		{"http://a.example and www.b.example", FilterFlag},
+		{"http://a.example https://b.example www.c.example", FilterReject},
+	}
+	for _, c := range cases {
+		if got := f.Check(context.Background(), &FilterContent{Body: c.body}); got.Verdict != c.want {
+			t.Errorf("%q: verdict = %v, want %v", c.body, got.Verdict, c.want)
+		}
+	}
+}
+
+func TestRepeatedMessageFilter(t *testing.T) {
+	f := NewRepeatedMessageFilter(time.Minute, 2)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	check := func(author, body string) FilterVerdict {
+		return f.Check(context.Background(), &FilterContent{Type: models.ReportTypeComment, AuthorID: author, Body: body}).Verdict
+	}
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		author  string
+		body    string
+		want    FilterVerdict
+	}{
+		{"first", 0, "u1", "hello there", FilterAllow},
+		{"second", time.Second, "u1", "Hello   THERE", FilterAllow},
+		{"third in window", time.Second, "u1", "hello there", FilterReject},
+		{"other author", 0, "u2", "hello there", FilterAllow},
+		{"other text", 0, "u1", "something else", FilterAllow},
+		{"window passed", time.Minute, "u1", "hello there", FilterAllow},
+	}
+	for _, s := range steps {
+		now = now.Add(s.advance)
+		if got := check(s.author, s.body); got != s.want {
+			t.Errorf("%s: verdict = %v, want %v", s.name, got, s.want)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		if got := f.Check(context.Background(), &FilterContent{Type: models.ReportTypePost, AuthorID: "u3", Body: "same"}); got.Verdict != FilterAllow {
+			t.Fatalf("post %d: verdict = %v, want posts left to the duplicate filter", i, got.Verdict)
+		}
+	}
+}
+
+// fakeDuplicatePosts answers CountRecentDuplicates with fixed counts
+type fakeDuplicatePosts struct {
+	repositories.PostRepository
+	own, others int
+	err         error
+}
+
+func (f *fakeDuplicatePosts) CountRecentDuplicates(ctx context.Context, authorID, title, content string, since time.Time) (int, int, error) {
+	return f.own, f.others, f.err
+}
+
+func TestDuplicatePostFilter(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		repo        *fakeDuplicatePosts
+		want        FilterVerdict
+	}{
+		{"new", models.ReportTypePost, &fakeDuplicatePosts{}, FilterAllow},
+		{"own repost", models.ReportTypePost, &fakeDuplicatePosts{own: 1, others: 1}, FilterReject},
+		{"copied", models.ReportTypePost, &fakeDuplicatePosts{others: 2}, FilterFlag},
+		{"lookup failed", models.ReportTypePost, &fakeDuplicatePosts{own: 1, err: errors.New("db down")}, FilterAllow},
+		{"comment", models.ReportTypeComment, &fakeDuplicatePosts{own: 1}, FilterAllow},
+	}
+	for _, c := range cases {
+		f := NewDuplicatePostFilter(c.repo, time.Hour)
+		got := f.Check(context.Background(), &FilterContent{Type: c.contentType, AuthorID: "u1", Title: "t", Body: "b"})
+		if got.Verdict != c.want {
+			t.Errorf("%s: verdict = %v, want %v", c.name, got.Verdict, c.want)
+		}
+	}
+}
+
+// stubFilter returns a fixed result and counts its calls
+type stubFilter struct {
+	name   string
+	result FilterResult
+	calls  int
+}
+
+func (f *stubFilter) Name() string { return f.name }
+
+func (f *stubFilter) Check(ctx context.Context, content *FilterContent) FilterResult {
+	f.calls++
+	return f.result
+}
+
+func TestScreen(t *testing.T) {
+	flagA := &stubFilter{name: "a", result: FilterResult{Verdict: FilterFlag, Reason: "reason a"}}
+	allow := &stubFilter{name: "b", result: FilterResult{Verdict: FilterAllow}}
+	flagC := &stubFilter{name: "c", result: FilterResult{Verdict: FilterFlag, Reason: "reason c"}}
+	reject := &stubFilter{name: "d", result: FilterResult{Verdict: FilterReject, Reason: "no"}}
+	after := &stubFilter{name: "e", result: FilterResult{Verdict: FilterReject, Reason: "later"}}
+
+	result, err := NewContentFilterService(nil, flagA, allow, flagC).Screen(context.Background(), &FilterContent{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != FilterFlag || result.Filter != "a" || result.Reason != "reason a; reason c" {
+		t.Errorf("flagged result = %+v", result)
+	}
+
+	_, err = NewContentFilterService(nil, flagA, reject, after).Screen(context.Background(), &FilterContent{})
+	var rejected *ContentRejectedError
+	if !errors.As(err, &rejected) || rejected.Filter != "d" || rejected.Reason != "no" {
+		t.Errorf("Screen error = %v, want the first rejection", err)
+	}
+	if !errors.Is(err, ErrContentRejected) {
+		t.Errorf("Screen error %v does not match ErrContentRejected", err)
+	}
+	if after.calls != 0 {
+		t.Errorf("filter after the rejection ran %d times", after.calls)
+	}
+
+	result, err = NewContentFilterService(nil, allow).Screen(context.Background(), &FilterContent{})
+	if err != nil || result.Verdict != FilterAllow {
+		t.Errorf("clean result = %+v, %v", result, err)
+	}
+}
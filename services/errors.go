@@ -0,0 +1,44 @@
+package services
+
+import "errors"
+
+// Error kinds. Every sentinel error a service returns wraps one of these, so
+// callers can tell what went wrong with errors.Is without knowing each
+// sentinel:
+//
+//	errors.Is(ErrCategoryNotFound, ErrNotFound) // true
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+	ErrForbidden  = errors.New("forbidden")
+)
+
+// kindError is a sentinel of one of the kinds above. Its message is meant
+// for the user.
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string { return e.msg }
+func (e *kindError) Unwrap() error { return e.kind }
+
+func notFound(msg string) error  { return &kindError{kind: ErrNotFound, msg: msg} }
+func conflict(msg string) error  { return &kindError{kind: ErrConflict, msg: msg} }
+func invalid(msg string) error   { return &kindError{kind: ErrValidation, msg: msg} }
+func forbidden(msg string) error { return &kindError{kind: ErrForbidden, msg: msg} }
+
+// ValidationError reports which input was rejected and why
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"encoding/json"
+	"log/slog"
+	"real-time-forum/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+var (
+	ErrInvalidTopic  = invalid("invalid topic")
+	ErrTooManyTopics = invalid("too many topic subscriptions")
+)
+
+const (
+	feedTopic         = "feed"
+	categoryTopicName = "category"
+	postTopicName     = "post"
+)
+
+// FeedService pushes new posts and comments to the WebSocket clients
+// subscribed to them. Clients subscribe to "feed" for every new post,
+// "category:{id}" for new posts in one category and "post:{id}" for new
+// comments on one post.
+type feedService struct {
+	hub *models.Hub
+}
+
+func NewFeedService(hub *models.Hub) FeedService {
+	return &feedService{hub: hub}
+}
+
+// Subscribe adds the client to a topic after checking its name
+func (s *feedService) Subscribe(client *models.Client, topic string) error {
+	if !validTopic(topic) {
+		return ErrInvalidTopic
+	}
+	if !s.hub.Subscribe(client, topic) {
+		return ErrTooManyTopics
+	}
+	return nil
+}
+
+// Unsubscribe removes the client from a topic
+func (s *feedService) Unsubscribe(client *models.Client, topic string) error {
+	if !validTopic(topic) {
+		return ErrInvalidTopic
+	}
+	s.hub.Unsubscribe(client, topic)
+	return nil
+}
+
+// PostCreated sends a post_created frame to the feed and to the topics of the
+// post's categories
+func (s *feedService) PostCreated(post *models.Post, categoryIDs []string) {
+	topics := []string{feedTopic}
+	for _, id := range categoryIDs {
+		topics = append(topics, categoryTopicName+":"+id)
+	}
+
+	s.publish(topics, map[string]interface{}{
+		"type":         "post_created",
+		"from":         "system",
+		"post":         post,
+		"category_ids": categoryIDs,
+		"timestamp":    time.Now(),
+	})
+}
+
+// CommentCreated sends a comment_created frame to the clients watching the
+// post
+func (s *feedService) CommentCreated(comment *models.Comment) {
+	s.publish([]string{postTopicName + ":" + comment.PostID}, map[string]interface{}{
+		"type":      "comment_created",
+		"from":      "system",
+		"post_id":   comment.PostID,
+		"comment":   comment,
+		"timestamp": time.Now(),
+	})
+}
+
+func (s *feedService) publish(topics []string, frame map[string]interface{}) {
+	messageBytes, err := json.Marshal(frame)
+	if err != nil {
+		slog.Error("Error marshaling frame", "type", frame["type"], "err", err)
+		return
+	}
+	s.hub.Publish(topics, messageBytes)
+}
+
+// validTopic accepts "feed", "category:{id}" with a numeric category ID and
+// "post:{id}" with a post UUID
+func validTopic(topic string) bool {
+	if topic == feedTopic {
+		return true
+	}
+
+	name, id, ok := strings.Cut(topic, ":")
+	if !ok {
+		return false
+	}
+	switch name {
+	case categoryTopicName:
+		n, err := strconv.Atoi(id)
+		return err == nil && n > 0 && strconv.Itoa(n) == id
+	case postTopicName:
+		parsed, err := uuid.FromString(id)
+		return err == nil && parsed.String() == id
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bytes"
+	"log/slog"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Raw HTML in the source is shown as text rather than dropped, as goldmark
+// would by default, and the output is passed through a strict allowlist as a
+// second line of defence.
+var markdownRenderer = goldmark.New(
+	goldmark.WithExtensions(extension.Strikethrough, extension.Linkify),
+	goldmark.WithRendererOptions(renderer.WithNodeRenderers(
+		// Goldmark's own HTML renderer has priority 1000; lower wins
+		util.Prioritized(escapedHTMLRenderer{}, 100),
+	)),
+)
+
+// escapedHTMLRenderer renders inline and block HTML as escaped text
+type escapedHTMLRenderer struct{}
+
+func (escapedHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindRawHTML, renderRawHTML)
+	reg.Register(ast.KindHTMLBlock, renderHTMLBlock)
+}
+
+func renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	segments := node.(*ast.RawHTML).Segments
+	for i := 0; i < segments.Len(); i++ {
+		segment := segments.At(i)
+		w.Write(util.EscapeHTML(segment.Value(source)))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := node.(*ast.HTMLBlock)
+	var text []byte
+	for i := 0; i < block.Lines().Len(); i++ {
+		line := block.Lines().At(i)
+		text = append(text, line.Value(source)...)
+	}
+	if block.HasClosure() {
+		text = append(text, block.ClosureLine.Value(source)...)
+	}
+	w.WriteString("<p>")
+	w.Write(util.EscapeHTML(bytes.TrimRight(text, "\n")))
+	w.WriteString("</p>\n")
+	return ast.WalkContinue, nil
+}
+
+var markdownPolicy = newMarkdownPolicy()
+
+func newMarkdownPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements(
+		"p", "br", "hr",
+		"strong", "em", "del", "code", "pre", "blockquote",
+		"ul", "ol", "li",
+		"h1", "h2", "h3", "h4", "h5", "h6",
+	)
+	p.AllowAttrs("start").Matching(regexp.MustCompile(`^[0-9]+$`)).OnElements("ol")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[a-zA-Z0-9_+-]+$`)).OnElements("code")
+
+	// Links may only point to web or mail addresses and never carry referrer
+	// or opener information to the target
+	p.AllowAttrs("href", "title").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowRelativeURLs(true)
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return p
+}
+
+// RenderMarkdown converts Markdown source into sanitized HTML that is safe to
+// insert into a page.
+func RenderMarkdown(source string) string {
+	var buf bytes.Buffer
+	if err := markdownRenderer.Convert([]byte(source), &buf); err != nil {
+		slog.Error("RenderMarkdown: failed to convert markdown", "err", err)
+		return markdownPolicy.Sanitize(source)
+	}
+	return markdownPolicy.Sanitize(buf.String())
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// xssCorpus holds Markdown and HTML payloads that must never produce
+// executable markup once rendered
+var xssCorpus = []string{
+	`<script>alert(1)</script>`,
+	`<SCRIPT SRC=//evil.example/xss.js></SCRIPT>`,
+	`<img src=x onerror=alert(1)>`,
+	`<svg onload=alert(1)>`,
+	`<svg><script>alert(1)</script></svg>`,
+	`<iframe src="javascript:alert(1)"></iframe>`,
+	`<body onload=alert(1)>`,
+	`<a href="javascript:alert(1)">click</a>`,
+	`<div style="background:url(javascript:alert(1))">x</div>`,
+	`<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>`,
+	`<object data="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg=="></object>`,
+	`<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`,
+	`<form action="javascript:alert(1)"><input type=submit></form>`,
+	`<details open ontoggle=alert(1)>`,
+	`[click](javascript:alert(1))`,
+	`[click](JaVaScRiPt:alert(1))`,
+	`[click](javascript&#58;alert(1))`,
+	`[click](  javascript:alert(1))`,
+	`[click](vbscript:msgbox(1))`,
+	`[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)`,
+	`[click](" onmouseover="alert(1))`,
+	`[click](https://example.com "title\" onmouseover=\"alert(1)")`,
+	`![x](x" onerror="alert(1))`,
+	`![x](javascript:alert(1))`,
+	`[ref]: javascript:alert(1)` + "\n\n[ref]",
+	`<javascript:alert(1)>`,
+	"```html\n<script>alert(1)</script>\n```",
+	"`<script>alert(1)</script>`",
+	`**<img src=x onerror=alert(1)>**`,
+	`> <script>alert(1)</script>`,
+	`<<script>script>alert(1)<</script>/script>`,
+	`<scr<script>ipt>alert(1)</script>`,
+	`&lt;script&gt;alert(1)&lt;/script&gt;`,
+	`<a href="&#x6A;avascript:alert(1)">x</a>`,
+	"<a href=\"java\tscript:alert(1)\">x</a>",
+	`<base href="javascript:alert(1)//">`,
+	`<link rel=stylesheet href=//evil.example/x.css>`,
+	`<style>*{background:url(javascript:alert(1))}</style>`,
+	`<!--<img src="--><img src=x onerror=alert(1)//">`,
+	`<noscript><p title="</noscript><img src=x onerror=alert(1)>">`,
+}
+
+var allowedTags = map[string]bool{
+	"p": true, "br": true, "hr": true, "a": true,
+	"strong": true, "em": true, "del": true, "code": true, "pre": true, "blockquote": true,
+	"ul": true, "ol": true, "li": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+var allowedAttrs = map[string]bool{
+	"href": true, "title": true, "rel": true, "target": true, "class": true, "start": true,
+}
+
+// assertInert fails the test if the rendered HTML contains any element,
+// attribute or link scheme outside the allowlist
+func assertInert(t *testing.T, payload, out string) {
+	t.Helper()
+
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				t.Errorf("payload %q: failed to tokenize output %q: %v", payload, out, z.Err())
+			}
+			return
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		tok := z.Token()
+		if !allowedTags[tok.Data] {
+			t.Errorf("payload %q rendered a <%s> element: %q", payload, tok.Data, out)
+		}
+		for _, attr := range tok.Attr {
+			if !allowedAttrs[attr.Key] {
+				t.Errorf("payload %q rendered a %s attribute: %q", payload, attr.Key, out)
+			}
+			if attr.Key != "href" {
+				continue
+			}
+			u, err := url.Parse(attr.Val)
+			if err != nil {
+				t.Errorf("payload %q rendered an unparseable href %q", payload, attr.Val)
+				continue
+			}
+			switch strings.ToLower(u.Scheme) {
+			case "", "http", "https", "mailto":
+			default:
+				t.Errorf("payload %q rendered a %s: link: %q", payload, u.Scheme, out)
+			}
+		}
+	}
+}
+
+func TestRenderMarkdownNeutralisesXSS(t *testing.T) {
+	for _, payload := range xssCorpus {
+		assertInert(t, payload, RenderMarkdown(payload))
+	}
+}
+
+func TestRenderMarkdownFormatting(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"emphasis", "*hi* **there**", "<p><em>hi</em> <strong>there</strong></p>\n"},
+		{"strikethrough", "~~gone~~", "<p><del>gone</del></p>\n"},
+		{"code block", "```go\nx := 1\n```", "<pre><code class=\"language-go\">x := 1\n</code></pre>\n"},
+		{"list", "- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"escaped html", "1 < 2 & <b>", "<p>1 &lt; 2 &amp; &lt;b&gt;</p>\n"},
+		{"escaped html block", "<div>\nhi\n</div>", "<p>&lt;div&gt;\nhi\n&lt;/div&gt;</p>\n"},
+		{"escaped comment", "a <!-- note --> b", "<p>a &lt;!-- note --&gt; b</p>\n"},
+		{
+			"external link",
+			"[site](https://example.com)",
+			`<p><a href="https://example.com" rel="nofollow noreferrer noopener" target="_blank">site</a></p>` + "\n",
+		},
+		{
+			"relative link",
+			"[post](/post?id=1)",
+			`<p><a href="/post?id=1" rel="nofollow noreferrer">post</a></p>` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderMarkdown(tt.source); got != tt.want {
+				t.Errorf("RenderMarkdown(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"real-time-forum/utils"
+	"regexp"
+)
+
+const (
+	MaxImageSize      = 5 << 20
+	MaxImageDimension = 4096
+	MediaURLPrefix    = "/media/"
+)
+
+var (
+	ErrImageTooLarge        = invalid("image must be at most 5 MB")
+	ErrUnsupportedImageType = invalid("image must be a JPEG, PNG or GIF")
+	ErrImageDimensions      = invalid("image must be at most 4096x4096 pixels")
+	ErrInvalidImage         = invalid("invalid image")
+)
+
+// Stored images are named after the SHA-256 of their (stripped) bytes and
+// sharded by the first two hex digits, e.g. "ab/ab12...ef.jpg"
+var mediaKeyPattern = regexp.MustCompile(`^[0-9a-f]{2}/[0-9a-f]{64}\.(jpg|png|gif)$`)
+
+var imageExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+type mediaService struct {
+	dir string
+}
+
+func NewMediaService(dir string) MediaService {
+	return &mediaService{dir: dir}
+}
+
+// SaveImage validates an uploaded image, strips its metadata and stores it
+// under a content-addressed path. It returns the storage key of the file, and
+// whether this upload created it rather than matching an image already
+// stored.
+func (s *mediaService) SaveImage(ctx context.Context, r io.Reader) (string, bool, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxImageSize+1))
+	if err != nil {
+		utils.Logger(ctx).Error("SaveImage: failed to read upload", "err", err)
+		return "", false, ErrInvalidImage
+	}
+	if len(data) > MaxImageSize {
+		utils.Logger(ctx).Warn("SaveImage: upload too large", "limit", MaxImageSize)
+		return "", false, ErrImageTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		utils.Logger(ctx).Warn("SaveImage: rejected content type", "content_type", contentType)
+		return "", false, ErrUnsupportedImageType
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || imageExtensions["image/"+format] != ext {
+		utils.Logger(ctx).Error("SaveImage: failed to decode image", "content_type", contentType, "err", err)
+		return "", false, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > MaxImageDimension || cfg.Height > MaxImageDimension {
+		utils.Logger(ctx).Warn("SaveImage: rejected image size", "width", cfg.Width, "height", cfg.Height)
+		return "", false, ErrImageDimensions
+	}
+
+	var stripped []byte
+	switch ext {
+	case "jpg":
+		stripped, err = stripJPEGMetadata(data)
+	case "png":
+		stripped, err = stripPNGMetadata(data)
+	case "gif":
+		stripped, err = stripGIFMetadata(data)
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("SaveImage: failed to strip metadata", "err", err)
+		return "", false, ErrInvalidImage
+	}
+
+	sum := sha256.Sum256(stripped)
+	hash := hex.EncodeToString(sum[:])
+	key := hash[:2] + "/" + hash + "." + ext
+
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if _, err := os.Stat(path); err == nil {
+		// Same content already stored
+		return key, false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		utils.Logger(ctx).Error("SaveImage: failed to create media directory", "err", err)
+		return "", false, errors.New("failed to store image")
+	}
+
+	// Write to a temporary file first so readers never see a partial image
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		utils.Logger(ctx).Error("SaveImage: failed to create temp file", "err", err)
+		return "", false, errors.New("failed to store image")
+	}
+	if _, err := tmp.Write(stripped); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		utils.Logger(ctx).Error("SaveImage: failed to write image", "err", err)
+		return "", false, errors.New("failed to store image")
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		utils.Logger(ctx).Error("SaveImage: failed to close image", "err", err)
+		return "", false, errors.New("failed to store image")
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		utils.Logger(ctx).Error("SaveImage: failed to move image into place", "err", err)
+		return "", false, errors.New("failed to store image")
+	}
+
+	return key, true, nil
+}
+
+// RemoveImage deletes a stored image, e.g. when the post it was uploaded
+// with could not be created
+func (s *mediaService) RemoveImage(ctx context.Context, key string) {
+	path, ok := s.MediaPath(key)
+	if !ok {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		utils.Logger(ctx).Error("RemoveImage: failed to remove image", "key", key, "err", err)
+	}
+}
+
+// MediaPath resolves a storage key to a file on disk. It returns false for
+// anything that is not a well-formed key.
+func (s *mediaService) MediaPath(key string) (string, bool) {
+	if !mediaKeyPattern.MatchString(key) {
+		return "", false
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), true
+}
+
+// MediaURL returns the public URL of a stored image, or "" when there is none
+func MediaURL(key string) string {
+	if key == "" {
+		return ""
+	}
+	return MediaURLPrefix + key
+}
+
+// stripJPEGMetadata drops APP1 (EXIF, XMP), APP13 (IPTC) and comment segments
+// while keeping JFIF, ICC profiles and the image data untouched.
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("missing JPEG SOI marker")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG marker at offset %d", i)
+		}
+		marker := data[i+1]
+
+		// Fill bytes may pad any marker
+		if marker == 0xFF {
+			i++
+			continue
+		}
+
+		// Start of scan: the rest is entropy-coded data
+		if marker == 0xDA {
+			out.Write(data[i:])
+			return out.Bytes(), nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errors.New("truncated JPEG segment")
+		}
+
+		if marker != 0xE1 && marker != 0xED && marker != 0xFE {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return nil, errors.New("JPEG has no image data")
+}
+
+// stripPNGMetadata drops textual, EXIF and timestamp chunks
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+	if !bytes.HasPrefix(data, signature) {
+		return nil, errors.New("missing PNG signature")
+	}
+
+	dropped := map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(signature)
+
+	i := len(signature)
+	for i+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		chunkType := string(data[i+4 : i+8])
+		end := i + 12 + length // length, type, data, crc
+		if length < 0 || end > len(data) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+
+		if !dropped[chunkType] {
+			out.Write(data[i:end])
+		}
+		i = end
+
+		if chunkType == "IEND" {
+			return out.Bytes(), nil
+		}
+	}
+	return nil, errors.New("PNG has no IEND chunk")
+}
+
+// stripGIFMetadata drops comment extensions and application extensions such
+// as embedded XMP, keeping the NETSCAPE2.0/ANIMEXTS1.0 loop count. It walks
+// the blocks without decoding any frame, so a GIF with many huge frames
+// costs no more memory than its size.
+func stripGIFMetadata(data []byte) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, errors.New("missing GIF header")
+	}
+
+	// Header, logical screen descriptor and global color table
+	i := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << (flags&0x07 + 1)
+	}
+	if i > len(data) {
+		return nil, errors.New("truncated GIF color table")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:i])
+
+	for i < len(data) {
+		start := i
+		switch data[i] {
+		case 0x3B: // trailer
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+
+		case 0x21: // extension: label, then data sub-blocks
+			if i+2 > len(data) {
+				return nil, errors.New("truncated GIF extension")
+			}
+			label := data[i+1]
+			end, err := skipGIFSubBlocks(data, i+2)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			if label == 0xFE || (label == 0xFF && !isGIFLoopExtension(data[start+2:end])) {
+				continue
+			}
+
+		case 0x2C: // image descriptor, local color table, LZW code size, data
+			if i+10 > len(data) {
+				return nil, errors.New("truncated GIF image descriptor")
+			}
+			i += 10
+			if flags := data[i-1]; flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			end, err := skipGIFSubBlocks(data, i+1)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		default:
+			return nil, fmt.Errorf("invalid GIF block 0x%02x at offset %d", data[i], i)
+		}
+		out.Write(data[start:i])
+	}
+	return nil, errors.New("GIF has no trailer")
+}
+
+// skipGIFSubBlocks returns the offset just past the sub-blocks starting at i,
+// each a length byte followed by that many bytes, ended by an empty one
+func skipGIFSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, errors.New("truncated GIF data")
+		}
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i, nil
+		}
+		i += size
+	}
+}
+
+// isGIFLoopExtension reports whether application extension sub-blocks carry
+// the animation loop count
+func isGIFLoopExtension(blocks []byte) bool {
+	if len(blocks) < 12 || blocks[0] != 11 {
+		return false
+	}
+	id := string(blocks[1:12])
+	return id == "NETSCAPE2.0" || id == "ANIMEXTS1.0"
+}
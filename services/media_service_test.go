@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"testing"
+)
+
+// testGIF encodes a two-frame GIF and splices a comment and an XMP
+// application extension in front of the first frame
+func testGIF(t *testing.T) []byte {
+	t.Helper()
+	pal := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{
+		Image:     []*image.Paletted{image.NewPaletted(image.Rect(0, 0, 4, 4), pal), image.NewPaletted(image.Rect(0, 0, 4, 4), pal)},
+		Delay:     []int{10, 10},
+		LoopCount: 0,
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// The encoder writes the NETSCAPE2.0 extension first; insert ours after it
+	at := bytes.Index(data, []byte("NETSCAPE2.0"))
+	at, err := skipGIFSubBlocks(data, at-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := append([]byte{0x21, 0xFE, 6}, "secret"...)
+	comment = append(comment, 0)
+	xmp := append([]byte{0x21, 0xFF, 11}, "XMP DataXMP"...)
+	xmp = append(xmp, 4, 'g', 'p', 's', '!', 0)
+
+	out := append([]byte{}, data[:at]...)
+	out = append(out, comment...)
+	out = append(out, xmp...)
+	return append(out, data[at:]...)
+}
+
+func TestStripGIFMetadata(t *testing.T) {
+	data := testGIF(t)
+
+	stripped, err := stripGIFMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"secret", "XMP DataXMP", "gps!"} {
+		if bytes.Contains(stripped, []byte(leak)) {
+			t.Errorf("stripped GIF still contains %q", leak)
+		}
+	}
+	if !bytes.Contains(stripped, []byte("NETSCAPE2.0")) {
+		t.Error("loop extension was dropped")
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("stripped GIF does not decode: %v", err)
+	}
+	if len(anim.Image) != 2 {
+		t.Errorf("frames = %d, want 2", len(anim.Image))
+	}
+}
+
+func TestStripGIFMetadataTruncated(t *testing.T) {
+	data := testGIF(t)
+	for _, n := range []int{5, 13, len(data) / 2, len(data) - 1} {
+		if _, err := stripGIFMetadata(data[:n]); err == nil {
+			t.Errorf("truncated to %d bytes: expected an error", n)
+		}
+	}
+}
+
+func TestSaveImageCreatedAndRemove(t *testing.T) {
+	svc := NewMediaService(t.TempDir())
+	data := testGIF(t)
+	ctx := context.Background()
+
+	key, created, err := svc.SaveImage(ctx, bytes.NewReader(data))
+	if err != nil || !created {
+		t.Fatalf("SaveImage = %q, %v, %v", key, created, err)
+	}
+	if _, created, _ := svc.SaveImage(ctx, bytes.NewReader(data)); created {
+		t.Error("second upload of the same image reported created")
+	}
+
+	path, ok := svc.MediaPath(key)
+	if !ok {
+		t.Fatalf("MediaPath(%q) not found", key)
+	}
+	svc.RemoveImage(ctx, key)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("image still on disk after RemoveImage: %v", err)
+	}
+}
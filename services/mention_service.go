@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"real-time-forum/models"
+	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
+	"regexp"
+	"strings"
+)
+
+// MaxMentions caps how many users one piece of content can mention
+const MaxMentions = 10
+
+// mentionPattern finds @nickname tokens that are not part of a word or an
+// email address
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([\p{L}\p{N}_.\-]+)`)
+
+type mentionService struct {
+	repo                repositories.MentionRepository
+	notificationService NotificationService
+}
+
+func NewMentionService(repo repositories.MentionRepository, notificationService NotificationService) MentionService {
+	return &mentionService{repo: repo, notificationService: notificationService}
+}
+
+// Resolve finds the users mentioned in text. Nicknames that don't exist, the
+// author and users with a block with the author are ignored.
+func (s *mentionService) Resolve(ctx context.Context, author *models.User, text string) []models.Mention {
+	nicknames := parseMentions(text)
+	if len(nicknames) == 0 {
+		return nil
+	}
+
+	mentions, err := s.repo.ResolveNicknames(ctx, author.ID, nicknames)
+	if err != nil {
+		utils.Logger(ctx).Error("Resolve: failed to resolve mentions", "author_id", author.ID, "err", err)
+		return nil
+	}
+	return mentions
+}
+
+// Record stores the mentions of saved content and, when notify is set, tells
+// the mentioned users. postID and commentID say where the mention was made.
+func (s *mentionService) Record(ctx context.Context, author *models.User, contentType, contentID, postID, commentID, text string, mentions []models.Mention, notify bool) {
+	if len(mentions) == 0 {
+		return
+	}
+
+	if err := s.repo.CreateMentions(ctx, contentType, contentID, author.ID, mentions); err != nil {
+		utils.Logger(ctx).Error("Record: failed to store mentions", "content_type", contentType, "content_id", contentID, "err", err)
+		return
+	}
+	if notify {
+		s.notificationService.Mentioned(ctx, author, mentions, postID, commentID, text)
+	}
+}
+
+// MentionsFor loads the stored mentions of several pieces of content of one
+// type, keyed by content ID
+func (s *mentionService) MentionsFor(ctx context.Context, contentType string, contentIDs []string) map[string][]models.Mention {
+	mentions, err := s.repo.GetMentions(ctx, contentType, contentIDs)
+	if err != nil {
+		utils.Logger(ctx).Error("MentionsFor: failed to load mentions", "content_type", contentType, "count", len(contentIDs), "err", err)
+		return map[string][]models.Mention{}
+	}
+	return mentions
+}
+
+// parseMentions returns the distinct nicknames mentioned in text, at most
+// MaxMentions of them
+func parseMentions(text string) []string {
+	seen := make(map[string]bool)
+	var nicknames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		// Trailing dots and dashes are sentence punctuation, not nickname
+		nickname := strings.TrimRight(match[1], ".-")
+		key := strings.ToLower(nickname)
+		if nickname == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		nicknames = append(nicknames, nickname)
+		if len(nicknames) == MaxMentions {
+			break
+		}
+	}
+	return nicknames
+}
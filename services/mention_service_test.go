@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"real-time-forum/models"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"none", "no mentions here", nil},
+		{"one", "hi @alice", []string{"alice"}},
+		{"start of text", "@alice hi", []string{"alice"}},
+		{"email", "mail bob@example.com or @carol", []string{"carol"}},
+		{"inside a word", "foo@bar and x_@baz", nil},
+		{"double at", "@@alice", nil},
+		{"trailing punctuation", "thanks @alice. and @bob-, @carol!", []string{"alice", "bob", "carol"}},
+		{"dots and dashes inside", "@jean-luc.p said so", []string{"jean-luc.p"}},
+		{"duplicates keep the first spelling", "@Alice @alice @ALICE", []string{"Alice"}},
+		{"unicode", "(@zoë) @日本", []string{"zoë", "日本"}},
+		{"bare at", "@ and @.", nil},
+	}
+	for _, c := range cases {
+		got := parseMentions(c.text)
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s: parseMentions(%q) = %q, want %q", c.name, c.text, got, c.want)
+		}
+	}
+}
+
+func TestParseMentionsCap(t *testing.T) {
+	var text []string
+	for i := 0; i < MaxMentions+5; i++ {
+		text = append(text, fmt.Sprintf("@user%d", i))
+	}
+	got := parseMentions(strings.Join(text, " "))
+	if len(got) != MaxMentions || got[MaxMentions-1] != fmt.Sprintf("user%d", MaxMentions-1) {
+		t.Errorf("parseMentions kept %v, want the first %d", got, MaxMentions)
+	}
+}
+
+func TestResolveMentions(t *testing.T) {
+	ctx := context.Background()
+	repos := openTestRepos(t)
+	createUsers(t, repos, "alice", "bobby", "carol", "dave")
+	mentions := NewMentionService(repos.Mentions, nil)
+
+	// carol blocked alice and alice blocked dave: neither gets mentioned
+	if err := repos.Blocks.Block(ctx, "id-carol", "id-alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Blocks.Block(ctx, "id-alice", "id-dave"); err != nil {
+		t.Fatal(err)
+	}
+
+	author := &models.User{ID: "id-alice", Nickname: "alice"}
+	resolved := mentions.Resolve(ctx, author, "@alice @Bobby @carol @dave @nobody")
+	var got []string
+	for _, mention := range resolved {
+		got = append(got, mention.UserID+":"+mention.Nickname)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != "id-bobby:bobby" {
+		t.Errorf("Resolve = %v, want only bobby", got)
+	}
+
+	if resolved := mentions.Resolve(ctx, author, "no one"); resolved != nil {
+		t.Errorf("Resolve without mentions = %v", resolved)
+	}
+}
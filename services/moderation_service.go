@@ -0,0 +1,445 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"real-time-forum/models"
+	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	MaxReportReasonLength = 500
+	MaxReportNoteLength   = 500
+)
+
+var (
+	ErrReportNotFound      = notFound("report not found")
+	ErrReportExists        = conflict("you have already reported this content")
+	ErrReportClaimed       = conflict("report is claimed by another moderator")
+	ErrReportResolved      = conflict("report is already resolved")
+	ErrInvalidReport       = invalid("invalid report")
+	ErrInvalidReportAction = invalid("action must be hide, delete, warn or dismiss")
+	ErrContentNotFound     = notFound("content not found")
+	ErrOwnContent          = invalid("you cannot report your own content")
+	ErrModerationForbidden = forbidden("not allowed to moderate")
+)
+
+type moderationService struct {
+	reportRepo   repositories.ReportRepository
+	postRepo     repositories.PostRepository
+	commentRepo  repositories.CommentRepository
+	messageRepo  repositories.MessageRepository
+	auditService AuditService
+	hub          *models.Hub
+}
+
+func NewModerationService(reportRepo repositories.ReportRepository, postRepo repositories.PostRepository, commentRepo repositories.CommentRepository, messageRepo repositories.MessageRepository, auditService AuditService, hub *models.Hub) ModerationService {
+	return &moderationService{
+		reportRepo:   reportRepo,
+		postRepo:     postRepo,
+		commentRepo:  commentRepo,
+		messageRepo:  messageRepo,
+		auditService: auditService,
+		hub:          hub,
+	}
+}
+
+// ReportContent files a report against a post, comment or chat message.
+// Chat messages can only be reported by one of the two participants.
+func (s *moderationService) ReportContent(ctx context.Context, reporter *models.User, contentType, contentID, reason string) (*models.Report, error) {
+	contentID = strings.TrimSpace(contentID)
+	reason = strings.TrimSpace(reason)
+	if !validReportType(contentType) || contentID == "" || reason == "" || utf8.RuneCountInString(reason) > MaxReportReasonLength {
+		return nil, ErrInvalidReport
+	}
+
+	content, err := s.reportRepo.GetContentAuthor(ctx, contentType, contentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrContentNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("ReportContent: failed to look up", "content_type", contentType, "content_id", contentID, "err", err)
+		return nil, errors.New("failed to create report")
+	}
+	if content.AuthorID == reporter.ID {
+		return nil, ErrOwnContent
+	}
+
+	if contentType == models.ReportTypeMessage {
+		participant, err := s.reportRepo.IsMessageParticipant(ctx, contentID, reporter.Nickname)
+		if err != nil {
+			utils.Logger(ctx).Error("ReportContent: failed to check participants of message", "content_id", contentID, "err", err)
+			return nil, errors.New("failed to create report")
+		}
+		if !participant {
+			return nil, ErrContentNotFound
+		}
+	}
+
+	exists, err := s.reportRepo.OpenReportExists(ctx, contentType, contentID, reporter.ID)
+	if err != nil {
+		utils.Logger(ctx).Error("ReportContent: failed to check for duplicate reports", "err", err)
+		return nil, errors.New("failed to create report")
+	}
+	if exists {
+		return nil, ErrReportExists
+	}
+
+	u1, err := uuid.NewV4()
+	if err != nil {
+		utils.Logger(ctx).Error("ReportContent: failed to generate report ID", "err", err)
+		return nil, errors.New("failed to generate report ID")
+	}
+
+	now := time.Now()
+	report := &models.Report{
+		ID:          u1.String(),
+		ContentType: contentType,
+		ContentID:   contentID,
+		AuthorID:    content.AuthorID,
+		ReporterID:  reporter.ID,
+		Reason:      reason,
+		Status:      models.ReportStatusOpen,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.reportRepo.CreateReport(ctx, report); err != nil {
+		utils.Logger(ctx).Error("ReportContent: failed to create report", "err", err)
+		return nil, errors.New("failed to create report")
+	}
+
+	return s.publish(ctx, "created", report.ID), nil
+}
+
+// FlagContent puts freshly published content in the moderator queue on behalf
+// of the content filter
+func (s *moderationService) FlagContent(ctx context.Context, contentType, contentID, reason string) (*models.Report, error) {
+	if runes := []rune(reason); len(runes) > MaxReportReasonLength {
+		reason = string(runes[:MaxReportReasonLength])
+	}
+
+	content, err := s.reportRepo.GetContentAuthor(ctx, contentType, contentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrContentNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("FlagContent: failed to look up", "content_type", contentType, "content_id", contentID, "err", err)
+		return nil, errors.New("failed to create report")
+	}
+
+	u1, err := uuid.NewV4()
+	if err != nil {
+		utils.Logger(ctx).Error("FlagContent: failed to generate report ID", "err", err)
+		return nil, errors.New("failed to generate report ID")
+	}
+
+	now := time.Now()
+	report := &models.Report{
+		ID:          u1.String(),
+		ContentType: contentType,
+		ContentID:   contentID,
+		AuthorID:    content.AuthorID,
+		Reason:      reason,
+		Status:      models.ReportStatusOpen,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.reportRepo.CreateReport(ctx, report); err != nil {
+		utils.Logger(ctx).Error("FlagContent: failed to create report", "err", err)
+		return nil, errors.New("failed to create report")
+	}
+
+	return s.publish(ctx, "created", report.ID), nil
+}
+
+func (s *moderationService) ListReports(ctx context.Context, filter models.ReportFilter) ([]models.Report, error) {
+	if filter.Status != "" && filter.Status != "all" && !validReportStatus(filter.Status) {
+		return nil, ErrInvalidReport
+	}
+	if filter.ContentType != "" && !validReportType(filter.ContentType) {
+		return nil, ErrInvalidReport
+	}
+
+	reports, err := s.reportRepo.ListReports(ctx, filter)
+	if err != nil {
+		utils.Logger(ctx).Error("ListReports: failed to fetch reports", "err", err)
+		return nil, errors.New("failed to fetch reports")
+	}
+	return reports, nil
+}
+
+func (s *moderationService) GetReport(ctx context.Context, reportID string) (*models.Report, error) {
+	report, err := s.reportRepo.GetReportByID(ctx, reportID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReportNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("GetReport: failed to fetch report", "report_id", reportID, "err", err)
+		return nil, errors.New("failed to fetch report")
+	}
+	return report, nil
+}
+
+// ClaimReport assigns an open report to the moderator so others know it is
+// being handled
+func (s *moderationService) ClaimReport(ctx context.Context, moderator *models.User, reportID string) (*models.Report, error) {
+	if !moderator.Can(models.PermModerate) {
+		return nil, ErrModerationForbidden
+	}
+
+	report, err := s.GetReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case report.Status == models.ReportStatusResolved:
+		return nil, ErrReportResolved
+	case report.Status == models.ReportStatusClaimed && report.ClaimedBy == moderator.ID:
+		return report, nil
+	case report.Status == models.ReportStatusClaimed:
+		return nil, ErrReportClaimed
+	}
+
+	if err := s.reportRepo.ClaimReport(ctx, reportID, moderator.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Someone else got there first
+			return nil, ErrReportClaimed
+		}
+		utils.Logger(ctx).Error("ClaimReport: failed to claim report", "report_id", reportID, "err", err)
+		return nil, errors.New("failed to claim report")
+	}
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     models.AuditReportClaimed,
+		TargetType: "report",
+		TargetID:   reportID,
+	})
+
+	return s.publish(ctx, "claimed", reportID), nil
+}
+
+// ResolveReport closes a report with one of the resolution actions: hide or
+// delete the content, warn its author, or dismiss the report. Hiding or
+// deleting content also closes every other report on it.
+func (s *moderationService) ResolveReport(ctx context.Context, moderator *models.User, reportID, action, note string) (*models.Report, error) {
+	if !moderator.Can(models.PermModerate) {
+		return nil, ErrModerationForbidden
+	}
+
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > MaxReportNoteLength {
+		return nil, ErrInvalidReport
+	}
+
+	report, err := s.GetReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	if report.Status == models.ReportStatusResolved {
+		return nil, ErrReportResolved
+	}
+	if report.Status == models.ReportStatusClaimed && report.ClaimedBy != moderator.ID {
+		return nil, ErrReportClaimed
+	}
+
+	switch action {
+	case models.ReportActionHide:
+		err = s.hideContent(ctx, report)
+	case models.ReportActionDelete:
+		err = s.deleteContent(ctx, moderator, report)
+	case models.ReportActionWarn:
+		err = s.warnAuthor(ctx, moderator, report, note)
+	case models.ReportActionDismiss:
+	default:
+		return nil, ErrInvalidReportAction
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	allForContent := action == models.ReportActionHide || action == models.ReportActionDelete
+	if err := s.reportRepo.ResolveReport(ctx, report, moderator.ID, action, note, allForContent); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReportClaimed
+		}
+		utils.Logger(ctx).Error("ResolveReport: failed to resolve report", "report_id", reportID, "err", err)
+		return nil, errors.New("failed to resolve report")
+	}
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     models.AuditReportResolved,
+		TargetType: "report",
+		TargetID:   reportID,
+		Metadata: map[string]interface{}{
+			"action":       action,
+			"note":         note,
+			"content_type": report.ContentType,
+			"content_id":   report.ContentID,
+		},
+	})
+
+	return s.publish(ctx, "resolved", reportID), nil
+}
+
+func (s *moderationService) hideContent(ctx context.Context, report *models.Report) error {
+	var err error
+	switch report.ContentType {
+	case models.ReportTypePost:
+		err = s.postRepo.SetPostHidden(ctx, report.ContentID, true)
+	case models.ReportTypeComment:
+		err = s.commentRepo.SetCommentHidden(ctx, report.ContentID, true)
+	case models.ReportTypeMessage:
+		err = s.messageRepo.SetMessageHidden(ctx, report.ContentID, true)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrContentNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("hideContent: failed to hide", "content_type", report.ContentType, "content_id", report.ContentID, "err", err)
+		return errors.New("failed to hide content")
+	}
+	s.recordContentAction(ctx, models.AuditContentHidden, report)
+	return nil
+}
+
+func (s *moderationService) deleteContent(ctx context.Context, moderator *models.User, report *models.Report) error {
+	var err error
+	switch report.ContentType {
+	case models.ReportTypePost:
+		if !moderator.Can(models.PermDeleteAnyPost) {
+			return ErrModerationForbidden
+		}
+		err = s.postRepo.DeletePost(ctx, report.ContentID)
+	case models.ReportTypeComment:
+		if !moderator.Can(models.PermDeleteAnyComment) {
+			return ErrModerationForbidden
+		}
+		err = s.commentRepo.DeleteComment(ctx, report.ContentID)
+	case models.ReportTypeMessage:
+		err = s.messageRepo.DeleteMessage(ctx, report.ContentID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrContentNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("deleteContent: failed to delete", "content_type", report.ContentType, "content_id", report.ContentID, "err", err)
+		return errors.New("failed to delete content")
+	}
+	s.recordContentAction(ctx, models.AuditContentDeleted, report)
+	return nil
+}
+
+// warnAuthor records a warning against the author of the reported content
+// and tells them right away if they are online
+func (s *moderationService) warnAuthor(ctx context.Context, moderator *models.User, report *models.Report, note string) error {
+	u1, err := uuid.NewV4()
+	if err != nil {
+		utils.Logger(ctx).Error("warnAuthor: failed to generate warning ID", "err", err)
+		return errors.New("failed to generate warning ID")
+	}
+
+	reason := note
+	if reason == "" {
+		reason = report.Reason
+	}
+
+	warning := &models.Warning{
+		ID:          u1.String(),
+		UserID:      report.AuthorID,
+		ModeratorID: moderator.ID,
+		ReportID:    report.ID,
+		Reason:      reason,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.reportRepo.CreateWarning(ctx, warning); err != nil {
+		utils.Logger(ctx).Error("warnAuthor: failed to record warning for user", "author_id", report.AuthorID, "err", err)
+		return errors.New("failed to warn user")
+	}
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     models.AuditUserWarned,
+		TargetType: "user",
+		TargetID:   report.AuthorID,
+		Metadata: map[string]interface{}{
+			"nickname":  report.AuthorName,
+			"report_id": report.ID,
+			"reason":    reason,
+		},
+	})
+
+	messageBytes, err := json.Marshal(map[string]interface{}{
+		"type":         "warning",
+		"from":         "system",
+		"to":           report.AuthorName,
+		"content":      reason,
+		"content_type": report.ContentType,
+		"content_id":   report.ContentID,
+		"timestamp":    warning.CreatedAt,
+	})
+	if err != nil {
+		utils.Logger(ctx).Error("warnAuthor: failed to marshal warning", "err", err)
+		return nil
+	}
+	s.hub.SendToUser(report.AuthorName, messageBytes)
+	return nil
+}
+
+// recordContentAction audits a hide or delete of reported content
+func (s *moderationService) recordContentAction(ctx context.Context, action string, report *models.Report) {
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     action,
+		TargetType: report.ContentType,
+		TargetID:   report.ContentID,
+		Metadata: map[string]interface{}{
+			"report_id":   report.ID,
+			"author_id":   report.AuthorID,
+			"author_name": report.AuthorName,
+			"preview":     report.ContentPreview,
+		},
+	})
+}
+
+// publish reloads a report after a state change and pushes it to every
+// online moderator
+func (s *moderationService) publish(ctx context.Context, event, reportID string) *models.Report {
+	report, err := s.reportRepo.GetReportByID(ctx, reportID)
+	if err != nil {
+		utils.Logger(ctx).Error("publish: failed to reload report", "report_id", reportID, "err", err)
+		return &models.Report{ID: reportID}
+	}
+
+	messageBytes, err := json.Marshal(map[string]interface{}{
+		"type":      "report_" + event,
+		"from":      "system",
+		"to":        models.RoleModerator,
+		"report":    report,
+		"timestamp": time.Now(),
+	})
+	if err != nil {
+		utils.Logger(ctx).Error("publish: failed to marshal report update", "err", err)
+		return report
+	}
+	s.hub.SendToRole(models.RoleModerator, messageBytes)
+	return report
+}
+
+func validReportType(contentType string) bool {
+	switch contentType {
+	case models.ReportTypePost, models.ReportTypeComment, models.ReportTypeMessage:
+		return true
+	}
+	return false
+}
+
+func validReportStatus(status string) bool {
+	switch status {
+	case models.ReportStatusOpen, models.ReportStatusClaimed, models.ReportStatusResolved:
+		return true
+	}
+	return false
+}
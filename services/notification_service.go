@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"real-time-forum/models"
+	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+const notificationPreviewLength = 140
+
+type notificationService struct {
+	repo        repositories.NotificationRepository
+	pushService PushService
+	hub         *models.Hub
+}
+
+func NewNotificationService(repo repositories.NotificationRepository, pushService PushService, hub *models.Hub) NotificationService {
+	return &notificationService{repo: repo, pushService: pushService, hub: hub}
+}
+
+// CommentCreated tells the post author about a new comment and everyone else
+// in the thread about a new reply. Users mentioned in the comment already get
+// a mention notification and are skipped. Failures are only logged so they
+// never undo the comment.
+func (s *notificationService) CommentCreated(ctx context.Context, comment *models.Comment) {
+	var recipients []models.User
+	var types []string
+	mentioned := mentionedIDs(comment.Mentions)
+
+	author, err := s.repo.GetPostAuthorRecipient(ctx, comment.PostID, comment.AuthorID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.Logger(ctx).Error("CommentCreated: failed to look up author of post", "post_id", comment.PostID, "err", err)
+	}
+	if author != nil && !mentioned[author.ID] {
+		recipients = append(recipients, *author)
+		types = append(types, models.NotificationComment)
+	}
+
+	commenters, err := s.repo.GetCommenterRecipients(ctx, comment.PostID, comment.AuthorID)
+	if err != nil {
+		utils.Logger(ctx).Error("CommentCreated: failed to look up commenters of post", "post_id", comment.PostID, "err", err)
+	}
+	for _, commenter := range commenters {
+		if mentioned[commenter.ID] {
+			continue
+		}
+		recipients = append(recipients, commenter)
+		types = append(types, models.NotificationReply)
+	}
+
+	notifications := make([]models.Notification, len(recipients))
+	for i := range recipients {
+		notifications[i] = models.Notification{
+			Type:      types[i],
+			ActorID:   comment.AuthorID,
+			ActorName: comment.AuthorName,
+			PostID:    comment.PostID,
+			CommentID: comment.ID,
+			Preview:   notificationPreview(comment.Content),
+		}
+	}
+	s.deliver(ctx, recipients, notifications)
+}
+
+// PostCreated tells the subscribers of the post's categories about it,
+// except those mentioned in it
+func (s *notificationService) PostCreated(ctx context.Context, author *models.User, post *models.Post, categoryIDs []string) {
+	subscribers, err := s.repo.GetSubscriberRecipients(ctx, categoryIDs, author.ID)
+	if err != nil {
+		utils.Logger(ctx).Error("PostCreated: failed to look up subscribers", "err", err)
+		return
+	}
+
+	mentioned := mentionedIDs(post.Mentions)
+	var recipients []models.User
+	for _, subscriber := range subscribers {
+		if !mentioned[subscriber.ID] {
+			recipients = append(recipients, subscriber)
+		}
+	}
+
+	notifications := make([]models.Notification, len(recipients))
+	for i := range recipients {
+		notifications[i] = models.Notification{
+			Type:      models.NotificationCategoryPost,
+			ActorID:   author.ID,
+			ActorName: author.Nickname,
+			PostID:    post.ID,
+			PostTitle: post.Title,
+			Preview:   notificationPreview(post.Title),
+		}
+	}
+	s.deliver(ctx, recipients, notifications)
+}
+
+// Mentioned tells users they were mentioned. postID and commentID point at
+// where; both are empty for chat messages.
+func (s *notificationService) Mentioned(ctx context.Context, author *models.User, mentions []models.Mention, postID, commentID, content string) {
+	recipients := make([]models.User, len(mentions))
+	notifications := make([]models.Notification, len(mentions))
+	for i, mention := range mentions {
+		recipients[i] = models.User{ID: mention.UserID, Nickname: mention.Nickname}
+		notifications[i] = models.Notification{
+			Type:      models.NotificationMention,
+			ActorID:   author.ID,
+			ActorName: author.Nickname,
+			PostID:    postID,
+			CommentID: commentID,
+			Preview:   notificationPreview(content),
+		}
+	}
+	s.deliver(ctx, recipients, notifications)
+}
+
+// deliver stores one notification per recipient and sends each to its
+// recipient right away: over the hub when they are online, as a Web Push
+// message otherwise
+func (s *notificationService) deliver(ctx context.Context, recipients []models.User, notifications []models.Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for i := range notifications {
+		u1, err := uuid.NewV4()
+		if err != nil {
+			utils.Logger(ctx).Error("deliver: failed to generate notification ID", "err", err)
+			return
+		}
+		notifications[i].ID = u1.String()
+		notifications[i].UserID = recipients[i].ID
+		notifications[i].CreatedAt = now
+	}
+
+	if err := s.repo.CreateNotifications(ctx, notifications); err != nil {
+		utils.Logger(ctx).Error("deliver: failed to store notifications", "err", err)
+		return
+	}
+
+	for i, recipient := range recipients {
+		unread, err := s.repo.CountUnread(ctx, recipient.ID)
+		if err != nil {
+			utils.Logger(ctx).Error("deliver: failed to count unread notifications of user", "user_id", recipient.ID, "err", err)
+		}
+
+		messageBytes, err := json.Marshal(map[string]interface{}{
+			"type":         "notification",
+			"from":         "system",
+			"to":           recipient.Nickname,
+			"notification": notifications[i],
+			"unread":       unread,
+			"timestamp":    now,
+		})
+		if err != nil {
+			utils.Logger(ctx).Error("deliver: failed to marshal notification", "err", err)
+			continue
+		}
+		s.hub.SendToUser(recipient.Nickname, messageBytes)
+		s.pushService.NotifyOffline(recipient.Nickname, notificationPushMessage(notifications[i]))
+	}
+}
+
+// notificationPushMessage words a notification for the system tray
+func notificationPushMessage(n models.Notification) models.PushMessage {
+	var title string
+	switch n.Type {
+	case models.NotificationComment:
+		title = n.ActorName + " commented on your post"
+	case models.NotificationReply:
+		title = n.ActorName + " replied in a thread you joined"
+	case models.NotificationCategoryPost:
+		title = n.ActorName + " posted in a category you follow"
+	case models.NotificationMention:
+		title = n.ActorName + " mentioned you"
+	default:
+		title = "New notification from " + n.ActorName
+	}
+	return models.PushMessage{Title: title, Body: n.Preview, URL: "/", Tag: "notification:" + n.ID}
+}
+
+// ListNotifications returns a page of the user's notifications together with
+// their unread count
+func (s *notificationService) ListNotifications(ctx context.Context, userID string, unreadOnly bool, limit, offset int) ([]models.Notification, int, error) {
+	notifications, err := s.repo.ListNotifications(ctx, userID, unreadOnly, limit, offset)
+	if err != nil {
+		utils.Logger(ctx).Error("ListNotifications: failed to fetch notifications of user", "user_id", userID, "err", err)
+		return nil, 0, errors.New("failed to fetch notifications")
+	}
+
+	unread, err := s.repo.CountUnread(ctx, userID)
+	if err != nil {
+		utils.Logger(ctx).Error("ListNotifications: failed to count unread notifications of user", "user_id", userID, "err", err)
+		return nil, 0, errors.New("failed to fetch notifications")
+	}
+	return notifications, unread, nil
+}
+
+// MarkRead marks the given notifications as read, or all of them when no IDs
+// are given, and returns the remaining unread count
+func (s *notificationService) MarkRead(ctx context.Context, userID string, ids []string) (int64, int, error) {
+	updated, err := s.repo.MarkRead(ctx, userID, ids)
+	if err != nil {
+		utils.Logger(ctx).Error("MarkRead: failed to mark notifications of user as read", "user_id", userID, "err", err)
+		return 0, 0, errors.New("failed to update notifications")
+	}
+
+	unread, err := s.repo.CountUnread(ctx, userID)
+	if err != nil {
+		utils.Logger(ctx).Error("MarkRead: failed to count unread notifications of user", "user_id", userID, "err", err)
+		return 0, 0, errors.New("failed to update notifications")
+	}
+	return updated, unread, nil
+}
+
+func mentionedIDs(mentions []models.Mention) map[string]bool {
+	ids := make(map[string]bool, len(mentions))
+	for _, mention := range mentions {
+		ids[mention.UserID] = true
+	}
+	return ids
+}
+
+// notificationPreview shortens content to a single line for the notification
+// list
+func notificationPreview(content string) string {
+	preview := strings.Join(strings.Fields(content), " ")
+	if runes := []rune(preview); len(runes) > notificationPreviewLength {
+		preview = string(runes[:notificationPreviewLength-1]) + "…"
+	}
+	return preview
+}
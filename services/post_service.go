@@ -4,32 +4,48 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
 	"real-time-forum/models"
 	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
 	"strings"
 	"time"
 
 	"github.com/gofrs/uuid"
 )
 
-type PostService struct {
-	repo repositories.PostRepository
+var ErrPostNotFound = notFound("post not found")
+
+type postService struct {
+	repo                repositories.PostRepository
+	contentFilter       ContentFilterService
+	notificationService NotificationService
+	mentionService      MentionService
+	feedService         FeedService
 }
 
-func NewPostService(repo repositories.PostRepository) *PostService {
-	return &PostService{repo: repo}
+func NewPostService(repo repositories.PostRepository, contentFilter ContentFilterService, notificationService NotificationService, mentionService MentionService, feedService FeedService) PostService {
+	return &postService{repo: repo, contentFilter: contentFilter, notificationService: notificationService, mentionService: mentionService, feedService: feedService}
 }
 
-func (s *PostService) CreatePost(ctx context.Context, user *models.User, post *models.Post, cat []string) error {
+func (s *postService) CreatePost(ctx context.Context, user *models.User, post *models.Post, cat []string) error {
 	if strings.TrimSpace(post.Title) == "" || strings.TrimSpace(post.Content) == "" {
-		log.Printf("CreatePost: post title and content cannot be empty")
-		return errors.New("post title and content cannot be empty")
+		utils.Logger(ctx).Warn("CreatePost: post title and content cannot be empty")
+		return invalid("post title and content cannot be empty")
+	}
+
+	screening, err := s.contentFilter.Screen(ctx, &FilterContent{
+		Type:     models.ReportTypePost,
+		AuthorID: user.ID,
+		Title:    post.Title,
+		Body:     post.Content,
+	})
+	if err != nil {
+		return err
 	}
 
 	u1, err := uuid.NewV4()
 	if err != nil {
-		log.Printf("CreatePost: failed to generate post ID: %v", err)
+		utils.Logger(ctx).Error("CreatePost: failed to generate post ID", "err", err)
 		return errors.New("failed to generate post ID")
 	}
 
@@ -37,49 +53,82 @@ func (s *PostService) CreatePost(ctx context.Context, user *models.User, post *m
 	post.CreatedAt = time.Now()
 	err = s.repo.CreatePost(ctx, user, post, cat)
 	if err != nil {
-		log.Printf("CreatePost: failed to create post: %v", err)
+		utils.Logger(ctx).Error("CreatePost: failed to create post", "err", err)
 		return errors.New("failed to create post")
 	}
+	s.contentFilter.Flag(ctx, screening, models.ReportTypePost, post.ID)
+	post.Mentions = s.mentionService.Resolve(ctx, user, post.Content)
+	s.mentionService.Record(ctx, user, models.ReportTypePost, post.ID, post.ID, "", post.Content, post.Mentions, true)
+	s.notificationService.PostCreated(ctx, user, post, cat)
+	post.AuthorID = user.ID
+	post.AuthorName = user.Nickname
+	presentPost(post)
+	s.feedService.PostCreated(post, cat)
 	return nil
 }
 
-func (s *PostService) GetAllPosts(ctx context.Context) ([]models.Post, error) {
+func (s *postService) GetAllPosts(ctx context.Context) ([]models.Post, error) {
 	postsView, err := s.repo.GetAllPosts(ctx)
 	if err != nil {
-		log.Printf("GetAllPosts: failed to fetch posts: %v", err)
+		utils.Logger(ctx).Error("GetAllPosts: failed to fetch posts", "err", err)
 		return nil, errors.New("failed to fetch posts")
 	}
-	return postsView, nil
+	return presentPosts(postsView), nil
 }
 
-func (s *PostService) GetPostByID(ctx context.Context, postID string) (*models.Post, error) {
+func (s *postService) GetPostByID(ctx context.Context, postID string) (*models.Post, error) {
 	postView, err := s.repo.GetPostByID(ctx, postID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("GetPostByID: post with ID %s not found", postID)
-			return nil, errors.New("post not found")
+			utils.Logger(ctx).Debug("GetPostByID: post not found", "post_id", postID)
+			return nil, ErrPostNotFound
 		}
-		log.Printf("GetPostByID: failed to fetch post %s: %v", postID, err)
+		utils.Logger(ctx).Error("GetPostByID: failed to fetch post", "post_id", postID, "err", err)
 		return nil, errors.New("failed to fetch post")
 	}
+	presentPost(postView)
+	postView.Mentions = s.mentionService.MentionsFor(ctx, models.ReportTypePost, []string{postView.ID})[postView.ID]
 	return postView, nil
 }
 
-func (s *PostService) GetPostsByCategory(ctx context.Context, categoryID string) ([]models.Post, error) {
+func (s *postService) GetPostsByCategory(ctx context.Context, categoryID string) ([]models.Post, error) {
 	posts, err := s.repo.GetPostsByCategory(ctx, categoryID)
 	if err != nil {
-		log.Printf("GetPostsByCategory: failed to fetch posts by category %s: %v", categoryID, err)
+		utils.Logger(ctx).Error("GetPostsByCategory: failed to fetch posts by category", "category_id", categoryID, "err", err)
 		return nil, errors.New("failed to fetch posts by this category")
 	}
-	return posts, nil
+	return presentPosts(posts), nil
 }
 
-func (s *PostService) GetUserPosts(ctx context.Context, userID string) ([]models.Post, error) {
+func (s *postService) GetUserPosts(ctx context.Context, userID string) ([]models.Post, error) {
 	
 	posts, err := s.repo.GetUserPosts(ctx, userID)
 	if err != nil {
-		log.Printf("GetUserPosts: failed to fetch posts of user %s: %v", userID, err)
+		utils.Logger(ctx).Error("GetUserPosts: failed to fetch posts of user", "user_id", userID, "err", err)
 		return nil, errors.New("failed to fetch posts of this user")
 	}
-	return posts, nil
+	return presentPosts(posts), nil
+}
+
+func (s *postService) GetSubscribedPosts(ctx context.Context, userID string) ([]models.Post, error) {
+	posts, err := s.repo.GetSubscribedPosts(ctx, userID)
+	if err != nil {
+		utils.Logger(ctx).Error("GetSubscribedPosts: failed to fetch subscribed posts of user", "user_id", userID, "err", err)
+		return nil, errors.New("failed to fetch subscribed posts")
+	}
+	return presentPosts(posts), nil
+}
+
+// presentPost fills in the fields derived from what is stored: the public
+// image URL and the rendered HTML of the Markdown content
+func presentPost(post *models.Post) {
+	post.Image = MediaURL(post.Image)
+	post.ContentHTML = RenderMarkdown(post.Content)
+}
+
+func presentPosts(posts []models.Post) []models.Post {
+	for i := range posts {
+		presentPost(&posts[i])
+	}
+	return posts
 }
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdh"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"real-time-forum/models"
+	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+var (
+	ErrInvalidPushSubscription  = invalid("invalid subscription")
+	ErrPushSubscriptionNotFound = notFound("subscription not found")
+	ErrPushDisabled             = errors.New("push notifications are disabled")
+)
+
+const maxPushEndpointLength = 2048
+
+// pushServiceHosts are the push services browsers hand out endpoints on,
+// including their subdomains. Endpoints anywhere else are refused so users
+// cannot make the server post to hosts of their choosing.
+var pushServiceHosts = []string{
+	"fcm.googleapis.com",                // Chrome and other Chromium browsers
+	"updates.push.services.mozilla.com", // Firefox
+	"push.apple.com",                    // Safari
+	"notify.windows.com",                // Edge on Windows
+}
+
+// PushService reaches users who are not connected to the hub through the
+// browsers they registered for Web Push
+type pushService struct {
+	repo      repositories.PushRepository
+	sender    PushSender
+	hub       *models.Hub
+	publicKey string
+}
+
+// NewPushService returns a push service delivering through sender. With a
+// nil sender registrations are refused and nothing is sent.
+func NewPushService(repo repositories.PushRepository, sender PushSender, hub *models.Hub, publicKey string) PushService {
+	return &pushService{repo: repo, sender: sender, hub: hub, publicKey: publicKey}
+}
+
+// LoadVAPIDKeys returns the server's VAPID keys, generating and storing them
+// on first use
+func LoadVAPIDKeys(ctx context.Context, repo repositories.PushRepository) (*models.VAPIDKeys, error) {
+	keys, err := repo.GetVAPIDKeys(ctx)
+	if err == nil {
+		return keys, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	keys, err = GenerateVAPIDKeys()
+	if err != nil {
+		return nil, err
+	}
+	utils.Logger(ctx).Info("LoadVAPIDKeys: generated new VAPID keys")
+	return repo.SaveVAPIDKeys(ctx, keys)
+}
+
+// PublicKey is the VAPID key browsers need to subscribe
+func (s *pushService) PublicKey() (string, error) {
+	if s.sender == nil {
+		return "", ErrPushDisabled
+	}
+	return s.publicKey, nil
+}
+
+// Subscribe registers a browser of the user. Only https endpoints with a
+// valid P-256 key and 16-byte auth secret are accepted.
+func (s *pushService) Subscribe(ctx context.Context, userID string, sub *models.PushSubscription) error {
+	if s.sender == nil {
+		return ErrPushDisabled
+	}
+	if !validPushSubscription(sub) {
+		return ErrInvalidPushSubscription
+	}
+
+	u1, err := uuid.NewV4()
+	if err != nil {
+		utils.Logger(ctx).Error("Subscribe: failed to generate push subscription ID", "err", err)
+		return errors.New("failed to generate push subscription ID")
+	}
+	sub.ID = u1.String()
+	sub.UserID = userID
+	sub.CreatedAt = time.Now()
+
+	if err := s.repo.SaveSubscription(ctx, sub); err != nil {
+		utils.Logger(ctx).Error("Subscribe: failed to save push subscription of user", "user_id", userID, "err", err)
+		return errors.New("failed to save push subscription")
+	}
+	return nil
+}
+
+func (s *pushService) Unsubscribe(ctx context.Context, userID, endpoint string) error {
+	deleted, err := s.repo.DeleteSubscription(ctx, userID, endpoint)
+	if err != nil {
+		utils.Logger(ctx).Error("Unsubscribe: failed to delete push subscription of user", "user_id", userID, "err", err)
+		return errors.New("failed to delete push subscription")
+	}
+	if deleted == 0 {
+		return ErrPushSubscriptionNotFound
+	}
+	return nil
+}
+
+// NotifyOffline pushes the message to the user's browsers when they have no
+// live connection to the hub. Delivery happens in the background and
+// failures are only logged.
+func (s *pushService) NotifyOffline(nickname string, message models.PushMessage) {
+	if s.sender == nil || s.hub.IsOnline(nickname) {
+		return
+	}
+	go s.push(nickname, message)
+}
+
+func (s *pushService) push(nickname string, message models.PushMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	subs, err := s.repo.GetSubscriptionsByNickname(ctx, nickname)
+	if err != nil {
+		slog.Error("push: failed to fetch push subscriptions", "nickname", nickname, "err", err)
+		return
+	}
+	if len(subs) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		slog.Error("push: failed to marshal push message", "err", err)
+		return
+	}
+
+	for _, sub := range subs {
+		err := s.sender.Send(ctx, sub, payload)
+		switch {
+		case errors.Is(err, ErrPushSubscriptionGone):
+			if err := s.repo.DeleteEndpoint(ctx, sub.Endpoint); err != nil {
+				slog.Error("push: failed to drop expired push subscription", "subscription_id", sub.ID, "err", err)
+			}
+		case err != nil:
+			slog.Error("push: failed to push to subscription", "subscription_id", sub.ID, "nickname", nickname, "err", err)
+		}
+	}
+}
+
+func validPushSubscription(sub *models.PushSubscription) bool {
+	if len(sub.Endpoint) > maxPushEndpointLength {
+		return false
+	}
+	u, err := url.Parse(sub.Endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil || !knownPushHost(u.Hostname()) {
+		return false
+	}
+	if port := u.Port(); port != "" && port != "443" {
+		return false
+	}
+
+	key, err := decodePushKey(sub.P256dh)
+	if err != nil {
+		return false
+	}
+	if _, err := ecdh.P256().NewPublicKey(key); err != nil {
+		return false
+	}
+	auth, err := decodePushKey(sub.Auth)
+	return err == nil && len(auth) == 16
+}
+
+func knownPushHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, known := range pushServiceHosts {
+		if host == known || strings.HasSuffix(host, "."+known) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"real-time-forum/models"
+	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofrs/uuid"
+)
+
+const MaxSanctionReasonLength = 500
+
+var (
+	ErrSanctionNotFound = notFound("sanction not found or already revoked")
+	ErrInvalidSanction  = invalid("type must be ban, suspension (with a duration) or mute, with a reason of at most 500 characters")
+	ErrCannotSanction   = forbidden("you cannot sanction this user")
+)
+
+type sanctionService struct {
+	repo           repositories.SanctionRepository
+	userRepo       repositories.UserRepository
+	sessionService SessionService
+	auditService   AuditService
+	hub            *models.Hub
+}
+
+func NewSanctionService(repo repositories.SanctionRepository, userRepo repositories.UserRepository, sessionService SessionService, auditService AuditService, hub *models.Hub) SanctionService {
+	return &sanctionService{
+		repo:           repo,
+		userRepo:       userRepo,
+		sessionService: sessionService,
+		auditService:   auditService,
+		hub:            hub,
+	}
+}
+
+// SanctionUser bans, suspends or mutes the user with the given nickname or
+// email. Suspensions need a duration, mutes without one last until revoked
+// and bans are always permanent. Banned and suspended users are logged out
+// and disconnected right away.
+func (s *sanctionService) SanctionUser(ctx context.Context, moderator *models.User, identifier, sanctionType string, duration time.Duration, reason string) (*models.Sanction, error) {
+	if !moderator.Can(models.PermSanctionUsers) {
+		return nil, ErrModerationForbidden
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" || utf8.RuneCountInString(reason) > MaxSanctionReasonLength || duration < 0 {
+		return nil, ErrInvalidSanction
+	}
+
+	now := time.Now().UTC()
+	var expiresAt *time.Time
+	switch sanctionType {
+	case models.SanctionBan:
+		if duration != 0 {
+			return nil, ErrInvalidSanction
+		}
+	case models.SanctionSuspension:
+		if duration == 0 {
+			return nil, ErrInvalidSanction
+		}
+		until := now.Add(duration)
+		expiresAt = &until
+	case models.SanctionMute:
+		if duration != 0 {
+			until := now.Add(duration)
+			expiresAt = &until
+		}
+	default:
+		return nil, ErrInvalidSanction
+	}
+
+	identifier = strings.TrimSpace(identifier)
+	target, err := s.userRepo.GetUserByEmailorName(ctx, identifier, identifier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("SanctionUser: failed to retrieve user", "user", identifier, "err", err)
+		return nil, errors.New("failed to retrieve user")
+	}
+
+	// Moderators can only act on users below them, and nobody on themselves
+	if target.ID == moderator.ID || models.RoleAtLeast(target.Role, moderator.Role) {
+		return nil, ErrCannotSanction
+	}
+
+	u1, err := uuid.NewV4()
+	if err != nil {
+		utils.Logger(ctx).Error("SanctionUser: failed to generate sanction ID", "err", err)
+		return nil, errors.New("failed to generate sanction ID")
+	}
+
+	sanction := &models.Sanction{
+		ID:            u1.String(),
+		UserID:        target.ID,
+		UserName:      target.Nickname,
+		Type:          sanctionType,
+		Reason:        reason,
+		ModeratorID:   moderator.ID,
+		ModeratorName: moderator.Nickname,
+		CreatedAt:     now,
+		ExpiresAt:     expiresAt,
+	}
+	if err := s.repo.CreateSanction(ctx, sanction); err != nil {
+		utils.Logger(ctx).Error("SanctionUser: failed to create sanction", "err", err)
+		return nil, errors.New("failed to create sanction")
+	}
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     models.AuditSanctionCreated,
+		TargetType: "user",
+		TargetID:   target.ID,
+		Metadata: map[string]interface{}{
+			"sanction_id": sanction.ID,
+			"nickname":    target.Nickname,
+			"type":        sanction.Type,
+			"reason":      sanction.Reason,
+			"expires_at":  sanction.ExpiresAt,
+		},
+	})
+
+	if sanctionType == models.SanctionMute {
+		s.notify(target.Nickname, "muted", sanction)
+		return sanction, nil
+	}
+
+	if err := s.sessionService.RevokeUserSessions(ctx, target.ID); err != nil {
+		utils.Logger(ctx).Warn("SanctionUser: sanction created but sessions of user were not revoked", "sanction_id", sanction.ID, "target_id", target.ID, "err", err)
+	}
+	s.hub.DisconnectUser(target.Nickname, BanMessage(sanction))
+	return sanction, nil
+}
+
+// RevokeSanction lifts a sanction before it runs out
+func (s *sanctionService) RevokeSanction(ctx context.Context, moderator *models.User, sanctionID string) (*models.Sanction, error) {
+	if !moderator.Can(models.PermSanctionUsers) {
+		return nil, ErrModerationForbidden
+	}
+
+	if err := s.repo.RevokeSanction(ctx, sanctionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSanctionNotFound
+		}
+		utils.Logger(ctx).Error("RevokeSanction: failed to revoke sanction", "sanction_id", sanctionID, "err", err)
+		return nil, errors.New("failed to revoke sanction")
+	}
+
+	sanction, err := s.repo.GetSanctionByID(ctx, sanctionID)
+	if err != nil {
+		utils.Logger(ctx).Error("RevokeSanction: failed to reload sanction", "sanction_id", sanctionID, "err", err)
+		return nil, errors.New("failed to fetch sanction")
+	}
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     models.AuditSanctionRevoked,
+		TargetType: "user",
+		TargetID:   sanction.UserID,
+		Metadata: map[string]interface{}{
+			"sanction_id": sanction.ID,
+			"nickname":    sanction.UserName,
+			"type":        sanction.Type,
+		},
+	})
+	if sanction.Type == models.SanctionMute {
+		s.notify(sanction.UserName, "unmuted", sanction)
+	}
+	return sanction, nil
+}
+
+// ListSanctions lists sanctions, optionally of one user given by nickname or
+// email
+func (s *sanctionService) ListSanctions(ctx context.Context, identifier string, filter models.SanctionFilter) ([]models.Sanction, error) {
+	if identifier = strings.TrimSpace(identifier); identifier != "" {
+		user, err := s.userRepo.GetUserByEmailorName(ctx, identifier, identifier)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		if err != nil {
+			utils.Logger(ctx).Error("ListSanctions: failed to retrieve user", "user", identifier, "err", err)
+			return nil, errors.New("failed to retrieve user")
+		}
+		filter.UserID = user.ID
+	}
+
+	sanctions, err := s.repo.ListSanctions(ctx, filter)
+	if err != nil {
+		utils.Logger(ctx).Error("ListSanctions: failed to fetch sanctions", "err", err)
+		return nil, errors.New("failed to fetch sanctions")
+	}
+	return sanctions, nil
+}
+
+// ActiveBan returns the ban or suspension currently keeping the user out, or
+// nil when there is none
+func (s *sanctionService) ActiveBan(ctx context.Context, userID string) (*models.Sanction, error) {
+	sanction, err := s.repo.GetActiveSanction(ctx, userID, models.SanctionBan, models.SanctionSuspension)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("ActiveBan: failed to check sanctions of user", "user_id", userID, "err", err)
+		return nil, errors.New("failed to check sanctions")
+	}
+	return sanction, nil
+}
+
+// notify tells an online user about a change to their chat permissions
+func (s *sanctionService) notify(username, eventType string, sanction *models.Sanction) {
+	messageBytes, err := json.Marshal(map[string]interface{}{
+		"type":       eventType,
+		"from":       "system",
+		"to":         username,
+		"content":    sanction.Reason,
+		"expires_at": sanction.ExpiresAt,
+		"timestamp":  time.Now(),
+	})
+	if err != nil {
+		slog.Error("notify: failed to marshal message", "type", eventType, "err", err)
+		return
+	}
+	s.hub.SendToUser(username, messageBytes)
+}
+
+// BanMessage describes a ban or suspension to the user it applies to
+func BanMessage(sanction *models.Sanction) string {
+	if sanction.ExpiresAt == nil {
+		return "Account banned: " + sanction.Reason
+	}
+	return "Account suspended until " + sanction.ExpiresAt.Format(time.RFC1123) + ": " + sanction.Reason
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"html"
+	"log/slog"
+	"real-time-forum/models"
+	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrSearchUnavailable = errors.New("search is unavailable")
+	ErrEmptySearchQuery  = invalid("search query must contain a letter or digit")
+	ErrInvalidSearchType = invalid("invalid result type")
+)
+
+type searchService struct {
+	repo    repositories.SearchRepository
+	enabled bool
+}
+
+func NewSearchService(repo repositories.SearchRepository) SearchService {
+	service := &searchService{repo: repo}
+
+	// Create or backfill the full-text index before serving queries
+	if err := repo.EnsureIndex(context.Background()); err != nil {
+		slog.Error("NewSearchService: search disabled, could not prepare the index", "err", err)
+		return service
+	}
+	service.enabled = true
+	return service
+}
+
+func (s *searchService) Search(ctx context.Context, q models.SearchQuery) ([]models.SearchResult, error) {
+	if !s.enabled {
+		return nil, ErrSearchUnavailable
+	}
+
+	match := buildMatchExpression(q.Text)
+	if match == "" {
+		utils.Logger(ctx).Warn("Search: empty search query")
+		return nil, ErrEmptySearchQuery
+	}
+
+	if len(q.Types) == 0 {
+		q.Types = []string{models.SearchTypePost, models.SearchTypeComment, models.SearchTypeUser}
+	}
+	for _, t := range q.Types {
+		if t != models.SearchTypePost && t != models.SearchTypeComment && t != models.SearchTypeUser {
+			utils.Logger(ctx).Warn("Search: invalid result type", "type", t)
+			return nil, ErrInvalidSearchType
+		}
+	}
+
+	results, err := s.repo.Search(ctx, match, q)
+	if err != nil {
+		utils.Logger(ctx).Error("Search: failed to search", "err", err)
+		return nil, errors.New("failed to search")
+	}
+
+	for i := range results {
+		results[i].Snippet = highlightSnippet(results[i].Snippet)
+	}
+	return results, nil
+}
+
+// buildMatchExpression turns free user input into a safe FTS5 query: every
+// word is quoted so operators and column filters typed by the user are matched
+// literally, and each word is treated as a prefix.
+func buildMatchExpression(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		terms = append(terms, `"`+w+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// highlightSnippet escapes the snippet text and turns the match markers into
+// <mark> tags so clients can render it as HTML.
+func highlightSnippet(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, repositories.SnippetMatchStart, "<mark>")
+	return strings.ReplaceAll(escaped, repositories.SnippetMatchEnd, "</mark>")
+}
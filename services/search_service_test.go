@@ -0,0 +1,31 @@
+package services
+
+import (
+	"real-time-forum/repositories"
+	"testing"
+)
+
+func TestBuildMatchExpression(t *testing.T) {
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"tomato soup", `"tomato"* "soup"*`},
+		{`title:secret OR "x`, `"title"* "secret"* "OR"* "x"*`},
+		{"  café-au-lait ", `"café"* "au"* "lait"*`},
+		{"*** ---", ""},
+	}
+	for _, c := range cases {
+		if got := buildMatchExpression(c.text); got != c.want {
+			t.Errorf("buildMatchExpression(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	snippet := "<b>" + repositories.SnippetMatchStart + "tomato" + repositories.SnippetMatchEnd + "</b> & soup"
+	want := "&lt;b&gt;<mark>tomato</mark>&lt;/b&gt; &amp; soup"
+	if got := highlightSnippet(snippet); got != want {
+		t.Errorf("highlightSnippet = %q, want %q", got, want)
+	}
+}
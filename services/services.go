@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"io"
+	"real-time-forum/models"
+	"time"
+)
+
+// The interfaces below are what handlers, middleware and other services
+// depend on, so that any of them can be replaced by a fake in tests. Each is
+// implemented by the unexported type its constructor returns.
+
+type AuditService interface {
+	Record(ctx context.Context, event models.AuditEvent)
+	ListEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+type AuthService interface {
+	Register(ctx context.Context, user *models.User) error
+	LoginUser(ctx context.Context, input *models.User) (*models.User, error)
+}
+
+type UserService interface {
+	GetUserBySessionID(ctx context.Context, sessionID string) (*models.User, error)
+	GetAllUsers(ctx context.Context) ([]models.User, error)
+	SetUserRole(ctx context.Context, identifier, role string) (*models.User, error)
+	BootstrapAdmin(ctx context.Context, identifier string) error
+}
+
+type SessionService interface {
+	GenerateSession(ctx context.Context, user *models.User) (models.Session, error)
+	ExpireSession(ctx context.Context, userID string) error
+	RevokeUserSessions(ctx context.Context, userID string) error
+	CleanupExpiredSessions(ctx context.Context) error
+	ValidateSession(ctx context.Context, sessionID string) error
+}
+
+type PostService interface {
+	CreatePost(ctx context.Context, user *models.User, post *models.Post, categoryIDs []string) error
+	GetAllPosts(ctx context.Context) ([]models.Post, error)
+	GetPostByID(ctx context.Context, postID string) (*models.Post, error)
+	GetPostsByCategory(ctx context.Context, categoryID string) ([]models.Post, error)
+	GetUserPosts(ctx context.Context, userID string) ([]models.Post, error)
+	GetSubscribedPosts(ctx context.Context, userID string) ([]models.Post, error)
+}
+
+type CommentsService interface {
+	CreateComment(ctx context.Context, comment *models.Comment) error
+	GetPostComments(ctx context.Context, postID string) ([]models.Comment, error)
+}
+
+type CategoriesService interface {
+	GetAllCategories(ctx context.Context) ([]models.Category, error)
+	GetCategoriesForUser(ctx context.Context, userID string) ([]models.Category, error)
+	GetCategoryForUser(ctx context.Context, categoryID, userID string) (*models.Category, error)
+	GetSubscribedCategories(ctx context.Context, userID string) ([]models.Category, error)
+	GetAllCategoriesWithArchived(ctx context.Context) ([]models.Category, error)
+	Subscribe(ctx context.Context, userID, categoryID string) error
+	Unsubscribe(ctx context.Context, userID, categoryID string) error
+	ValidateCategoryIDs(ctx context.Context, categoryIDs []string) error
+	CreateCategory(ctx context.Context, category *models.Category) error
+	UpdateCategory(ctx context.Context, categoryID, name, description string, setDescription bool) (*models.Category, error)
+	SetCategoryArchived(ctx context.Context, categoryID string, archived bool) (*models.Category, error)
+	ReorderCategories(ctx context.Context, categoryIDs []string) error
+}
+
+type ChatService interface {
+	// ProcessMessage saves and delivers a chat frame read from a client
+	ProcessMessage(msg *models.Message)
+	SendError(username, code, content string)
+	IsBlocked(ctx context.Context, user1, user2 string) (bool, error)
+	BlockUser(ctx context.Context, user *models.User, nickname string) error
+	UnblockUser(ctx context.Context, user *models.User, nickname string) error
+	GetBlockedUsers(ctx context.Context, userID string) ([]models.BlockedUser, error)
+	GetChatHistoryWithPagination(ctx context.Context, user1, user2 string, limit, offset int) ([]models.Message, error)
+}
+
+type FeedService interface {
+	Subscribe(client *models.Client, topic string) error
+	Unsubscribe(client *models.Client, topic string) error
+	PostCreated(post *models.Post, categoryIDs []string)
+	CommentCreated(comment *models.Comment)
+}
+
+type SearchService interface {
+	Search(ctx context.Context, q models.SearchQuery) ([]models.SearchResult, error)
+}
+
+type MediaService interface {
+	SaveImage(ctx context.Context, r io.Reader) (key string, created bool, err error)
+	RemoveImage(ctx context.Context, key string)
+	MediaPath(key string) (string, bool)
+}
+
+type MentionService interface {
+	Resolve(ctx context.Context, author *models.User, text string) []models.Mention
+	Record(ctx context.Context, author *models.User, contentType, contentID, postID, commentID, text string, mentions []models.Mention, notify bool)
+	MentionsFor(ctx context.Context, contentType string, contentIDs []string) map[string][]models.Mention
+}
+
+type ContentFilterService interface {
+	Screen(ctx context.Context, content *FilterContent) (FilterResult, error)
+	Flag(ctx context.Context, result FilterResult, contentType, contentID string)
+}
+
+type ModerationService interface {
+	ReportContent(ctx context.Context, reporter *models.User, contentType, contentID, reason string) (*models.Report, error)
+	FlagContent(ctx context.Context, contentType, contentID, reason string) (*models.Report, error)
+	ListReports(ctx context.Context, filter models.ReportFilter) ([]models.Report, error)
+	GetReport(ctx context.Context, reportID string) (*models.Report, error)
+	ClaimReport(ctx context.Context, moderator *models.User, reportID string) (*models.Report, error)
+	ResolveReport(ctx context.Context, moderator *models.User, reportID, action, note string) (*models.Report, error)
+}
+
+type SanctionService interface {
+	SanctionUser(ctx context.Context, moderator *models.User, identifier, sanctionType string, duration time.Duration, reason string) (*models.Sanction, error)
+	RevokeSanction(ctx context.Context, moderator *models.User, sanctionID string) (*models.Sanction, error)
+	ListSanctions(ctx context.Context, identifier string, filter models.SanctionFilter) ([]models.Sanction, error)
+	ActiveBan(ctx context.Context, userID string) (*models.Sanction, error)
+}
+
+type NotificationService interface {
+	CommentCreated(ctx context.Context, comment *models.Comment)
+	PostCreated(ctx context.Context, author *models.User, post *models.Post, categoryIDs []string)
+	Mentioned(ctx context.Context, author *models.User, mentions []models.Mention, postID, commentID, content string)
+	ListNotifications(ctx context.Context, userID string, unreadOnly bool, limit, offset int) ([]models.Notification, int, error)
+	MarkRead(ctx context.Context, userID string, ids []string) (int64, int, error)
+}
+
+type PushService interface {
+	PublicKey() (string, error)
+	Subscribe(ctx context.Context, userID string, sub *models.PushSubscription) error
+	Unsubscribe(ctx context.Context, userID, endpoint string) error
+	NotifyOffline(nickname string, message models.PushMessage)
+}
@@ -3,32 +3,35 @@ package services
 import (
 	"context"
 	"errors"
-	"log"
 	"real-time-forum/models"
 	repositories "real-time-forum/repositories"
+	"real-time-forum/utils"
 	"time"
 
 	"github.com/gofrs/uuid"
 )
 
-type SessionService struct {
-	repo repositories.SessionRepository
+type sessionService struct {
+	repo         repositories.SessionRepository
+	auditService AuditService
+	ttl          time.Duration
 }
 
-func NewSessionService(repo repositories.SessionRepository) *SessionService {
-	return &SessionService{repo: repo}
+// NewSessionService returns a service whose sessions last ttl
+func NewSessionService(repo repositories.SessionRepository, auditService AuditService, ttl time.Duration) SessionService {
+	return &sessionService{repo: repo, auditService: auditService, ttl: ttl}
 }
 
-func (s *SessionService) GenerateSession(ctx context.Context, user *models.User) (models.Session, error) {
+func (s *sessionService) GenerateSession(ctx context.Context, user *models.User) (models.Session, error) {
 
 	u1, err := uuid.NewV4()
 	if err != nil {
-		log.Printf("GenerateSession: failed to generate session ID: %v", err)
+		utils.Logger(ctx).Error("GenerateSession: failed to generate session ID", "err", err)
 		return models.Session{}, errors.New("failed to generate session ID")
 	}
 
 	createdAt := time.Now()
-	expiresAt := time.Now().Add(time.Hour)
+	expiresAt := createdAt.Add(s.ttl)
 
 	session := models.Session{
 		ID:        u1.String(),
@@ -38,36 +41,67 @@ func (s *SessionService) GenerateSession(ctx context.Context, user *models.User)
 	}
 
 	if err := s.repo.CreateSession(ctx, session); err != nil {
-		log.Printf("GenerateSession: failed to save session: %v", err)
+		utils.Logger(ctx).Error("GenerateSession: failed to save session", "err", err)
 		return models.Session{}, errors.New("failed to save session")
 	}
 
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     models.AuditLogin,
+		ActorID:    user.ID,
+		ActorName:  user.Nickname,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
 	return session, nil
 }
 
-func (s *SessionService) ExpireSession(ctx context.Context, UserID string) error {
+func (s *sessionService) ExpireSession(ctx context.Context, UserID string) error {
 	err := s.repo.DeleteSession(ctx, UserID)
 	if err != nil {
-		log.Printf("ExpireSession: failed to expire session for user %s: %v", UserID, err)
+		utils.Logger(ctx).Error("ExpireSession: failed to expire session for user", "user_id", UserID, "err", err)
 		return errors.New("failed to expire session")
 	}
 
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     models.AuditLogout,
+		TargetType: "user",
+		TargetID:   UserID,
+	})
 	return nil
 }
 
-func (s *SessionService) CleanupExpiredSessions(ctx context.Context) error {
+// RevokeUserSessions logs the user out everywhere, e.g. after a ban
+func (s *sessionService) RevokeUserSessions(ctx context.Context, userID string) error {
+	n, err := s.repo.DeleteUserSessions(ctx, userID)
+	if err != nil {
+		utils.Logger(ctx).Error("RevokeUserSessions: failed to revoke sessions of user", "user_id", userID, "err", err)
+		return errors.New("failed to revoke sessions")
+	}
+	utils.Logger(ctx).Info("RevokeUserSessions: revoked sessions", "count", n, "user_id", userID)
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     models.AuditSessionsRevoked,
+		TargetType: "user",
+		TargetID:   userID,
+		Metadata: map[string]interface{}{
+			"sessions": n,
+		},
+	})
+	return nil
+}
+
+func (s *sessionService) CleanupExpiredSessions(ctx context.Context) error {
 	err := s.repo.CleanupExpiredSessions(ctx)
 	if err != nil {
-		log.Printf("CleanupExpiredSessions: failed to cleanup expired sessions: %v", err)
+		utils.Logger(ctx).Error("CleanupExpiredSessions: failed to cleanup expired sessions", "err", err)
 		return errors.New("failed to cleanup expired sessions")
 	}
 	return nil
 }
 
-func (s *SessionService) ValidateSession(ctx context.Context, sessionID string) error {
+func (s *sessionService) ValidateSession(ctx context.Context, sessionID string) error {
 	err := s.repo.CheckSession(ctx, sessionID)
 	if err != nil {
-		log.Printf("ValidateSession: failed to retrieve session %s: %v", sessionID, err)
+		utils.Logger(ctx).Error("ValidateSession: failed to retrieve session", "err", err)
 		return errors.New("failed to retrieve session")
 	}
 	return nil
@@ -4,37 +4,146 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
 	"real-time-forum/models"
 	repo "real-time-forum/repositories"
+	"real-time-forum/utils"
+	"strings"
 )
 
-type UserService struct {
-	repo repo.UserRepository
+var (
+	ErrUserNotFound = notFound("user not found")
+	ErrInvalidRole  = invalid("role must be user, moderator or admin")
+	ErrLastAdmin    = conflict("cannot remove the last admin")
+
+	// ErrInvalidSession means the session is unknown or has expired
+	ErrInvalidSession = errors.New("invalid or expired session")
+)
+
+type userService struct {
+	repo         repo.UserRepository
+	auditService AuditService
+	hub          *models.Hub
 }
 
-func NewUserService(r repo.UserRepository) *UserService {
-	return &UserService{repo: r}
+func NewUserService(r repo.UserRepository, auditService AuditService, hub *models.Hub) UserService {
+	return &userService{repo: r, auditService: auditService, hub: hub}
 }
 
-func (s *UserService) GetUserBySessionID(ctx context.Context, sessionID string) (*models.User, error) {
+func (s *userService) GetUserBySessionID(ctx context.Context, sessionID string) (*models.User, error) {
 	user, err := s.repo.GetUserBySessionID(ctx, sessionID)
 	if errors.Is(err, sql.ErrNoRows) {
-		log.Printf("GetUserBySessionID: user with session ID %s not found", sessionID)
-		return nil, errors.New("user not found")
+		utils.Logger(ctx).Debug("GetUserBySessionID: no user for the session")
+		return nil, ErrInvalidSession
 	}
 	if err != nil {
-		log.Printf("GetUserBySessionID: failed to retrieve user %s: %v", sessionID, err)
+		utils.Logger(ctx).Error("GetUserBySessionID: failed to retrieve user", "err", err)
 		return nil, errors.New("failed to retrieve user")
 	}
 	return user, nil
 }
 
-func (s *UserService) GetAllUsers(ctx context.Context) ([]models.User, error) {
+func (s *userService) GetAllUsers(ctx context.Context) ([]models.User, error) {
 	users, err := s.repo.GetAllUsers(ctx)
 	if err != nil {
-		log.Printf("AllUsers: internal server error: %v", err)
+		utils.Logger(ctx).Error("AllUsers: internal server error", "err", err)
 		return nil, errors.New("internal server error")
 	}
 	return users, nil
 }
+
+// SetUserRole changes the role of the user with the given nickname or email.
+// The last remaining admin cannot be demoted.
+func (s *userService) SetUserRole(ctx context.Context, identifier, role string) (*models.User, error) {
+	if !models.ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	user, err := s.findUser(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.SetUserRole(ctx, user.ID, role)
+	if errors.Is(err, repo.ErrLastAdmin) {
+		return nil, ErrLastAdmin
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("SetUserRole: failed to set role of user", "user_id", user.ID, "err", err)
+		return nil, errors.New("failed to update role")
+	}
+
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     models.AuditRoleChanged,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Metadata: map[string]interface{}{
+			"nickname": user.Nickname,
+			"old_role": user.Role,
+			"new_role": role,
+		},
+	})
+
+	// Connected clients keep the role they had when they connected
+	s.hub.SetUserRole(user.Nickname, role)
+
+	user.Role = role
+	user.Password = ""
+	return user, nil
+}
+
+// BootstrapAdmin promotes the given user to admin when no admin exists yet.
+// Once an admin exists it does nothing, so it is safe to run on every start.
+func (s *userService) BootstrapAdmin(ctx context.Context, identifier string) error {
+	admins, err := s.repo.CountUsersWithRole(ctx, models.RoleAdmin)
+	if err != nil {
+		utils.Logger(ctx).Error("BootstrapAdmin: failed to count admins", "err", err)
+		return errors.New("failed to count admins")
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	if strings.TrimSpace(identifier) == "" {
+		utils.Logger(ctx).Warn("BootstrapAdmin: no admin exists, set FORUM_BOOTSTRAP_ADMIN to the nickname or email of a registered user")
+		return nil
+	}
+
+	user, err := s.findUser(ctx, identifier)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SetUserRole(ctx, user.ID, models.RoleAdmin); err != nil {
+		utils.Logger(ctx).Error("BootstrapAdmin: failed to promote user", "user_id", user.ID, "err", err)
+		return errors.New("failed to promote user")
+	}
+	utils.Logger(ctx).Info("BootstrapAdmin: promoted user to admin", "user", user)
+	s.auditService.Record(ctx, models.AuditEvent{
+		Action:     models.AuditRoleChanged,
+		ActorName:  "system",
+		TargetType: "user",
+		TargetID:   user.ID,
+		Metadata: map[string]interface{}{
+			"nickname":  user.Nickname,
+			"old_role":  user.Role,
+			"new_role":  models.RoleAdmin,
+			"bootstrap": true,
+		},
+	})
+	return nil
+}
+
+func (s *userService) findUser(ctx context.Context, identifier string) (*models.User, error) {
+	identifier = strings.TrimSpace(identifier)
+	user, err := s.repo.GetUserByEmailorName(ctx, identifier, identifier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).Error("findUser: failed to retrieve user", "user", identifier, "err", err)
+		return nil, errors.New("failed to retrieve user")
+	}
+	return user, nil
+}
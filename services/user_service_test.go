@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"real-time-forum/database"
+	"real-time-forum/database/sqlite"
+	"real-time-forum/models"
+	"real-time-forum/repositories"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestRepos migrates a fresh SQLite database in a temp dir
+func openTestRepos(t *testing.T) *repositories.Repositories {
+	t.Helper()
+	ctx := context.Background()
+
+	db, err := sqlite.Open(ctx, sqlite.Options{
+		Path:        filepath.Join(t.TempDir(), "forum.db"),
+		BusyTimeout: time.Second,
+		ReadConns:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := database.NewMigrator(db.Write, database.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return repositories.NewSQLiteRepositories(db)
+}
+
+func createUsers(t *testing.T, repos *repositories.Repositories, nicknames ...string) {
+	t.Helper()
+	for _, nickname := range nicknames {
+		user := &models.User{ID: "id-" + nickname, Nickname: nickname, Email: nickname + "@example.com", Password: "hash"}
+		if err := repos.Users.CreateUser(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// startHub runs the hub until the test ends. Services that install hub hooks
+// must be created before.
+func startHub(t *testing.T, hub *models.Hub) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func userRole(t *testing.T, repos *repositories.Repositories, nickname string) string {
+	t.Helper()
+	user, err := repos.Users.GetUserByEmailorName(context.Background(), "", nickname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.Role
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	ctx := context.Background()
+	repos := openTestRepos(t)
+	users := NewUserService(repos.Users, NewAuditService(repos.Audit), models.NewHub())
+	createUsers(t, repos, "alice", "bobby")
+
+	if err := users.BootstrapAdmin(ctx, ""); err != nil {
+		t.Fatalf("BootstrapAdmin without a user = %v", err)
+	}
+	if err := users.BootstrapAdmin(ctx, "nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("BootstrapAdmin(unknown) = %v, want ErrUserNotFound", err)
+	}
+	if err := users.BootstrapAdmin(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if role := userRole(t, repos, "alice"); role != models.RoleAdmin {
+		t.Errorf("alice role = %q after bootstrap", role)
+	}
+
+	// Once there is an admin, bootstrapping again changes nothing
+	if err := users.BootstrapAdmin(ctx, "bobby"); err != nil {
+		t.Fatal(err)
+	}
+	if role := userRole(t, repos, "bobby"); role != models.RoleUser {
+		t.Errorf("bobby role = %q, want the second bootstrap ignored", role)
+	}
+}
+
+func TestSetUserRole(t *testing.T) {
+	ctx := context.Background()
+	repos := openTestRepos(t)
+	hub := models.NewHub()
+	users := NewUserService(repos.Users, NewAuditService(repos.Audit), hub)
+	startHub(t, hub)
+	bobby := &models.Client{Username: "bobby", Role: models.RoleUser, Send: make(chan []byte, 16)}
+	hub.Register <- bobby
+	createUsers(t, repos, "alice", "bobby")
+	if err := users.BootstrapAdmin(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name       string
+		identifier string
+		role       string
+		want       error
+	}{
+		{"invalid role", "bobby", "owner", ErrInvalidRole},
+		{"unknown user", "nobody", models.RoleModerator, ErrUserNotFound},
+		{"last admin", "alice", models.RoleUser, ErrLastAdmin},
+		{"promote", "bobby", models.RoleModerator, nil},
+	}
+	for _, c := range cases {
+		if _, err := users.SetUserRole(ctx, c.identifier, c.role); !errors.Is(err, c.want) {
+			t.Errorf("%s: SetUserRole(%s, %s) = %v, want %v", c.name, c.identifier, c.role, err, c.want)
+		}
+	}
+	if role := userRole(t, repos, "bobby"); role != models.RoleModerator {
+		t.Errorf("bobby role = %q", role)
+	}
+
+	// bobby's open connection gets moderator broadcasts without reconnecting
+	hub.SendToRole(models.RoleModerator, []byte("report"))
+	timeout := time.After(time.Second)
+	for received := false; !received; {
+		select {
+		case message := <-bobby.Send:
+			received = string(message) == "report"
+		case <-timeout:
+			t.Fatal("promoted client got no moderator broadcast")
+		}
+	}
+}
+
+func TestSetUserRoleKeepsOneAdmin(t *testing.T) {
+	ctx := context.Background()
+	repos := openTestRepos(t)
+	hub := models.NewHub()
+	users := NewUserService(repos.Users, NewAuditService(repos.Audit), hub)
+	startHub(t, hub)
+	createUsers(t, repos, "alice", "bobby")
+	for _, nickname := range []string{"alice", "bobby"} {
+		if err := repos.Users.SetUserRole(ctx, "id-"+nickname, models.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both admins demoted at once: exactly one of them must stay
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, nickname := range []string{"alice", "bobby"} {
+		wg.Add(1)
+		go func(i int, nickname string) {
+			defer wg.Done()
+			_, errs[i] = users.SetUserRole(ctx, nickname, models.RoleUser)
+		}(i, nickname)
+	}
+	wg.Wait()
+
+	if n, err := repos.Users.CountUsersWithRole(ctx, models.RoleAdmin); err != nil || n != 1 {
+		t.Errorf("admins left = %d, %v (errors %v)", n, err, errs)
+	}
+	if (errs[0] == nil) == (errs[1] == nil) {
+		t.Errorf("errors = %v, want exactly one ErrLastAdmin", errs)
+	}
+}
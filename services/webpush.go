@@ -0,0 +1,288 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"real-time-forum/models"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPushSubscriptionGone is returned by a PushSender when the push service
+// says the subscription no longer exists, e.g. the user revoked permission
+var ErrPushSubscriptionGone = errors.New("push subscription gone")
+
+// PushSender delivers one payload to one subscription. WebPushSender talks to
+// real push services; tests can swap in anything else.
+type PushSender interface {
+	Send(ctx context.Context, sub models.PushSubscription, payload []byte) error
+}
+
+const (
+	// pushRecordSize is the aes128gcm record size. Payloads must fit in a
+	// single record, which leaves a little under 4 KB for them.
+	pushRecordSize = 4096
+	pushTTL        = 24 * time.Hour
+	vapidLifetime  = 12 * time.Hour
+)
+
+// WebPushSender sends messages with the Web Push protocol (RFC 8030),
+// encrypting payloads with aes128gcm (RFC 8291) and identifying the server
+// with VAPID (RFC 8292)
+type WebPushSender struct {
+	publicKey string
+	key       *ecdsa.PrivateKey
+	subject   string
+	client    *http.Client
+}
+
+// NewWebPushSender checks the VAPID keys and returns a sender using them.
+// subject is a mailto: or https: URL push services can use to reach the
+// operator.
+func NewWebPushSender(keys models.VAPIDKeys, subject string, client *http.Client) (*WebPushSender, error) {
+	key, err := parseVAPIDPrivateKey(keys)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: refusePrivateAddress}).DialContext,
+				TLSHandshakeTimeout: 5 * time.Second,
+				ForceAttemptHTTP2:   true,
+			},
+		}
+	}
+	return &WebPushSender{publicKey: keys.PublicKey, key: key, subject: subject, client: client}, nil
+}
+
+// refusePrivateAddress stops the push client from connecting to loopback,
+// private or link-local addresses, e.g. when a push service host name
+// resolves to one
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	ip := addrPort.Addr().Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("refusing to connect to non-public address %s", ip)
+	}
+	return nil
+}
+
+// GenerateVAPIDKeys creates a new P-256 key pair for VAPID
+func GenerateVAPIDKeys() (*models.VAPIDKeys, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &models.VAPIDKeys{
+		PublicKey:  base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		PrivateKey: base64.RawURLEncoding.EncodeToString(key.Bytes()),
+	}, nil
+}
+
+func (s *WebPushSender) Send(ctx context.Context, sub models.PushSubscription, payload []byte) error {
+	body, err := encryptPushPayload(sub.P256dh, sub.Auth, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := s.vapidAuthorization(sub.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(pushTTL.Seconds())))
+	req.Header.Set("Urgency", "normal")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrPushSubscriptionGone
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("push service answered %s", resp.Status)
+	}
+	return nil
+}
+
+// vapidAuthorization builds the Authorization header: a short-lived ES256 JWT
+// for the push service's origin plus our public key
+func (s *WebPushSender) vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(vapidLifetime).Unix(),
+		"sub": s.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	r, sigS, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sigS.FillBytes(signature[32:])
+
+	return "vapid t=" + unsigned + "." + base64.RawURLEncoding.EncodeToString(signature) + ", k=" + s.publicKey, nil
+}
+
+// encryptPushPayload encrypts payload for the browser holding the private
+// half of p256dh, following RFC 8291. The result is one aes128gcm record
+// whose header carries the salt and our one-off public key.
+func encryptPushPayload(p256dh, authSecret string, payload []byte) ([]byte, error) {
+	uaPublicBytes, err := decodePushKey(p256dh)
+	if err != nil {
+		return nil, err
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := decodePushKey(authSecret)
+	if err != nil {
+		return nil, err
+	}
+	// Push services take at most 4 KB: our 86-byte header, the payload, its
+	// delimiter and the 16-byte GCM tag
+	if 86+len(payload)+1+16 > pushRecordSize {
+		return nil, errors.New("push payload too large")
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	asPublicBytes := asPrivate.PublicKey().Bytes()
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	cek, nonce, err := pushContentKeys(sharedSecret, auth, salt, uaPublicBytes, asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single, and so last, record: the payload followed by the 0x02
+	// delimiter and no padding
+	plaintext := append(append([]byte{}, payload...), 0x02)
+
+	header := make([]byte, 0, 21+len(asPublicBytes))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(asPublicBytes)))
+	header = append(header, asPublicBytes...)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// pushContentKeys derives the content encryption key and nonce shared by the
+// server and the browser (RFC 8291 section 3.4)
+func pushContentKeys(sharedSecret, auth, salt, uaPublic, asPublic []byte) ([]byte, []byte, error) {
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, auth, keyInfo, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cek, nonce, nil
+}
+
+func parseVAPIDPrivateKey(keys models.VAPIDKeys) (*ecdsa.PrivateKey, error) {
+	raw, err := decodePushKey(keys.PrivateKey)
+	if err != nil {
+		return nil, errors.New("invalid VAPID private key")
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, errors.New("invalid VAPID private key")
+	}
+	public := key.PublicKey().Bytes()
+	if base64.RawURLEncoding.EncodeToString(public) != strings.TrimRight(keys.PublicKey, "=") {
+		return nil, errors.New("VAPID public key does not match the private key")
+	}
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}, nil
+}
+
+// decodePushKey decodes the base64url keys browsers hand out, with or
+// without padding
+func decodePushKey(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"real-time-forum/models"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testBrowser plays the browser side of a subscription: it owns the private
+// key the payload is encrypted for
+type testBrowser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newTestBrowser(t *testing.T) *testBrowser {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	if _, err := rand.Read(auth); err != nil {
+		t.Fatal(err)
+	}
+	return &testBrowser{key: key, auth: auth}
+}
+
+func (b *testBrowser) subscription(endpoint string) models.PushSubscription {
+	return models.PushSubscription{
+		ID:       "sub-1",
+		Endpoint: endpoint,
+		P256dh:   base64.RawURLEncoding.EncodeToString(b.key.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(b.auth),
+	}
+}
+
+// decrypt undoes encryptPushPayload the way a browser would
+func (b *testBrowser) decrypt(body []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("body too short")
+	}
+	salt := body[:16]
+	recordSize := binary.BigEndian.Uint32(body[16:20])
+	idLen := int(body[20])
+	if len(body) < 21+idLen {
+		return nil, errors.New("header too short")
+	}
+	asPublicBytes := body[21 : 21+idLen]
+	ciphertext := body[21+idLen:]
+	if uint32(len(ciphertext)) > recordSize {
+		return nil, errors.New("record larger than the record size")
+	}
+
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := b.key.ECDH(asPublic)
+	if err != nil {
+		return nil, err
+	}
+	cek, nonce, err := pushContentKeys(sharedSecret, b.auth, salt, b.key.PublicKey().Bytes(), asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		return nil, errors.New("missing last record delimiter")
+	}
+	return plaintext[:len(plaintext)-1], nil
+}
+
+// verifyVAPID checks the Authorization header against the server's public
+// key and returns the JWT claims
+func verifyVAPID(header, publicKey string) (map[string]interface{}, error) {
+	token, key, ok := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+	if !ok || !strings.HasPrefix(header, "vapid t=") {
+		return nil, errors.New("malformed vapid header")
+	}
+	if key != publicKey {
+		return nil, errors.New("unexpected public key")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed JWT")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return nil, errors.New("malformed signature")
+	}
+	keyBytes, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil || len(keyBytes) != 65 {
+		return nil, errors.New("malformed public key")
+	}
+
+	pub := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(keyBytes[1:33]),
+		Y:     new(big.Int).SetBytes(keyBytes[33:]),
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		return nil, errors.New("bad signature")
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func newTestSender(t *testing.T, server *httptest.Server) (*WebPushSender, *models.VAPIDKeys) {
+	t.Helper()
+	keys, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := NewWebPushSender(*keys, "mailto:ops@example.com", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sender, keys
+}
+
+func TestWebPushSenderDeliversEncryptedPayload(t *testing.T) {
+	browser := newTestBrowser(t)
+	received := make(chan []byte, 1)
+	var keys *models.VAPIDKeys
+
+	// A stand-in push service: it checks what a real one would and decrypts
+	// the payload as the browser would
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Encoding"); got != "aes128gcm" {
+			t.Errorf("Content-Encoding = %q, want aes128gcm", got)
+		}
+		if r.Header.Get("TTL") == "" {
+			t.Errorf("TTL header missing")
+		}
+
+		claims, err := verifyVAPID(r.Header.Get("Authorization"), keys.PublicKey)
+		if err != nil {
+			t.Errorf("VAPID check failed: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if claims["aud"] != "https://"+r.Host {
+			t.Errorf("aud = %v, want https://%s", claims["aud"], r.Host)
+		}
+		if claims["sub"] != "mailto:ops@example.com" {
+			t.Errorf("sub = %v", claims["sub"])
+		}
+		if exp, _ := claims["exp"].(float64); time.Unix(int64(exp), 0).Before(time.Now()) || time.Unix(int64(exp), 0).After(time.Now().Add(24*time.Hour)) {
+			t.Errorf("exp = %v out of range", claims["exp"])
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		plaintext, err := browser.decrypt(body)
+		if err != nil {
+			t.Errorf("decrypt: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- plaintext
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sender, senderKeys := newTestSender(t, server)
+	keys = senderKeys
+
+	payload, _ := json.Marshal(models.PushMessage{Title: "New message from alice", Body: "hi there", URL: "/"})
+	if err := sender.Send(context.Background(), browser.subscription(server.URL+"/push/abc"), payload); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if string(got) != string(payload) {
+			t.Errorf("decrypted payload = %s, want %s", got, payload)
+		}
+	default:
+		t.Fatal("push service received nothing")
+	}
+}
+
+func TestWebPushSenderReportsGoneSubscriptions(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusGone} {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		sender, _ := newTestSender(t, server)
+
+		err := sender.Send(context.Background(), newTestBrowser(t).subscription(server.URL), []byte(`{}`))
+		if !errors.Is(err, ErrPushSubscriptionGone) {
+			t.Errorf("status %d: err = %v, want ErrPushSubscriptionGone", status, err)
+		}
+		server.Close()
+	}
+}
+
+func TestWebPushSenderReportsServiceErrors(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	sender, _ := newTestSender(t, server)
+
+	err := sender.Send(context.Background(), newTestBrowser(t).subscription(server.URL), []byte(`{}`))
+	if err == nil || errors.Is(err, ErrPushSubscriptionGone) {
+		t.Errorf("err = %v, want a plain delivery error", err)
+	}
+}
+
+func TestEncryptPushPayloadRejectsOversizedPayloads(t *testing.T) {
+	sub := newTestBrowser(t).subscription("https://push.example.com/x")
+	if _, err := encryptPushPayload(sub.P256dh, sub.Auth, make([]byte, 4000)); err == nil {
+		t.Error("4000-byte payload was accepted")
+	}
+	if _, err := encryptPushPayload(sub.P256dh, sub.Auth, make([]byte, 3993)); err != nil {
+		t.Errorf("3993-byte payload was refused: %v", err)
+	}
+}
+
+func TestNewWebPushSenderRejectsMismatchedKeys(t *testing.T) {
+	a, _ := GenerateVAPIDKeys()
+	b, _ := GenerateVAPIDKeys()
+	if _, err := NewWebPushSender(models.VAPIDKeys{PublicKey: a.PublicKey, PrivateKey: b.PrivateKey}, "mailto:x@example.com", nil); err == nil {
+		t.Error("mismatched VAPID keys were accepted")
+	}
+}
+
+func TestValidPushSubscription(t *testing.T) {
+	valid := newTestBrowser(t).subscription("https://fcm.googleapis.com/fcm/send/abc")
+	tests := []struct {
+		name string
+		edit func(*models.PushSubscription)
+		want bool
+	}{
+		{"valid", func(s *models.PushSubscription) {}, true},
+		{"padded keys", func(s *models.PushSubscription) { s.Auth += "==" }, true},
+		{"plain http", func(s *models.PushSubscription) { s.Endpoint = "http://push.example.com/x" }, false},
+		{"credentials in URL", func(s *models.PushSubscription) { s.Endpoint = "https://u:p@push.example.com/x" }, false},
+		{"not a URL", func(s *models.PushSubscription) { s.Endpoint = "push" }, false},
+		{"short auth", func(s *models.PushSubscription) { s.Auth = "AAAA" }, false},
+		{"bad key", func(s *models.PushSubscription) { s.P256dh = base64.RawURLEncoding.EncodeToString(make([]byte, 65)) }, false},
+		{"long endpoint", func(s *models.PushSubscription) { s.Endpoint += strings.Repeat("a", 2048) }, false},
+	}
+	for _, tt := range tests {
+		sub := valid
+		tt.edit(&sub)
+		if got := validPushSubscription(&sub); got != tt.want {
+			t.Errorf("%s: validPushSubscription = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidPushSubscriptionHosts(t *testing.T) {
+	endpoints := map[string]bool{
+		"https://fcm.googleapis.com/fcm/send/abc":                true,
+		"https://updates.push.services.mozilla.com/wpush/v2/abc": true,
+		"https://web.push.apple.com:443/abc":                     true,
+		"https://wns2-par02p.notify.windows.com/w/?token=abc":    true,
+		"https://push.example.com/x":                             false,
+		"https://fcm.googleapis.com.example.com/x":               false,
+		"https://fcm.googleapis.com:8443/x":                      false,
+		"https://127.0.0.1/x":                                    false,
+		"https://[::1]/x":                                        false,
+		"https://169.254.169.254/latest/meta-data":               false,
+	}
+	sub := newTestBrowser(t).subscription("")
+	for endpoint, want := range endpoints {
+		sub.Endpoint = endpoint
+		if got := validPushSubscription(&sub); got != want {
+			t.Errorf("validPushSubscription(%s) = %v, want %v", endpoint, got, want)
+		}
+	}
+}
+
+func TestRefusePrivateAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"142.250.74.10:443", true},
+		{"[2a00:1450:4007::5f]:443", true},
+		{"127.0.0.1:443", false},
+		{"[::1]:443", false},
+		{"10.0.0.8:443", false},
+		{"172.16.4.1:443", false},
+		{"192.168.1.1:443", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:443", false},
+		{"[fd00::1]:443", false},
+		{"[::ffff:127.0.0.1]:443", false},
+		{"0.0.0.0:443", false},
+	}
+	for _, tt := range tests {
+		if got := refusePrivateAddress("tcp", tt.address, nil) == nil; got != tt.want {
+			t.Errorf("refusePrivateAddress(%s) allowed = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestWebPushSenderRefusesLocalEndpoints(t *testing.T) {
+	var reached atomic.Bool
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached.Store(true)
+	}))
+	defer server.Close()
+
+	keys, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The default client, not the test server's
+	sender, err := NewWebPushSender(*keys, "mailto:ops@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sender.Send(context.Background(), newTestBrowser(t).subscription(server.URL+"/push"), []byte("hi"))
+	if err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Errorf("Send to %s = %v, want the connection refused", server.URL, err)
+	}
+	if reached.Load() {
+		t.Error("the local server was reached")
+	}
+}
+
+// TestPushEncryptionMatchesRFC8291 replays the example from RFC 8291
+// appendix A, whose keys and salt are fixed
+func TestPushEncryptionMatchesRFC8291(t *testing.T) {
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	asPrivate, err := ecdh.P256().NewPrivateKey(decode("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPublicBytes := decode("BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4")
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := decode("BTBZMqHH6r4Tts7J_aSIgg")
+	salt := decode("DGv6ra1nlYgDCS1FRnbzlw")
+
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asPublicBytes := asPrivate.PublicKey().Bytes()
+	cek, nonce, err := pushContentKeys(sharedSecret, auth, salt, uaPublicBytes, asPublicBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+
+	body := append([]byte{}, salt...)
+	body = binary.BigEndian.AppendUint32(body, pushRecordSize)
+	body = append(body, byte(len(asPublicBytes)))
+	body = append(body, asPublicBytes...)
+	body = gcm.Seal(body, nonce, []byte("When I grow up, I want to be a watermelon\x02"), nil)
+
+	want := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if got := base64.RawURLEncoding.EncodeToString(body); got != want {
+		t.Errorf("encrypted message = %s, want %s", got, want)
+	}
+}
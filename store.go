@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"real-time-forum/config"
+	"real-time-forum/database"
+	"real-time-forum/database/postgres"
+	"real-time-forum/database/sqlite"
+	"real-time-forum/repositories"
+)
+
+// Store is the database the server runs on, whichever backend it is
+type Store struct {
+	Dialect database.Dialect
+	// DB is the handle migrations and maintenance commands run on
+	DB           *sql.DB
+	Repositories *repositories.Repositories
+
+	stats func() map[string]sql.DBStats
+	close func() error
+}
+
+// OpenStore opens the backend chosen by db-driver
+func OpenStore(ctx context.Context, cfg config.DatabaseConfig) (*Store, error) {
+	switch cfg.Driver {
+	case "sqlite":
+		db, err := sqlite.Open(ctx, sqlite.Options{
+			Path:        cfg.Path,
+			BusyTimeout: cfg.BusyTimeout,
+			ReadConns:   cfg.ReadConns,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Store{
+			Dialect:      database.SQLite,
+			DB:           db.Write,
+			Repositories: repositories.NewSQLiteRepositories(db),
+			stats:        db.Stats,
+			close:        db.Close,
+		}, nil
+
+	case "postgres":
+		db, err := postgres.Open(ctx, postgres.Options{
+			DSN:      cfg.DSN,
+			MaxConns: cfg.ReadConns + 1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Store{
+			Dialect:      database.Postgres,
+			DB:           db,
+			Repositories: repositories.NewPostgresRepositories(db),
+			stats:        func() map[string]sql.DBStats { return map[string]sql.DBStats{"postgres": db.Stats()} },
+			close:        db.Close,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+}
+
+// Stats reports the connection pool statistics by pool name
+func (s *Store) Stats() map[string]sql.DBStats {
+	return s.stats()
+}
+
+func (s *Store) Close() error {
+	return s.close()
+}
@@ -0,0 +1,35 @@
+/**
+ * Service Worker
+ * Shows Web Push messages sent while the forum is closed or the user is away
+ */
+self.addEventListener('push', (event) => {
+    let message = { title: 'Real-Time Forum', body: '' };
+    if (event.data) {
+        try {
+            message = event.data.json();
+        } catch (error) {
+            message.body = event.data.text();
+        }
+    }
+
+    event.waitUntil(self.registration.showNotification(message.title, {
+        body: message.body,
+        tag: message.tag,
+        data: { url: message.url || '/' }
+    }));
+});
+
+self.addEventListener('notificationclick', (event) => {
+    event.notification.close();
+    const url = (event.notification.data && event.notification.data.url) || '/';
+
+    // Focus an open forum tab when there is one
+    event.waitUntil(clients.matchAll({ type: 'window', includeUncontrolled: true }).then((windows) => {
+        for (const client of windows) {
+            if ('focus' in client) {
+                return client.focus();
+            }
+        }
+        return clients.openWindow(url);
+    }));
+});
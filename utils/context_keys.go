@@ -9,10 +9,28 @@ type contextKey string
 
 const ContextUser contextKey = "user"
 
+const ContextClientIP contextKey = "client_ip"
+
+const ContextRequestID contextKey = "request_id"
+
 func GetUserFromContext(ctx context.Context) *models.User {
 	user, ok := ctx.Value(ContextUser).(*models.User)
 	if !ok {
 		return nil
 	}
 	return user
-}
\ No newline at end of file
+}
+
+// GetClientIPFromContext returns the address of the client that made the
+// request, or "" outside of a request
+func GetClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(ContextClientIP).(string)
+	return ip
+}
+
+// GetRequestIDFromContext returns the ID the logging middleware gave the
+// request, or "" outside of a request
+func GetRequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ContextRequestID).(string)
+	return id
+}
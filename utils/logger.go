@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const ContextLogger contextKey = "logger"
+
+// SensitiveKeys are log attributes whose values are masked: credentials and
+// the text users write, which can hold anything
+var SensitiveKeys = map[string]bool{
+	"password":   true,
+	"session_id": true,
+	"token":      true,
+	"email":      true,
+	"title":      true,
+	"content":    true,
+	"comment":    true,
+	"query":      true,
+}
+
+// NewLogger returns a logger writing to w, as JSON or as logfmt style text,
+// that drops records below level and masks SensitiveKeys
+func NewLogger(w io.Writer, level slog.Level, json bool) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: scrub}
+	if json {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+func scrub(groups []string, a slog.Attr) slog.Attr {
+	if SensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "[redacted]")
+	}
+	return a
+}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ContextLogger, logger)
+}
+
+// Logger returns the logger of the request ctx belongs to, which tags every
+// record with the request ID, or the default logger outside of a request
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ContextLogger).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}